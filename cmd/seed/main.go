@@ -7,6 +7,7 @@ import (
     "log"
     "os"
     "path/filepath"
+    "time"
 
     "go.mongodb.org/mongo-driver/bson"
     "go.mongodb.org/mongo-driver/mongo"
@@ -52,6 +53,7 @@ func seedFile(ctx context.Context, db *mongo.Database, path string) error {
 
     c := db.Collection(coll)
     for _, d := range docs {
+        parseTimes(d)
         // idempotent upsert by external_id if present, else by _id if provided
         filter := bson.M{}
         if v, ok := d["external_id"]; ok { filter["external_id"] = v } else if v, ok := d["id"]; ok { filter["id"] = v }
@@ -62,6 +64,18 @@ func seedFile(ctx context.Context, db *mongo.Database, path string) error {
     return nil
 }
 
+// parseTimes stores RFC 3339 strings as BSON dates so they decode into time.Time.
+func parseTimes(d map[string]any) {
+    for k, v := range d {
+        switch x := v.(type) {
+        case string:
+            if t, err := time.Parse(time.RFC3339, x); err == nil { d[k] = t }
+        case map[string]any:
+            parseTimes(x)
+        }
+    }
+}
+
 func indexOf(s string, ch byte) int {
     for i := 0; i < len(s); i++ { if s[i] == ch { return i } }
     return -1
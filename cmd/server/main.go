@@ -3,13 +3,28 @@ package main
 import (
     "context"
     "log"
+    "net/http"
+    "os/exec"
+    "time"
 
     "github.com/gin-contrib/cors"
     "github.com/gin-gonic/gin"
+    "real_deal/internal/auth"
+    "real_deal/internal/compliance"
     "real_deal/internal/config"
     "real_deal/internal/db"
+    "real_deal/internal/domaincheck"
+    "real_deal/internal/feed"
     "real_deal/internal/handlers"
+    "real_deal/internal/mail"
+    "real_deal/internal/oauth"
+    "real_deal/internal/ratelimit"
+    "real_deal/internal/repo"
+    "real_deal/internal/screen"
+    "real_deal/internal/search"
+    "real_deal/internal/session"
     "real_deal/internal/storage"
+    "real_deal/internal/transcode"
 )
 
 func main() {
@@ -17,50 +32,130 @@ func main() {
 
     mongo, err := db.NewMongo(cfg)
     if err != nil { log.Fatalf("mongo error: %v", err) }
+    rdb := db.NewRedis(cfg)
+    if err := rdb.Ping(context.Background()); err != nil { log.Fatalf("redis error: %v", err) }
 
     r := gin.Default()
+    if err := r.SetTrustedProxies(cfg.TrustedProxies); err != nil { log.Fatalf("trusted proxies: %v", err) }
     r.Use(cors.New(cors.Config{
         AllowOrigins:     []string{"http://localhost:3000", "http://127.0.0.1:3000"},
-        AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS"},
+        AllowMethods:     []string{"GET", "POST", "PUT", "PATCH", "DELETE", "OPTIONS"},
         AllowHeaders:     []string{"Content-Type", "Authorization", "X-Requested-With", "Accept", "Origin"},
         ExposeHeaders:    []string{"Set-Cookie"},
         AllowCredentials: true,
     }))
 
     // Services
-    st, err := storage.NewMinio(cfg)
-    if err != nil { log.Fatalf("minio error: %v", err) }
+    st, err := storage.New(cfg)
+    if err != nil { log.Fatalf("storage error: %v", err) }
     if err := st.EnsureBucket(context.Background()); err != nil { log.Fatalf("bucket error: %v", err) }
+    if served, ok := st.(storage.Served); ok {
+        objects := handlers.NewObject(served)
+        r.GET(storage.ObjectPath+"/*key", objects.Get)
+        r.HEAD(storage.ObjectPath+"/*key", objects.Get)
+        r.PUT(storage.ObjectPath+"/*key", objects.Put)
+    }
+    mailer, err := mail.New(cfg)
+    if err != nil { log.Fatalf("mail error: %v", err) }
+    repos := repo.NewMongo(mongo.DB)
+    if err := repo.EnsureIndexes(context.Background(), mongo.DB); err != nil { log.Fatalf("index error: %v", err) }
+    links := auth.NewMagicLinks(repos.LoginTokens, cfg.LoginTokenTTL)
+    sessions := session.NewRedis(rdb.Client, cfg.SessionTTL)
+    authH := handlers.NewAuth(repos.Users, links, mailer, sessions, ratelimit.NewRedis(rdb.Client), cfg)
+    flows := auth.NewFlows(repos.OAuthStates, 10*time.Minute)
+    oauthH := handlers.NewOAuth(repos, oauth.FromConfig(cfg, http.DefaultClient), flows, sessions, cfg)
+
+    tokenizer, err := search.NewTokenizer(cfg.SearchTokenizer)
+    if err != nil { log.Fatalf("search error: %v", err) }
+    idx := search.NewIndex(mongo.DB, tokenizer)
+    if err := idx.EnsureIndexes(context.Background()); err != nil { log.Fatalf("search index error: %v", err) }
+
+    keywords, err := screen.LoadKeywords(cfg.ScreenBlocklists)
+    if err != nil { log.Fatalf("screen error: %v", err) }
+    screeners := screen.Chain{keywords}
+    if cfg.ScreenHookURL != "" { screeners = append(screeners, screen.NewHook(cfg.ScreenHookURL, cfg.ScreenHookTimeout)) }
+    screening := &screen.Policy{Screener: screeners, RejectAt: cfg.ScreenRejectScore, ReviewAt: cfg.ScreenReviewScore}
+    queue := handlers.NewModerationQueue(repos, screening)
 
     // Routes
-    r.GET("/api/explore", handlers.NewExplore(mongo.DB).Get)
-    r.GET("/api/projects", handlers.NewProject(mongo.DB).List)
-    r.GET("/api/jobs", handlers.NewJob(mongo.DB).List)
-    r.GET("/api/companies/:id", handlers.NewCompany(mongo.DB).Get)
-    r.GET("/api/products", handlers.NewProduct(mongo.DB).List)
-    r.GET("/api/posts", handlers.NewPost(mongo.DB).List)
-    r.GET("/api/company-verifications/:companyId", handlers.NewVerification(mongo.DB).Company)
-    r.GET("/api/job-compliance/:jobId", handlers.NewCompliance(mongo.DB).Job)
-    r.GET("/api/content-moderation/:id", handlers.NewModeration(mongo.DB).Content)
-    r.GET("/api/inbox", handlers.NewInbox(mongo.DB).List)
-    r.GET("/api/notification-preferences", handlers.NewPreference(mongo.DB).Get)
-    r.GET("/api/usage", handlers.NewUsage(mongo.DB).Get)
-    r.GET("/api/quota", handlers.NewQuota(mongo.DB).Get)
-    r.GET("/api/capacity-packs", handlers.NewCapacity(mongo.DB).List)
-    r.GET("/api/job-slots", handlers.NewJobSlot(mongo.DB).Get)
-    r.GET("/api/charges", handlers.NewCharge(mongo.DB).List)
-    r.GET("/api/investors", handlers.NewInvestor(mongo.DB).List)
-    r.GET("/api/pitch/:id", handlers.NewPitch(mongo.DB).Get)
-    r.GET("/api/deal-room/:id", handlers.NewDealRoom(mongo.DB).Get)
-    r.GET("/api/media/:id", handlers.NewMedia(mongo.DB, st).Get)
-    r.GET("/api/media-assets", handlers.NewMediaAssets(mongo.DB).List)
-    r.GET("/api/users/:id", handlers.NewUser(mongo.DB).Get)
-    r.POST("/api/login", handlers.NewAuth(mongo.DB).Login)
-    r.GET("/api/me", handlers.NewAuth(mongo.DB).Me)
+    projects, products, posts := handlers.NewProject(repos, idx, queue), handlers.NewProduct(repos, idx, queue), handlers.NewPost(repos, idx, queue)
+    jobs := handlers.NewJob(repos, compliance.Default(), screening, cfg.JobTTL, idx)
+    apps := handlers.NewApplication(repos, st, cfg.ApplicationStages)
+    companies, pitch := handlers.NewCompany(repos, mailer, idx, cfg), handlers.NewPitch(repos)
+    moderation := handlers.NewModeration(queue)
+    if _, err := exec.LookPath(cfg.FFmpegPath); err != nil { log.Printf("transcoding: %v; videos will fail to transcode", err) }
+    transcodes := handlers.NewTranscode(repos, transcode.New(st, transcode.Exec{}, cfg), cfg)
+    uploads := handlers.NewUpload(repos, st, queue, transcodes, cfg)
+    reports := handlers.NewReport(queue, cfg.ReportThreshold)
+    verifs := handlers.NewVerification(repos, st, domaincheck.New(10*time.Second), cfg.VerificationTTL)
+    follows := handlers.NewFollow(repos)
+    api := handlers.API{
+        Auth: authH, OAuth: oauthH, Search: handlers.NewSearch(idx),
+        Feed: handlers.NewFeed(feed.NewService(repos)), Explore: handlers.NewExplore(handlers.RepoExploreSource{Repos: repos}, cfg.ExploreTimeout),
+        Projects: projects, Products: products, Posts: posts, Jobs: jobs, Applications: apps,
+        Companies: companies, Verifications: verifs, Compliance: handlers.NewCompliance(repos),
+        Investors: handlers.NewInvestor(repos), Pitch: pitch, DealRoom: handlers.NewDealRoom(repos),
+        Media: handlers.NewMedia(repos, st, cfg), MediaAssets: handlers.NewMediaAssets(repos), Users: handlers.NewUser(repos),
+        Moderation: moderation, Reports: reports, Follows: follows, Uploads: uploads, Transcodes: transcodes,
+        Inbox: handlers.NewInbox(repos), Preferences: handlers.NewPreference(repos),
+        Usage: handlers.NewUsage(repos), Quota: handlers.NewQuota(repos), Capacity: handlers.NewCapacity(repos),
+        JobSlots: handlers.NewJobSlot(repos), Charges: handlers.NewCharge(repos),
+    }
+    handlers.Mount(r.Group("/api", handlers.Authenticate(repos.Users, sessions)), api.Routes())
+
+    // Background work
+    go every(time.Minute, func(ctx context.Context) {
+        if n, err := jobs.ExpireDue(ctx); err != nil {
+            log.Printf("job expiry error: %v", err)
+        } else if n > 0 {
+            log.Printf("expired %d jobs", n)
+        }
+    })
+    go every(time.Hour, func(ctx context.Context) {
+        if n, err := verifs.ExpireDue(ctx); err != nil {
+            log.Printf("verification expiry error: %v", err)
+        } else if n > 0 {
+            log.Printf("expired %d company verifications", n)
+        }
+    })
+    go every(10*time.Minute, func(ctx context.Context) {
+        if n, err := verifs.CheckPendingDomains(ctx); err != nil {
+            log.Printf("domain check error: %v", err)
+        } else if n > 0 {
+            log.Printf("verified %d company domains", n)
+        }
+    })
+
+    go every(10*time.Minute, func(ctx context.Context) {
+        if n, err := uploads.SweepStale(ctx); err != nil {
+            log.Printf("upload sweep error: %v", err)
+        } else if n > 0 {
+            log.Printf("aborted %d stale uploads", n)
+        }
+    })
+    go every(time.Minute, func(ctx context.Context) {
+        if n, err := transcodes.RunQueued(ctx); err != nil {
+            log.Printf("transcode error: %v", err)
+        } else if n > 0 {
+            log.Printf("ran %d transcode jobs", n)
+        }
+    })
 
     addr := cfg.ServerAddr
     log.Printf("server listening on %s", addr)
     if err := r.Run(addr); err != nil {
         log.Fatalf("server error: %v", err)
     }
-}
\ No newline at end of file
+}
+
+// every runs fn now and then on each tick, giving each run a bounded context.
+func every(d time.Duration, fn func(ctx context.Context)) {
+    t := time.NewTicker(d)
+    defer t.Stop()
+    for {
+        ctx, cancel := context.WithTimeout(context.Background(), d)
+        fn(ctx)
+        cancel()
+        <-t.C
+    }
+}
@@ -0,0 +1,27 @@
+package auth
+
+import (
+    "context"
+    "time"
+
+    "real_deal/internal/model"
+    "real_deal/internal/oauth"
+    "real_deal/internal/repo"
+)
+
+// UserIdentity links an external provider subject to users.id.
+type UserIdentity = model.UserIdentity
+
+// Link attaches the provider identity id to userID. A user holds at most
+// one identity per provider: see repo.Identities.Link for the errors.
+func Link(ctx context.Context, ids repo.Identities, userID string, id *oauth.Identity) error {
+    return ids.Link(ctx, &UserIdentity{
+        UserID:        userID,
+        Provider:      id.Provider,
+        Subject:       id.Subject,
+        Email:         id.Email,
+        EmailVerified: id.EmailVerified,
+        Name:          id.Name,
+        LinkedAt:      time.Now(),
+    })
+}
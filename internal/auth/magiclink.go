@@ -0,0 +1,59 @@
+package auth
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "time"
+
+    "real_deal/internal/model"
+    "real_deal/internal/repo"
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// MagicLinks issues and redeems one-time login tokens. Only the SHA-256 of a
+// token is stored, so leaked login tokens cannot be replayed.
+type MagicLinks struct {
+    Tokens repo.LoginTokens
+    TTL    time.Duration
+    Now    func() time.Time
+}
+
+func NewMagicLinks(tokens repo.LoginTokens, ttl time.Duration) *MagicLinks {
+    return &MagicLinks{Tokens: tokens, TTL: ttl, Now: time.Now}
+}
+
+// Issue creates a fresh token for email. Earlier unused tokens for the same
+// address are discarded so only the latest link works.
+func (m *MagicLinks) Issue(ctx context.Context, email string) (string, error) {
+    tok, err := RandomToken(32)
+    if err != nil { return "", err }
+    now := m.Now()
+    if err := m.Tokens.Issue(ctx, &model.LoginToken{Hash: hashToken(tok), Email: email, CreatedAt: now, ExpiresAt: now.Add(m.TTL)}); err != nil { return "", err }
+    return tok, nil
+}
+
+// Redeem marks the token used and returns the email it was issued for; two
+// concurrent redeems cannot both succeed.
+func (m *MagicLinks) Redeem(ctx context.Context, token string) (string, error) {
+    if token == "" { return "", ErrInvalidToken }
+    email, err := m.Tokens.Redeem(ctx, hashToken(token), m.Now())
+    if errors.Is(err, repo.ErrNotFound) { return "", ErrInvalidToken }
+    return email, err
+}
+
+// RandomToken returns n random bytes encoded as unpadded base64url.
+func RandomToken(n int) (string, error) {
+    b := make([]byte, n)
+    if _, err := rand.Read(b); err != nil { return "", err }
+    return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func hashToken(tok string) string {
+    sum := sha256.Sum256([]byte(tok))
+    return hex.EncodeToString(sum[:])
+}
@@ -0,0 +1,19 @@
+package auth
+
+import (
+    "context"
+    "errors"
+
+    "real_deal/internal/repo"
+)
+
+var ErrSameUser = errors.New("cannot merge a user into itself")
+
+// MergeUsers folds fromID into intoID: everything owned by fromID moves to
+// intoID, missing profile fields are filled in, and fromID can no longer
+// sign in. Callers should revoke fromID's sessions afterwards. A missing
+// user is repo.ErrNotFound.
+func MergeUsers(ctx context.Context, users repo.Users, fromID, intoID string) error {
+    if fromID == intoID { return ErrSameUser }
+    return users.Merge(ctx, fromID, intoID)
+}
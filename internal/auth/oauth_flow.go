@@ -0,0 +1,57 @@
+package auth
+
+import (
+    "context"
+    "crypto/subtle"
+    "errors"
+    "time"
+
+    "real_deal/internal/model"
+    "real_deal/internal/repo"
+)
+
+var ErrInvalidState = errors.New("invalid or expired oauth state")
+
+// Flow is the server-side half of an in-flight OAuth login.
+type Flow = model.OAuthFlow
+
+// Flows keys in-flight logins by the hash of their state parameter.
+type Flows struct {
+    States repo.OAuthStates
+    TTL    time.Duration
+    Now    func() time.Time
+}
+
+func NewFlows(states repo.OAuthStates, ttl time.Duration) *Flows {
+    return &Flows{States: states, TTL: ttl, Now: time.Now}
+}
+
+// Begin stores flow for the browser holding the browser token and returns the
+// state value to send to the provider. Verifier and Nonce are generated here
+// when empty.
+func (f *Flows) Begin(ctx context.Context, flow Flow, browser string) (string, *Flow, error) {
+    if browser == "" { return "", nil, ErrInvalidState }
+    state, err := RandomToken(24)
+    if err != nil { return "", nil, err }
+    if flow.Verifier == "" {
+        if flow.Verifier, err = RandomToken(32); err != nil { return "", nil, err }
+    }
+    if flow.Nonce == "" {
+        if flow.Nonce, err = RandomToken(16); err != nil { return "", nil, err }
+    }
+    flow.Hash, flow.Browser, flow.ExpiresAt = hashToken(state), hashToken(browser), f.Now().Add(f.TTL)
+    if err := f.States.Insert(ctx, &flow); err != nil { return "", nil, err }
+    return state, &flow, nil
+}
+
+// Consume returns and deletes the flow for state, so each state works once.
+// A state presented by any browser but the one that began the flow is
+// ErrInvalidState, and is used up all the same.
+func (f *Flows) Consume(ctx context.Context, provider, state, browser string) (*Flow, error) {
+    if state == "" || browser == "" { return nil, ErrInvalidState }
+    flow, err := f.States.Take(ctx, hashToken(state), provider, f.Now())
+    if errors.Is(err, repo.ErrNotFound) { return nil, ErrInvalidState }
+    if err != nil { return nil, err }
+    if subtle.ConstantTimeCompare([]byte(flow.Browser), []byte(hashToken(browser))) != 1 { return nil, ErrInvalidState }
+    return flow, nil
+}
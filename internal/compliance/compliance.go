@@ -0,0 +1,110 @@
+// Package compliance evaluates job postings against the platform's posting
+// rules. An Engine runs every Rule over a posting and reports what each one
+// found; a hard finding keeps the job from going live.
+package compliance
+
+const (
+    // Hard findings block publication; Warn findings are advice.
+    Hard = "hard"
+    Warn = "warn"
+
+    StatusPassed  = "passed"
+    StatusWarning = "warning"
+    StatusFailed  = "failed"
+)
+
+// Finding is one problem a rule found. Match is the offending text, when
+// there is one.
+type Finding struct {
+    Severity string `json:"severity" bson:"severity"`
+    Field    string `json:"field" bson:"field"`
+    Message  string `json:"message" bson:"message"`
+    Match    string `json:"match,omitempty" bson:"match,omitempty"`
+}
+
+// RuleResult is what one rule found; Status is passed, warning or failed.
+type RuleResult struct {
+    Rule     string    `json:"rule" bson:"rule"`
+    Status   string    `json:"status" bson:"status"`
+    Findings []Finding `json:"findings" bson:"findings"`
+}
+
+// Result is the outcome of every rule; Status is the worst of them.
+type Result struct {
+    Status string       `json:"status" bson:"status"`
+    Rules  []RuleResult `json:"rules" bson:"rules"`
+}
+
+// Blocked reports whether a hard finding keeps the job from going live.
+func (r *Result) Blocked() bool { return r.Status == StatusFailed }
+
+// Messages lists the findings at severity, for summaries and errors.
+func (r *Result) Messages(severity string) []string {
+    var out []string
+    for _, rr := range r.Rules {
+        for _, f := range rr.Findings {
+            if f.Severity == severity { out = append(out, f.Message) }
+        }
+    }
+    return out
+}
+
+// Posting is the text of a job the rules look at.
+type Posting struct {
+    Title       string
+    Description string
+    Location    string
+    Level       string
+    Salary      string
+    Skills      []string
+}
+
+type Rule struct {
+    Name  string
+    Check func(p *Posting) []Finding
+}
+
+type Engine struct {
+    Rules []Rule
+}
+
+// Default returns an engine with the salary, discrimination, location and
+// level rules.
+func Default() *Engine {
+    return &Engine{Rules: []Rule{
+        {"salary", checkSalary},
+        {"discrimination", checkDiscrimination},
+        {"location", checkLocation},
+        {"level", checkLevel},
+    }}
+}
+
+func (e *Engine) Evaluate(p *Posting) *Result {
+    res := &Result{Status: StatusPassed, Rules: []RuleResult{}}
+    for _, r := range e.Rules {
+        res.Add(r.Name, r.Check(p))
+    }
+    return res
+}
+
+// Add records what a rule found, for checks that run outside the engine.
+func (r *Result) Add(rule string, findings []Finding) {
+    rr := RuleResult{Rule: rule, Status: StatusPassed, Findings: findings}
+    if rr.Findings == nil { rr.Findings = []Finding{} }
+    for _, f := range rr.Findings {
+        rr.Status = worse(rr.Status, f.Severity)
+    }
+    r.Status = worse(r.Status, rr.Status)
+    r.Rules = append(r.Rules, rr)
+}
+
+// worse combines a status with a finding severity or another status.
+func worse(status, s string) string {
+    switch {
+    case status == StatusFailed || s == Hard || s == StatusFailed:
+        return StatusFailed
+    case s == Warn || s == StatusWarning:
+        return StatusWarning
+    }
+    return status
+}
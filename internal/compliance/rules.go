@@ -0,0 +1,181 @@
+package compliance
+
+import (
+    "fmt"
+    "regexp"
+    "strconv"
+    "strings"
+)
+
+// salaryRange matches ranges such as "25k-35k/月", "30-50K", "1.5万-2万·14薪"
+// and "$8000 - $12000 per month", after lower-casing.
+var salaryRange = regexp.MustCompile(`^(?:[¥￥$]|rmb|cny|usd)?\s*(\d+(?:\.\d+)?)\s*([kw千万]?)\s*(?:[-~～—–至到]|to)\s*(?:[¥￥$])?\s*(\d+(?:\.\d+)?)\s*([kw千万]?)\s*(?:元|rmb|cny|usd)?\s*(?:(?:/|每|per)\s*(月|年|天|日|小时|时|month|mo|year|yr|day|hour|h))?\s*(?:[·.,，]\s*\d{2}\s*薪)?$`)
+
+var salaryUnits = map[string]float64{"": 1, "k": 1e3, "千": 1e3, "w": 1e4, "万": 1e4}
+
+func checkSalary(p *Posting) []Finding {
+    s := strings.ToLower(strings.TrimSpace(p.Salary))
+    if s == "" { return []Finding{{Severity: Hard, Field: "salary", Message: "salary range is required"}} }
+    m := salaryRange.FindStringSubmatch(s)
+    if m == nil {
+        return []Finding{{Severity: Hard, Field: "salary", Message: "salary must be a range such as 25k-35k/月", Match: p.Salary}}
+    }
+    lo, _ := strconv.ParseFloat(m[1], 64)
+    hi, _ := strconv.ParseFloat(m[3], 64)
+    loUnit, hiUnit := m[2], m[4]
+    // "30-50k": the unit on the upper bound applies to both
+    if loUnit == "" { loUnit = hiUnit }
+    lo, hi = lo*salaryUnits[loUnit], hi*salaryUnits[hiUnit]
+    switch {
+    case lo <= 0:
+        return []Finding{{Severity: Hard, Field: "salary", Message: "salary minimum must be above zero", Match: p.Salary}}
+    case lo > hi:
+        return []Finding{{Severity: Hard, Field: "salary", Message: "salary minimum is above the maximum", Match: p.Salary}}
+    }
+    var out []Finding
+    if hi > 2*lo {
+        out = append(out, Finding{Severity: Warn, Field: "salary", Message: "salary maximum is more than twice the minimum", Match: p.Salary})
+    }
+    if hiUnit == "" && (m[5] == "" || m[5] == "月" || m[5] == "month" || m[5] == "mo") && hi < 1000 {
+        out = append(out, Finding{Severity: Warn, Field: "salary", Message: "monthly salary below 1000; is a unit such as k missing?", Match: p.Salary})
+    }
+    return out
+}
+
+type phrase struct {
+    re       *regexp.Regexp
+    severity string
+    topic    string
+}
+
+func hard(topic, expr string) phrase { return phrase{regexp.MustCompile(`(?i)` + expr), Hard, topic} }
+func warn(topic, expr string) phrase { return phrase{regexp.MustCompile(`(?i)` + expr), Warn, topic} }
+
+// bannedPhrases are requirements on age, gender and marital status, which
+// a posting may not state. The warn entries are wording that often implies
+// one and is worth a second look.
+var bannedPhrases = []phrase{
+    hard("age", `\d{2}\s*(周岁|岁)\s*(以下|以内|以上|之前)`),
+    hard("age", `\d{2}\s*[-~～至到]\s*\d{2}\s*(周岁|岁)`),
+    hard("age", `年龄\s*(要求|限制|限|不超过|不大于|不高于|在|须|需)`),
+    hard("age", `\b(under|below|over|above|no older than|younger than)\s+(the\s+age\s+of\s+\d{2}\b|\d{2}\s*(years?\s+old|y/?o)\b)`),
+    hard("age", `\bage[ds]?\s+(under|below|over|above|no older than|younger than)\s+\d{2}\b`),
+    hard("age", `\baged?\s+\d{2}\s*(-|to)\s*\d{2}\b`),
+    hard("age", `\bage\s+(limit|requirement|restriction)s?\b`),
+    warn("age", `年轻|青春活力`),
+    warn("age", `\b(young|youthful|digital native)s?\b`),
+    hard("gender", `(限|仅限|只招|只限|只要)\s*(男|女)`),
+    hard("gender", `(男|女)(性|士|生)?\s*优先`),
+    hard("gender", `性别\s*(要求|限制|[：:]\s*[男女])`),
+    hard("gender", `\b(males?|females?|men|women)\s+(only|preferred)\b`),
+    hard("gender", `\bonly\s+(males?|females?|men|women)\b`),
+    hard("gender", `\bgender\s+(requirement|restriction)s?\b`),
+    hard("marital", `已婚|未婚|已育|未育|婚育`),
+    hard("marital", `\b(must be|only|prefer(red)?)\s+(married|single|unmarried)\b`),
+    hard("marital", `\bmarital\s+status\b`),
+    hard("marital", `\bno\s+(kids|children)\b|\bpregnan`),
+}
+
+// neutral says there is no requirement; it is removed before matching so
+// that "不限男女" does not read as "限男".
+var neutral = strings.NewReplacer(
+    "不限男女", "", "男女不限", "", "性别不限", "", "不限性别", "",
+    "年龄不限", "", "不限年龄", "", "婚否不限", "", "婚育不限", "",
+)
+
+func checkDiscrimination(p *Posting) []Finding {
+    fields := []struct{ name, text string }{
+        {"title", p.Title}, {"description", p.Description}, {"level", p.Level}, {"skills", strings.Join(p.Skills, " ")},
+    }
+    var out []Finding
+    for _, f := range fields {
+        text := neutral.Replace(f.text)
+        for _, ph := range bannedPhrases {
+            if m := ph.re.FindString(text); m != "" {
+                msg := fmt.Sprintf("%s requirements are not allowed", ph.topic)
+                if ph.severity == Warn { msg = fmt.Sprintf("wording may imply a requirement on %s", ph.topic) }
+                out = append(out, Finding{Severity: ph.severity, Field: f.name, Message: msg, Match: m})
+            }
+        }
+    }
+    return out
+}
+
+var noLocation = map[string]bool{"-": true, "n/a": true, "na": true, "tbd": true, "待定": true, "不限": true}
+
+func checkLocation(p *Posting) []Finding {
+    if l := strings.ToLower(strings.TrimSpace(p.Location)); l == "" || noLocation[l] {
+        return []Finding{{Severity: Hard, Field: "location", Message: "location is required; use \"远程\" or \"Remote\" for remote roles"}}
+    }
+    return nil
+}
+
+// levelSpan is the seniority a level term covers, from 0 (intern) to 5
+// (expert). Management titles are a separate track and are not compared.
+type levelSpan struct{ lo, hi int }
+
+type levelTerm struct {
+    term string
+    span levelSpan
+    re   *regexp.Regexp
+}
+
+// lvl matches Chinese terms anywhere and English ones as whole words.
+func lvl(term string, lo, hi int) levelTerm {
+    t := levelTerm{term: term, span: levelSpan{lo, hi}}
+    if regexp.MustCompile(`^[a-z-]+$`).MatchString(term) { t.re = regexp.MustCompile(`(?i)\b` + regexp.QuoteMeta(term) + `\b`) }
+    return t
+}
+
+// levelTerms are ordered so longer terms match before the terms they
+// contain ("中高级" before "高级").
+var levelTerms = []levelTerm{
+    lvl("中高级", 2, 4), lvl("实习", 0, 0), lvl("初级", 1, 1), lvl("中级", 2, 2),
+    lvl("高级", 4, 4), lvl("资深", 4, 5), lvl("专家", 5, 5),
+    lvl("mid-senior", 2, 4), lvl("internship", 0, 0), lvl("intern", 0, 0), lvl("entry", 1, 1), lvl("junior", 1, 1), lvl("jr", 1, 1),
+    lvl("mid", 2, 2), lvl("intermediate", 2, 2), lvl("senior", 4, 4), lvl("sr", 4, 4),
+    lvl("staff", 5, 5), lvl("principal", 5, 5), lvl("expert", 5, 5),
+}
+
+var managementLevels = []string{"主管", "经理", "总监", "负责人", "lead", "manager", "director", "head"}
+
+// levelIn returns the first level term in s and its span.
+func levelIn(s string) (string, levelSpan, bool) {
+    for _, t := range levelTerms {
+        if t.matches(s) { return t.term, t.span, true }
+    }
+    return "", levelSpan{}, false
+}
+
+// topLevelIn returns the most senior level term in s, so that "Senior Staff
+// Engineer" reads as staff. Of equally senior terms the first listed wins.
+func topLevelIn(s string) (string, levelSpan, bool) {
+    var top *levelTerm
+    for i, t := range levelTerms {
+        if t.matches(s) && (top == nil || t.span.hi > top.span.hi) { top = &levelTerms[i] }
+    }
+    if top == nil { return "", levelSpan{}, false }
+    return top.term, top.span, true
+}
+
+func (t levelTerm) matches(s string) bool {
+    if t.re != nil { return t.re.MatchString(s) }
+    return strings.Contains(s, t.term)
+}
+
+func checkLevel(p *Posting) []Finding {
+    level := strings.TrimSpace(p.Level)
+    if level == "" { return []Finding{{Severity: Warn, Field: "level", Message: "level is not set"}} }
+    _, span, ok := levelIn(level)
+    if !ok {
+        l := strings.ToLower(level)
+        for _, m := range managementLevels {
+            if strings.Contains(l, m) { return nil }
+        }
+        return []Finding{{Severity: Warn, Field: "level", Message: "unrecognised level; use one such as 初级, 中级, 高级 or junior, senior", Match: level}}
+    }
+    if term, t, ok := topLevelIn(p.Title); ok && (t.hi < span.lo || t.lo > span.hi) {
+        return []Finding{{Severity: Hard, Field: "title", Message: fmt.Sprintf("title says %q but level is %q", term, level), Match: term}}
+    }
+    return nil
+}
@@ -0,0 +1,166 @@
+package compliance
+
+import (
+    "encoding/json"
+    "os"
+    "strings"
+    "testing"
+)
+
+func posting() *Posting {
+    return &Posting{Title: "后端工程师（Golang）", Description: "负责交易系统。", Location: "北京", Level: "高级", Salary: "35k-45k/月", Skills: []string{"Go", "MySQL"}}
+}
+
+// check runs one rule over a posting changed by edit and compares the
+// severities and matches it reports; want lists "severity:match" pairs.
+func check(t *testing.T, rule func(*Posting) []Finding, edit func(*Posting), want ...string) {
+    t.Helper()
+    p := posting()
+    edit(p)
+    var got []string
+    for _, f := range rule(p) { got = append(got, f.Severity+":"+f.Match) }
+    if strings.Join(got, "|") != strings.Join(want, "|") { t.Errorf("%+v: got %q, want %q", p, got, want) }
+}
+
+func TestSalary(t *testing.T) {
+    cases := []struct {
+        salary string
+        want   []string
+    }{
+        {"25k-35k/月", nil},
+        {"30-50K", nil},
+        {"1.5万-2万·14薪", nil},
+        {"1.5w-2.5w", nil},
+        {"$8000 - $12000 per month", nil},
+        {"RMB 300-500 / day", nil},
+        {"200-300/天", nil},
+        {"80-120/小时", nil},
+        {"40万-60万/年", nil},
+        {"20千-30千", nil},
+        {"15k~20k", nil},
+        {"15k 至 20k", nil},
+        {"15k to 20k", nil},
+        {"", []string{"hard:"}},
+        {"面议", []string{"hard:面议"}},
+        {"25k+", []string{"hard:25k+"}},
+        {"0-20k", []string{"hard:0-20k"}},
+        {"35k-25k", []string{"hard:35k-25k"}},
+        // Each bound keeps its own unit: 30000-50000 and 20000-30000.
+        {"30k-5w", nil},
+        {"2万-30k", nil},
+        {"10k-30k", []string{"warn:10k-30k"}},
+        {"20-30", []string{"warn:20-30"}},
+        {"200-900/月", []string{"warn:200-900/月", "warn:200-900/月"}},
+        {"200-300/day", nil},
+        {"500-800/year", nil},
+    }
+    for _, tc := range cases {
+        check(t, checkSalary, func(p *Posting) { p.Salary = tc.salary }, tc.want...)
+    }
+}
+
+func TestDiscrimination(t *testing.T) {
+    cases := []struct {
+        description string
+        want        []string
+    }{
+        {"不限男女，欢迎应届生。", nil},
+        {"男女不限；性别不限；年龄不限；婚育不限。", nil},
+        {"Open to all genders and ages.", nil},
+        {"Our senior engineers average 10 years of experience.", nil},
+        {"5 years of Go; 30 services in production.", nil},
+        {"限男性，35岁以下。", []string{"hard:35岁以下", "hard:限男"}},
+        {"年龄要求：25-35岁", []string{"hard:25-35岁", "hard:年龄要求"}},
+        {"女士优先", []string{"hard:女士优先"}},
+        {"性别：女", []string{"hard:性别：女"}},
+        {"已婚已育优先", []string{"hard:已婚"}},
+        {"团队年轻有活力", []string{"warn:年轻"}},
+        {"Candidates under 35 years old only.", []string{"hard:under 35 years old"}},
+        {"Applicants must be no older than the age of 40.", []string{"hard:no older than the age of 40"}},
+        {"Aged 22 to 30.", []string{"hard:Aged 22 to 30"}},
+        {"Ages under 30 preferred.", []string{"hard:Ages under 30"}},
+        {"Strict age limit applies.", []string{"hard:age limit"}},
+        {"Males only.", []string{"hard:Males only"}},
+        {"We prefer married candidates.", []string{"hard:prefer married"}},
+        {"Applicants must be single.", []string{"hard:must be single"}},
+        {"Please state marital status.", []string{"hard:marital status"}},
+        {"A young, energetic team.", []string{"warn:young"}},
+    }
+    for _, tc := range cases {
+        check(t, checkDiscrimination, func(p *Posting) { p.Description = tc.description }, tc.want...)
+    }
+    // Every text field is checked.
+    check(t, checkDiscrimination, func(p *Posting) { p.Title = "前端工程师（限女）" }, "hard:限女")
+    check(t, checkDiscrimination, func(p *Posting) { p.Skills = []string{"Go", "Digital natives"} }, "warn:Digital natives")
+}
+
+func TestLocation(t *testing.T) {
+    for loc, ok := range map[string]bool{"上海": true, "远程": true, "Remote": true, "": false, " ": false, "TBD": false, "待定": false, "不限": false, "n/a": false} {
+        var want []string
+        if !ok { want = []string{"hard:"} }
+        check(t, checkLocation, func(p *Posting) { p.Location = loc }, want...)
+    }
+}
+
+func TestLevel(t *testing.T) {
+    cases := []struct {
+        title, level string
+        want         []string
+    }{
+        {"后端工程师", "高级", nil},
+        {"高级后端工程师", "高级", nil},
+        {"资深后端工程师", "高级", nil},
+        {"中高级全栈工程师", "中级", nil},
+        {"中高级全栈工程师", "高级", nil},
+        {"Senior Backend Engineer", "mid-senior", nil},
+        {"Senior Staff Engineer", "专家", nil},
+        {"Senior Staff Engineer", "senior", []string{"hard:staff"}},
+        {"Junior Developer", "高级", []string{"hard:junior"}},
+        {"实习生", "中级", []string{"hard:实习"}},
+        {"高级工程师", "初级", []string{"hard:高级"}},
+        {"Internal Tools Engineer", "junior", nil},
+        {"Midfield Analyst", "senior", nil},
+        {"Engineering Manager", "技术经理", nil},
+        {"Team Lead", "Tech Lead", nil},
+        {"后端工程师", "", []string{"warn:"}},
+        {"后端工程师", "L5", []string{"warn:L5"}},
+    }
+    for _, tc := range cases {
+        check(t, checkLevel, func(p *Posting) { p.Title, p.Level = tc.title, tc.level }, tc.want...)
+    }
+}
+
+func TestEvaluate(t *testing.T) {
+    cases := []struct {
+        edit   func(*Posting)
+        status string
+    }{
+        {func(p *Posting) {}, StatusPassed},
+        {func(p *Posting) { p.Salary = "10k-30k" }, StatusWarning},
+        {func(p *Posting) { p.Salary = "10k-30k"; p.Location = "" }, StatusFailed},
+        {func(p *Posting) { p.Description = "不限男女" }, StatusPassed},
+    }
+    for _, tc := range cases {
+        p := posting()
+        tc.edit(p)
+        res := Default().Evaluate(p)
+        if res.Status != tc.status || res.Blocked() != (tc.status == StatusFailed) || len(res.Rules) != 4 { t.Errorf("%+v: %+v, want %s", p, res, tc.status) }
+    }
+}
+
+// The seed jobs are the examples in the docs and the demo data; they must
+// all go live without warnings.
+func TestSeedJobs(t *testing.T) {
+    b, err := os.ReadFile("../../seeds/jobs.json")
+    if err != nil { t.Fatal(err) }
+    var jobs []struct {
+        ID, Title, Description, Location, Level, Salary string
+        Skills                                          []string
+    }
+    if err := json.Unmarshal(b, &jobs); err != nil { t.Fatal(err) }
+    if len(jobs) == 0 { t.Fatal("no seed jobs") }
+    for _, j := range jobs {
+        res := Default().Evaluate(&Posting{Title: j.Title, Description: j.Description, Location: j.Location, Level: j.Level, Salary: j.Salary, Skills: j.Skills})
+        if res.Status != StatusPassed { t.Errorf("%s: %s %v %v", j.ID, res.Status, res.Messages(Hard), res.Messages(Warn)) }
+    }
+}
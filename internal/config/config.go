@@ -3,6 +3,8 @@ package config
 import (
     "log"
     "os"
+    "strconv"
+    "strings"
     "time"
 
     "github.com/joho/godotenv"
@@ -17,9 +19,64 @@ type Config struct {
     MinioAccessKey  string
     MinioSecretKey  string
     MinioBucket     string
+    StorageDriver   string
+    StorageDir      string
+    StorageSecret   string
     ServerAddr      string
+    PublicAPIURL    string
+    WebURL          string
+    MailDriver      string
+    MailDir         string
+    MailFrom        string
+    LoginTokenTTL   time.Duration
+    // Login links are limited per address and per client IP within
+    // LoginLimitWindow; 0 turns a limit off.
+    LoginEmailLimit int
+    LoginIPLimit    int
+    LoginLimitWindow time.Duration
+    // TrustedProxies may set X-Forwarded-For; the client IP of anyone else
+    // is the connection's address.
+    TrustedProxies  []string
+    SessionTTL      time.Duration
+    CookieDomain    string
+    CookieSecure    bool
+    OAuth           map[string]OAuthClient
+    JobTTL          time.Duration
+    ApplicationStages []string
+    SearchTokenizer string
+    ExploreTimeout  time.Duration
+    InviteTTL       time.Duration
+    VerificationTTL time.Duration
+    ScreenBlocklists []string
+    ScreenHookURL   string
+    ScreenHookTimeout time.Duration
+    ScreenRejectScore float64
+    ScreenReviewScore float64
+    ReportThreshold int
+    MediaUploadTTL  time.Duration
+    // MediaMaxMB caps an upload per media type (image, video, document).
+    MediaMaxMB      map[string]int
+    MediaPartMB     int
+    MediaUploadStale time.Duration
+    MediaMaxUploads int
+    FFmpegPath      string
+    FFprobePath     string
+    TranscodeDir    string
+    TranscodeTimeout time.Duration
 }
 
+// OAuthClient is one identity provider registration. Issuer is only used by
+// OIDC providers and overrides their well-known issuer URL.
+type OAuthClient struct {
+    ClientID     string
+    ClientSecret string
+    Issuer       string
+}
+
+// oauthProviders are read from OAUTH_<NAME>_CLIENT_ID / _CLIENT_SECRET /
+// _ISSUER; "oidc" is a generic issuer, e.g. a local fake for development.
+var oauthProviders = []string{"google", "github", "linkedin", "apple", "wechat", "oidc"}
+
 func Load() *Config {
     _ = godotenv.Load()
 
@@ -32,7 +89,56 @@ func Load() *Config {
         MinioAccessKey: get("MINIO_ACCESS_KEY", "miniouser"),
         MinioSecretKey: get("MINIO_SECRET_KEY", "miniopass123"),
         MinioBucket:    get("MINIO_BUCKET", "media"),
+        StorageDriver:  get("STORAGE_DRIVER", "minio"),
+        StorageDir:     get("STORAGE_DIR", "data/objects"),
+        StorageSecret:  get("STORAGE_URL_SECRET", ""),
         ServerAddr:     get("SERVER_ADDR", ":8080"),
+        PublicAPIURL:   get("PUBLIC_API_URL", "http://localhost:8080"),
+        WebURL:         get("WEB_URL", "http://localhost:3000"),
+        MailDriver:     get("MAIL_DRIVER", "stdout"),
+        MailDir:        get("MAIL_DIR", "data/mail"),
+        MailFrom:       get("MAIL_FROM", "Real Deal <no-reply@realdeal.local>"),
+        LoginTokenTTL:  getDuration("LOGIN_TOKEN_TTL", 15*time.Minute),
+        LoginEmailLimit: getInt("LOGIN_EMAIL_LIMIT", 5),
+        LoginIPLimit:   getInt("LOGIN_IP_LIMIT", 30),
+        LoginLimitWindow: getDuration("LOGIN_LIMIT_WINDOW", time.Hour),
+        TrustedProxies: getList("TRUSTED_PROXIES", ""),
+        SessionTTL:     getDuration("SESSION_TTL", 7*24*time.Hour),
+        CookieDomain:   get("COOKIE_DOMAIN", "localhost"),
+        CookieSecure:   get("COOKIE_SECURE", "false") == "true",
+        JobTTL:         getDuration("JOB_TTL", 30*24*time.Hour),
+        ApplicationStages: getList("APPLICATION_STAGES", "applied,screening,interview,offer,hired"),
+        SearchTokenizer: get("SEARCH_TOKENIZER", "bigram"),
+        ExploreTimeout:  getDuration("EXPLORE_TIMEOUT", 2*time.Second),
+        InviteTTL:       getDuration("COMPANY_INVITE_TTL", 7*24*time.Hour),
+        VerificationTTL: getDuration("VERIFICATION_TTL", 365*24*time.Hour),
+        ScreenBlocklists: getList("SCREEN_BLOCKLISTS", ""),
+        ScreenHookURL:   get("SCREEN_HOOK_URL", ""),
+        ScreenHookTimeout: getDuration("SCREEN_HOOK_TIMEOUT", 2*time.Second),
+        ScreenRejectScore: getFloat("SCREEN_REJECT_SCORE", 0.9),
+        ScreenReviewScore: getFloat("SCREEN_REVIEW_SCORE", 0.5),
+        ReportThreshold: getInt("REPORT_THRESHOLD", 3),
+        MediaUploadTTL:  getDuration("MEDIA_UPLOAD_TTL", 15*time.Minute),
+        MediaMaxMB: map[string]int{
+            "image":    getInt("MEDIA_MAX_IMAGE_MB", 10),
+            "video":    getInt("MEDIA_MAX_VIDEO_MB", 4096),
+            "document": getInt("MEDIA_MAX_DOCUMENT_MB", 20),
+        },
+        MediaPartMB:     getInt("MEDIA_PART_MB", 16),
+        MediaUploadStale: getDuration("MEDIA_UPLOAD_STALE", 24*time.Hour),
+        MediaMaxUploads: getInt("MEDIA_MAX_UPLOADS", 3),
+        FFmpegPath:      get("FFMPEG_PATH", "ffmpeg"),
+        FFprobePath:     get("FFPROBE_PATH", "ffprobe"),
+        TranscodeDir:    get("TRANSCODE_DIR", ""),
+        TranscodeTimeout: getDuration("TRANSCODE_TIMEOUT", time.Hour),
+    }
+
+    cfg.OAuth = map[string]OAuthClient{}
+    for _, name := range oauthProviders {
+        prefix := "OAUTH_" + strings.ToUpper(name) + "_"
+        id := get(prefix+"CLIENT_ID", "")
+        if id == "" { continue }
+        cfg.OAuth[name] = OAuthClient{ClientID: id, ClientSecret: get(prefix+"CLIENT_SECRET", ""), Issuer: get(prefix+"ISSUER", "")}
     }
 
     return cfg
@@ -46,6 +152,53 @@ func get(key, def string) string {
     return v
 }
 
+func getDuration(key string, def time.Duration) time.Duration {
+    v := os.Getenv(key)
+    if v == "" {
+        return def
+    }
+    d, err := time.ParseDuration(v)
+    if err != nil {
+        log.Printf("invalid %s=%q, using %s", key, v, def)
+        return def
+    }
+    return d
+}
+
+func getInt(key string, def int) int {
+    v := os.Getenv(key)
+    if v == "" {
+        return def
+    }
+    n, err := strconv.Atoi(v)
+    if err != nil || n < 1 {
+        log.Printf("invalid %s=%q, using %d", key, v, def)
+        return def
+    }
+    return n
+}
+
+func getFloat(key string, def float64) float64 {
+    v := os.Getenv(key)
+    if v == "" {
+        return def
+    }
+    f, err := strconv.ParseFloat(v, 64)
+    if err != nil {
+        log.Printf("invalid %s=%q, using %v", key, v, def)
+        return def
+    }
+    return f
+}
+
+func getList(key, def string) []string {
+    var out []string
+    for _, v := range strings.Split(get(key, def), ",") {
+        if v = strings.TrimSpace(v); v != "" { out = append(out, v) }
+    }
+    return out
+}
+
 func MustEnv(keys ...string) {
     for _, k := range keys {
         if os.Getenv(k) == "" {
@@ -54,4 +207,4 @@ func MustEnv(keys ...string) {
     }
 }
 
-func Timeout() time.Duration { return 5 * time.Second }
\ No newline at end of file
+func Timeout() time.Duration { return 5 * time.Second }
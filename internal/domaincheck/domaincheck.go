@@ -0,0 +1,129 @@
+// Package domaincheck proves that a company controls a domain: the company
+// publishes a token either as a DNS TXT record on the domain or in a
+// well-known file served from it.
+package domaincheck
+
+import (
+    "bufio"
+    "context"
+    "errors"
+    "fmt"
+    "io"
+    "net"
+    "net/http"
+    "regexp"
+    "strings"
+    "syscall"
+    "time"
+)
+
+const (
+    // TXTPrefix starts the TXT record value, followed by the token.
+    TXTPrefix = "realdeal-verification="
+    // WellKnownPath holds the token on a line of its own.
+    WellKnownPath = "/.well-known/realdeal-verification.txt"
+
+    MethodDNS  = "dns"
+    MethodHTTP = "http"
+)
+
+var (
+    ErrInvalidDomain = errors.New("invalid domain")
+    ErrNotFound      = errors.New("verification token not found")
+    errBlocked       = errors.New("address is not public")
+)
+
+// Resolver looks up TXT records; *net.Resolver implements it.
+type Resolver interface {
+    LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// Fetcher performs HTTP requests; *http.Client implements it.
+type Fetcher interface {
+    Do(req *http.Request) (*http.Response, error)
+}
+
+type Checker struct {
+    Resolver Resolver
+    Fetcher  Fetcher
+}
+
+// New returns a Checker using the system resolver and PublicClient.
+func New(timeout time.Duration) *Checker {
+    return &Checker{Resolver: net.DefaultResolver, Fetcher: PublicClient(timeout)}
+}
+
+var hostname = regexp.MustCompile(`^([a-z0-9]([a-z0-9-]{0,61}[a-z0-9])?\.)+[a-z]{2,63}$`)
+
+// Normalize lower-cases domain and checks it is a plain host name: no
+// scheme, port, path or IP address.
+func Normalize(domain string) (string, error) {
+    d := strings.TrimSuffix(strings.ToLower(strings.TrimSpace(domain)), ".")
+    if len(d) > 253 || !hostname.MatchString(d) { return "", ErrInvalidDomain }
+    return d, nil
+}
+
+// Check looks for token on domain, first in DNS and then over HTTPS and
+// HTTP, and returns the method that found it.
+func (c *Checker) Check(ctx context.Context, domain, token string) (string, error) {
+    dnsErr := c.checkDNS(ctx, domain, token)
+    if dnsErr == nil { return MethodDNS, nil }
+    httpErr := c.checkHTTP(ctx, domain, token)
+    if httpErr == nil { return MethodHTTP, nil }
+    return "", fmt.Errorf("%w (dns: %v; http: %v)", ErrNotFound, dnsErr, httpErr)
+}
+
+func (c *Checker) checkDNS(ctx context.Context, domain, token string) error {
+    records, err := c.Resolver.LookupTXT(ctx, domain)
+    if err != nil { return err }
+    for _, r := range records {
+        if strings.TrimSpace(r) == TXTPrefix+token { return nil }
+    }
+    return fmt.Errorf("no TXT record %q", TXTPrefix+token)
+}
+
+func (c *Checker) checkHTTP(ctx context.Context, domain, token string) error {
+    var errs []string
+    for _, scheme := range []string{"https", "http"} {
+        err := c.fetch(ctx, scheme+"://"+domain+WellKnownPath, token)
+        if err == nil { return nil }
+        errs = append(errs, scheme+": "+err.Error())
+    }
+    return errors.New(strings.Join(errs, "; "))
+}
+
+func (c *Checker) fetch(ctx context.Context, url, token string) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+    if err != nil { return err }
+    res, err := c.Fetcher.Do(req)
+    if err != nil { return err }
+    defer res.Body.Close()
+    if res.StatusCode != http.StatusOK { return fmt.Errorf("status %d", res.StatusCode) }
+    sc := bufio.NewScanner(io.LimitReader(res.Body, 4096))
+    for sc.Scan() {
+        line := strings.TrimSpace(sc.Text())
+        if line == token || line == TXTPrefix+token { return nil }
+    }
+    return errors.New("token not in file")
+}
+
+// PublicClient returns an HTTP client that only connects to public
+// addresses, so a claimed domain cannot point the checker at loopback,
+// private or link-local services, and follows at most three redirects.
+func PublicClient(timeout time.Duration) *http.Client {
+    d := &net.Dialer{Timeout: timeout, Control: func(network, address string, _ syscall.RawConn) error {
+        host, _, err := net.SplitHostPort(address)
+        if err != nil { return err }
+        ip := net.ParseIP(host)
+        if ip == nil || !ip.IsGlobalUnicast() || ip.IsPrivate() || ip.IsLoopback() { return errBlocked }
+        return nil
+    }}
+    return &http.Client{
+        Timeout:   timeout,
+        Transport: &http.Transport{DialContext: d.DialContext, TLSHandshakeTimeout: timeout},
+        CheckRedirect: func(req *http.Request, via []*http.Request) error {
+            if len(via) > 3 { return errors.New("too many redirects") }
+            return nil
+        },
+    }
+}
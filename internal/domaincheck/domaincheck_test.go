@@ -0,0 +1,143 @@
+package domaincheck
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "slices"
+    "strconv"
+    "strings"
+    "testing"
+    "time"
+)
+
+type fakeResolver map[string][]string
+
+func (r fakeResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+    if txt, ok := r[name]; ok { return txt, nil }
+    return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+// toServer sends every request, whatever its scheme and host, to the test
+// server, and records the URLs asked for.
+type toServer struct {
+    target *url.URL
+    seen   []string
+}
+
+func (t *toServer) RoundTrip(req *http.Request) (*http.Response, error) {
+    t.seen = append(t.seen, req.URL.String())
+    r := req.Clone(req.Context())
+    r.URL.Scheme, r.URL.Host, r.Host = t.target.Scheme, t.target.Host, req.URL.Host
+    return http.DefaultTransport.RoundTrip(r)
+}
+
+// site serves the well-known file per host: hop.test after n redirects,
+// loop.test never.
+func site(w http.ResponseWriter, r *http.Request) {
+    if r.URL.Path != WellKnownPath { http.NotFound(w, r); return }
+    n, _ := strconv.Atoi(r.URL.Query().Get("n"))
+    switch r.Host {
+    case "file.test":
+        fmt.Fprint(w, "# other-service-token\n  tok123  \n")
+    case "prefixed.test":
+        fmt.Fprint(w, TXTPrefix+"tok123\n")
+    case "wrong.test":
+        fmt.Fprint(w, "tok1234\n")
+    case "hop.test", "loop.test":
+        if r.Host == "loop.test" || n < 3 { http.Redirect(w, r, fmt.Sprintf("%s?n=%d", WellKnownPath, n+1), http.StatusFound); return }
+        fmt.Fprint(w, "tok123\n")
+    default:
+        http.NotFound(w, r)
+    }
+}
+
+func TestCheck(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(site))
+    defer srv.Close()
+    target, _ := url.Parse(srv.URL)
+    resolver := fakeResolver{
+        "txt.test":  {"v=spf1 -all", " " + TXTPrefix + "tok123 "},
+        "file.test": {TXTPrefix + "tok999"},
+    }
+    cases := []struct {
+        domain, method string
+        fetched        []string
+        errHas         string
+    }{
+        {"txt.test", MethodDNS, nil, ""},
+        {"file.test", MethodHTTP, []string{"https://file.test" + WellKnownPath}, ""},
+        {"prefixed.test", MethodHTTP, []string{"https://prefixed.test" + WellKnownPath}, ""},
+        {"hop.test", MethodHTTP, []string{"https://hop.test" + WellKnownPath, "https://hop.test" + WellKnownPath + "?n=1", "https://hop.test" + WellKnownPath + "?n=2", "https://hop.test" + WellKnownPath + "?n=3"}, ""},
+        {"wrong.test", "", []string{"https://wrong.test" + WellKnownPath, "http://wrong.test" + WellKnownPath}, "token not in file"},
+        {"missing.test", "", []string{"https://missing.test" + WellKnownPath, "http://missing.test" + WellKnownPath}, "status 404"},
+        {"loop.test", "", nil, "too many redirects"},
+    }
+    for _, tc := range cases {
+        t.Run(tc.domain, func(t *testing.T) {
+            rt := &toServer{target: target}
+            client := PublicClient(time.Second)
+            client.Transport = rt
+            c := &Checker{Resolver: resolver, Fetcher: client}
+            method, err := c.Check(context.Background(), tc.domain, "tok123")
+            if tc.method != "" {
+                if err != nil || method != tc.method { t.Fatalf("Check = %q, %v; want %q", method, err, tc.method) }
+            } else if !errors.Is(err, ErrNotFound) || !strings.Contains(err.Error(), tc.errHas) {
+                t.Fatalf("Check = %q, %v; want ErrNotFound with %q", method, err, tc.errHas)
+            }
+            if tc.fetched != nil && !slices.Equal(rt.seen, tc.fetched) { t.Errorf("fetched %v, want %v", rt.seen, tc.fetched) }
+            if tc.method == MethodDNS && len(rt.seen) != 0 { t.Errorf("fetched %v after a TXT hit", rt.seen) }
+        })
+    }
+}
+
+// The redirect limit is three: the fourth redirect is refused.
+func TestRedirectLimit(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(site))
+    defer srv.Close()
+    target, _ := url.Parse(srv.URL)
+    rt := &toServer{target: target}
+    client := PublicClient(time.Second)
+    client.Transport = rt
+    _, err := client.Get("http://loop.test" + WellKnownPath)
+    if err == nil || !strings.Contains(err.Error(), "too many redirects") { t.Fatalf("got %v, want too many redirects", err) }
+    if len(rt.seen) != 4 { t.Fatalf("fetched %v, want the first request and three redirects", rt.seen) }
+}
+
+func TestPublicClientBlocks(t *testing.T) {
+    srv := httptest.NewServer(http.HandlerFunc(site))
+    defer srv.Close()
+    _, port, _ := net.SplitHostPort(strings.TrimPrefix(srv.URL, "http://"))
+    client := PublicClient(time.Second)
+    for _, host := range []string{"127.0.0.1", "[::1]", "10.0.0.1", "192.168.1.1", "172.16.0.1", "169.254.169.254", "[fe80::1]", "[fd00::1]", "0.0.0.0"} {
+        _, err := client.Get("http://" + host + ":" + port + WellKnownPath)
+        if !errors.Is(err, errBlocked) { t.Errorf("%s: got %v, want blocked", host, err) }
+    }
+}
+
+func TestNormalize(t *testing.T) {
+    cases := []struct{ in, want string }{
+        {"Example.COM", "example.com"},
+        {" jobs.example.co.uk. ", "jobs.example.co.uk"},
+        {"xn--fiqs8s.com", "xn--fiqs8s.com"},
+        {"https://example.com", ""},
+        {"example.com:8080", ""},
+        {"example.com/path", ""},
+        {"127.0.0.1", ""},
+        {"localhost", ""},
+        {"-bad.example.com", ""},
+        {"", ""},
+    }
+    for _, tc := range cases {
+        got, err := Normalize(tc.in)
+        if tc.want == "" {
+            if !errors.Is(err, ErrInvalidDomain) { t.Errorf("Normalize(%q) = %q, %v; want ErrInvalidDomain", tc.in, got, err) }
+        } else if got != tc.want || err != nil {
+            t.Errorf("Normalize(%q) = %q, %v; want %q", tc.in, got, err, tc.want)
+        }
+    }
+}
@@ -0,0 +1,213 @@
+// Package feed blends projects, products, posts, jobs and companies into one
+// ranked stream. Candidates are the newest documents of each source; Rank
+// orders them for a reader's Profile.
+package feed
+
+import (
+    "context"
+    "encoding/base64"
+    "errors"
+    "strconv"
+    "strings"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+
+    "real_deal/internal/query"
+    "real_deal/internal/repo"
+)
+
+var ErrBadCursor = errors.New("invalid cursor")
+
+// Item is one entry of the feed. Doc is the underlying document.
+// CompanyID is the company a company item is or a job is posted for;
+// Verified is that company's, for jobs.
+type Item struct {
+    Type       string    `json:"type"`
+    ID         string    `json:"id"`
+    AuthorID   string    `json:"authorId,omitempty"`
+    CompanyID  string    `json:"-"`
+    Tags       []string  `json:"tags"`
+    Verified   bool      `json:"verified,omitempty"`
+    Engagement float64   `json:"-"`
+    CreatedAt  time.Time `json:"createdAt"`
+    Score      float64   `json:"score"`
+    Explain    *Explain  `json:"explain,omitempty"`
+    Doc        bson.M    `json:"item"`
+}
+
+type source struct {
+    Type    string
+    Coll    string
+    Tags    string
+    Author  string
+    Company string
+}
+
+var sources = []source{
+    {Type: "project", Coll: "projects", Tags: "tags", Author: "authorId"},
+    {Type: "product", Coll: "products", Tags: "tags", Author: "authorId"},
+    {Type: "post", Coll: "posts", Tags: "tags", Author: "authorId"},
+    {Type: "job", Coll: "jobs", Tags: "skills", Author: "ownerId", Company: "companyId"},
+    {Type: "company", Coll: "companies", Tags: "tags", Company: "id"},
+}
+
+// Service builds feeds from the repositories. PerSource bounds how many of
+// the newest documents of each source are considered for ranking.
+type Service struct {
+    Repos     *repo.Repos
+    Weights   Weights
+    PerSource int64
+    Now       func() time.Time
+}
+
+func NewService(repos *repo.Repos) *Service {
+    return &Service{Repos: repos, Weights: DefaultWeights, PerSource: 200, Now: time.Now}
+}
+
+// Feed returns one page of the ranked feed for userID ("" for anonymous
+// readers). The cursor is an offset into the ranking, which is recomputed on
+// every request.
+func (s *Service) Feed(ctx context.Context, userID, cursor string, limit int, explain bool) (*query.Page[Item], error) {
+    offset := 0
+    if cursor != "" {
+        b, err := base64.RawURLEncoding.DecodeString(cursor)
+        if err != nil { return nil, ErrBadCursor }
+        if offset, err = strconv.Atoi(string(b)); err != nil || offset < 0 { return nil, ErrBadCursor }
+    }
+    now := s.Now()
+    items, err := s.Candidates(ctx, now)
+    if err != nil { return nil, err }
+    var p *Profile
+    if userID != "" {
+        if p, err = s.Profile(ctx, userID); err != nil { return nil, err }
+    }
+    Rank(items, p, s.Weights, now, explain)
+
+    page := &query.Page[Item]{Items: []Item{}, Total: int64(len(items))}
+    if offset < len(items) {
+        end := offset + limit
+        if end > len(items) { end = len(items) }
+        page.Items = items[offset:end]
+        if end < len(items) { page.NextCursor = base64.RawURLEncoding.EncodeToString([]byte(strconv.Itoa(end))) }
+    }
+    return page, nil
+}
+
+// Candidates loads the newest visible documents of every source. Jobs take
+// their company's verification.
+func (s *Service) Candidates(ctx context.Context, now time.Time) ([]Item, error) {
+    var items []Item
+    for _, src := range sources {
+        docs, err := s.Repos.Documents.Newest(ctx, src.Coll, now, s.PerSource)
+        if err != nil { return nil, err }
+        for _, d := range docs {
+            it := Item{Type: src.Type, Doc: d, Tags: stringList(d[src.Tags]), Engagement: engagement(d)}
+            it.ID, _ = d["id"].(string)
+            if src.Author != "" { it.AuthorID, _ = d[src.Author].(string) }
+            if src.Company != "" { it.CompanyID, _ = d[src.Company].(string) }
+            it.Verified, _ = d["verified"].(bool)
+            if t, ok := d["createdAt"].(primitive.DateTime); ok { it.CreatedAt = t.Time() }
+            items = append(items, it)
+        }
+    }
+    return items, s.verifyJobs(ctx, items)
+}
+
+// verifyJobs marks the jobs posted for verified companies as verified.
+func (s *Service) verifyJobs(ctx context.Context, items []Item) error {
+    var ids []string
+    for _, it := range items {
+        if it.Type == "job" && it.CompanyID != "" { ids = append(ids, it.CompanyID) }
+    }
+    if len(ids) == 0 { return nil }
+    companies, err := s.Repos.Companies.GetMany(ctx, ids)
+    if err != nil { return err }
+    verified := map[string]bool{}
+    for _, co := range companies { verified[co.ID] = co.Verified }
+    for i := range items {
+        if items[i].Type == "job" && verified[items[i].CompanyID] { items[i].Verified = true }
+    }
+    return nil
+}
+
+// engagement combines the counters in a document's optional "stats"
+// sub-document; applications count more than views.
+func engagement(d bson.M) float64 {
+    st, ok := d["stats"].(bson.M)
+    if !ok { return 0 }
+    n := func(k string) float64 {
+        switch v := st[k].(type) {
+        case int32:
+            return float64(v)
+        case int64:
+            return float64(v)
+        case float64:
+            return v
+        }
+        return 0
+    }
+    return n("views") + 5*n("applications")
+}
+
+// Profile derives a reader's interests: followed tags count fully, tags of
+// the reader's own content and skills of jobs they applied to count half.
+// Followed users and companies boost their items directly, a company's jobs
+// included.
+func (s *Service) Profile(ctx context.Context, userID string) (*Profile, error) {
+    p := &Profile{UserID: userID, Tags: map[string]float64{}, Users: map[string]bool{}, Companies: map[string]bool{}}
+    add := func(tags []string, w float64) {
+        for _, t := range tags {
+            t = strings.ToLower(t)
+            if p.Tags[t] < w { p.Tags[t] = w }
+        }
+    }
+
+    follows, err := s.Repos.Follows.List(ctx, userID)
+    if err != nil { return nil, err }
+    for _, f := range follows {
+        switch f.Type {
+        case "tag":
+            add([]string{f.Target}, 1)
+        case "user":
+            p.Users[f.Target] = true
+        case "company":
+            p.Companies[f.Target] = true
+        }
+    }
+
+    own := func() *query.Query {
+        return &query.Query{Filter: bson.M{"authorId": userID}, Field: "createdAt", Desc: true, Limit: 100}
+    }
+    projects, err := s.Repos.Projects.List(ctx, own())
+    if err != nil { return nil, err }
+    for _, v := range projects.Items { add(v.Tags, 0.5) }
+    products, err := s.Repos.Products.List(ctx, own())
+    if err != nil { return nil, err }
+    for _, v := range products.Items { add(v.Tags, 0.5) }
+    posts, err := s.Repos.Posts.List(ctx, own())
+    if err != nil { return nil, err }
+    for _, v := range posts.Items { add(v.Tags, 0.5) }
+
+    apps, err := s.Repos.Applications.List(ctx, repo.ApplicationFilter{CandidateID: userID}, &query.Query{Field: "createdAt", Desc: true, Limit: 100})
+    if err != nil { return nil, err }
+    if len(apps.Items) > 0 {
+        ids := make([]string, 0, len(apps.Items))
+        for _, a := range apps.Items { ids = append(ids, a.JobID) }
+        jobs, err := s.Repos.Jobs.GetMany(ctx, ids)
+        if err != nil { return nil, err }
+        for _, j := range jobs { add(j.Skills, 0.5) }
+    }
+    return p, nil
+}
+
+func stringList(v any) []string {
+    a, ok := v.(bson.A)
+    if !ok { return []string{} }
+    out := make([]string, 0, len(a))
+    for _, e := range a {
+        if s, ok := e.(string); ok { out = append(out, s) }
+    }
+    return out
+}
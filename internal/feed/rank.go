@@ -0,0 +1,93 @@
+package feed
+
+import (
+    "math"
+    "sort"
+    "strings"
+    "time"
+)
+
+// Weights scale each ranking signal. Every signal is normalised to [0, 1]
+// before weighting, so the weights read as the most an item can gain from it.
+type Weights struct {
+    Recency    float64
+    Tags       float64
+    Follow     float64
+    Verified   float64
+    Engagement float64
+    // HalfLife is the age at which the recency signal drops to one half.
+    HalfLife time.Duration
+}
+
+var DefaultWeights = Weights{Recency: 1, Tags: 1.5, Follow: 2, Verified: 0.5, Engagement: 0.75, HalfLife: 72 * time.Hour}
+
+// engagementSaturation is the engagement count at which the signal reaches 1.
+const engagementSaturation = 1000
+
+// Profile is what personalises the ranking. A nil Profile (an anonymous
+// reader) leaves only the recency, verification and engagement signals.
+type Profile struct {
+    UserID string `json:"userId"`
+    // Tags maps lowercased tags to an interest weight in (0, 1].
+    Tags      map[string]float64 `json:"tags"`
+    Users     map[string]bool    `json:"users"`
+    Companies map[string]bool    `json:"companies"`
+}
+
+// Explain is the breakdown of an item's score returned in debug mode. Each
+// field is the weighted contribution of one signal.
+type Explain struct {
+    Recency     float64  `json:"recency"`
+    Tags        float64  `json:"tags"`
+    MatchedTags []string `json:"matchedTags,omitempty"`
+    Follow      float64  `json:"follow"`
+    Verified    float64  `json:"verified"`
+    Engagement  float64  `json:"engagement"`
+}
+
+// Rank scores items for p at time now and sorts them best first. Ties are
+// broken by recency and then by type and id, so the same inputs always give
+// the same order. With explain set each item carries its score breakdown.
+func Rank(items []Item, p *Profile, w Weights, now time.Time, explain bool) {
+    for i := range items {
+        e := score(&items[i], p, w, now)
+        items[i].Score = round(e.Recency + e.Tags + e.Follow + e.Verified + e.Engagement)
+        if explain { items[i].Explain = &e }
+    }
+    sort.SliceStable(items, func(i, j int) bool {
+        a, b := items[i], items[j]
+        if a.Score != b.Score { return a.Score > b.Score }
+        if !a.CreatedAt.Equal(b.CreatedAt) { return a.CreatedAt.After(b.CreatedAt) }
+        if a.Type != b.Type { return a.Type < b.Type }
+        return a.ID < b.ID
+    })
+}
+
+func score(it *Item, p *Profile, w Weights, now time.Time) Explain {
+    var e Explain
+    if !it.CreatedAt.IsZero() && w.HalfLife > 0 {
+        age := now.Sub(it.CreatedAt)
+        if age < 0 { age = 0 }
+        e.Recency = round(w.Recency * math.Exp2(-float64(age)/float64(w.HalfLife)))
+    }
+    if it.Verified { e.Verified = w.Verified }
+    if it.Engagement > 0 {
+        e.Engagement = round(w.Engagement * math.Min(1, math.Log1p(it.Engagement)/math.Log1p(engagementSaturation)))
+    }
+    if p == nil { return e }
+
+    // Tag overlap is the best interest weight among the item's tags plus a
+    // small bonus for each further match, capped at 1.
+    var best, extra float64
+    for _, t := range it.Tags {
+        wt, ok := p.Tags[strings.ToLower(t)]
+        if !ok { continue }
+        e.MatchedTags = append(e.MatchedTags, t)
+        if wt > best { extra += best * 0.25; best = wt } else { extra += wt * 0.25 }
+    }
+    e.Tags = round(w.Tags * math.Min(1, best+extra))
+    if (it.AuthorID != "" && p.Users[it.AuthorID]) || (it.CompanyID != "" && p.Companies[it.CompanyID]) { e.Follow = w.Follow }
+    return e
+}
+
+func round(f float64) float64 { return math.Round(f*1e4) / 1e4 }
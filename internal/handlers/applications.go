@@ -0,0 +1,196 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "real_deal/internal/query"
+    "real_deal/internal/repo"
+    "real_deal/internal/storage"
+)
+
+// ApplicationHandler runs the hiring pipeline. Stages is the ordered list of
+// stages from APPLICATION_STAGES; the first is where every application starts
+// and the last is final. StageRejected can be reached from any open stage.
+type ApplicationHandler struct {
+    Applications repo.Applications
+    Jobs         repo.Jobs
+    Media        repo.MediaAssets
+    Inbox        repo.Inbox
+    Store        storage.Store
+    Stages       []string
+}
+
+func NewApplication(repos *repo.Repos, st storage.Store, stages []string) *ApplicationHandler {
+    if len(stages) == 0 { stages = []string{"applied", "screening", "interview", "offer", "hired"} }
+    return &ApplicationHandler{Applications: repos.Applications, Jobs: repos.Jobs, Media: repos.Media, Inbox: repos.Inbox, Store: st, Stages: stages}
+}
+
+// stageLabels are the inbox wording for the default stages; custom stages
+// are shown by name.
+var stageLabels = map[string]string{
+    "applied":     "已投递",
+    "screening":   "筛选中",
+    "interview":   "面试",
+    "offer":       "录用意向",
+    "hired":       "已录用",
+    StageRejected: "未通过",
+}
+
+func stageLabel(s string) string {
+    if l, ok := stageLabels[s]; ok { return l }
+    return s
+}
+
+// ListStages returns the pipeline so clients can render it.
+func (h *ApplicationHandler) ListStages(c *gin.Context) {
+    c.JSON(http.StatusOK, gin.H{"stages": h.Stages, "rejected": StageRejected})
+}
+
+// canMove allows moving forward through the pipeline, skipping stages if
+// needed, or rejecting an application that is still open.
+func (h *ApplicationHandler) canMove(from, to string) bool {
+    last := h.Stages[len(h.Stages)-1]
+    if from == StageRejected || from == last { return false }
+    if to == StageRejected { return true }
+    fi, ti := -1, -1
+    for i, s := range h.Stages {
+        if s == from { fi = i }
+        if s == to { ti = i }
+    }
+    return ti >= 0 && ti > fi
+}
+
+type applyReq struct {
+    ResumeID  string `json:"resumeId" binding:"required"`
+    CoverNote string `json:"coverNote" binding:"max=5000"`
+}
+
+// Apply submits the caller's application to a live job.
+func (h *ApplicationHandler) Apply(c *gin.Context) {
+    ctx := c.Request.Context()
+    var req applyReq
+    if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    u := CurrentUser(c)
+    j, err := h.Jobs.Get(ctx, c.Param("id"))
+    if err != nil || !j.Live(time.Now()) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    if j.OwnerID == u.ID { c.JSON(http.StatusBadRequest, gin.H{"error": "cannot apply to your own job"}); return }
+    resume, err := h.Media.Get(ctx, req.ResumeID)
+    if err != nil || resume.OwnerID != u.ID { c.JSON(http.StatusBadRequest, gin.H{"error": "resume not found"}); return }
+    if resume.Type != "document" { c.JSON(http.StatusBadRequest, gin.H{"error": "resume must be a document"}); return }
+
+    now := time.Now().UTC()
+    a := Application{
+        ID: newID("app"), JobID: j.ID, CandidateID: u.ID, RecruiterID: j.OwnerID,
+        ResumeID: req.ResumeID, CoverNote: req.CoverNote, Stage: h.Stages[0], CreatedAt: now, UpdatedAt: now,
+    }
+    if err := h.Applications.Insert(ctx, &a); err != nil {
+        if errors.Is(err, repo.ErrDuplicate) { c.JSON(http.StatusConflict, gin.H{"error": "already applied to this job"}); return }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    _ = h.record(ctx, ApplicationEvent{ApplicationID: a.ID, ActorID: u.ID, To: a.Stage, At: now})
+    _ = h.Jobs.Bump(ctx, j.ID, "applications")
+    _ = notify(ctx, h.Inbox, u.ID, "job_update", "职位申请已收到：「"+j.Title+"」", "application", a.ID)
+    _ = notify(ctx, h.Inbox, j.OwnerID, "job_update", "收到新的职位申请：「"+j.Title+"」", "application", a.ID)
+    c.JSON(http.StatusCreated, a)
+}
+
+// Mine lists the caller's applications, filterable by ?stage= and ?jobId=.
+func (h *ApplicationHandler) Mine(c *gin.Context) {
+    listWith(c, applicationSpec, func(q *query.Query) (*query.Page[Application], error) {
+        return h.Applications.List(c.Request.Context(), repo.ApplicationFilter{CandidateID: CurrentUser(c).ID}, q)
+    })
+}
+
+// ForJob lists a job's applications for its owner, filterable by ?stage=.
+func (h *ApplicationHandler) ForJob(c *gin.Context) {
+    j, ok := loadOwnedJob(c, h.Jobs)
+    if !ok { return }
+    listWith(c, applicationSpec, func(q *query.Query) (*query.Page[Application], error) {
+        return h.Applications.List(c.Request.Context(), repo.ApplicationFilter{JobID: j.ID}, q)
+    })
+}
+
+func (h *ApplicationHandler) Get(c *gin.Context) {
+    a, ok := h.load(c)
+    if !ok { return }
+    c.JSON(http.StatusOK, a)
+}
+
+// Resume returns the application's resume with a short-lived download URL.
+// Resumes are private documents; this is how the job's recruiter gets them.
+func (h *ApplicationHandler) Resume(c *gin.Context) {
+    ctx := c.Request.Context()
+    a, ok := h.load(c)
+    if !ok { return }
+    m, err := h.Media.Get(ctx, a.ResumeID)
+    if errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "resume not found"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    url, err := h.Store.Presign(ctx, m.Key, 15*time.Minute)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    m.ContentURL = url
+    c.JSON(http.StatusOK, m)
+}
+
+// Events returns the application's stage history, oldest first. Notes
+// are the recruiter's own and are left out for the candidate.
+func (h *ApplicationHandler) Events(c *gin.Context) {
+    a, ok := h.load(c)
+    if !ok { return }
+    items, err := h.Applications.Events(c.Request.Context(), a.ID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if u := CurrentUser(c); u.ID != a.RecruiterID && !u.IsAdmin() {
+        for i := range items { items[i].Note = "" }
+    }
+    c.JSON(http.StatusOK, items)
+}
+
+type stageReq struct {
+    Stage string `json:"stage" binding:"required"`
+    Note  string `json:"note" binding:"max=2000"`
+}
+
+// Move changes an application's stage. Only the job's recruiter (or an admin)
+// may do so; the change is audited and the candidate is notified.
+func (h *ApplicationHandler) Move(c *gin.Context) {
+    ctx := c.Request.Context()
+    var req stageReq
+    if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    a, ok := h.load(c)
+    if !ok { return }
+    u := CurrentUser(c)
+    if a.RecruiterID != u.ID && !u.IsAdmin() { c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"}); return }
+    if !h.canMove(a.Stage, req.Stage) { c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("cannot move from %s to %s", a.Stage, req.Stage)}); return }
+
+    now := time.Now().UTC()
+    err := h.Applications.Move(ctx, a.ID, a.Stage, req.Stage, now)
+    if errors.Is(err, repo.ErrConflict) { c.JSON(http.StatusConflict, gin.H{"error": "application changed concurrently, retry"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if err := h.record(ctx, ApplicationEvent{ApplicationID: a.ID, ActorID: u.ID, From: a.Stage, To: req.Stage, Note: req.Note, At: now}); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+
+    title := a.JobID
+    if j, err := h.Jobs.Get(ctx, a.JobID); err == nil { title = j.Title }
+    _ = notify(ctx, h.Inbox, a.CandidateID, "job_update", "你申请的职位「"+title+"」进入"+stageLabel(req.Stage)+"阶段", "application", a.ID)
+
+    a.Stage, a.UpdatedAt = req.Stage, now
+    c.JSON(http.StatusOK, a)
+}
+
+func (h *ApplicationHandler) record(ctx context.Context, e ApplicationEvent) error { return h.Applications.AddEvent(ctx, &e) }
+
+// load fetches the application named by :id if the caller is its candidate,
+// its recruiter or an admin.
+func (h *ApplicationHandler) load(c *gin.Context) (*Application, bool) {
+    a, err := h.Applications.Get(c.Request.Context(), c.Param("id"))
+    if errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return nil, false }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return nil, false }
+    u := CurrentUser(c)
+    if a.CandidateID != u.ID && a.RecruiterID != u.ID && !u.IsAdmin() { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return nil, false }
+    return a, true
+}
@@ -2,38 +2,207 @@ package handlers
 
 import (
     "context"
+    "errors"
+    "fmt"
+    "html/template"
+    "log"
     nhtt "net/http"
+    "net/url"
+    "strconv"
+    "strings"
 
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+    "github.com/gin-gonic/gin/binding"
+    "real_deal/internal/auth"
+    "real_deal/internal/config"
+    "real_deal/internal/mail"
+    "real_deal/internal/ratelimit"
+    "real_deal/internal/rbac"
+    "real_deal/internal/repo"
+    "real_deal/internal/session"
 )
 
-type AuthHandler struct{ DB *mongo.Database }
+const sessionCookie = "sid"
 
-func NewAuth(db *mongo.Database) *AuthHandler { return &AuthHandler{DB: db} }
+type AuthHandler struct {
+    Users      repo.Users
+    Links      *auth.MagicLinks
+    Mailer     mail.Mailer
+    Sessions   session.Store
+    EmailLimit *ratelimit.Limit
+    IPLimit    *ratelimit.Limit
+    Cfg        *config.Config
+}
+
+func NewAuth(users repo.Users, links *auth.MagicLinks, m mail.Mailer, sessions session.Store, counter ratelimit.Counter, cfg *config.Config) *AuthHandler {
+    return &AuthHandler{
+        Users: users, Links: links, Mailer: m, Sessions: sessions,
+        EmailLimit: ratelimit.New(counter, "login_email", cfg.LoginEmailLimit, cfg.LoginLimitWindow),
+        IPLimit:    ratelimit.New(counter, "login_ip", cfg.LoginIPLimit, cfg.LoginLimitWindow),
+        Cfg:        cfg,
+    }
+}
 
 type loginReq struct{ Email string `json:"email"` }
 
+// Login emails a one-time sign-in link. The response is the same whether or
+// not the address belongs to a user, and whether or not the mail went out,
+// so the endpoint cannot be used to probe for accounts. Requests are limited
+// per client IP and per address, counting unknown addresses too, so it
+// cannot be used to flood an inbox either.
 func (h *AuthHandler) Login(c *gin.Context) {
+    if !allow(c, h.IPLimit, c.ClientIP()) { return }
     var req loginReq
     if err := c.ShouldBindJSON(&req); err != nil { c.JSON(nhtt.StatusBadRequest, gin.H{"error": "bad request"}); return }
-    ctx := context.Background()
-    var u map[string]any
-    err := h.DB.Collection("users").FindOne(ctx, bson.M{"email": req.Email}).Decode(&u)
-    if err != nil { c.JSON(nhtt.StatusUnauthorized, gin.H{"error": "not found"}); return }
-    id, _ := u["id"].(string)
-    c.SetCookie("uid", id, 86400*7, "/", "localhost", false, true)
-    nhtt.SetCookie(c.Writer, &nhtt.Cookie{Name: "uid", Value: id, Path: "/", Domain: "localhost", HttpOnly: true, SameSite: nhtt.SameSiteLaxMode})
+    email := strings.ToLower(strings.TrimSpace(req.Email))
+    if email == "" { c.JSON(nhtt.StatusBadRequest, gin.H{"error": "email required"}); return }
+    if !allow(c, h.EmailLimit, email) { return }
+    ctx := c.Request.Context()
+    _, err := h.Users.ByEmail(ctx, email)
+    if errors.Is(err, repo.ErrNotFound) { c.JSON(nhtt.StatusAccepted, gin.H{"status": "sent"}); return }
+    if err != nil { c.JSON(nhtt.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    tok, err := h.Links.Issue(ctx, email)
+    if err != nil { c.JSON(nhtt.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    link := h.Cfg.PublicAPIURL + "/api/login/verify?token=" + url.QueryEscape(tok)
+    msg := mail.Message{
+        To:      email,
+        Subject: "登录 Real Deal",
+        Body:    fmt.Sprintf("点击以下链接登录（%d 分钟内有效，仅可使用一次）：\n\n%s\n\n如果不是你本人操作，请忽略此邮件。", int(h.Cfg.LoginTokenTTL.Minutes()), link),
+    }
+    if err := h.Mailer.Send(ctx, msg); err != nil { log.Printf("login mail to %s failed: %v", email, err) }
+    c.JSON(nhtt.StatusAccepted, gin.H{"status": "sent"})
+}
+
+// allow counts a request against l and answers 429 with Retry-After once
+// key is over it.
+func allow(c *gin.Context, l *ratelimit.Limit, key string) bool {
+    wait, err := l.Allow(c.Request.Context(), key)
+    if errors.Is(err, ratelimit.ErrLimited) {
+        c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+        c.JSON(nhtt.StatusTooManyRequests, gin.H{"error": "too many login requests, try again later"})
+        return false
+    }
+    if err != nil { c.JSON(nhtt.StatusInternalServerError, gin.H{"error": err.Error()}); return false }
+    return true
+}
+
+type verifyReq struct{ Token string `json:"token" form:"token"` }
+
+// verifyPage is what the emailed link opens. Mail scanners and link
+// prefetchers follow links with GET, so the token is only redeemed when the
+// user submits the form.
+var verifyPage = template.Must(template.New("verify").Parse(`<!doctype html>
+<html lang="zh-CN"><head><meta charset="utf-8"><meta name="viewport" content="width=device-width, initial-scale=1"><meta name="robots" content="noindex">
+<title>登录 Real Deal</title></head>
+<body style="font-family: sans-serif; text-align: center; margin-top: 20vh">
+<form method="post" action="{{.Action}}"><input type="hidden" name="token" value="{{.Token}}">
+<button type="submit" style="font-size: 1.2em; padding: .6em 2em">登录 Real Deal</button></form>
+</body></html>`))
+
+// VerifyPage serves the confirmation page for an emailed link without
+// using up its token.
+func (h *AuthHandler) VerifyPage(c *gin.Context) {
+    c.Header("Cache-Control", "no-store")
+    c.Header("Referrer-Policy", "no-referrer")
+    c.Header("Content-Type", "text/html; charset=utf-8")
+    c.Status(nhtt.StatusOK)
+    _ = verifyPage.Execute(c.Writer, gin.H{"Action": h.Cfg.PublicAPIURL + "/api/login/verify", "Token": c.Query("token")})
+}
+
+// Verify redeems a login token. The confirmation page posts a form and is
+// redirected back to the web app; API clients post JSON and get the user.
+func (h *AuthHandler) Verify(c *gin.Context) {
+    var req verifyReq
+    if err := c.ShouldBind(&req); err != nil { c.JSON(nhtt.StatusBadRequest, gin.H{"error": "bad request"}); return }
+    u, err := h.redeem(c.Request.Context(), req.Token)
+    if err == nil { err = h.startSession(c, u) }
+    if c.ContentType() == binding.MIMEPOSTForm {
+        if err != nil { c.Redirect(nhtt.StatusSeeOther, h.Cfg.WebURL+"/login?error=invalid_link"); return }
+        c.Redirect(nhtt.StatusSeeOther, h.Cfg.WebURL+"/")
+        return
+    }
+    if errors.Is(err, auth.ErrInvalidToken) || errors.Is(err, repo.ErrNotFound) { c.JSON(nhtt.StatusUnauthorized, gin.H{"error": "invalid or expired token"}); return }
+    if err != nil { c.JSON(nhtt.StatusInternalServerError, gin.H{"error": err.Error()}); return }
     c.JSON(nhtt.StatusOK, u)
 }
 
+func (h *AuthHandler) redeem(ctx context.Context, token string) (*User, error) {
+    email, err := h.Links.Redeem(ctx, token)
+    if err != nil { return nil, err }
+    return h.Users.ByEmail(ctx, email)
+}
+
+func (h *AuthHandler) startSession(c *gin.Context, u *User) error {
+    return startSession(c, h.Sessions, h.Cfg, u.ID)
+}
+
+func (h *AuthHandler) setCookie(c *gin.Context, value string, maxAge int) { setSessionCookie(c, h.Cfg, value, maxAge) }
+
+// startSession creates a server-side session for userID and sets its cookie.
+func startSession(c *gin.Context, sessions session.Store, cfg *config.Config, userID string) error {
+    tok, _, err := sessions.Create(c.Request.Context(), userID, session.Meta{UserAgent: c.Request.UserAgent(), IP: c.ClientIP()})
+    if err != nil { return err }
+    setSessionCookie(c, cfg, tok, int(cfg.SessionTTL.Seconds()))
+    return nil
+}
+
+func setSessionCookie(c *gin.Context, cfg *config.Config, value string, maxAge int) {
+    nhtt.SetCookie(c.Writer, &nhtt.Cookie{Name: sessionCookie, Value: value, Path: "/", Domain: cfg.CookieDomain, MaxAge: maxAge, Secure: cfg.CookieSecure, HttpOnly: true, SameSite: nhtt.SameSiteLaxMode})
+}
+
+type meView struct {
+    *User
+    Permissions []rbac.Permission `json:"permissions"`
+}
+
 func (h *AuthHandler) Me(c *gin.Context) {
-    uid, err := c.Cookie("uid")
-    if err != nil { c.JSON(nhtt.StatusUnauthorized, gin.H{"error": "unauth"}); return }
-    ctx := context.Background()
-    var u map[string]any
-    err = h.DB.Collection("users").FindOne(ctx, bson.M{"id": uid}).Decode(&u)
-    if err != nil { c.JSON(nhtt.StatusUnauthorized, gin.H{"error": "unauth"}); return }
-    c.JSON(nhtt.StatusOK, u)
-}
\ No newline at end of file
+    u := CurrentUser(c)
+    c.JSON(nhtt.StatusOK, meView{User: u, Permissions: rbac.Permissions(u.Role)})
+}
+
+// Logout ends the session on this device only.
+func (h *AuthHandler) Logout(c *gin.Context) {
+    if sess := currentSession(c); sess != nil {
+        if err := h.Sessions.Delete(c.Request.Context(), sess.ID); err != nil { c.JSON(nhtt.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    }
+    h.setCookie(c, "", -1)
+    c.Status(nhtt.StatusNoContent)
+}
+
+// LogoutAll revokes every session belonging to the current user.
+func (h *AuthHandler) LogoutAll(c *gin.Context) {
+    if err := h.Sessions.DeleteUser(c.Request.Context(), CurrentUser(c).ID); err != nil { c.JSON(nhtt.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    h.setCookie(c, "", -1)
+    c.Status(nhtt.StatusNoContent)
+}
+
+type sessionView struct {
+    session.Session
+    Current bool `json:"current"`
+}
+
+func (h *AuthHandler) ListSessions(c *gin.Context) {
+    sess := currentSession(c)
+    list, err := h.Sessions.List(c.Request.Context(), sess.UserID)
+    if err != nil { c.JSON(nhtt.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    items := make([]sessionView, 0, len(list))
+    for _, s := range list { items = append(items, sessionView{Session: s, Current: s.ID == sess.ID}) }
+    c.JSON(nhtt.StatusOK, items)
+}
+
+// RevokeSession logs out one of the current user's other devices.
+func (h *AuthHandler) RevokeSession(c *gin.Context) {
+    sess := currentSession(c)
+    ctx := c.Request.Context()
+    list, err := h.Sessions.List(ctx, sess.UserID)
+    if err != nil { c.JSON(nhtt.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    id := c.Param("id")
+    for _, s := range list {
+        if s.ID != id { continue }
+        if err := h.Sessions.Delete(ctx, id); err != nil { c.JSON(nhtt.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+        if id == sess.ID { h.setCookie(c, "", -1) }
+        c.Status(nhtt.StatusNoContent)
+        return
+    }
+    c.JSON(nhtt.StatusNotFound, gin.H{"error": "not found"})
+}
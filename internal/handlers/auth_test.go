@@ -0,0 +1,87 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "real_deal/internal/auth"
+    "real_deal/internal/config"
+    "real_deal/internal/mail"
+    "real_deal/internal/model"
+    "real_deal/internal/ratelimit"
+)
+
+// outbox records login mails, failing every send while down is set.
+type outbox struct {
+    sent []string
+    down bool
+}
+
+func (o *outbox) Send(ctx context.Context, msg mail.Message) error {
+    if o.down { return errors.New("smtp: connection refused") }
+    o.sent = append(o.sent, msg.To)
+    return nil
+}
+
+func newLoginServer(t *testing.T) (*testServer, *outbox) {
+    s := newTestServer(t)
+    box := &outbox{}
+    cfg := &config.Config{LoginEmailLimit: 2, LoginIPLimit: 4, LoginLimitWindow: time.Hour}
+    h := NewAuth(s.repos.Users, auth.NewMagicLinks(s.repos.LoginTokens, time.Minute), box, s.sessions, ratelimit.NewMemory(), cfg)
+    s.router.POST("/api/login", h.Login)
+    s.mem.Users["u1"] = model.User{ID: "u1", Email: "ada@example.com"}
+    return s, box
+}
+
+func (s *testServer) requestLogin(ip, email string) *httptest.ResponseRecorder {
+    req := httptest.NewRequest("POST", "/api/login", strings.NewReader(`{"email":"`+email+`"}`))
+    req.Header.Set("Content-Type", "application/json")
+    req.RemoteAddr = ip + ":40000"
+    w := httptest.NewRecorder()
+    s.router.ServeHTTP(w, req)
+    return w
+}
+
+// Known and unknown addresses get the same answer, even when the mail
+// cannot be delivered.
+func TestLoginSameAnswer(t *testing.T) {
+    s, box := newLoginServer(t)
+    box.down = true
+    known, unknown := s.requestLogin("192.0.2.1", "ada@example.com"), s.requestLogin("192.0.2.2", "bob@example.com")
+    if known.Code != http.StatusAccepted || unknown.Code != http.StatusAccepted || known.Body.String() != unknown.Body.String() {
+        t.Fatalf("known: %d %s; unknown: %d %s", known.Code, known.Body, unknown.Code, unknown.Body)
+    }
+    box.down = false
+    if w := s.requestLogin("192.0.2.1", " Ada@Example.com "); w.Code != http.StatusAccepted || len(box.sent) != 1 || box.sent[0] != "ada@example.com" { t.Fatalf("got %d, sent %v", w.Code, box.sent) }
+}
+
+func TestLoginRateLimits(t *testing.T) {
+    s, box := newLoginServer(t)
+    steps := []struct {
+        ip, email string
+        want      int
+    }{
+        {"192.0.2.1", "ada@example.com", http.StatusAccepted},
+        {"192.0.2.2", "ADA@example.com", http.StatusAccepted},
+        // A third link for the address within the window, from any IP.
+        {"192.0.2.3", "ada@example.com", http.StatusTooManyRequests},
+        // Unknown addresses count the same.
+        {"192.0.2.3", "bob@example.com", http.StatusAccepted},
+        {"192.0.2.3", "bob@example.com", http.StatusAccepted},
+        {"192.0.2.3", "bob@example.com", http.StatusTooManyRequests},
+        // 192.0.2.3 has used its four requests, whatever the address.
+        {"192.0.2.3", "cy@example.com", http.StatusTooManyRequests},
+        {"192.0.2.4", "cy@example.com", http.StatusAccepted},
+    }
+    for i, st := range steps {
+        w := s.requestLogin(st.ip, st.email)
+        if w.Code != st.want { t.Fatalf("step %d %s %s: got %d %s, want %d", i, st.ip, st.email, w.Code, w.Body, st.want) }
+        if w.Code == http.StatusTooManyRequests && w.Header().Get("Retry-After") == "" { t.Errorf("step %d: no Retry-After", i) }
+    }
+    if len(box.sent) != 2 { t.Fatalf("sent %v, want two mails to ada", box.sent) }
+}
@@ -1,23 +1,20 @@
 package handlers
 
 import (
-    "context"
-    "net/http"
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/query"
+    "real_deal/internal/repo"
 )
 
-type CapacityHandler struct{ DB *mongo.Database }
+type CapacityHandler struct{ Billing repo.Billing }
 
-func NewCapacity(db *mongo.Database) *CapacityHandler { return &CapacityHandler{DB: db} }
+func NewCapacity(repos *repo.Repos) *CapacityHandler { return &CapacityHandler{Billing: repos.Billing} }
 
 func (h *CapacityHandler) List(c *gin.Context) {
-    user := c.Query("userId")
-    ctx := context.Background()
-    cur, err := h.DB.Collection("capacity_packs").Find(ctx, bson.M{"userId": user})
-    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
-    var items []CapacityPack
-    for cur.Next(ctx) { var cp CapacityPack; _ = cur.Decode(&cp); items = append(items, cp) }
-    c.JSON(http.StatusOK, items)
-}
\ No newline at end of file
+    user, ok := targetUserID(c)
+    if !ok { return }
+    listWith(c, billingSpec, func(q *query.Query) (*query.Page[CapacityPack], error) {
+        return h.Billing.CapacityPacks(c.Request.Context(), user, q)
+    })
+}
@@ -1,23 +1,20 @@
 package handlers
 
 import (
-    "context"
-    "net/http"
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/query"
+    "real_deal/internal/repo"
 )
 
-type ChargeHandler struct{ DB *mongo.Database }
+type ChargeHandler struct{ Billing repo.Billing }
 
-func NewCharge(db *mongo.Database) *ChargeHandler { return &ChargeHandler{DB: db} }
+func NewCharge(repos *repo.Repos) *ChargeHandler { return &ChargeHandler{Billing: repos.Billing} }
 
 func (h *ChargeHandler) List(c *gin.Context) {
-    user := c.Query("userId")
-    ctx := context.Background()
-    cur, err := h.DB.Collection("charges").Find(ctx, bson.M{"userId": user})
-    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
-    var items []Charge
-    for cur.Next(ctx) { var ch Charge; _ = cur.Decode(&ch); items = append(items, ch) }
-    c.JSON(http.StatusOK, items)
-}
\ No newline at end of file
+    user, ok := targetUserID(c)
+    if !ok { return }
+    listWith(c, billingSpec, func(q *query.Query) (*query.Page[Charge], error) {
+        return h.Billing.Charges(c.Request.Context(), user, q)
+    })
+}
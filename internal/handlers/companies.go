@@ -2,22 +2,312 @@ package handlers
 
 import (
     "context"
+    "errors"
+    "fmt"
+    "log"
     "net/http"
+    "strings"
+    "time"
 
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/config"
+    "real_deal/internal/mail"
+    "real_deal/internal/model"
+    "real_deal/internal/repo"
+    "real_deal/internal/search"
 )
 
-type CompanyHandler struct{ DB *mongo.Database }
+// CompanyHandler manages company profiles, their team and invitations.
+type CompanyHandler struct {
+    Companies repo.Companies
+    Users     repo.Users
+    Inbox     repo.Inbox
+    Mailer    mail.Mailer
+    Search    search.Engine
+    Cfg       *config.Config
+}
 
-func NewCompany(db *mongo.Database) *CompanyHandler { return &CompanyHandler{DB: db} }
+func NewCompany(repos *repo.Repos, m mail.Mailer, idx search.Engine, cfg *config.Config) *CompanyHandler {
+    return &CompanyHandler{Companies: repos.Companies, Users: repos.Users, Inbox: repos.Inbox, Mailer: m, Search: idx, Cfg: cfg}
+}
 
 func (h *CompanyHandler) Get(c *gin.Context) {
-    id := c.Param("id")
-    ctx := context.Background()
-    var co Company
-    err := h.DB.Collection("companies").FindOne(ctx, bson.M{"id": id}).Decode(&co)
+    co, err := h.Companies.Get(c.Request.Context(), c.Param("id"))
     if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
     c.JSON(http.StatusOK, co)
-}
\ No newline at end of file
+}
+
+// companyRole checks that the caller holds at least role at companyID and
+// returns their membership; site admins act as the owner. It writes the
+// error response itself and returns false when the request must stop.
+func companyRole(c *gin.Context, companies repo.Companies, companyID, role string) (*CompanyMember, bool) {
+    ctx := c.Request.Context()
+    if _, err := companies.Get(ctx, companyID); err != nil {
+        if errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "company not found"}); return nil, false }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return nil, false
+    }
+    u := CurrentUser(c)
+    if u.IsAdmin() { return &CompanyMember{CompanyID: companyID, UserID: u.ID, Role: model.CompanyRoleOwner}, true }
+    m, err := companies.Member(ctx, companyID, u.ID)
+    if err != nil && !errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return nil, false }
+    if m == nil || !m.AtLeast(role) { c.JSON(http.StatusForbidden, gin.H{"error": "requires company role " + role}); return nil, false }
+    return m, true
+}
+
+// Create registers a company with the caller as its owner. New companies
+// start unverified.
+func (h *CompanyHandler) Create(c *gin.Context) {
+    var co Company
+    if err := c.ShouldBindJSON(&co); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    now := time.Now().UTC()
+    co.ID, co.Verified, co.CreatedAt, co.UpdatedAt = newID("co"), false, &now, &now
+    if co.Tags == nil { co.Tags = []string{} }
+    if err := h.Companies.Create(c.Request.Context(), &co, CurrentUser(c).ID); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    reindex(c.Request.Context(), h.Search, "companies", co.ID)
+    c.JSON(http.StatusCreated, co)
+}
+
+// Update edits the profile (PUT replaces, PATCH merges); owners and admins only.
+func (h *CompanyHandler) Update(c *gin.Context) {
+    ctx := c.Request.Context()
+    if _, ok := companyRole(c, h.Companies, c.Param("id"), model.CompanyRoleAdmin); !ok { return }
+    cur, err := h.Companies.Get(ctx, c.Param("id"))
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    var co Company
+    if c.Request.Method == http.MethodPatch { co = *cur }
+    if err := c.ShouldBindJSON(&co); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    now := time.Now().UTC()
+    co.ID, co.Verified, co.CreatedAt, co.UpdatedAt = cur.ID, cur.Verified, cur.CreatedAt, &now
+    if co.Tags == nil { co.Tags = []string{} }
+    if err := h.Companies.Update(ctx, &co); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    reindex(ctx, h.Search, "companies", co.ID)
+    c.JSON(http.StatusOK, co)
+}
+
+type membership struct {
+    Company  *Company  `json:"company"`
+    Role     string    `json:"role"`
+    JoinedAt time.Time `json:"joinedAt"`
+}
+
+// Mine lists the companies the caller belongs to, with their role.
+func (h *CompanyHandler) Mine(c *gin.Context) {
+    ctx := c.Request.Context()
+    ms, err := h.Companies.MembershipsOf(ctx, CurrentUser(c).ID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    items := []membership{}
+    for _, m := range ms {
+        co, err := h.Companies.Get(ctx, m.CompanyID)
+        if errors.Is(err, repo.ErrNotFound) { continue }
+        if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+        items = append(items, membership{Company: co, Role: m.Role, JoinedAt: m.JoinedAt})
+    }
+    c.JSON(http.StatusOK, items)
+}
+
+// Members lists the team; visible to its members.
+func (h *CompanyHandler) Members(c *gin.Context) {
+    if _, ok := companyRole(c, h.Companies, c.Param("id"), model.CompanyRoleMember); !ok { return }
+    items, err := h.Companies.Members(c.Request.Context(), c.Param("id"))
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, items)
+}
+
+type roleReq struct {
+    Role string `json:"role" binding:"required"`
+}
+
+// SetRole changes a member's role. Callers can only manage members below
+// them and grant roles below their own, so admins handle recruiters and
+// members and only the owner appoints admins.
+func (h *CompanyHandler) SetRole(c *gin.Context) {
+    ctx := c.Request.Context()
+    var req roleReq
+    if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    if !model.ValidCompanyRole(req.Role) { c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role; ownership changes by transfer"}); return }
+    me, ok := companyRole(c, h.Companies, c.Param("id"), model.CompanyRoleAdmin)
+    if !ok { return }
+    m, err := h.Companies.Member(ctx, c.Param("id"), c.Param("userId"))
+    if errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if !me.Outranks(m.Role) || !me.Outranks(req.Role) { c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"}); return }
+    if err := h.Companies.SetRole(ctx, m.CompanyID, m.UserID, m.Role, req.Role); err != nil {
+        if errors.Is(err, repo.ErrConflict) { c.JSON(http.StatusConflict, gin.H{"error": "member changed concurrently, retry"}); return }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    m.Role = req.Role
+    c.JSON(http.StatusOK, m)
+}
+
+// RemoveMember takes someone off the team, or lets the caller leave. The
+// owner has to transfer ownership before leaving.
+func (h *CompanyHandler) RemoveMember(c *gin.Context) {
+    ctx := c.Request.Context()
+    companyID, userID := c.Param("id"), c.Param("userId")
+    m, err := h.Companies.Member(ctx, companyID, userID)
+    if errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if m.Role == model.CompanyRoleOwner { c.JSON(http.StatusConflict, gin.H{"error": "transfer ownership before the owner leaves"}); return }
+    if userID != CurrentUser(c).ID {
+        me, ok := companyRole(c, h.Companies, companyID, model.CompanyRoleAdmin)
+        if !ok { return }
+        if !me.Outranks(m.Role) { c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"}); return }
+    }
+    if err := h.Companies.RemoveMember(ctx, companyID, userID); err != nil && !errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.Status(http.StatusNoContent)
+}
+
+type transferReq struct {
+    UserID string `json:"userId" binding:"required"`
+}
+
+// Transfer hands ownership to another member; the previous owner stays on
+// as an admin.
+func (h *CompanyHandler) Transfer(c *gin.Context) {
+    ctx := c.Request.Context()
+    var req transferReq
+    if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    companyID := c.Param("id")
+    if _, ok := companyRole(c, h.Companies, companyID, model.CompanyRoleOwner); !ok { return }
+    members, err := h.Companies.Members(ctx, companyID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    var owner, target *CompanyMember
+    for i := range members {
+        if members[i].Role == model.CompanyRoleOwner { owner = &members[i] }
+        if members[i].UserID == req.UserID { target = &members[i] }
+    }
+    if target == nil { c.JSON(http.StatusBadRequest, gin.H{"error": "new owner must already be a member"}); return }
+    if owner == nil || owner.UserID == target.UserID { c.JSON(http.StatusConflict, gin.H{"error": "nothing to transfer"}); return }
+    if err := h.Companies.TransferOwnership(ctx, companyID, owner.UserID, target.UserID); err != nil {
+        if errors.Is(err, repo.ErrConflict) { c.JSON(http.StatusConflict, gin.H{"error": "members changed concurrently, retry"}); return }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    co, _ := h.Companies.Get(ctx, companyID)
+    if co != nil { _ = notify(ctx, h.Inbox, target.UserID, "company", "你已成为「"+co.Name+"」的所有者", "company", companyID) }
+    c.JSON(http.StatusOK, gin.H{"companyId": companyID, "owner": target.UserID, "previousOwner": owner.UserID})
+}
+
+type inviteReq struct {
+    Email string `json:"email" binding:"required,email"`
+    Role  string `json:"role" binding:"required"`
+}
+
+// Invite asks an email address to join the team. The invitee gets an email
+// and, if they already have an account, an inbox item.
+func (h *CompanyHandler) Invite(c *gin.Context) {
+    ctx := c.Request.Context()
+    var req inviteReq
+    if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    if !model.ValidCompanyRole(req.Role) { c.JSON(http.StatusBadRequest, gin.H{"error": "invalid role; ownership changes by transfer"}); return }
+    companyID := c.Param("id")
+    me, ok := companyRole(c, h.Companies, companyID, model.CompanyRoleAdmin)
+    if !ok { return }
+    if !me.Outranks(req.Role) { c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"}); return }
+    email := strings.ToLower(strings.TrimSpace(req.Email))
+    invitee, err := h.Users.ByEmail(ctx, email)
+    if err != nil && !errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if invitee != nil {
+        if _, err := h.Companies.Member(ctx, companyID, invitee.ID); err == nil { c.JSON(http.StatusConflict, gin.H{"error": "already a member"}); return }
+    }
+
+    now := time.Now().UTC()
+    inv := CompanyInvitation{
+        ID: newID("inv"), CompanyID: companyID, Email: email, Role: req.Role, InvitedBy: CurrentUser(c).ID,
+        Status: model.InvitePending, CreatedAt: now, ExpiresAt: now.Add(h.Cfg.InviteTTL),
+    }
+    if err := h.Companies.Invite(ctx, &inv); err != nil {
+        if errors.Is(err, repo.ErrDuplicate) { c.JSON(http.StatusConflict, gin.H{"error": "already invited"}); return }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    co, _ := h.Companies.Get(ctx, companyID)
+    name := companyID
+    if co != nil { name = co.Name }
+    if invitee != nil { _ = notify(ctx, h.Inbox, invitee.ID, "company", "「"+name+"」邀请你加入团队", "company_invitation", inv.ID) }
+    msg := mail.Message{
+        To:      email,
+        Subject: "加入「" + name + "」",
+        Body:    fmt.Sprintf("「%s」邀请你以 %s 身份加入团队。\n\n登录后在以下页面接受或拒绝邀请（%d 天内有效）：\n\n%s\n", name, req.Role, int(h.Cfg.InviteTTL.Hours()/24), h.Cfg.WebURL+"/invitations"),
+    }
+    if err := h.Mailer.Send(ctx, msg); err != nil { log.Printf("invitation mail to %s failed: %v", email, err) }
+    c.JSON(http.StatusCreated, inv)
+}
+
+// Invitations lists every invitation the company has sent.
+func (h *CompanyHandler) Invitations(c *gin.Context) {
+    if _, ok := companyRole(c, h.Companies, c.Param("id"), model.CompanyRoleAdmin); !ok { return }
+    items, err := h.Companies.Invitations(c.Request.Context(), c.Param("id"))
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, items)
+}
+
+// Revoke withdraws a pending invitation.
+func (h *CompanyHandler) Revoke(c *gin.Context) {
+    ctx := c.Request.Context()
+    if _, ok := companyRole(c, h.Companies, c.Param("id"), model.CompanyRoleAdmin); !ok { return }
+    inv, err := h.Companies.Invitation(ctx, c.Param("inviteId"))
+    if err != nil || inv.CompanyID != c.Param("id") { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    if err := h.Companies.CloseInvitation(ctx, inv.ID, model.InviteRevoked, time.Now().UTC()); err != nil {
+        if errors.Is(err, repo.ErrConflict) { c.JSON(http.StatusConflict, gin.H{"error": "invitation is no longer pending"}); return }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    c.Status(http.StatusNoContent)
+}
+
+// MyInvitations lists the open invitations addressed to the caller's email.
+func (h *CompanyHandler) MyInvitations(c *gin.Context) {
+    u := CurrentUser(c)
+    items := []CompanyInvitation{}
+    if u.Email == "" { c.JSON(http.StatusOK, items); return }
+    pending, err := h.Companies.PendingFor(c.Request.Context(), u.Email)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    now := time.Now()
+    for _, inv := range pending {
+        if inv.ExpiresAt.After(now) { items = append(items, inv) }
+    }
+    c.JSON(http.StatusOK, items)
+}
+
+func (h *CompanyHandler) Accept(c *gin.Context)  { h.respond(c, model.InviteAccepted) }
+func (h *CompanyHandler) Decline(c *gin.Context) { h.respond(c, model.InviteDeclined) }
+
+// respond closes an invitation addressed to the caller; accepting it adds
+// them to the team with the invited role.
+func (h *CompanyHandler) respond(c *gin.Context, status string) {
+    ctx := c.Request.Context()
+    u := CurrentUser(c)
+    inv, err := h.Companies.Invitation(ctx, c.Param("id"))
+    if err != nil || u.Email == "" || inv.Email != u.Email { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    if inv.Status != model.InvitePending { c.JSON(http.StatusConflict, gin.H{"error": "invitation is " + inv.Status}); return }
+    now := time.Now().UTC()
+    if !inv.ExpiresAt.After(now) { c.JSON(http.StatusGone, gin.H{"error": "invitation expired"}); return }
+    if err := h.Companies.CloseInvitation(ctx, inv.ID, status, now); err != nil {
+        if errors.Is(err, repo.ErrConflict) { c.JSON(http.StatusConflict, gin.H{"error": "invitation is no longer pending"}); return }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    inv.Status, inv.RespondedAt = status, &now
+    if status == model.InviteAccepted {
+        err := h.Companies.AddMember(ctx, &CompanyMember{CompanyID: inv.CompanyID, UserID: u.ID, Role: inv.Role, JoinedAt: now})
+        if err != nil && !errors.Is(err, repo.ErrDuplicate) { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    }
+    verb := "拒绝"
+    if status == model.InviteAccepted { verb = "接受" }
+    _ = notify(ctx, h.Inbox, inv.InvitedBy, "company", inv.Email+" 已"+verb+"加入团队的邀请", "company", inv.CompanyID)
+    c.JSON(http.StatusOK, inv)
+}
+
+// notifyCompany drops an inbox item for every member holding at least role.
+// It is best effort, like the other notifications.
+func notifyCompany(ctx context.Context, inbox repo.Inbox, companies repo.Companies, companyID, role, text, refType, refID string) {
+    members, err := companies.Members(ctx, companyID)
+    if err != nil { return }
+    for _, m := range members {
+        if m.AtLeast(role) { _ = notify(ctx, inbox, m.UserID, "company", text, refType, refID) }
+    }
+}
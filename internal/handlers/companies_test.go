@@ -0,0 +1,63 @@
+package handlers
+
+import (
+    "context"
+    "io"
+    "net/http"
+    "testing"
+
+    "real_deal/internal/config"
+    "real_deal/internal/mail"
+    "real_deal/internal/model"
+    "real_deal/internal/search"
+)
+
+// recordingIndex notes which documents were refreshed in the index.
+type recordingIndex struct {
+    *search.Memory
+    refreshed []string
+}
+
+func (x *recordingIndex) Refresh(ctx context.Context, coll, id string) error {
+    x.refreshed = append(x.refreshed, coll+"/"+id)
+    return x.Memory.Refresh(ctx, coll, id)
+}
+
+func TestCompanyReindexed(t *testing.T) {
+    s := newTestServer(t)
+    tokenizer, _ := search.NewTokenizer("")
+    idx := &recordingIndex{Memory: search.NewMemory(s.repos.Documents, tokenizer)}
+    h := NewCompany(s.repos, mail.NewWriter(io.Discard, "noreply@test"), idx, &config.Config{})
+    me := s.router.Group("/api", Authenticate(s.repos.Users, s.sessions), RequireUser())
+    me.POST("/companies", h.Create)
+    me.PATCH("/companies/:id", h.Update)
+    tok := s.login("u1", "recruiter")
+
+    w := s.do("POST", "/api/companies", tok, `{"name":"Lantern Robotics","description":"Warehouse arms"}`)
+    if w.Code != http.StatusCreated { t.Fatalf("create: got %d %s", w.Code, w.Body) }
+    co := decode[Company](t, w)
+    if w := s.do("PATCH", "/api/companies/"+co.ID, tok, `{"description":"Orchard drones"}`); w.Code != http.StatusOK { t.Fatalf("update: got %d %s", w.Code, w.Body) }
+    want := "companies/" + co.ID
+    if len(idx.refreshed) != 2 || idx.refreshed[0] != want || idx.refreshed[1] != want { t.Fatalf("refreshed %v, want %s twice", idx.refreshed, want) }
+}
+
+// A merged-away owner's role survives on the account it is merged into.
+func TestMergeKeepsCompanyOwner(t *testing.T) {
+    s, _ := newOAuthServer(t)
+    s.login("u1", "recruiter")
+    s.login("u2", "recruiter")
+    s.mem.Companies["co1"] = model.Company{ID: "co1", Name: "Lantern"}
+    s.mem.Companies["co2"] = model.Company{ID: "co2", Name: "Orchard"}
+    s.mem.Members = []model.CompanyMember{
+        {CompanyID: "co1", UserID: "u2", Role: model.CompanyRoleOwner},
+        {CompanyID: "co1", UserID: "u1", Role: model.CompanyRoleMember},
+        {CompanyID: "co2", UserID: "u1", Role: model.CompanyRoleOwner},
+        {CompanyID: "co2", UserID: "u2", Role: model.CompanyRoleAdmin},
+    }
+    if w := s.do("POST", "/api/admin/users/merge", "", `{"from":"u2","into":"u1"}`); w.Code != http.StatusOK { t.Fatalf("merge: got %d %s", w.Code, w.Body) }
+    want := map[string]string{"co1": model.CompanyRoleOwner, "co2": model.CompanyRoleOwner}
+    if len(s.mem.Members) != 2 { t.Fatalf("members = %+v", s.mem.Members) }
+    for _, m := range s.mem.Members {
+        if m.UserID != "u1" || m.Role != want[m.CompanyID] { t.Errorf("member %+v, want u1 as %s", m, want[m.CompanyID]) }
+    }
+}
@@ -1,22 +1,19 @@
 package handlers
 
 import (
-    "context"
     "net/http"
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/repo"
 )
 
-type ComplianceHandler struct{ DB *mongo.Database }
+type ComplianceHandler struct{ Jobs repo.Jobs }
 
-func NewCompliance(db *mongo.Database) *ComplianceHandler { return &ComplianceHandler{DB: db} }
+func NewCompliance(repos *repo.Repos) *ComplianceHandler { return &ComplianceHandler{Jobs: repos.Jobs} }
 
+// Job returns the job's latest compliance check with its per-rule findings.
 func (h *ComplianceHandler) Job(c *gin.Context) {
-    id := c.Param("jobId")
-    ctx := context.Background()
-    var v JobCompliance
-    err := h.DB.Collection("job_compliance").FindOne(ctx, bson.M{"jobId": id}).Decode(&v)
+    v, err := h.Jobs.Compliance(c.Request.Context(), c.Param("jobId"))
     if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
     c.JSON(http.StatusOK, v)
-}
\ No newline at end of file
+}
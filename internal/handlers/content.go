@@ -0,0 +1,114 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "real_deal/internal/rbac"
+    "real_deal/internal/repo"
+    "real_deal/internal/screen"
+    "real_deal/internal/search"
+)
+
+// contentPtr is satisfied by *Project, *Product and *Post, letting the write
+// endpoints below be shared across the three collections. ScreenText is
+// the title and text screened before moderation.
+type contentPtr[T any] interface {
+    *T
+    Meta() *ContentMeta
+    ScreenText() (title, text string)
+}
+
+// contentTypes maps a content collection to the type recorded in the
+// moderation queue.
+var contentTypes = map[string]string{"posts": "post", "projects": "project", "products": "product"}
+
+// createContent stores new content and puts it in the moderation queue; it
+// is visible until the screener or a moderator rejects it.
+func createContent[T any, PT contentPtr[T]](c *gin.Context, store repo.Content[T], idx search.Engine, q *ModerationQueue, coll, prefix string) {
+    ctx := c.Request.Context()
+    var v T
+    if err := c.ShouldBindJSON(&v); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    now := time.Now().UTC()
+    *PT(&v).Meta() = ContentMeta{ID: newID(prefix), AuthorID: CurrentUser(c).ID, CreatedAt: now, UpdatedAt: now}
+    if err := store.Insert(ctx, &v); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if !submitContent[T, PT](c, q, coll, &v) { return }
+    reindex(ctx, idx, coll, PT(&v).Meta().ID)
+    c.JSON(http.StatusCreated, v)
+}
+
+// getContent hides rejected content from everyone but its author and
+// moderators.
+func getContent[T any, PT contentPtr[T]](c *gin.Context, store repo.Content[T]) {
+    ctx := c.Request.Context()
+    v, err := store.Get(ctx, c.Param("id"))
+    if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    m := PT(v).Meta()
+    if m.Moderation == ModerationRejected {
+        if !canSeeRejected(c, m.AuthorID) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    } else {
+        // best effort: a lost view only nudges the feed's ranking
+        _ = store.Bump(ctx, m.ID, "views")
+    }
+    c.JSON(http.StatusOK, v)
+}
+
+func canSeeRejected(c *gin.Context, authorID string) bool {
+    u := CurrentUser(c)
+    return u != nil && (u.ID == authorID || u.Can(rbac.ModerationReview))
+}
+
+// updateContent handles PUT (replace) and PATCH (merge the body over the
+// stored document). Either way the result is validated as a whole and the
+// server-managed fields are kept; the repository keeps the document's stats
+// and search entry. Approved content goes back into the moderation queue.
+func updateContent[T any, PT contentPtr[T]](c *gin.Context, store repo.Content[T], idx search.Engine, q *ModerationQueue, coll string) {
+    ctx := c.Request.Context()
+    cur, ok := loadOwned[T, PT](c, store)
+    if !ok { return }
+    var v T
+    if c.Request.Method == http.MethodPatch { v = *cur }
+    if err := c.ShouldBindJSON(&v); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    m := *PT(cur).Meta()
+    m.UpdatedAt = time.Now().UTC()
+    *PT(&v).Meta() = m
+    if err := store.Update(ctx, &v); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if !submitContent[T, PT](c, q, coll, &v) { return }
+    reindex(ctx, idx, coll, m.ID)
+    c.JSON(http.StatusOK, v)
+}
+
+// submitContent hands stored content to the moderation queue, marking v
+// when the screener rejected it. It writes the error response itself.
+func submitContent[T any, PT contentPtr[T]](c *gin.Context, q *ModerationQueue, coll string, v *T) bool {
+    m := PT(v).Meta()
+    title, text := PT(v).ScreenText()
+    rejected, err := q.Submit(c.Request.Context(), &screen.Input{Type: contentTypes[coll], ID: m.ID, Title: title, Text: text}, m.AuthorID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return false }
+    if rejected { m.Moderation = ModerationRejected }
+    return true
+}
+
+// deleteContent soft-deletes: the document stays for audit but disappears
+// from every read endpoint.
+func deleteContent[T any, PT contentPtr[T]](c *gin.Context, store repo.Content[T]) {
+    cur, ok := loadOwned[T, PT](c, store)
+    if !ok { return }
+    if err := store.Delete(c.Request.Context(), PT(cur).Meta().ID, time.Now().UTC()); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.Status(http.StatusNoContent)
+}
+
+// loadOwned loads the live document named by :id and checks that the caller
+// authored it (admins may edit anything). It writes the error response and
+// returns false otherwise.
+func loadOwned[T any, PT contentPtr[T]](c *gin.Context, store repo.Content[T]) (*T, bool) {
+    v, err := store.Get(c.Request.Context(), c.Param("id"))
+    if errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return nil, false }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return nil, false }
+    u := CurrentUser(c)
+    if PT(v).Meta().AuthorID != u.ID && !u.IsAdmin() { c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"}); return nil, false }
+    return v, true
+}
@@ -1,22 +1,19 @@
 package handlers
 
 import (
-    "context"
     "net/http"
+
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/repo"
 )
 
-type DealRoomHandler struct{ DB *mongo.Database }
+type DealRoomHandler struct{ DealRooms repo.DealRooms }
 
-func NewDealRoom(db *mongo.Database) *DealRoomHandler { return &DealRoomHandler{DB: db} }
+func NewDealRoom(repos *repo.Repos) *DealRoomHandler { return &DealRoomHandler{DealRooms: repos.DealRooms} }
 
 func (h *DealRoomHandler) Get(c *gin.Context) {
-    id := c.Param("id")
-    ctx := context.Background()
-    var d DealRoom
-    err := h.DB.Collection("deal_rooms").FindOne(ctx, bson.M{"id": id}).Decode(&d)
+    d, err := h.DealRooms.Get(c.Request.Context(), c.Param("id"))
     if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
     c.JSON(http.StatusOK, d)
-}
\ No newline at end of file
+}
@@ -0,0 +1,152 @@
+package handlers
+
+import (
+    "context"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "net/http"
+    "strconv"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "real_deal/internal/domaincheck"
+    "real_deal/internal/model"
+    "real_deal/internal/repo"
+)
+
+const (
+    // domainCheckInterval is how soon a company may check its domain again.
+    domainCheckInterval = 30 * time.Second
+    // domainCheckTimeout bounds one check, DNS and HTTP together.
+    domainCheckTimeout = 20 * time.Second
+    // domainRetryWindow is how long after a claim the background pass keeps
+    // checking it, to pick up records that were slow to propagate.
+    domainRetryWindow = 72 * time.Hour
+)
+
+// domainView is a claim as its company sees it, with the token and where to
+// publish it.
+type domainView struct {
+    *DomainVerification
+    Token     string `json:"token"`
+    TXTRecord string `json:"txtRecord"`
+    WellKnown string `json:"wellKnownUrl"`
+}
+
+func newDomainView(d *DomainVerification) domainView {
+    return domainView{
+        DomainVerification: d,
+        Token:              d.Token,
+        TXTRecord:          domaincheck.TXTPrefix + d.Token,
+        WellKnown:          "https://" + d.Name + domaincheck.WellKnownPath,
+    }
+}
+
+// Domain returns the company's domain claim with its token.
+func (h *VerificationHandler) Domain(c *gin.Context) {
+    companyID := c.Param("id")
+    if _, ok := companyRole(c, h.Companies, companyID, model.CompanyRoleAdmin); !ok { return }
+    d, err := h.domain(c.Request.Context(), companyID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if d == nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    c.JSON(http.StatusOK, newDomainView(d))
+}
+
+type domainReq struct {
+    Name string `json:"name" binding:"required"`
+}
+
+// ClaimDomain starts verification of a domain and issues its token.
+// Claiming another domain replaces the previous claim, verified or not.
+func (h *VerificationHandler) ClaimDomain(c *gin.Context) {
+    ctx := c.Request.Context()
+    var req domainReq
+    if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    name, err := domaincheck.Normalize(req.Name)
+    if err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": "name must be a domain such as example.com"}); return }
+    companyID := c.Param("id")
+    if _, ok := companyRole(c, h.Companies, companyID, model.CompanyRoleAdmin); !ok { return }
+    cur, err := h.domain(ctx, companyID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if cur != nil && cur.Name == name {
+        if cur.Status == DomainVerified { c.JSON(http.StatusConflict, gin.H{"error": "domain already verified"}); return }
+        c.JSON(http.StatusOK, newDomainView(cur))
+        return
+    }
+    d := DomainVerification{Name: name, Token: domainToken(), Status: DomainPending, ClaimedBy: CurrentUser(c).ID, ClaimedAt: time.Now().UTC()}
+    if err := h.Verifications.ClaimDomain(ctx, companyID, &d); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusCreated, newDomainView(&d))
+}
+
+// CheckDomain looks for the token now. A miss is not an error: the claim
+// stays pending with LastError saying what was found.
+func (h *VerificationHandler) CheckDomain(c *gin.Context) {
+    ctx := c.Request.Context()
+    companyID := c.Param("id")
+    if _, ok := companyRole(c, h.Companies, companyID, model.CompanyRoleAdmin); !ok { return }
+    d, err := h.domain(ctx, companyID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if d == nil { c.JSON(http.StatusNotFound, gin.H{"error": "no domain claimed"}); return }
+    if d.Status == DomainVerified { c.JSON(http.StatusOK, newDomainView(d)); return }
+    if d.CheckedAt != nil {
+        if wait := d.CheckedAt.Add(domainCheckInterval).Sub(time.Now()); wait > 0 {
+            c.Header("Retry-After", strconv.Itoa(int(wait.Seconds())+1))
+            c.JSON(http.StatusTooManyRequests, gin.H{"error": "checked recently, try again shortly"})
+            return
+        }
+    }
+    if err := h.checkDomain(ctx, companyID, d); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, newDomainView(d))
+}
+
+// CheckPendingDomains re-checks recent claims that are still pending and
+// returns how many it verified.
+func (h *VerificationHandler) CheckPendingDomains(ctx context.Context) (int, error) {
+    now := time.Now().UTC()
+    due, err := h.Verifications.PendingDomains(ctx, now.Add(-domainRetryWindow), now.Add(-domainCheckInterval))
+    if err != nil { return 0, err }
+    n := 0
+    for _, v := range due {
+        if err := h.checkDomain(ctx, v.CompanyID, v.Domain); err != nil { return n, err }
+        if v.Domain.Status == DomainVerified { n++ }
+    }
+    return n, nil
+}
+
+// checkDomain runs the checker for d and records the outcome, updating d in
+// place. The write is conditional on the token so a check that finishes
+// after the company claimed another domain changes nothing.
+func (h *VerificationHandler) checkDomain(ctx context.Context, companyID string, d *DomainVerification) error {
+    cctx, cancel := context.WithTimeout(ctx, domainCheckTimeout)
+    method, cerr := h.Domains.Check(cctx, d.Name, d.Token)
+    cancel()
+    now := time.Now().UTC()
+    if cerr == nil {
+        d.Status, d.Method, d.VerifiedAt, d.LastError = DomainVerified, method, &now, ""
+    } else {
+        d.LastError = cerr.Error()
+    }
+    d.CheckedAt = &now
+    recorded, err := h.Verifications.CheckedDomain(ctx, companyID, d, now)
+    if err != nil { return err }
+    if cerr == nil && recorded {
+        notifyCompany(ctx, h.Inbox, h.Companies, companyID, model.CompanyRoleAdmin, "企业域名 "+d.Name+" 已验证", "company", companyID)
+    }
+    return nil
+}
+
+// domain returns the company's claim, or nil if it has none.
+func (h *VerificationHandler) domain(ctx context.Context, companyID string) (*DomainVerification, error) {
+    v, err := h.Verifications.Get(ctx, companyID)
+    if errors.Is(err, repo.ErrNotFound) { return nil, nil }
+    if err != nil { return nil, err }
+    return v.Domain, nil
+}
+
+func domainToken() string {
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil { panic(err) }
+    return hex.EncodeToString(b)
+}
@@ -3,51 +3,133 @@ package handlers
 import (
     "context"
     "net/http"
+    "strconv"
+    "sync"
+    "time"
 
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/query"
+    "real_deal/internal/repo"
+)
+
+// exploreLimit is the default cap of each explore section; ?limit= may ask
+// for up to exploreMaxLimit.
+const (
+    exploreLimit    = 20
+    exploreMaxLimit = 50
+    exploreSections = 5
 )
 
-type ExploreHandler struct{ DB *mongo.Database }
+// ExploreSource loads the sections of the explore page, newest first and at
+// most limit items each. The handler only depends on this interface, so it can
+// run against a fake in place of the repositories.
+type ExploreSource interface {
+    Projects(ctx context.Context, limit int64) ([]Project, error)
+    Products(ctx context.Context, limit int64) ([]Product, error)
+    Posts(ctx context.Context, limit int64) ([]Post, error)
+    Jobs(ctx context.Context, limit int64) ([]Job, error)
+    Companies(ctx context.Context, limit int64) ([]Company, error)
+}
+
+// RepoExploreSource reads the sections from the repositories.
+type RepoExploreSource struct{ Repos *repo.Repos }
+
+// newest pages through a list newest first.
+func newest(limit int64) *query.Query { return &query.Query{Field: "createdAt", Desc: true, Limit: int(limit)} }
+
+// items unwraps a page, keeping ExploreSource's error-or-items contract.
+func items[T any](page *query.Page[T], err error) ([]T, error) {
+    if err != nil { return nil, err }
+    return page.Items, nil
+}
+
+func (s RepoExploreSource) Projects(ctx context.Context, limit int64) ([]Project, error) {
+    return items(s.Repos.Projects.List(ctx, newest(limit)))
+}
+
+func (s RepoExploreSource) Products(ctx context.Context, limit int64) ([]Product, error) {
+    return items(s.Repos.Products.List(ctx, newest(limit)))
+}
 
-func NewExplore(db *mongo.Database) *ExploreHandler { return &ExploreHandler{DB: db} }
+func (s RepoExploreSource) Posts(ctx context.Context, limit int64) ([]Post, error) {
+    return items(s.Repos.Posts.List(ctx, newest(limit)))
+}
 
+func (s RepoExploreSource) Jobs(ctx context.Context, limit int64) ([]Job, error) {
+    return items(s.Repos.Jobs.List(ctx, repo.JobFilter{Live: true, Now: time.Now()}, newest(limit)))
+}
+
+func (s RepoExploreSource) Companies(ctx context.Context, limit int64) ([]Company, error) {
+    return items(s.Repos.Companies.List(ctx, &query.Query{Field: "id", Limit: int(limit)}))
+}
+
+type ExploreHandler struct {
+    Source  ExploreSource
+    Timeout time.Duration
+}
+
+func NewExplore(src ExploreSource, timeout time.Duration) *ExploreHandler {
+    return &ExploreHandler{Source: src, Timeout: timeout}
+}
+
+// Get loads every section concurrently under the request context, bounded
+// by Timeout. A section that fails or runs out of time comes back empty and
+// is named in "errors"; the others are still returned. Only when every
+// section fails does the request fail, with 503.
 func (h *ExploreHandler) Get(c *gin.Context) {
-    ctx := context.Background()
-    var resp ExploreResponse
-
-    // Load collections with simple limits
-    projCur, _ := h.DB.Collection("projects").Find(ctx, bson.D{}, nil)
-    for projCur.Next(ctx) {
-        var p Project
-        _ = projCur.Decode(&p)
-        resp.Projects = append(resp.Projects, p)
+    limit := int64(exploreLimit)
+    if l := c.Query("limit"); l != "" {
+        n, err := strconv.Atoi(l)
+        if err != nil || n < 1 || n > exploreMaxLimit { c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number between 1 and 50"}); return }
+        limit = int64(n)
     }
-    prodCur, _ := h.DB.Collection("products").Find(ctx, bson.D{}, nil)
-    for prodCur.Next(ctx) {
-        var p Product
-        _ = prodCur.Decode(&p)
-        resp.Products = append(resp.Products, p)
-    }
-    postCur, _ := h.DB.Collection("posts").Find(ctx, bson.D{}, nil)
-    for postCur.Next(ctx) {
-        var p Post
-        _ = postCur.Decode(&p)
-        resp.Posts = append(resp.Posts, p)
-    }
-    jobCur, _ := h.DB.Collection("jobs").Find(ctx, bson.D{}, nil)
-    for jobCur.Next(ctx) {
-        var j Job
-        _ = jobCur.Decode(&j)
-        resp.Jobs = append(resp.Jobs, j)
-    }
-    compCur, _ := h.DB.Collection("companies").Find(ctx, bson.D{}, nil)
-    for compCur.Next(ctx) {
-        var co Company
-        _ = compCur.Decode(&co)
-        resp.Companies = append(resp.Companies, co)
+    ctx, cancel := context.WithTimeout(c.Request.Context(), h.Timeout)
+    defer cancel()
+
+    resp := ExploreResponse{Projects: []Project{}, Products: []Product{}, Posts: []Post{}, Jobs: []Job{}, Companies: []Company{}}
+    var mu sync.Mutex
+    var wg sync.WaitGroup
+    load := func(name string, fn func() error) {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            if err := fn(); err != nil {
+                mu.Lock()
+                if resp.Errors == nil { resp.Errors = map[string]string{} }
+                resp.Errors[name] = err.Error()
+                mu.Unlock()
+            }
+        }()
     }
+    // Each goroutine writes only its own field; the mutex guards Errors.
+    load("projects", func() error {
+        v, err := h.Source.Projects(ctx, limit)
+        if err == nil && v != nil { resp.Projects = v }
+        return err
+    })
+    load("products", func() error {
+        v, err := h.Source.Products(ctx, limit)
+        if err == nil && v != nil { resp.Products = v }
+        return err
+    })
+    load("posts", func() error {
+        v, err := h.Source.Posts(ctx, limit)
+        if err == nil && v != nil { resp.Posts = v }
+        return err
+    })
+    load("jobs", func() error {
+        v, err := h.Source.Jobs(ctx, limit)
+        if err == nil && v != nil { resp.Jobs = v }
+        return err
+    })
+    load("companies", func() error {
+        v, err := h.Source.Companies(ctx, limit)
+        if err == nil && v != nil { resp.Companies = v }
+        return err
+    })
+    wg.Wait()
 
+    if len(resp.Errors) == exploreSections { c.JSON(http.StatusServiceUnavailable, gin.H{"error": "explore unavailable", "errors": resp.Errors}); return }
     c.JSON(http.StatusOK, resp)
-}
\ No newline at end of file
+}
@@ -0,0 +1,104 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "testing"
+    "time"
+)
+
+// fakeExplore answers each section with one item, or as its mode says:
+// "fail" errors at once and "slow" blocks until the context is done.
+type fakeExplore struct {
+    mode  map[string]string
+    limit int64 // as asked of Jobs; one section, so no lock
+}
+
+func section[T any](f *fakeExplore, ctx context.Context, name string, item T) ([]T, error) {
+    switch f.mode[name] {
+    case "fail":
+        return nil, errors.New(name + " down")
+    case "slow":
+        <-ctx.Done()
+        return nil, ctx.Err()
+    }
+    return []T{item}, nil
+}
+
+func (f *fakeExplore) Projects(ctx context.Context, limit int64) ([]Project, error) {
+    var p Project
+    p.ID = "p1"
+    return section(f, ctx, "projects", p)
+}
+
+func (f *fakeExplore) Products(ctx context.Context, limit int64) ([]Product, error) {
+    var p Product
+    p.ID = "pr1"
+    return section(f, ctx, "products", p)
+}
+
+func (f *fakeExplore) Posts(ctx context.Context, limit int64) ([]Post, error) {
+    var p Post
+    p.ID = "po1"
+    return section(f, ctx, "posts", p)
+}
+
+func (f *fakeExplore) Jobs(ctx context.Context, limit int64) ([]Job, error) {
+    f.limit = limit
+    return section(f, ctx, "jobs", Job{ID: "j1"})
+}
+
+func (f *fakeExplore) Companies(ctx context.Context, limit int64) ([]Company, error) {
+    return section(f, ctx, "companies", Company{ID: "c1"})
+}
+
+func TestExploreFanOut(t *testing.T) {
+    all := func(mode string) map[string]string {
+        return map[string]string{"projects": mode, "products": mode, "posts": mode, "jobs": mode, "companies": mode}
+    }
+    tests := []struct {
+        name   string
+        mode   map[string]string
+        want   int
+        errors []string
+    }{
+        {name: "all load", mode: map[string]string{}, want: http.StatusOK},
+        {name: "slow section", mode: map[string]string{"posts": "slow"}, want: http.StatusOK, errors: []string{"posts"}},
+        {name: "slow and failing", mode: map[string]string{"jobs": "slow", "companies": "fail"}, want: http.StatusOK, errors: []string{"jobs", "companies"}},
+        {name: "all fail", mode: all("fail"), want: http.StatusServiceUnavailable, errors: []string{"projects", "products", "posts", "jobs", "companies"}},
+        {name: "all slow", mode: all("slow"), want: http.StatusServiceUnavailable, errors: []string{"projects", "products", "posts", "jobs", "companies"}},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            s := newTestServer(t)
+            s.router.GET("/api/explore", NewExplore(&fakeExplore{mode: tt.mode}, 50*time.Millisecond).Get)
+            start := time.Now()
+            w := s.do("GET", "/api/explore", "", "")
+            if d := time.Since(start); d > time.Second { t.Fatalf("took %s, the deadline did not hold", d) }
+            if w.Code != tt.want { t.Fatalf("got %d %s, want %d", w.Code, w.Body, tt.want) }
+            resp := decode[ExploreResponse](t, w)
+            if len(resp.Errors) != len(tt.errors) { t.Fatalf("errors = %v, want %v", resp.Errors, tt.errors) }
+            for _, name := range tt.errors {
+                if resp.Errors[name] == "" { t.Errorf("no error for %s in %v", name, resp.Errors) }
+            }
+            if w.Code != http.StatusOK { return }
+            counts := map[string]int{"projects": len(resp.Projects), "products": len(resp.Products), "posts": len(resp.Posts), "jobs": len(resp.Jobs), "companies": len(resp.Companies)}
+            for name, n := range counts {
+                want := 1
+                if _, failed := resp.Errors[name]; failed { want = 0 }
+                if n != want { t.Errorf("%s: got %d items, want %d", name, n, want) }
+            }
+        })
+    }
+}
+
+func TestExploreLimit(t *testing.T) {
+    s := newTestServer(t)
+    src := &fakeExplore{}
+    s.router.GET("/api/explore", NewExplore(src, time.Second).Get)
+    if w := s.do("GET", "/api/explore?limit=5", "", ""); w.Code != http.StatusOK || src.limit != 5 { t.Fatalf("limit=5: got %d, source asked for %d", w.Code, src.limit) }
+    for _, bad := range []string{"0", "51", "ten"} {
+        if w := s.do("GET", "/api/explore?limit="+bad, "", ""); w.Code != http.StatusBadRequest { t.Errorf("limit=%s: got %d", bad, w.Code) }
+    }
+}
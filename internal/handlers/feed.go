@@ -0,0 +1,34 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+    "strconv"
+
+    "github.com/gin-gonic/gin"
+
+    "real_deal/internal/feed"
+    "real_deal/internal/query"
+)
+
+type FeedHandler struct{ Feed *feed.Service }
+
+func NewFeed(f *feed.Service) *FeedHandler { return &FeedHandler{Feed: f} }
+
+// Get returns the caller's ranked feed (?cursor=&limit=). Anonymous callers
+// get the same deterministic ranking without personal signals. ?debug=1
+// adds each item's score breakdown.
+func (h *FeedHandler) Get(c *gin.Context) {
+    limit := query.DefaultLimit
+    if l := c.Query("limit"); l != "" {
+        n, err := strconv.Atoi(l)
+        if err != nil || n < 1 || n > query.MaxLimit { c.JSON(http.StatusBadRequest, gin.H{"error": query.ErrBadLimit.Error()}); return }
+        limit = n
+    }
+    userID := ""
+    if u := CurrentUser(c); u != nil { userID = u.ID }
+    page, err := h.Feed.Feed(c.Request.Context(), userID, c.Query("cursor"), limit, c.Query("debug") == "1")
+    if errors.Is(err, feed.ErrBadCursor) { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, page)
+}
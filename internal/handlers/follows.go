@@ -0,0 +1,38 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "real_deal/internal/repo"
+)
+
+type FollowHandler struct{ Follows repo.Follows }
+
+func NewFollow(repos *repo.Repos) *FollowHandler { return &FollowHandler{Follows: repos.Follows} }
+
+func (h *FollowHandler) List(c *gin.Context) {
+    items, err := h.Follows.List(c.Request.Context(), CurrentUser(c).ID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, items)
+}
+
+// Create follows a target; following it again is a no-op.
+func (h *FollowHandler) Create(c *gin.Context) {
+    var f Follow
+    if err := c.ShouldBindJSON(&f); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    f.UserID, f.CreatedAt = CurrentUser(c).ID, time.Now().UTC()
+    err := h.Follows.Add(c.Request.Context(), &f)
+    if err != nil && !errors.Is(err, repo.ErrDuplicate) { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, f)
+}
+
+func (h *FollowHandler) Delete(c *gin.Context) {
+    err := h.Follows.Remove(c.Request.Context(), CurrentUser(c).ID, c.Param("type"), c.Param("target"))
+    if errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.Status(http.StatusNoContent)
+}
@@ -0,0 +1,14 @@
+package handlers
+
+import (
+    "crypto/rand"
+    "encoding/hex"
+)
+
+// newID returns a server-generated id such as "user_3f9a1c0b7d2e4a61",
+// matching the prefix_suffix shape of the seeded ids.
+func newID(prefix string) string {
+    b := make([]byte, 8)
+    if _, err := rand.Read(b); err != nil { panic(err) }
+    return prefix + "_" + hex.EncodeToString(b)
+}
@@ -2,22 +2,25 @@ package handlers
 
 import (
     "context"
-    "net/http"
+    "time"
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/query"
+    "real_deal/internal/repo"
 )
 
-type InboxHandler struct{ DB *mongo.Database }
+type InboxHandler struct{ Inbox repo.Inbox }
 
-func NewInbox(db *mongo.Database) *InboxHandler { return &InboxHandler{DB: db} }
+func NewInbox(repos *repo.Repos) *InboxHandler { return &InboxHandler{Inbox: repos.Inbox} }
 
 func (h *InboxHandler) List(c *gin.Context) {
-    user := c.Query("userId")
-    ctx := context.Background()
-    cur, err := h.DB.Collection("inbox_items").Find(ctx, bson.M{"userId": user})
-    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
-    var items []map[string]any
-    for cur.Next(ctx) { var m map[string]any; _ = cur.Decode(&m); items = append(items, m) }
-    c.JSON(http.StatusOK, items)
-}
\ No newline at end of file
+    user, ok := targetUserID(c)
+    if !ok { return }
+    listWith(c, inboxSpec, func(q *query.Query) (*query.Page[InboxItem], error) { return h.Inbox.List(c.Request.Context(), user, q) })
+}
+
+// notify drops an item into a user's inbox. refType/refID point the client at
+// the object the item is about and may be empty.
+func notify(ctx context.Context, inbox repo.Inbox, userID, typ, text, refType, refID string) error {
+    return inbox.Add(ctx, &InboxItem{UserID: userID, Type: typ, Text: text, RefType: refType, RefID: refID, CreatedAt: time.Now().UTC()})
+}
@@ -1,22 +1,16 @@
 package handlers
 
 import (
-    "context"
-    "net/http"
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/query"
+    "real_deal/internal/repo"
 )
 
-type InvestorHandler struct{ DB *mongo.Database }
+type InvestorHandler struct{ Investors repo.Investors }
 
-func NewInvestor(db *mongo.Database) *InvestorHandler { return &InvestorHandler{DB: db} }
+func NewInvestor(repos *repo.Repos) *InvestorHandler { return &InvestorHandler{Investors: repos.Investors} }
 
 func (h *InvestorHandler) List(c *gin.Context) {
-    ctx := context.Background()
-    cur, err := h.DB.Collection("investor_profiles").Find(ctx, bson.D{})
-    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
-    var items []InvestorProfile
-    for cur.Next(ctx) { var ip InvestorProfile; _ = cur.Decode(&ip); items = append(items, ip) }
-    c.JSON(http.StatusOK, items)
+    listWith(c, investorSpec, func(q *query.Query) (*query.Page[InvestorProfile], error) { return h.Investors.List(c.Request.Context(), q) })
 }
\ No newline at end of file
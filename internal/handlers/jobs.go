@@ -2,22 +2,280 @@ package handlers
 
 import (
     "context"
+    "errors"
+    "fmt"
+    "log"
     "net/http"
+    "strings"
+    "time"
 
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/compliance"
+    "real_deal/internal/model"
+    "real_deal/internal/query"
+    "real_deal/internal/repo"
+    "real_deal/internal/screen"
+    "real_deal/internal/search"
 )
 
-type JobHandler struct{ DB *mongo.Database }
+// JobHandler runs Rules over every job it creates or edits and before a job
+// goes live; a job with hard findings cannot be published.
+type JobHandler struct {
+    Jobs      repo.Jobs
+    Billing   repo.Billing
+    Companies repo.Companies
+    Rules     *compliance.Engine
+    Screen    *screen.Policy
+    TTL       time.Duration
+    Search    search.Engine
+}
 
-func NewJob(db *mongo.Database) *JobHandler { return &JobHandler{DB: db} }
+func NewJob(repos *repo.Repos, rules *compliance.Engine, p *screen.Policy, ttl time.Duration, idx search.Engine) *JobHandler {
+    return &JobHandler{Jobs: repos.Jobs, Billing: repos.Billing, Companies: repos.Companies, Rules: rules, Screen: p, TTL: ttl, Search: idx}
+}
 
 func (h *JobHandler) List(c *gin.Context) {
-    ctx := context.Background()
-    cur, err := h.DB.Collection("jobs").Find(ctx, bson.D{}, nil)
-    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
-    var items []Job
-    for cur.Next(ctx) { var j Job; _ = cur.Decode(&j); items = append(items, j) }
-    c.JSON(http.StatusOK, items)
-}
\ No newline at end of file
+    listWith(c, jobSpec, func(q *query.Query) (*query.Page[Job], error) {
+        return h.Jobs.List(c.Request.Context(), repo.JobFilter{Live: true, Now: time.Now()}, q)
+    })
+}
+
+// Get returns a live job to anyone, and any job to its owner or an admin.
+func (h *JobHandler) Get(c *gin.Context) {
+    ctx := c.Request.Context()
+    j, err := h.Jobs.Get(ctx, c.Param("id"))
+    if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    live := j.Live(time.Now())
+    if u := CurrentUser(c); !live && (u == nil || (u.ID != j.OwnerID && !u.IsAdmin())) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    // best effort, like countView
+    if live { _ = h.Jobs.Bump(ctx, j.ID, "views") }
+    c.JSON(http.StatusOK, j)
+}
+
+// Mine lists the caller's jobs in every state.
+func (h *JobHandler) Mine(c *gin.Context) {
+    listWith(c, jobSpec, func(q *query.Query) (*query.Page[Job], error) {
+        return h.Jobs.List(c.Request.Context(), repo.JobFilter{OwnerID: CurrentUser(c).ID}, q)
+    })
+}
+
+// Create stores a new draft. Drafts do not use a job slot. A job posted for
+// a company needs the poster to be at least a recruiter there.
+func (h *JobHandler) Create(c *gin.Context) {
+    var j Job
+    if err := c.ShouldBindJSON(&j); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    if j.CompanyID != "" {
+        if _, ok := companyRole(c, h.Companies, j.CompanyID, model.CompanyRoleRecruiter); !ok { return }
+    }
+    now := time.Now().UTC()
+    if j.ExpiresAt != nil && !j.ExpiresAt.After(now) { c.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt must be in the future"}); return }
+    j.ExpiresAt = h.capExpiry(j.ExpiresAt, now)
+    j.ID, j.OwnerID, j.Status, j.Moderation = newID("job"), CurrentUser(c).ID, JobDraft, ""
+    j.PublishedAt, j.CreatedAt, j.UpdatedAt = nil, now, now
+    jc := h.evaluate(c.Request.Context(), &j)
+    if err := h.Jobs.Insert(c.Request.Context(), &j); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if err := h.Jobs.SetCompliance(c.Request.Context(), jc); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    j.Compliance = jc
+    reindex(c.Request.Context(), h.Search, "jobs", j.ID)
+    c.JSON(http.StatusCreated, j)
+}
+
+// Update edits a job's content (PUT replaces, PATCH merges). Lifecycle
+// fields only change through the transition endpoints, moderation only
+// through the moderation queue. An edit that would
+// leave a published job failing compliance is refused.
+func (h *JobHandler) Update(c *gin.Context) {
+    ctx := c.Request.Context()
+    cur, ok := h.loadOwned(c)
+    if !ok { return }
+    var j Job
+    if c.Request.Method == http.MethodPatch { j = copyJob(cur) }
+    if err := c.ShouldBindJSON(&j); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    if j.CompanyID != "" && j.CompanyID != cur.CompanyID {
+        if _, ok := companyRole(c, h.Companies, j.CompanyID, model.CompanyRoleRecruiter); !ok { return }
+    }
+    now := time.Now().UTC()
+    if j.ExpiresAt != nil && (cur.ExpiresAt == nil || !j.ExpiresAt.Equal(*cur.ExpiresAt)) {
+        if !j.ExpiresAt.After(now) { c.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt must be in the future"}); return }
+        j.ExpiresAt = h.capExpiry(j.ExpiresAt, now)
+    }
+    // a live job always keeps an expiry, otherwise it would hold its slot forever
+    if j.ExpiresAt == nil && cur.SlotHeld { j.ExpiresAt = cur.ExpiresAt }
+    j.ID, j.OwnerID, j.Status, j.SlotHeld, j.Moderation = cur.ID, cur.OwnerID, cur.Status, cur.SlotHeld, cur.Moderation
+    if j.Status == "" { j.Status = JobPublished }
+    j.PublishedAt, j.CreatedAt, j.UpdatedAt = cur.PublishedAt, cur.CreatedAt, now
+    jc := h.evaluate(c.Request.Context(), &j)
+    if j.Status == JobPublished && jc.Status == compliance.StatusFailed { complianceFailed(c, jc); return }
+    if err := h.Jobs.Save(ctx, &j, cur); err != nil {
+        if errors.Is(err, repo.ErrConflict) { c.JSON(http.StatusConflict, gin.H{"error": "job changed concurrently, retry"}); return }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if err := h.Jobs.SetCompliance(ctx, jc); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    j.Compliance = jc
+    reindex(ctx, h.Search, "jobs", j.ID)
+    c.JSON(http.StatusOK, j)
+}
+
+// capExpiry brings exp forward to at most TTL from now: a job may run
+// shorter than the slot it takes is sold for, not longer.
+func (h *JobHandler) capExpiry(exp *time.Time, now time.Time) *time.Time {
+    if limit := now.Add(h.TTL); exp != nil && exp.After(limit) { return &limit }
+    return exp
+}
+
+// copyJob copies j deeply enough that binding a PATCH body over the copy
+// cannot write through to j: JSON decoding reuses pointers and slices it
+// finds in place.
+func copyJob(j *Job) Job {
+    c := *j
+    if j.ExpiresAt != nil { t := *j.ExpiresAt; c.ExpiresAt = &t }
+    if j.PublishedAt != nil { t := *j.PublishedAt; c.PublishedAt = &t }
+    c.Skills = append([]string(nil), j.Skills...)
+    return c
+}
+
+// jobActions lists, per lifecycle endpoint, the states it may start from.
+// Jobs without a status (seeded) count as published.
+var jobActions = map[string]struct {
+    from []string
+    to   string
+}{
+    "publish": {[]string{JobDraft}, JobPublished},
+    "pause":   {[]string{JobPublished}, JobPaused},
+    "close":   {[]string{JobDraft, JobPublished, JobPaused}, JobClosed},
+    "reopen":  {[]string{JobPaused, JobClosed, JobExpired}, JobPublished},
+}
+
+type transitionReq struct {
+    ExpiresAt *time.Time `json:"expiresAt"`
+}
+
+func (h *JobHandler) Publish(c *gin.Context) { h.transition(c, "publish") }
+func (h *JobHandler) Pause(c *gin.Context)   { h.transition(c, "pause") }
+func (h *JobHandler) Close(c *gin.Context)   { h.transition(c, "close") }
+func (h *JobHandler) Reopen(c *gin.Context)  { h.transition(c, "reopen") }
+
+// transition moves a job between states and keeps job_slots in step: a job
+// takes one of its owner's slots when it goes live and gives it back when it
+// is closed or expires. SlotHeld on the job records which side it is on, so
+// a slot is never returned twice or returned for a seeded job.
+func (h *JobHandler) transition(c *gin.Context, action string) {
+    ctx := c.Request.Context()
+    j, ok := h.loadOwned(c)
+    if !ok { return }
+    var req transitionReq
+    if c.Request.ContentLength > 0 {
+        if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    }
+    act := jobActions[action]
+    status := j.Status
+    if status == "" { status = JobPublished }
+    allowed := false
+    for _, f := range act.from { allowed = allowed || f == status }
+    if !allowed { c.JSON(http.StatusConflict, gin.H{"error": "cannot " + action + " a " + status + " job"}); return }
+
+    now := time.Now().UTC()
+    next := *j
+    next.Status, next.UpdatedAt = act.to, now
+    next.SlotHeld = act.to == JobPublished || act.to == JobPaused
+    if act.to == JobPublished {
+        exp := j.ExpiresAt
+        if req.ExpiresAt != nil { exp = req.ExpiresAt }
+        if exp == nil || !exp.After(now) {
+            if req.ExpiresAt != nil { c.JSON(http.StatusBadRequest, gin.H{"error": "expiresAt must be in the future"}); return }
+            t := now.Add(h.TTL)
+            exp = &t
+        }
+        next.ExpiresAt = h.capExpiry(exp, now)
+        if j.PublishedAt == nil { next.PublishedAt = &now }
+        // the rules may have changed since the job was last edited
+        jc := h.evaluate(ctx, j)
+        if err := h.Jobs.SetCompliance(ctx, jc); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+        if jc.Status == compliance.StatusFailed { complianceFailed(c, jc); return }
+    }
+
+    took := false
+    if next.SlotHeld && !j.SlotHeld {
+        if err := h.Billing.TakeJobSlot(ctx, j.OwnerID); err != nil {
+            if errors.Is(err, repo.ErrNoJobSlots) { c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error(), "code": "job_slots_exhausted"}); return }
+            c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+            return
+        }
+        took = true
+    }
+    if err := h.Jobs.Save(ctx, &next, j); err != nil {
+        if took { _ = h.Billing.ReleaseJobSlot(ctx, j.OwnerID) }
+        if errors.Is(err, repo.ErrConflict) { c.JSON(http.StatusConflict, gin.H{"error": "job changed concurrently, retry"}); return }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    if j.SlotHeld && !next.SlotHeld {
+        if err := h.Billing.ReleaseJobSlot(ctx, j.OwnerID); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    }
+    c.JSON(http.StatusOK, next)
+}
+
+// ExpireDue moves live jobs past their expiry to expired and frees their
+// slots. It is safe to run from several instances at once: each job's update
+// is conditional on the state it was read in.
+func (h *JobHandler) ExpireDue(ctx context.Context) (int, error) {
+    due, err := h.Jobs.Due(ctx, time.Now())
+    if err != nil { return 0, err }
+    n := 0
+    for i := range due {
+        j := &due[i]
+        next := *j
+        next.Status, next.SlotHeld, next.UpdatedAt = JobExpired, false, time.Now().UTC()
+        if err := h.Jobs.Save(ctx, &next, j); err != nil {
+            if errors.Is(err, repo.ErrConflict) { continue }
+            return n, err
+        }
+        n++
+        if j.SlotHeld {
+            if err := h.Billing.ReleaseJobSlot(ctx, j.OwnerID); err != nil { return n, err }
+        }
+    }
+    return n, nil
+}
+
+// evaluate runs the compliance rules and the screener, whose verdict is
+// recorded as the "screening" rule: hard when it would reject the text,
+// a warning when it would flag it. Notes lists the hard findings before
+// the warnings.
+func (h *JobHandler) evaluate(ctx context.Context, j *Job) *JobCompliance {
+    p := j.Posting()
+    res := h.Rules.Evaluate(p)
+    v, action, err := h.Screen.Decide(ctx, &screen.Input{Type: "job", ID: j.ID, Title: p.Title, Text: p.Description + "\n" + strings.Join(p.Skills, " ")})
+    if err != nil { log.Printf("screen job %s: %v", j.ID, err) }
+    var findings []compliance.Finding
+    if sev := map[string]string{screen.Reject: compliance.Hard, screen.Review: compliance.Warn}[action]; sev != "" {
+        reasons := v.Reasons
+        if len(reasons) == 0 { reasons = []string{fmt.Sprintf("score %.2f", v.Score)} }
+        for _, r := range reasons {
+            findings = append(findings, compliance.Finding{Severity: sev, Field: "description", Message: "screening flagged " + r})
+        }
+    }
+    res.Add("screening", findings)
+    now := time.Now().UTC()
+    notes := append(res.Messages(compliance.Hard), res.Messages(compliance.Warn)...)
+    return &JobCompliance{JobID: j.ID, Status: res.Status, Notes: strings.Join(notes, "; "), Rules: res.Rules, CheckedAt: &now}
+}
+
+func complianceFailed(c *gin.Context, jc *JobCompliance) {
+    c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "job fails compliance checks: " + jc.Notes, "code": "compliance_failed", "compliance": jc})
+}
+
+func (h *JobHandler) loadOwned(c *gin.Context) (*Job, bool) { return loadOwnedJob(c, h.Jobs) }
+
+// loadOwnedJob fetches the job named by :id if the caller owns it or is an
+// admin.
+func loadOwnedJob(c *gin.Context, jobs repo.Jobs) (*Job, bool) {
+    j, err := jobs.Get(c.Request.Context(), c.Param("id"))
+    if errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return nil, false }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return nil, false }
+    u := CurrentUser(c)
+    if j.OwnerID != u.ID && !u.IsAdmin() { c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"}); return nil, false }
+    return j, true
+}
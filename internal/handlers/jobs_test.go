@@ -0,0 +1,68 @@
+package handlers
+
+import (
+    "fmt"
+    "net/http"
+    "testing"
+    "time"
+
+    "real_deal/internal/compliance"
+    "real_deal/internal/model"
+    "real_deal/internal/rbac"
+    "real_deal/internal/screen"
+    "real_deal/internal/search"
+)
+
+// A job never runs longer than JOB_TTL from when its expiry was last set.
+func TestJobExpiryCapped(t *testing.T) {
+    s := newTestServer(t)
+    tokenizer, _ := search.NewTokenizer("")
+    ttl := 30 * 24 * time.Hour
+    h := NewJob(s.repos, compliance.Default(), &screen.Policy{Screener: screen.Chain{}, RejectAt: 0.9, ReviewAt: 0.5}, ttl, search.NewMemory(s.repos.Documents, tokenizer))
+    me := s.router.Group("/api", Authenticate(s.repos.Users, s.sessions), RequireUser())
+    me.POST("/jobs", h.Create)
+    me.PATCH("/jobs/:id", h.Update)
+    me.POST("/jobs/:id/publish", h.Publish)
+    me.POST("/jobs/:id/close", h.Close)
+    me.POST("/jobs/:id/reopen", h.Reopen)
+    tok := s.login("r1", rbac.RoleRecruiter)
+    s.mem.JobSlots["r1"] = model.JobSlot{UserID: "r1", Slots: 5}
+
+    at := func(d time.Duration) string { return time.Now().Add(d).UTC().Format(time.RFC3339) }
+    check := func(what string, code, wantCode int, j Job, want time.Duration) {
+        t.Helper()
+        if code != wantCode { t.Fatalf("%s: got %d, want %d", what, code, wantCode) }
+        if j.ExpiresAt == nil { t.Fatalf("%s: no expiresAt", what) }
+        if d := time.Until(*j.ExpiresAt) - want; d < -time.Minute || d > time.Minute { t.Fatalf("%s: expires in %v, want %v", what, time.Until(*j.ExpiresAt), want) }
+    }
+    job := `"title":"后端工程师","description":"负责交易系统。","location":"北京","level":"高级","salary":"35k-45k/月","skills":["Go"]`
+
+    w := s.do("POST", "/api/jobs", tok, fmt.Sprintf(`{%s,"expiresAt":%q}`, job, at(10*365*24*time.Hour)))
+    j := decode[Job](t, w)
+    check("create far out", w.Code, http.StatusCreated, j, ttl)
+
+    w = s.do("PATCH", "/api/jobs/"+j.ID, tok, fmt.Sprintf(`{"expiresAt":%q}`, at(365*24*time.Hour)))
+    check("update far out", w.Code, http.StatusOK, decode[Job](t, w), ttl)
+    w = s.do("PATCH", "/api/jobs/"+j.ID, tok, fmt.Sprintf(`{"expiresAt":%q}`, at(7*24*time.Hour)))
+    check("update within", w.Code, http.StatusOK, decode[Job](t, w), 7*24*time.Hour)
+    if w := s.do("PATCH", "/api/jobs/"+j.ID, tok, fmt.Sprintf(`{"expiresAt":%q}`, at(-time.Hour))); w.Code != http.StatusBadRequest { t.Fatalf("past expiry: got %d", w.Code) }
+
+    w = s.do("POST", "/api/jobs/"+j.ID+"/publish", tok, fmt.Sprintf(`{"expiresAt":%q}`, at(90*24*time.Hour)))
+    check("publish far out", w.Code, http.StatusOK, decode[Job](t, w), ttl)
+
+    // A job published without an expiry gets the full TTL.
+    w = s.do("POST", "/api/jobs", tok, "{"+job+"}")
+    j2 := decode[Job](t, w)
+    if j2.ExpiresAt != nil { t.Fatalf("draft got expiresAt %v", j2.ExpiresAt) }
+    w = s.do("POST", "/api/jobs/"+j2.ID+"/publish", tok, "")
+    check("publish default", w.Code, http.StatusOK, decode[Job](t, w), ttl)
+
+    // A job stored with a longer expiry, such as a seeded one, is capped
+    // when it goes live again.
+    far := time.Now().Add(5 * 365 * 24 * time.Hour)
+    stored := s.mem.Jobs[j2.ID]
+    stored.Status, stored.ExpiresAt, stored.SlotHeld = JobClosed, &far, false
+    s.mem.Jobs[j2.ID] = stored
+    w = s.do("POST", "/api/jobs/"+j2.ID+"/reopen", tok, "")
+    check("reopen", w.Code, http.StatusOK, decode[Job](t, w), ttl)
+}
@@ -1,22 +1,21 @@
 package handlers
 
 import (
-    "context"
     "net/http"
+
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/repo"
 )
 
-type JobSlotHandler struct{ DB *mongo.Database }
+type JobSlotHandler struct{ Billing repo.Billing }
 
-func NewJobSlot(db *mongo.Database) *JobSlotHandler { return &JobSlotHandler{DB: db} }
+func NewJobSlot(repos *repo.Repos) *JobSlotHandler { return &JobSlotHandler{Billing: repos.Billing} }
 
 func (h *JobSlotHandler) Get(c *gin.Context) {
-    user := c.Query("userId")
-    ctx := context.Background()
-    var js JobSlot
-    err := h.DB.Collection("job_slots").FindOne(ctx, bson.M{"userId": user}).Decode(&js)
+    user, ok := targetUserID(c)
+    if !ok { return }
+    js, err := h.Billing.JobSlots(c.Request.Context(), user)
     if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
     c.JSON(http.StatusOK, js)
-}
\ No newline at end of file
+}
@@ -0,0 +1,59 @@
+package handlers
+
+import (
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+
+    "real_deal/internal/query"
+)
+
+// Specs for the list endpoints: which query parameters filter on which
+// fields, and which sort keys are allowed.
+var (
+    contentSpec = query.Spec{
+        Filters:     map[string]string{"tags": "tags", "authorId": "authorId"},
+        Sorts:       map[string]string{"createdAt": "createdAt", "updatedAt": "updatedAt"},
+        DefaultSort: "-createdAt",
+    }
+    jobSpec = query.Spec{
+        Filters:     map[string]string{"level": "level", "location": "location", "skills": "skills", "ownerId": "ownerId", "status": "status", "companyId": "companyId"},
+        Sorts:       map[string]string{"createdAt": "createdAt", "updatedAt": "updatedAt", "publishedAt": "publishedAt", "expiresAt": "expiresAt", "title": "title"},
+        DefaultSort: "-createdAt",
+    }
+    investorSpec = query.Spec{
+        Filters:     map[string]string{"stages": "stages", "regions": "regions"},
+        Sorts:       map[string]string{"name": "name", "id": "id"},
+        DefaultSort: "name",
+    }
+    mediaSpec = query.Spec{
+        Filters:     map[string]string{"type": "type", "ownerId": "ownerId"},
+        Sorts:       map[string]string{"createdAt": "createdAt", "title": "title"},
+        DefaultSort: "-createdAt",
+    }
+    inboxSpec = query.Spec{
+        Filters:     map[string]string{"type": "type"},
+        Sorts:       map[string]string{"createdAt": "createdAt"},
+        DefaultSort: "-createdAt",
+    }
+    billingSpec = query.Spec{
+        Sorts:       map[string]string{"id": "id"},
+        DefaultSort: "-id",
+    }
+    applicationSpec = query.Spec{
+        Filters:     map[string]string{"stage": "stage", "jobId": "jobId"},
+        Sorts:       map[string]string{"createdAt": "createdAt", "updatedAt": "updatedAt"},
+        DefaultSort: "-updatedAt",
+    }
+)
+
+// listWith answers a list request with one page from list, which gets the
+// query parsed from the request's filters, in the `{items, nextCursor,
+// total}` envelope.
+func listWith[T any](c *gin.Context, spec query.Spec, list func(*query.Query) (*query.Page[T], error)) {
+    q, err := query.Parse(c.Request.URL.Query(), spec)
+    if err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    page, err := list(q)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, page)
+}
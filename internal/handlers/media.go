@@ -1,26 +1,96 @@
 package handlers
 
 import (
-    "context"
+    "errors"
+    "io"
     "net/http"
+    "path"
+    "strings"
     "time"
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+    "real_deal/internal/config"
+    "real_deal/internal/model"
+    "real_deal/internal/repo"
     "real_deal/internal/storage"
 )
 
-type MediaHandler struct{ DB *mongo.Database; Store storage.Store }
+// hlsURLTTL is how long the segment URLs in a served playlist stay valid,
+// long enough to watch a video through.
+const hlsURLTTL = 6 * time.Hour
 
-func NewMedia(db *mongo.Database, st storage.Store) *MediaHandler { return &MediaHandler{DB: db, Store: st} }
+type MediaHandler struct{ Media repo.MediaAssets; Billing repo.Billing; Store storage.Store; Cfg *config.Config }
+
+func NewMedia(repos *repo.Repos, st storage.Store, cfg *config.Config) *MediaHandler { return &MediaHandler{Media: repos.Media, Billing: repos.Billing, Store: st, Cfg: cfg} }
 
 func (h *MediaHandler) Get(c *gin.Context) {
-    id := c.Param("id")
-    ctx := context.Background()
-    var m MediaAsset
-    err := h.DB.Collection("media_assets").FindOne(ctx, bson.M{"id": id}).Decode(&m)
-    if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    ctx := c.Request.Context()
+    m, ok := h.load(c)
+    if !ok { return }
     url, err := h.Store.Presign(ctx, m.Key, 15*time.Minute)
     if err == nil { m.ContentURL = url }
+    if t := m.Transcode; t != nil && t.Status == model.TranscodeDone {
+        t.PlaylistURL = h.Cfg.PublicAPIURL + "/api/media/" + m.ID + "/hls/master.m3u8"
+        if url, err := h.Store.Presign(ctx, t.Poster, 15*time.Minute); err == nil { t.PosterURL = url }
+    }
     c.JSON(http.StatusOK, m)
-}
\ No newline at end of file
+}
+
+// HLS serves a transcoded video's playlists. Stored playlists refer to
+// segments by relative path; those are swapped for presigned URLs, while
+// the master playlist's relative links lead back here.
+func (h *MediaHandler) HLS(c *gin.Context) {
+    ctx := c.Request.Context()
+    m, ok := h.load(c)
+    if !ok { return }
+    file := strings.TrimPrefix(c.Param("file"), "/")
+    if m.Transcode == nil || m.Transcode.Status != model.TranscodeDone || path.Ext(file) != ".m3u8" || path.Clean(file) != file || strings.HasPrefix(file, "../") { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    dir := path.Dir(m.Transcode.Playlist)
+    key := path.Join(dir, file)
+    r, _, err := h.Store.Get(ctx, key)
+    if errors.Is(err, storage.ErrNotExist) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    defer r.Close()
+    b, err := io.ReadAll(r)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    lines := strings.Split(string(b), "\n")
+    for i, line := range lines {
+        line = strings.TrimSpace(line)
+        if line == "" || strings.HasPrefix(line, "#") || strings.HasSuffix(line, ".m3u8") { continue }
+        url, err := h.Store.Presign(ctx, path.Join(path.Dir(key), line), hlsURLTTL)
+        if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+        lines[i] = url
+    }
+    c.Data(http.StatusOK, "application/vnd.apple.mpegurl", []byte(strings.Join(lines, "\n")))
+}
+
+// Delete removes an asset, its stored object and any transcoded output, and
+// gives the space back to the owner's storage quota. Owners and moderators
+// may delete.
+func (h *MediaHandler) Delete(c *gin.Context) {
+    ctx := c.Request.Context()
+    m, err := h.Media.Get(ctx, c.Param("id"))
+    if err != nil || !canSeeRejected(c, m.OwnerID) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    if err := h.Store.Delete(ctx, m.Key); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if m.Transcode != nil {
+        if _, err := storage.DeleteAll(ctx, h.Store, "transcodes/"+m.ID+"/"); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    }
+    if err := h.Media.Delete(ctx, m.ID); errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return } else if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if m.Size > 0 {
+        if err := h.Billing.ReleaseStorage(ctx, m.OwnerID, gigabytes(m.Size)); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    }
+    c.Status(http.StatusNoContent)
+}
+
+// load returns the asset, hiding rejected ones from all but their owner and
+// moderators, and documents from all but their owner and admins (recruiters
+// get resumes through GET /applications/:id/resume).
+func (h *MediaHandler) load(c *gin.Context) (*MediaAsset, bool) {
+    m, err := h.Media.Get(c.Request.Context(), c.Param("id"))
+    if err != nil || m.Moderation == ModerationRejected && !canSeeRejected(c, m.OwnerID) || !publicMediaTypes[m.Type] && !canSeePrivate(c, m.OwnerID) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return nil, false }
+    return m, true
+}
+
+func canSeePrivate(c *gin.Context, ownerID string) bool {
+    u := CurrentUser(c)
+    return u != nil && (u.ID == ownerID || u.IsAdmin())
+}
@@ -1,22 +1,46 @@
 package handlers
 
 import (
-    "context"
-    "net/http"
     "github.com/gin-gonic/gin"
     "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/query"
+    "real_deal/internal/repo"
 )
 
-type MediaAssetsHandler struct{ DB *mongo.Database }
+// publicMediaTypes are the media types anyone may list. Documents are
+// resumes: they are listed only to their owner, and reach recruiters through
+// the applications that reference them.
+var publicMediaTypes = map[string]bool{"image": true, "video": true}
+
+type MediaAssetsHandler struct{ Media repo.MediaAssets }
 
-func NewMediaAssets(db *mongo.Database) *MediaAssetsHandler { return &MediaAssetsHandler{DB: db} }
+func NewMediaAssets(repos *repo.Repos) *MediaAssetsHandler { return &MediaAssetsHandler{Media: repos.Media} }
 
+// List lists media assets. Unless the caller asks for their own
+// (ownerId=<their id>), only public types are listed.
 func (h *MediaAssetsHandler) List(c *gin.Context) {
-    ctx := context.Background()
-    cur, err := h.DB.Collection("media_assets").Find(ctx, bson.D{})
-    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
-    var items []MediaAsset
-    for cur.Next(ctx) { var m MediaAsset; _ = cur.Decode(&m); items = append(items, m) }
-    c.JSON(http.StatusOK, items)
-}
\ No newline at end of file
+    listWith(c, mediaSpec, func(q *query.Query) (*query.Page[MediaAsset], error) {
+        if u := CurrentUser(c); u == nil || q.Filter["ownerId"] != u.ID { publicTypes(q) }
+        return h.Media.List(c.Request.Context(), q)
+    })
+}
+
+// publicTypes narrows q's type filter to publicMediaTypes; asking only for
+// private types matches nothing.
+func publicTypes(q *query.Query) {
+    var asked bson.A
+    switch v := q.Filter["type"].(type) {
+    case nil:
+        for t := range publicMediaTypes { asked = append(asked, t) }
+    case bson.M:
+        asked, _ = v["$in"].(bson.A)
+    default:
+        asked = bson.A{v}
+    }
+    types := bson.A{}
+    for _, t := range asked {
+        if s, _ := t.(string); publicMediaTypes[s] { types = append(types, s) }
+    }
+    q.Filter["type"] = bson.M{"$in": types}
+}
@@ -0,0 +1,72 @@
+package handlers
+
+import (
+    "net/http"
+    "testing"
+    "time"
+
+    "real_deal/internal/config"
+    "real_deal/internal/model"
+    "real_deal/internal/storage"
+)
+
+func TestResumesArePrivate(t *testing.T) {
+    s := newTestServer(t)
+    st := storage.NewMemory("http://localhost/objects", "secret")
+    media := NewMedia(s.repos, st, &config.Config{})
+    apps := NewApplication(s.repos, st, nil)
+    api := s.router.Group("/api", Authenticate(s.repos.Users, s.sessions))
+    api.GET("/media/:id", media.Get)
+    api.GET("/media-assets", NewMediaAssets(s.repos).List)
+    api.GET("/applications/:id/resume", RequireUser(), apps.Resume)
+
+    candidate := s.login("cand", "candidate")
+    recruiter := s.login("rec", "recruiter")
+    otherRecruiter := s.login("rec2", "recruiter")
+    admin := s.login("admin", "admin")
+    now := time.Now()
+    s.mem.Media["cv"] = model.MediaAsset{ID: "cv", OwnerID: "cand", Type: "document", Key: "uploads/cand/cv.pdf", CreatedAt: now}
+    s.mem.Media["logo"] = model.MediaAsset{ID: "logo", OwnerID: "cand", Type: "image", Key: "uploads/cand/logo.png", CreatedAt: now}
+    s.mem.Applications["a1"] = model.Application{ID: "a1", JobID: "j1", CandidateID: "cand", RecruiterID: "rec", ResumeID: "cv", Stage: "applied"}
+
+    list := func(tok, query string) []string {
+        t.Helper()
+        w := s.do("GET", "/api/media-assets"+query, tok, "")
+        if w.Code != http.StatusOK { t.Fatalf("list%s: got %d %s", query, w.Code, w.Body) }
+        var ids []string
+        for _, m := range decode[struct{ Items []MediaAsset `json:"items"` }](t, w).Items { ids = append(ids, m.ID) }
+        return ids
+    }
+    for _, tt := range []struct {
+        tok, query string
+        want       int
+    }{
+        {"", "", 1},
+        {recruiter, "", 1},
+        {recruiter, "?type=document", 0},
+        {recruiter, "?ownerId=cand&type=document,image", 1},
+        {candidate, "", 1},
+        {candidate, "?ownerId=cand", 2},
+        {candidate, "?ownerId=cand&type=document", 1},
+    } {
+        if ids := list(tt.tok, tt.query); len(ids) != tt.want { t.Errorf("list%s: got %v, want %d items", tt.query, ids, tt.want) }
+    }
+
+    for _, tt := range []struct {
+        who, tok, path string
+        want           int
+    }{
+        {"anonymous", "", "/api/media/cv", http.StatusNotFound},
+        {"recruiter", recruiter, "/api/media/cv", http.StatusNotFound},
+        {"owner", candidate, "/api/media/cv", http.StatusOK},
+        {"admin", admin, "/api/media/cv", http.StatusOK},
+        {"anonymous", "", "/api/media/logo", http.StatusOK},
+        {"recruiter", recruiter, "/api/applications/a1/resume", http.StatusOK},
+        {"candidate", candidate, "/api/applications/a1/resume", http.StatusOK},
+        {"other recruiter", otherRecruiter, "/api/applications/a1/resume", http.StatusNotFound},
+    } {
+        w := s.do("GET", tt.path, tt.tok, "")
+        if w.Code != tt.want { t.Errorf("%s GET %s: got %d, want %d", tt.who, tt.path, w.Code, tt.want); continue }
+        if w.Code == http.StatusOK && decode[MediaAsset](t, w).ContentURL == "" { t.Errorf("%s GET %s: no content URL", tt.who, tt.path) }
+    }
+}
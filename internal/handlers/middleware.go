@@ -0,0 +1,82 @@
+package handlers
+
+import (
+    "errors"
+    "net/http"
+
+    "github.com/gin-gonic/gin"
+    "real_deal/internal/rbac"
+    "real_deal/internal/repo"
+    "real_deal/internal/session"
+)
+
+const (
+    ctxUser    = "user"
+    ctxSession = "session"
+)
+
+// Authenticate resolves the session cookie on every request and, if it maps
+// to a live session and an existing user, stores both in the gin.Context.
+// Anonymous requests pass through; use RequireUser to reject them.
+func Authenticate(users repo.Users, sessions session.Store) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        tok, err := c.Cookie(sessionCookie)
+        if err != nil || tok == "" { c.Next(); return }
+        ctx := c.Request.Context()
+        sess, err := sessions.Get(ctx, tok)
+        if errors.Is(err, session.ErrNotFound) { c.Next(); return }
+        if err != nil { c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+        u, err := users.Get(ctx, sess.UserID)
+        if errors.Is(err, repo.ErrNotFound) { c.Next(); return }
+        if err != nil { c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+        c.Set(ctxSession, sess)
+        c.Set(ctxUser, u)
+        c.Next()
+    }
+}
+
+// RequireUser rejects requests that Authenticate could not attach a user to.
+func RequireUser() gin.HandlerFunc {
+    return func(c *gin.Context) {
+        if CurrentUser(c) == nil { c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauth"}); return }
+        c.Next()
+    }
+}
+
+// Require rejects requests whose user's role does not grant p: 401 when
+// there is no user, 403 when the role lacks the permission.
+func Require(p rbac.Permission) gin.HandlerFunc {
+    return func(c *gin.Context) {
+        u := CurrentUser(c)
+        if u == nil { c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauth"}); return }
+        if !u.Can(p) { c.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden", "permission": p}); return }
+        c.Next()
+    }
+}
+
+// CurrentUser returns the authenticated user or nil.
+func CurrentUser(c *gin.Context) *User {
+    v, ok := c.Get(ctxUser)
+    if !ok { return nil }
+    u, _ := v.(*User)
+    return u
+}
+
+func currentSession(c *gin.Context) *session.Session {
+    v, ok := c.Get(ctxSession)
+    if !ok { return nil }
+    s, _ := v.(*session.Session)
+    return s
+}
+
+// targetUserID picks whose data a per-user endpoint reads: the caller by
+// default, or ?userId= when the caller is an admin. It writes the error
+// response itself and returns false when the request must stop.
+func targetUserID(c *gin.Context) (string, bool) {
+    u := CurrentUser(c)
+    if u == nil { c.JSON(http.StatusUnauthorized, gin.H{"error": "unauth"}); return "", false }
+    id := c.Query("userId")
+    if id == "" || id == u.ID { return u.ID, true }
+    if !u.IsAdmin() { c.JSON(http.StatusForbidden, gin.H{"error": "forbidden"}); return "", false }
+    return id, true
+}
@@ -2,21 +2,325 @@ package handlers
 
 import (
     "context"
+    "errors"
+    "log"
     "net/http"
+    "strings"
+    "time"
+
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/query"
+    "real_deal/internal/rbac"
+    "real_deal/internal/repo"
+    "real_deal/internal/screen"
 )
 
-type ModerationHandler struct{ DB *mongo.Database }
+// moderationClaimTTL is how long a claim keeps other reviewers off an item.
+const moderationClaimTTL = 30 * time.Minute
+
+var moderationSpec = query.Spec{
+    Filters:     map[string]string{"status": "status", "contentType": "contentType", "claimedBy": "claimedBy", "authorId": "authorId"},
+    Sorts:       map[string]string{"createdAt": "createdAt", "updatedAt": "updatedAt", "score": "score", "reports": "reports"},
+    DefaultSort: "createdAt",
+}
+
+// screenerID is recorded as the reviewer of automatic rejections.
+const screenerID = "system:screener"
+
+// ModerationQueue admits new and edited content into moderation. Content is
+// screened first: a high-confidence violation is rejected on the spot, a
+// borderline one is queued with the screener's reasons in Notes.
+type ModerationQueue struct {
+    Moderation repo.Moderation
+    Reports    repo.Reports
+    Inbox      repo.Inbox
+    Projects   repo.Content[Project]
+    Products   repo.Content[Product]
+    Posts      repo.Content[Post]
+    Media      repo.MediaAssets
+    Jobs       repo.Jobs
+    Users      repo.Users
+    Screen     *screen.Policy
+}
+
+func NewModerationQueue(repos *repo.Repos, p *screen.Policy) *ModerationQueue {
+    return &ModerationQueue{
+        Moderation: repos.Moderation, Reports: repos.Reports, Inbox: repos.Inbox,
+        Projects: repos.Projects, Products: repos.Products, Posts: repos.Posts,
+        Media: repos.Media, Jobs: repos.Jobs, Users: repos.Users, Screen: p,
+    }
+}
+
+// ModerationHandler runs the review queue. Content that passes screening
+// is queued as pending and stays visible; reviewers claim an item and
+// approve, reject or escalate it to an admin. Rejected content is hidden
+// everywhere but from its author, who may appeal once; a different reviewer
+// decides the appeal.
+type ModerationHandler struct {
+    *ModerationQueue
+}
+
+func NewModeration(q *ModerationQueue) *ModerationHandler { return &ModerationHandler{q} }
 
-func NewModeration(db *mongo.Database) *ModerationHandler { return &ModerationHandler{DB: db} }
+// Submit screens content and puts it in the queue, or back into it when
+// approved content is edited. A pending item is re-screened in place, and
+// rejected content stays rejected: its author appeals instead. It reports
+// whether the screener rejected the content.
+func (q *ModerationQueue) Submit(ctx context.Context, in *screen.Input, authorID string) (bool, error) {
+    v, action, err := q.Screen.Decide(ctx, in)
+    if err != nil { log.Printf("screen %s %s: %v", in.Type, in.ID, err) }
+    now := time.Now().UTC()
+    status, notes := ModerationPending, strings.Join(v.Reasons, "; ")
+    if action == screen.Allow { notes = "" }
+    if action == screen.Reject { status = ModerationRejected }
+    events := func(first string) []ModerationEvent {
+        evs := []ModerationEvent{{Action: first, By: authorID, At: now}}
+        if action == screen.Reject { evs = append(evs, ModerationEvent{Action: "rejected", By: screenerID, Note: notes, At: now}) }
+        return evs
+    }
+    r := &ContentModeration{
+        ContentID: in.ID, ContentType: in.Type, AuthorID: authorID, Title: in.Title, Status: status, Score: v.Score, Notes: notes,
+        History: events("submitted"), CreatedAt: &now, UpdatedAt: &now,
+    }
+    if action == screen.Reject { r.ReviewerID, r.ReviewedAt = screenerID, &now }
 
+    found, err := q.Moderation.Resubmit(ctx, r, events("edited"))
+    if err != nil { return false, err }
+    if !found {
+        opened, err := q.Moderation.Open(ctx, r)
+        if err != nil || !opened { return false, err }
+    }
+    if action != screen.Reject { return false, nil }
+    if err := q.setModeration(ctx, in.Type, in.ID, ModerationRejected); err != nil { return false, err }
+    if authorID != "" { _ = notify(ctx, q.Inbox, authorID, "moderation", "你的内容未通过自动审核，可提交申诉："+notes, "content_moderation", in.ID) }
+    return true, nil
+}
+
+// reportsID is recorded as the actor when reports push an item into the
+// queue.
+const reportsID = "system:reports"
+
+// Flag puts a reported target in front of reviewers; the caller decides
+// when enough reports are open. Unqueued or approved targets become pending
+// with note in Notes; items already in the queue only have their report
+// count updated.
+func (q *ModerationQueue) Flag(ctx context.Context, typ, id, authorID, title string, reports int, note string) error {
+    now := time.Now().UTC()
+    ev := ModerationEvent{Action: "reported", By: reportsID, Note: note, At: now}
+    return q.Moderation.Flag(ctx, &ContentModeration{
+        ContentID: id, ContentType: typ, AuthorID: authorID, Title: title, Status: ModerationPending, Notes: note, Reports: reports,
+        History: []ModerationEvent{ev}, CreatedAt: &now, UpdatedAt: &now,
+    }, ev)
+}
+
+// SubmitMedia screens an asset's metadata and queues it like content.
+func (q *ModerationQueue) SubmitMedia(ctx context.Context, a *MediaAsset) (bool, error) {
+    in := &screen.Input{Type: "media", ID: a.ID, Title: a.Title, Meta: map[string]string{"type": a.Type, "key": a.Key}}
+    return q.Submit(ctx, in, a.OwnerID)
+}
+
+// Content returns the record for a piece of content to reviewers and to its
+// author.
 func (h *ModerationHandler) Content(c *gin.Context) {
-    id := c.Param("id")
-    ctx := context.Background()
-    var v ContentModeration
-    err := h.DB.Collection("content_moderation").FindOne(ctx, bson.M{"contentId": id}).Decode(&v)
-    if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
-    c.JSON(http.StatusOK, v)
-}
\ No newline at end of file
+    r, ok := h.load(c)
+    if !ok { return }
+    if u := CurrentUser(c); !u.Can(rbac.ModerationReview) && (r.AuthorID == "" || r.AuthorID != u.ID) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    c.JSON(http.StatusOK, r)
+}
+
+// Queue lists records for reviewers, oldest first; ?status=pending is the
+// work queue, ?status=appealed the appeals.
+func (h *ModerationHandler) Queue(c *gin.Context) {
+    listWith(c, moderationSpec, func(q *query.Query) (*query.Page[ContentModeration], error) {
+        return h.Moderation.List(c.Request.Context(), q)
+    })
+}
+
+// Claim reserves an item for the caller for moderationClaimTTL.
+func (h *ModerationHandler) Claim(c *gin.Context) {
+    r, ok := h.load(c)
+    if !ok || !h.reviewable(c, r) { return }
+    if h.save(c, r, func(n *ContentModeration) { n.ClaimedBy, n.ClaimedAt = CurrentUser(c).ID, n.UpdatedAt }, ModerationEvent{Action: "claimed"}) { c.JSON(http.StatusOK, r) }
+}
+
+// Release gives up the caller's claim.
+func (h *ModerationHandler) Release(c *gin.Context) {
+    r, ok := h.load(c)
+    if !ok { return }
+    u := CurrentUser(c)
+    if r.ClaimedBy == "" || r.ClaimedBy != u.ID && !u.IsAdmin() { c.JSON(http.StatusConflict, gin.H{"error": "not claimed by you"}); return }
+    if h.save(c, r, func(n *ContentModeration) { n.ClaimedBy = "" }, ModerationEvent{Action: "released"}) { c.JSON(http.StatusOK, r) }
+}
+
+type moderationNote struct {
+    Notes string `json:"notes" binding:"max=2000"`
+}
+
+type moderationNoteRequired struct {
+    Notes string `json:"notes" binding:"required,max=2000"`
+}
+
+// Approve makes the content visible; on an appeal it overturns the
+// rejection.
+func (h *ModerationHandler) Approve(c *gin.Context) {
+    var req moderationNote
+    if c.Request.ContentLength > 0 {
+        if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    }
+    h.decide(c, ModerationApproved, req.Notes)
+}
+
+// Reject hides the content; on an appeal it upholds the rejection, which is
+// then final.
+func (h *ModerationHandler) Reject(c *gin.Context) {
+    var req moderationNoteRequired
+    if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    h.decide(c, ModerationRejected, req.Notes)
+}
+
+// Escalate hands an item to the admins, releasing the claim.
+func (h *ModerationHandler) Escalate(c *gin.Context) {
+    var req moderationNoteRequired
+    if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    r, ok := h.load(c)
+    if !ok || !h.reviewable(c, r) { return }
+    if r.Status == ModerationEscalated { c.JSON(http.StatusConflict, gin.H{"error": "already escalated"}); return }
+    escalate := func(n *ContentModeration) { n.Status, n.Notes, n.ClaimedBy = ModerationEscalated, req.Notes, "" }
+    if h.save(c, r, escalate, ModerationEvent{Action: "escalated", Note: req.Notes}) { c.JSON(http.StatusOK, r) }
+}
+
+// decide records a reviewer's decision, shows or hides the content to match
+// and tells the author about a rejection or an appeal outcome.
+func (h *ModerationHandler) decide(c *gin.Context, status, notes string) {
+    ctx := c.Request.Context()
+    r, ok := h.load(c)
+    if !ok || !h.reviewable(c, r) { return }
+    appeal := r.Appeal != nil && r.Appeal.Outcome == ""
+    set := func(n *ContentModeration) {
+        n.Status, n.ReviewerID, n.ReviewedAt, n.Notes, n.Reports, n.ClaimedBy = status, CurrentUser(c).ID, n.UpdatedAt, notes, 0, ""
+        if appeal {
+            a := *n.Appeal
+            a.Outcome = "upheld"
+            if status == ModerationApproved { a.Outcome = "overturned" }
+            n.Appeal = &a
+        }
+    }
+    action := map[string]string{ModerationApproved: "approved", ModerationRejected: "rejected"}[status]
+    if !h.save(c, r, set, ModerationEvent{Action: action, Note: notes}) { return }
+
+    hidden := ""
+    if status == ModerationRejected { hidden = ModerationRejected }
+    if err := h.setModeration(ctx, r.ContentType, r.ContentID, hidden); err != nil && !errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    outcome := ReportDismissed
+    if status == ModerationRejected { outcome = ReportActioned }
+    if err := h.resolveReports(ctx, r.ContentID, outcome, CurrentUser(c).ID); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, r)
+    if r.AuthorID == "" { return }
+    switch {
+    case appeal && status == ModerationApproved:
+        _ = notify(ctx, h.Inbox, r.AuthorID, "moderation", "你的申诉已通过，内容已恢复："+r.Title, "content_moderation", r.ContentID)
+    case appeal:
+        _ = notify(ctx, h.Inbox, r.AuthorID, "moderation", "你的申诉未通过："+notes, "content_moderation", r.ContentID)
+    case status == ModerationRejected:
+        _ = notify(ctx, h.Inbox, r.AuthorID, "moderation", "你的内容未通过审核："+notes, "content_moderation", r.ContentID)
+    }
+}
+
+type appealReq struct {
+    Reason string `json:"reason" binding:"required,max=2000"`
+}
+
+// Appeal lets the author contest a rejection once. The content stays hidden
+// until the appeal is decided.
+func (h *ModerationHandler) Appeal(c *gin.Context) {
+    var req appealReq
+    if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    r, ok := h.load(c)
+    if !ok { return }
+    if r.AuthorID == "" || r.AuthorID != CurrentUser(c).ID { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    if r.Status != ModerationRejected { c.JSON(http.StatusConflict, gin.H{"error": "only rejected content can be appealed"}); return }
+    if r.Appeal != nil { c.JSON(http.StatusConflict, gin.H{"error": "already appealed"}); return }
+    appeal := func(n *ContentModeration) { n.Status, n.Appeal = ModerationAppealed, &ModerationAppeal{Reason: req.Reason, CreatedAt: *n.UpdatedAt} }
+    if h.save(c, r, appeal, ModerationEvent{Action: "appealed", Note: req.Reason}) { c.JSON(http.StatusOK, r) }
+}
+
+// reviewable checks that the caller may act on r now, writing the error
+// response itself when not: nobody but an admin reviews their own content,
+// the item must be waiting for a decision, not claimed by someone else,
+// escalated items are for admins and an appeal is for a reviewer other than
+// the one who rejected.
+func (h *ModerationHandler) reviewable(c *gin.Context, r *ContentModeration) bool {
+    u := CurrentUser(c)
+    if r.AuthorID == u.ID && !u.IsAdmin() { c.JSON(http.StatusForbidden, gin.H{"error": "your own content is reviewed by someone else"}); return false }
+    switch r.Status {
+    case ModerationPending, ModerationAppealed:
+    case ModerationEscalated:
+        if !u.IsAdmin() { c.JSON(http.StatusForbidden, gin.H{"error": "escalated items are decided by an admin"}); return false }
+    default:
+        c.JSON(http.StatusConflict, gin.H{"error": "item is " + r.Status})
+        return false
+    }
+    if r.ClaimedBy != "" && r.ClaimedBy != u.ID && r.ClaimedAt != nil && time.Since(*r.ClaimedAt) < moderationClaimTTL {
+        c.JSON(http.StatusConflict, gin.H{"error": "claimed by another reviewer", "claimedBy": r.ClaimedBy})
+        return false
+    }
+    if r.Appeal != nil && r.Appeal.Outcome == "" && r.ReviewerID == u.ID && !u.IsAdmin() {
+        c.JSON(http.StatusForbidden, gin.H{"error": "an appeal is decided by a different reviewer"})
+        return false
+    }
+    return true
+}
+
+// save applies change to a copy of r stamped with the current time, and
+// stores it if r has not changed since it was loaded, appending ev to its
+// history and updating r to the result. It writes the error response
+// itself.
+func (h *ModerationHandler) save(c *gin.Context, r *ContentModeration, change func(*ContentModeration), ev ModerationEvent) bool {
+    now := time.Now().UTC()
+    ev.By, ev.At = CurrentUser(c).ID, now
+    next := *r
+    next.UpdatedAt = &now
+    change(&next)
+    err := h.Moderation.Save(c.Request.Context(), &next, r, ev)
+    if errors.Is(err, repo.ErrConflict) { c.JSON(http.StatusConflict, gin.H{"error": "item changed concurrently, retry"}); return false }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return false }
+    *r = next
+    return true
+}
+
+// setModeration marks the content itself rejected, or clears the mark.
+func (q *ModerationQueue) setModeration(ctx context.Context, typ, id, status string) error {
+    if typ == "" { typ = contentTypeOf(id) }
+    switch typ {
+    case "media":
+        return q.Media.SetModeration(ctx, id, status)
+    case "job":
+        return q.Jobs.SetModeration(ctx, id, status)
+    case "user":
+        return q.Users.SetModeration(ctx, id, status)
+    case "project":
+        return q.Projects.SetModeration(ctx, id, status)
+    case "product":
+        return q.Products.SetModeration(ctx, id, status)
+    case "post":
+        return q.Posts.SetModeration(ctx, id, status)
+    }
+    return repo.ErrNotFound
+}
+
+// contentTypeOf recognises seeded records, which have no ContentType, by
+// the id prefix.
+func contentTypeOf(id string) string {
+    for prefix, typ := range map[string]string{"post_": "post", "proj_": "project", "prod_": "product", "media_": "media", "job_": "job", "user_": "user"} {
+        if strings.HasPrefix(id, prefix) { return typ }
+    }
+    return ""
+}
+
+func (h *ModerationHandler) load(c *gin.Context) (*ContentModeration, bool) {
+    r, err := h.Moderation.Get(c.Request.Context(), c.Param("id"))
+    if errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return nil, false }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return nil, false }
+    return r, true
+}
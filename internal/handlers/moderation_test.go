@@ -0,0 +1,49 @@
+package handlers
+
+import (
+    "context"
+    "net/http"
+    "testing"
+
+    "real_deal/internal/rbac"
+    "real_deal/internal/screen"
+)
+
+// Moderators never decide on their own content, neither when it is first
+// reviewed nor on appeal; admins may.
+func TestModerationOwnContent(t *testing.T) {
+    s := newTestServer(t)
+    queue := NewModerationQueue(s.repos, &screen.Policy{Screener: screen.Chain{}, RejectAt: 0.9, ReviewAt: 0.5})
+    h := NewModeration(queue)
+    api := s.router.Group("/api", Authenticate(s.repos.Users, s.sessions))
+    review := api.Group("", Require(rbac.ModerationReview))
+    review.POST("/content-moderation/:id/claim", h.Claim)
+    review.POST("/content-moderation/:id/approve", h.Approve)
+    review.POST("/content-moderation/:id/reject", h.Reject)
+    review.POST("/content-moderation/:id/escalate", h.Escalate)
+    api.POST("/content-moderation/:id/appeal", RequireUser(), h.Appeal)
+
+    author, other, admin := s.login("m1", rbac.RoleModerator), s.login("m2", rbac.RoleModerator), s.login("a1", rbac.RoleAdmin)
+    for _, id := range []string{"post_1", "post_2", "post_3"} {
+        if _, err := queue.Submit(context.Background(), &screen.Input{Type: "post", ID: id, Title: "Mine"}, "m1"); err != nil { t.Fatal(err) }
+    }
+    steps := []struct {
+        who, tok, path, body string
+        want                 int
+    }{
+        {"author", author, "/api/content-moderation/post_1/claim", "", http.StatusForbidden},
+        {"author", author, "/api/content-moderation/post_1/approve", "", http.StatusForbidden},
+        {"author", author, "/api/content-moderation/post_1/escalate", `{"notes":"x"}`, http.StatusForbidden},
+        {"other", other, "/api/content-moderation/post_1/approve", "", http.StatusOK},
+        {"admin", admin, "/api/content-moderation/post_2/approve", "", http.StatusOK},
+
+        {"other", other, "/api/content-moderation/post_3/reject", `{"notes":"spam"}`, http.StatusOK},
+        {"author", author, "/api/content-moderation/post_3/appeal", `{"reason":"not spam"}`, http.StatusOK},
+        {"author", author, "/api/content-moderation/post_3/approve", "", http.StatusForbidden},
+        {"rejecter", other, "/api/content-moderation/post_3/approve", "", http.StatusForbidden},
+        {"admin", admin, "/api/content-moderation/post_3/approve", "", http.StatusOK},
+    }
+    for _, st := range steps {
+        if w := s.do("POST", st.path, st.tok, st.body); w.Code != st.want { t.Fatalf("%s POST %s: got %d %s, want %d", st.who, st.path, w.Code, w.Body, st.want) }
+    }
+}
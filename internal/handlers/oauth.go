@@ -0,0 +1,234 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "log"
+    "net/http"
+    "net/url"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+    "real_deal/internal/auth"
+    "real_deal/internal/config"
+    "real_deal/internal/oauth"
+    "real_deal/internal/rbac"
+    "real_deal/internal/repo"
+    "real_deal/internal/session"
+)
+
+// oauthBrowserCookie ties an OAuth flow to the browser that started it, so a
+// provider URL with someone else's state cannot be completed elsewhere.
+const oauthBrowserCookie = "oauth_browser"
+
+type OAuthHandler struct {
+    Users      repo.Users
+    Providers  map[string]oauth.Provider
+    Flows      *auth.Flows
+    Identities repo.Identities
+    Sessions   session.Store
+    Cfg        *config.Config
+}
+
+func NewOAuth(repos *repo.Repos, providers map[string]oauth.Provider, flows *auth.Flows, sessions session.Store, cfg *config.Config) *OAuthHandler {
+    return &OAuthHandler{Users: repos.Users, Providers: providers, Flows: flows, Identities: repos.Identities, Sessions: sessions, Cfg: cfg}
+}
+
+func (h *OAuthHandler) provider(c *gin.Context) (oauth.Provider, bool) {
+    p, ok := h.Providers[c.Param("provider")]
+    if !ok { c.JSON(http.StatusNotFound, gin.H{"error": oauth.ErrUnknownProvider.Error()}) }
+    return p, ok
+}
+
+// List returns the names of the configured providers.
+func (h *OAuthHandler) List(c *gin.Context) {
+    names := make([]string, 0, len(h.Providers))
+    for n := range h.Providers { names = append(names, n) }
+    sort.Strings(names)
+    c.JSON(http.StatusOK, names)
+}
+
+// Start redirects the browser to the provider to log in.
+func (h *OAuthHandler) Start(c *gin.Context) {
+    p, ok := h.provider(c)
+    if !ok { return }
+    u, err := h.begin(c, p, auth.Flow{Provider: p.Name()})
+    if err != nil { c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()}); return }
+    c.Redirect(http.StatusFound, u)
+}
+
+type bindReq struct{ Merge bool `json:"merge"` }
+
+// Bind starts linking a provider to the signed-in user and returns the URL to
+// send the browser to. With merge=true, an identity that already belongs to
+// another account causes that account to be merged into this one; signing in
+// at the provider is the proof of ownership. The callback must come back to
+// the same browser, still signed in as the same user.
+func (h *OAuthHandler) Bind(c *gin.Context) {
+    p, ok := h.provider(c)
+    if !ok { return }
+    var req bindReq
+    if c.Request.ContentLength > 0 {
+        if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": "bad request"}); return }
+    }
+    u, err := h.begin(c, p, auth.Flow{Provider: p.Name(), UserID: CurrentUser(c).ID, Merge: req.Merge})
+    if err != nil { c.JSON(http.StatusBadGateway, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, gin.H{"url": u})
+}
+
+// begin stores flow for this browser, giving it a browser cookie if it has
+// none yet, and returns the provider URL.
+func (h *OAuthHandler) begin(c *gin.Context, p oauth.Provider, flow auth.Flow) (string, error) {
+    ctx := c.Request.Context()
+    browser, _ := c.Cookie(oauthBrowserCookie)
+    if browser == "" {
+        var err error
+        if browser, err = auth.RandomToken(24); err != nil { return "", err }
+    }
+    state, f, err := h.Flows.Begin(ctx, flow, browser)
+    if err != nil { return "", err }
+    h.setBrowserCookie(c, browser, int(h.Flows.TTL.Seconds()))
+    return p.AuthCodeURL(ctx, oauth.AuthRequest{State: state, CodeChallenge: oauth.CodeChallenge(f.Verifier), Nonce: f.Nonce})
+}
+
+// setBrowserCookie scopes the browser cookie to the callbacks. Providers that
+// answer with a cross-site form_post only get it back with SameSite=None,
+// which browsers accept on Secure cookies alone.
+func (h *OAuthHandler) setBrowserCookie(c *gin.Context, value string, maxAge int) {
+    same := http.SameSiteLaxMode
+    if h.Cfg.CookieSecure { same = http.SameSiteNoneMode }
+    http.SetCookie(c.Writer, &http.Cookie{Name: oauthBrowserCookie, Value: value, Path: "/api/auth", Domain: h.Cfg.CookieDomain, MaxAge: maxAge, Secure: h.Cfg.CookieSecure, HttpOnly: true, SameSite: same})
+}
+
+// Callback finishes either a login or a bind, depending on how the flow was
+// started, and redirects back to the web app. A bind is only finished for
+// the user who started it: a cross-site form_post carries no session cookie,
+// so binding is refused for providers that answer that way.
+func (h *OAuthHandler) Callback(c *gin.Context) {
+    p, ok := h.provider(c)
+    if !ok { return }
+    ctx := c.Request.Context()
+    browser, _ := c.Cookie(oauthBrowserCookie)
+    flow, err := h.Flows.Consume(ctx, p.Name(), formValue(c, "state"), browser)
+    if err != nil { h.fail(c, nil, "invalid_state", err); return }
+    if e := formValue(c, "error"); e != "" { h.fail(c, flow, "denied", errors.New(e)); return }
+    if flow.UserID != "" {
+        if u := CurrentUser(c); u == nil || u.ID != flow.UserID { h.fail(c, flow, "invalid_state", errors.New("bind completed by another user")); return }
+    }
+    id, err := p.Exchange(ctx, formValue(c, "code"), flow.Verifier, flow.Nonce)
+    if err != nil { h.fail(c, flow, "oauth_failed", err); return }
+
+    if flow.UserID != "" {
+        err := auth.Link(ctx, h.Identities, flow.UserID, id)
+        if errors.Is(err, repo.ErrIdentityInUse) && flow.Merge { err = h.mergeOwner(ctx, id, flow.UserID) }
+        switch {
+        case errors.Is(err, repo.ErrIdentityInUse):
+            h.fail(c, flow, "identity_in_use", err)
+        case errors.Is(err, repo.ErrProviderBound):
+            h.fail(c, flow, "provider_bound", err)
+        case err != nil:
+            h.fail(c, flow, "oauth_failed", err)
+        default:
+            c.Redirect(http.StatusFound, h.Cfg.WebURL+"/profile?linked="+url.QueryEscape(p.Name()))
+        }
+        return
+    }
+
+    userID, err := h.resolveUser(ctx, id)
+    if err == nil { err = startSession(c, h.Sessions, h.Cfg, userID) }
+    if err != nil { h.fail(c, flow, "oauth_failed", err); return }
+    c.Redirect(http.StatusFound, h.Cfg.WebURL+"/")
+}
+
+func (h *OAuthHandler) fail(c *gin.Context, flow *auth.Flow, code string, err error) {
+    log.Printf("oauth %s callback: %s: %v", c.Param("provider"), code, err)
+    page := "/login"
+    if flow != nil && flow.UserID != "" { page = "/profile" }
+    c.Redirect(http.StatusFound, h.Cfg.WebURL+page+"?error="+code)
+}
+
+// mergeOwner merges the account that currently owns id into userID.
+func (h *OAuthHandler) mergeOwner(ctx context.Context, id *oauth.Identity, userID string) error {
+    owner, err := h.Identities.Find(ctx, id.Provider, id.Subject)
+    if err != nil { return err }
+    if err := auth.MergeUsers(ctx, h.Users, owner.UserID, userID); err != nil { return err }
+    return h.Sessions.DeleteUser(ctx, owner.UserID)
+}
+
+// resolveUser finds the user for a provider login: an existing link first,
+// then a user with the same provider-verified email (which gets linked), and
+// otherwise a new candidate account.
+func (h *OAuthHandler) resolveUser(ctx context.Context, id *oauth.Identity) (string, error) {
+    link, err := h.Identities.Find(ctx, id.Provider, id.Subject)
+    if err == nil { return link.UserID, nil }
+    if !errors.Is(err, repo.ErrNotFound) { return "", err }
+
+    var u *User
+    if id.Email != "" && id.EmailVerified {
+        u, err = h.Users.ByEmail(ctx, id.Email)
+        if err != nil && !errors.Is(err, repo.ErrNotFound) { return "", err }
+    }
+    if u == nil {
+        now := time.Now()
+        u = &User{ID: newID("user"), Name: id.Name, Role: rbac.RoleCandidate, AvatarURL: id.AvatarURL, CreatedAt: &now}
+        if id.EmailVerified { u.Email = id.Email }
+        if err := h.Users.Create(ctx, u); err != nil { return "", err }
+    }
+    if err := auth.Link(ctx, h.Identities, u.ID, id); err != nil { return "", err }
+    return u.ID, nil
+}
+
+// ListIdentities lists the providers linked to the signed-in user.
+func (h *OAuthHandler) ListIdentities(c *gin.Context) {
+    items, err := h.Identities.List(c.Request.Context(), CurrentUser(c).ID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, items)
+}
+
+// Unbind removes a provider link, refusing to remove the user's last way to
+// sign in (an account without an email can only use its providers).
+func (h *OAuthHandler) Unbind(c *gin.Context) {
+    u := CurrentUser(c)
+    ctx := c.Request.Context()
+    provider := c.Param("provider")
+    if u.Email == "" {
+        items, err := h.Identities.List(ctx, u.ID)
+        if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+        others := 0
+        for _, it := range items {
+            if it.Provider != provider { others++ }
+        }
+        if others == 0 { c.JSON(http.StatusConflict, gin.H{"error": "cannot remove the last sign-in method"}); return }
+    }
+    ok, err := h.Identities.Unlink(ctx, u.ID, provider)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if !ok { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    c.Status(http.StatusNoContent)
+}
+
+type mergeReq struct {
+    From string `json:"from" binding:"required"`
+    Into string `json:"into" binding:"required"`
+}
+
+// Merge folds one user record into another (admin only).
+func (h *OAuthHandler) Merge(c *gin.Context) {
+    var req mergeReq
+    if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": "bad request"}); return }
+    ctx := c.Request.Context()
+    err := auth.MergeUsers(ctx, h.Users, req.From, req.Into)
+    if errors.Is(err, auth.ErrSameUser) { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    if errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if err := h.Sessions.DeleteUser(ctx, req.From); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, gin.H{"from": req.From, "into": req.Into})
+}
+
+// formValue reads a callback parameter from the query string or, for
+// providers using response_mode=form_post, the POST body.
+func formValue(c *gin.Context, key string) string {
+    if v := c.PostForm(key); v != "" { return v }
+    return strings.TrimSpace(c.Query(key))
+}
@@ -0,0 +1,228 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+
+    "real_deal/internal/auth"
+    "real_deal/internal/config"
+    "real_deal/internal/model"
+    "real_deal/internal/oauth"
+)
+
+// fakeProvider hands out the identity registered for each code, once, and
+// only to the verifier and nonce that AuthCodeURL was given for it.
+type fakeProvider struct {
+    ids     map[string]oauth.Identity
+    pending map[string]oauth.AuthRequest
+}
+
+func (p *fakeProvider) Name() string { return "idp" }
+
+func (p *fakeProvider) AuthCodeURL(ctx context.Context, r oauth.AuthRequest) (string, error) {
+    p.pending[r.State] = r
+    return "https://idp.test/authorize?state=" + url.QueryEscape(r.State), nil
+}
+
+func (p *fakeProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*oauth.Identity, error) {
+    id, ok := p.ids[code]
+    if !ok { return nil, errors.New("invalid_grant") }
+    delete(p.ids, code)
+    for _, r := range p.pending {
+        if r.CodeChallenge == oauth.CodeChallenge(verifier) && r.Nonce == nonce { return &id, nil }
+    }
+    return nil, errors.New("verifier or nonce mismatch")
+}
+
+func newOAuthServer(t *testing.T) (*testServer, *fakeProvider) {
+    s := newTestServer(t)
+    p := &fakeProvider{ids: map[string]oauth.Identity{}, pending: map[string]oauth.AuthRequest{}}
+    cfg := &config.Config{WebURL: "https://app.test", SessionTTL: time.Hour}
+    h := NewOAuth(s.repos, map[string]oauth.Provider{"idp": p}, auth.NewFlows(s.repos.OAuthStates, 10*time.Minute), s.sessions, cfg)
+    api := s.router.Group("/api", Authenticate(s.repos.Users, s.sessions))
+    api.GET("/auth/:provider/start", h.Start)
+    api.GET("/auth/:provider/callback", h.Callback)
+    me := api.Group("", RequireUser())
+    me.POST("/me/identities/:provider", h.Bind)
+    me.GET("/me/identities", h.ListIdentities)
+    api.POST("/admin/users/merge", h.Merge)
+    return s, p
+}
+
+// state returns the state parameter of the provider URL a flow was sent to.
+func state(t *testing.T, rawURL string) string {
+    t.Helper()
+    u, err := url.Parse(rawURL)
+    if err != nil { t.Fatal(err) }
+    return u.Query().Get("state")
+}
+
+// browserCookie returns the cookie tying a flow to the browser it began in.
+func browserCookie(t *testing.T, w *httptest.ResponseRecorder) *http.Cookie {
+    t.Helper()
+    for _, c := range w.Result().Cookies() {
+        if c.Name == oauthBrowserCookie { return c }
+    }
+    t.Fatal("no browser cookie set")
+    return nil
+}
+
+// start begins a login and returns its state and browser cookie.
+func (s *testServer) start() (string, *http.Cookie) {
+    s.t.Helper()
+    w := s.do("GET", "/api/auth/idp/start", "", "")
+    if w.Code != http.StatusFound { s.t.Fatalf("start: got %d", w.Code) }
+    return state(s.t, w.Header().Get("Location")), browserCookie(s.t, w)
+}
+
+// callback returns to the server from the provider as the holder of the
+// session tok and the browser cookie (either may be empty).
+func (s *testServer) callback(st, code, tok string, browser *http.Cookie) *http.Response {
+    s.t.Helper()
+    req := httptest.NewRequest("GET", "/api/auth/idp/callback?state="+url.QueryEscape(st)+"&code="+code, nil)
+    if tok != "" { req.AddCookie(&http.Cookie{Name: sessionCookie, Value: tok}) }
+    if browser != nil { req.AddCookie(browser) }
+    w := httptest.NewRecorder()
+    s.router.ServeHTTP(w, req)
+    return w.Result()
+}
+
+func TestOAuthCallbackState(t *testing.T) {
+    s, p := newOAuthServer(t)
+    p.ids["c1"] = oauth.Identity{Provider: "idp", Subject: "s1", Name: "Wang Fang"}
+    st, browser := s.start()
+
+    for _, bad := range []string{"", "forged"} {
+        if loc := s.callback(bad, "c1", "", browser).Header.Get("Location"); loc != "https://app.test/login?error=invalid_state" { t.Fatalf("state %q: redirected to %s", bad, loc) }
+    }
+    res := s.callback(st, "c1", "", browser)
+    if loc := res.Header.Get("Location"); loc != "https://app.test/" { t.Fatalf("callback: redirected to %s", loc) }
+    if len(res.Cookies()) != 1 || res.Cookies()[0].Name != sessionCookie { t.Fatalf("callback set cookies %v", res.Cookies()) }
+    if len(s.mem.Identities) != 1 || s.mem.Identities[0].Subject != "s1" { t.Fatalf("identities = %+v", s.mem.Identities) }
+
+    // A state works once.
+    p.ids["c2"] = oauth.Identity{Provider: "idp", Subject: "s1"}
+    if loc := s.callback(st, "c2", "", browser).Header.Get("Location"); loc != "https://app.test/login?error=invalid_state" { t.Fatalf("replay: redirected to %s", loc) }
+}
+
+// A state only completes in the browser that started the flow, so a provider
+// URL cannot be handed to someone else to finish.
+func TestOAuthCallbackOtherBrowser(t *testing.T) {
+    s, p := newOAuthServer(t)
+    p.ids["c1"] = oauth.Identity{Provider: "idp", Subject: "s1"}
+    st, _ := s.start()
+    _, other := s.start()
+    for name, browser := range map[string]*http.Cookie{"no cookie": nil, "another browser": other} {
+        if loc := s.callback(st, "c1", "", browser).Header.Get("Location"); loc != "https://app.test/login?error=invalid_state" { t.Fatalf("%s: redirected to %s", name, loc) }
+    }
+    if len(s.mem.Identities) != 0 { t.Fatalf("identities = %+v", s.mem.Identities) }
+}
+
+func TestOAuthLoginLinksVerifiedEmail(t *testing.T) {
+    s, p := newOAuthServer(t)
+    s.mem.Users["u1"] = model.User{ID: "u1", Email: "li@example.com", Role: "candidate"}
+    login := func(code string, id oauth.Identity) {
+        t.Helper()
+        p.ids[code] = id
+        st, browser := s.start()
+        if loc := s.callback(st, code, "", browser).Header.Get("Location"); loc != "https://app.test/" { t.Fatalf("login %s: redirected to %s", code, loc) }
+    }
+
+    // An unverified email must not take over the account that owns it.
+    login("c1", oauth.Identity{Provider: "idp", Subject: "s1", Email: "li@example.com"})
+    if owner := s.mem.Identities[0].UserID; owner == "u1" { t.Fatal("unverified email linked to the existing user") }
+    login("c2", oauth.Identity{Provider: "idp", Subject: "s2", Email: "li@example.com", EmailVerified: true})
+    if owner := s.mem.Identities[1].UserID; owner != "u1" { t.Fatalf("verified email linked to %s, want u1", owner) }
+    if len(s.mem.Users) != 2 { t.Fatalf("got %d users, want 2", len(s.mem.Users)) }
+}
+
+func TestOAuthBindMerge(t *testing.T) {
+    s, p := newOAuthServer(t)
+    into := s.login("u1", "candidate")
+    from := s.login("u2", "founder")
+    s.mem.Projects["p1"] = model.Project{ContentMeta: model.ContentMeta{ID: "p1", AuthorID: "u2"}}
+    s.mem.Identities = append(s.mem.Identities, model.UserIdentity{UserID: "u2", Provider: "idp", Subject: "s2"})
+    bind := func(body, code string) string {
+        t.Helper()
+        p.ids[code] = oauth.Identity{Provider: "idp", Subject: "s2"}
+        w := s.do("POST", "/api/me/identities/idp", into, body)
+        if w.Code != http.StatusOK { t.Fatalf("bind: got %d %s", w.Code, w.Body) }
+        return s.callback(state(t, decode[struct{ URL string `json:"url"` }](t, w).URL), code, into, browserCookie(t, w)).Header.Get("Location")
+    }
+
+    if loc := bind("", "c1"); loc != "https://app.test/profile?error=identity_in_use" { t.Fatalf("bind without merge: redirected to %s", loc) }
+    if _, ok := s.mem.Users["u2"]; !ok { t.Fatal("u2 merged without merge=true") }
+
+    if loc := bind(`{"merge":true}`, "c2"); loc != "https://app.test/profile?linked=idp" { t.Fatalf("bind with merge: redirected to %s", loc) }
+    if _, ok := s.mem.Users["u2"]; ok { t.Fatal("u2 still exists after merge") }
+    if got := s.mem.Identities[0].UserID; got != "u1" { t.Fatalf("identity owned by %s, want u1", got) }
+    if got := s.mem.Projects["p1"].AuthorID; got != "u1" { t.Fatalf("project authored by %s, want u1", got) }
+    if w := s.do("GET", "/api/me/identities", from, ""); w.Code != http.StatusUnauthorized { t.Fatalf("merged user's session: got %d", w.Code) }
+    ids := decode[[]auth.UserIdentity](t, s.do("GET", "/api/me/identities", into, ""))
+    if len(ids) != 1 || ids[0].Subject != "s2" { t.Fatalf("u1 identities = %+v", ids) }
+}
+
+// An attacker who starts a merge bind and gets a victim to sign in at the
+// provider must not end up with the victim's account, whether or not the
+// attacker's browser cookie comes along.
+func TestOAuthBindCompletedByOther(t *testing.T) {
+    s, p := newOAuthServer(t)
+    attacker := s.login("u1", "candidate")
+    victim := s.login("u2", "founder")
+    s.mem.Identities = append(s.mem.Identities, model.UserIdentity{UserID: "u2", Provider: "idp", Subject: "s2"})
+    for _, caller := range []string{"", victim} {
+        for _, withCookie := range []bool{true, false} {
+            p.ids["c"] = oauth.Identity{Provider: "idp", Subject: "s2"}
+            w := s.do("POST", "/api/me/identities/idp", attacker, `{"merge":true}`)
+            if w.Code != http.StatusOK { t.Fatalf("bind: got %d %s", w.Code, w.Body) }
+            var browser *http.Cookie
+            if withCookie { browser = browserCookie(t, w) }
+            loc := s.callback(state(t, decode[struct{ URL string `json:"url"` }](t, w).URL), "c", caller, browser).Header.Get("Location")
+            if !strings.HasSuffix(loc, "?error=invalid_state") { t.Fatalf("victim %v, browser cookie %v: redirected to %s", caller != "", withCookie, loc) }
+        }
+    }
+    if _, ok := s.mem.Users["u2"]; !ok { t.Fatal("victim merged into the attacker") }
+    if got := s.mem.Identities[0].UserID; got != "u2" { t.Fatalf("identity owned by %s, want u2", got) }
+}
+
+func TestAdminMerge(t *testing.T) {
+    s, _ := newOAuthServer(t)
+    s.login("u1", "candidate")
+    s.login("u2", "candidate")
+    for _, tt := range []struct {
+        body string
+        want int
+    }{
+        {`{"from":"u1","into":"u1"}`, http.StatusBadRequest},
+        {`{"from":"u1"}`, http.StatusBadRequest},
+        {`{"from":"nobody","into":"u1"}`, http.StatusNotFound},
+        {`{"from":"u2","into":"u1"}`, http.StatusOK},
+        {`{"from":"u2","into":"u1"}`, http.StatusNotFound},
+    } {
+        if w := s.do("POST", "/api/admin/users/merge", "", tt.body); w.Code != tt.want { t.Errorf("%s: got %d, want %d", tt.body, w.Code, tt.want) }
+    }
+}
+
+// Merging moves the merged user's media, uploads and upload slots along
+// with the storage they are charged for.
+func TestAdminMergeMovesMedia(t *testing.T) {
+    s, _ := newOAuthServer(t)
+    s.login("u1", "candidate")
+    s.login("u2", "candidate")
+    s.mem.Media["m1"] = model.MediaAsset{ID: "m1", OwnerID: "u2", Type: "image"}
+    s.mem.Uploads["up1"] = model.MediaUpload{ID: "up1", OwnerID: "u2", Status: model.UploadPending}
+    s.mem.UploadSlots["u1"], s.mem.UploadSlots["u2"] = 1, 2
+    s.mem.Usage["u2"] = model.Usage{UserID: "u2", StorageGB: 1.5}
+
+    if w := s.do("POST", "/api/admin/users/merge", "", `{"from":"u2","into":"u1"}`); w.Code != http.StatusOK { t.Fatalf("merge: got %d %s", w.Code, w.Body) }
+    if got := s.mem.Media["m1"].OwnerID; got != "u1" { t.Errorf("media owned by %s, want u1", got) }
+    if got := s.mem.Uploads["up1"].OwnerID; got != "u1" { t.Errorf("upload owned by %s, want u1", got) }
+    if got := s.mem.UploadSlots["u1"]; got != 3 || len(s.mem.UploadSlots) != 1 { t.Errorf("upload slots = %v, want u1: 3", s.mem.UploadSlots) }
+    if got := s.mem.Usage["u1"].StorageGB; got != 1.5 { t.Errorf("u1 storage = %v GB, want 1.5", got) }
+}
@@ -0,0 +1,61 @@
+package handlers
+
+import (
+    "errors"
+    "io"
+    "net/http"
+    "path"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+    "real_deal/internal/storage"
+)
+
+// ObjectHandler answers the presigned URLs of a store with no object
+// storage service behind it. The URL's signature is the only authorization,
+// as with the service's own presigned URLs.
+type ObjectHandler struct{ Store storage.Served }
+
+func NewObject(st storage.Served) *ObjectHandler { return &ObjectHandler{Store: st} }
+
+// Get serves an object, with Range support, for GET and HEAD.
+func (h *ObjectHandler) Get(c *gin.Context) {
+    key, ok := h.verify(c)
+    if !ok { return }
+    r, obj, err := h.Store.Get(c.Request.Context(), key)
+    if errors.Is(err, storage.ErrNotExist) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    defer r.Close()
+    if obj.ContentType != "" { c.Header("Content-Type", obj.ContentType) }
+    if rs, ok := r.(io.ReadSeeker); ok { http.ServeContent(c.Writer, c.Request, path.Base(key), obj.LastModified, rs); return }
+    c.DataFromReader(http.StatusOK, obj.Size, obj.ContentType, r, nil)
+}
+
+// Put stores an upload: the whole object, or with uploadId and partNumber
+// one part of a multipart upload, answered with the part's ETag.
+func (h *ObjectHandler) Put(c *gin.Context) {
+    key, ok := h.verify(c)
+    if !ok { return }
+    ctx, q := c.Request.Context(), c.Request.URL.Query()
+    if id := q.Get("uploadId"); id != "" {
+        n, err := strconv.Atoi(q.Get("partNumber"))
+        if err != nil || n < 1 || n > maxParts { c.JSON(http.StatusBadRequest, gin.H{"error": "invalid part number"}); return }
+        p, err := h.Store.PutPart(ctx, key, id, n, c.Request.Body)
+        if errors.Is(err, storage.ErrNoUpload) { c.JSON(http.StatusNotFound, gin.H{"error": err.Error()}); return }
+        if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+        c.Header("ETag", `"`+p.ETag+`"`)
+        c.Status(http.StatusOK)
+        return
+    }
+    err := h.Store.PutReader(ctx, key, c.Request.Body, c.Request.ContentLength, c.ContentType())
+    if errors.Is(err, io.ErrUnexpectedEOF) { c.JSON(http.StatusBadRequest, gin.H{"error": "body shorter than Content-Length"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.Status(http.StatusOK)
+}
+
+func (h *ObjectHandler) verify(c *gin.Context) (string, bool) {
+    key := strings.TrimPrefix(c.Param("key"), "/")
+    if err := h.Store.Verify(c.Request, key); err != nil { c.JSON(http.StatusForbidden, gin.H{"error": err.Error()}); return "", false }
+    return key, true
+}
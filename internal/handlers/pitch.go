@@ -1,22 +1,36 @@
 package handlers
 
 import (
-    "context"
     "net/http"
+    "time"
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+    "real_deal/internal/model"
+    "real_deal/internal/repo"
 )
 
-type PitchHandler struct{ DB *mongo.Database }
+type PitchHandler struct {
+    Pitches   repo.Pitches
+    Companies repo.Companies
+}
 
-func NewPitch(db *mongo.Database) *PitchHandler { return &PitchHandler{DB: db} }
+func NewPitch(repos *repo.Repos) *PitchHandler { return &PitchHandler{Pitches: repos.Pitches, Companies: repos.Companies} }
 
 func (h *PitchHandler) Get(c *gin.Context) {
-    id := c.Param("id")
-    ctx := context.Background()
-    var p PitchPage
-    err := h.DB.Collection("pitch_pages").FindOne(ctx, bson.M{"id": id}).Decode(&p)
+    p, err := h.Pitches.Get(c.Request.Context(), c.Param("id"))
     if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
     c.JSON(http.StatusOK, p)
-}
\ No newline at end of file
+}
+
+// Create publishes a pitch page for a company the caller runs (owner or admin).
+func (h *PitchHandler) Create(c *gin.Context) {
+    ctx := c.Request.Context()
+    var p PitchPage
+    if err := c.ShouldBindJSON(&p); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    if _, ok := companyRole(c, h.Companies, p.CompanyID, model.CompanyRoleAdmin); !ok { return }
+    co, err := h.Companies.Get(ctx, p.CompanyID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    now := time.Now().UTC()
+    p.ID, p.Company, p.OwnerID, p.CreatedAt = newID("pitch"), co.Name, CurrentUser(c).ID, &now
+    if err := h.Pitches.Insert(ctx, &p); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusCreated, p)
+}
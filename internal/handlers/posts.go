@@ -1,22 +1,31 @@
 package handlers
 
 import (
-    "context"
-    "net/http"
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/query"
+    "real_deal/internal/repo"
+    "real_deal/internal/search"
 )
 
-type PostHandler struct{ DB *mongo.Database }
+type PostHandler struct {
+    Posts  repo.Content[Post]
+    Search search.Engine
+    Queue  *ModerationQueue
+}
 
-func NewPost(db *mongo.Database) *PostHandler { return &PostHandler{DB: db} }
+func NewPost(repos *repo.Repos, idx search.Engine, q *ModerationQueue) *PostHandler {
+    return &PostHandler{Posts: repos.Posts, Search: idx, Queue: q}
+}
 
 func (h *PostHandler) List(c *gin.Context) {
-    ctx := context.Background()
-    cur, err := h.DB.Collection("posts").Find(ctx, bson.D{}, nil)
-    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
-    var items []Post
-    for cur.Next(ctx) { var p Post; _ = cur.Decode(&p); items = append(items, p) }
-    c.JSON(http.StatusOK, items)
-}
\ No newline at end of file
+    listWith(c, contentSpec, func(q *query.Query) (*query.Page[Post], error) { return h.Posts.List(c.Request.Context(), q) })
+}
+
+func (h *PostHandler) Get(c *gin.Context) { getContent(c, h.Posts) }
+
+func (h *PostHandler) Create(c *gin.Context) { createContent(c, h.Posts, h.Search, h.Queue, "posts", "post") }
+
+func (h *PostHandler) Update(c *gin.Context) { updateContent(c, h.Posts, h.Search, h.Queue, "posts") }
+
+func (h *PostHandler) Delete(c *gin.Context) { deleteContent(c, h.Posts) }
@@ -1,22 +1,20 @@
 package handlers
 
 import (
-    "context"
     "net/http"
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/repo"
 )
 
-type PreferenceHandler struct{ DB *mongo.Database }
+type PreferenceHandler struct{ Preferences repo.Preferences }
 
-func NewPreference(db *mongo.Database) *PreferenceHandler { return &PreferenceHandler{DB: db} }
+func NewPreference(repos *repo.Repos) *PreferenceHandler { return &PreferenceHandler{Preferences: repos.Preferences} }
 
 func (h *PreferenceHandler) Get(c *gin.Context) {
-    user := c.Query("userId")
-    ctx := context.Background()
-    var p NotificationPreference
-    err := h.DB.Collection("notification_preferences").FindOne(ctx, bson.M{"userId": user}).Decode(&p)
+    user, ok := targetUserID(c)
+    if !ok { return }
+    p, err := h.Preferences.Get(c.Request.Context(), user)
     if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
     c.JSON(http.StatusOK, p)
-}
\ No newline at end of file
+}
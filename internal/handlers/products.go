@@ -1,22 +1,31 @@
 package handlers
 
 import (
-    "context"
-    "net/http"
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/query"
+    "real_deal/internal/repo"
+    "real_deal/internal/search"
 )
 
-type ProductHandler struct{ DB *mongo.Database }
+type ProductHandler struct {
+    Products repo.Content[Product]
+    Search   search.Engine
+    Queue    *ModerationQueue
+}
 
-func NewProduct(db *mongo.Database) *ProductHandler { return &ProductHandler{DB: db} }
+func NewProduct(repos *repo.Repos, idx search.Engine, q *ModerationQueue) *ProductHandler {
+    return &ProductHandler{Products: repos.Products, Search: idx, Queue: q}
+}
 
 func (h *ProductHandler) List(c *gin.Context) {
-    ctx := context.Background()
-    cur, err := h.DB.Collection("products").Find(ctx, bson.D{}, nil)
-    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
-    var items []Product
-    for cur.Next(ctx) { var p Product; _ = cur.Decode(&p); items = append(items, p) }
-    c.JSON(http.StatusOK, items)
-}
\ No newline at end of file
+    listWith(c, contentSpec, func(q *query.Query) (*query.Page[Product], error) { return h.Products.List(c.Request.Context(), q) })
+}
+
+func (h *ProductHandler) Get(c *gin.Context) { getContent(c, h.Products) }
+
+func (h *ProductHandler) Create(c *gin.Context) { createContent(c, h.Products, h.Search, h.Queue, "products", "prod") }
+
+func (h *ProductHandler) Update(c *gin.Context) { updateContent(c, h.Products, h.Search, h.Queue, "products") }
+
+func (h *ProductHandler) Delete(c *gin.Context) { deleteContent(c, h.Products) }
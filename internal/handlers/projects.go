@@ -1,23 +1,31 @@
 package handlers
 
 import (
-    "context"
-    "net/http"
-
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/query"
+    "real_deal/internal/repo"
+    "real_deal/internal/search"
 )
 
-type ProjectHandler struct{ DB *mongo.Database }
+type ProjectHandler struct {
+    Projects repo.Content[Project]
+    Search   search.Engine
+    Queue    *ModerationQueue
+}
 
-func NewProject(db *mongo.Database) *ProjectHandler { return &ProjectHandler{DB: db} }
+func NewProject(repos *repo.Repos, idx search.Engine, q *ModerationQueue) *ProjectHandler {
+    return &ProjectHandler{Projects: repos.Projects, Search: idx, Queue: q}
+}
 
 func (h *ProjectHandler) List(c *gin.Context) {
-    ctx := context.Background()
-    cur, err := h.DB.Collection("projects").Find(ctx, bson.D{}, nil)
-    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
-    var items []Project
-    for cur.Next(ctx) { var p Project; _ = cur.Decode(&p); items = append(items, p) }
-    c.JSON(http.StatusOK, items)
-}
\ No newline at end of file
+    listWith(c, contentSpec, func(q *query.Query) (*query.Page[Project], error) { return h.Projects.List(c.Request.Context(), q) })
+}
+
+func (h *ProjectHandler) Get(c *gin.Context) { getContent(c, h.Projects) }
+
+func (h *ProjectHandler) Create(c *gin.Context) { createContent(c, h.Projects, h.Search, h.Queue, "projects", "proj") }
+
+func (h *ProjectHandler) Update(c *gin.Context) { updateContent(c, h.Projects, h.Search, h.Queue, "projects") }
+
+func (h *ProjectHandler) Delete(c *gin.Context) { deleteContent(c, h.Projects) }
@@ -0,0 +1,110 @@
+package handlers
+
+import (
+    "context"
+    "encoding/json"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "real_deal/internal/model"
+    "real_deal/internal/rbac"
+    "real_deal/internal/repo"
+    "real_deal/internal/screen"
+    "real_deal/internal/search"
+    "real_deal/internal/session"
+)
+
+// testServer wires handlers to the in-memory repositories and session store.
+type testServer struct {
+    t        *testing.T
+    repos    *repo.Repos
+    mem      *repo.Memory
+    sessions *session.MemoryStore
+    router   *gin.Engine
+}
+
+func newTestServer(t *testing.T) *testServer {
+    t.Helper()
+    gin.SetMode(gin.TestMode)
+    repos, mem := repo.NewMemory()
+    return &testServer{t: t, repos: repos, mem: mem, sessions: session.NewMemory(time.Hour), router: gin.New()}
+}
+
+// login stores a user with role and returns a session token for it.
+func (s *testServer) login(id, role string) string {
+    s.t.Helper()
+    s.mem.Users[id] = model.User{ID: id, Name: id, Role: role}
+    tok, _, err := s.sessions.Create(context.Background(), id, session.Meta{})
+    if err != nil { s.t.Fatal(err) }
+    return tok
+}
+
+// do sends a request as the holder of tok ("" for anonymous) and returns the
+// recorded response.
+func (s *testServer) do(method, path, tok, body string) *httptest.ResponseRecorder {
+    s.t.Helper()
+    req := httptest.NewRequest(method, path, strings.NewReader(body))
+    if body != "" { req.Header.Set("Content-Type", "application/json") }
+    if tok != "" { req.AddCookie(&http.Cookie{Name: sessionCookie, Value: tok}) }
+    w := httptest.NewRecorder()
+    s.router.ServeHTTP(w, req)
+    return w
+}
+
+func decode[T any](t *testing.T, w *httptest.ResponseRecorder) T {
+    t.Helper()
+    var v T
+    if err := json.Unmarshal(w.Body.Bytes(), &v); err != nil { t.Fatalf("decode %q: %v", w.Body.String(), err) }
+    return v
+}
+
+func TestProjectLifecycle(t *testing.T) {
+    s := newTestServer(t)
+    tokenizer, _ := search.NewTokenizer("")
+    idx := search.NewMemory(s.repos.Documents, tokenizer)
+    queue := NewModerationQueue(s.repos, &screen.Policy{Screener: screen.Chain{}, RejectAt: 0.9, ReviewAt: 0.5})
+    h := NewProject(s.repos, idx, queue)
+    api := s.router.Group("/api", Authenticate(s.repos.Users, s.sessions))
+    api.GET("/projects", h.List)
+    api.GET("/projects/:id", h.Get)
+    api.GET("/search", NewSearch(idx).Get)
+    content := api.Group("", Require(rbac.ContentWrite))
+    content.POST("/projects", h.Create)
+    content.PATCH("/projects/:id", h.Update)
+    content.DELETE("/projects/:id", h.Delete)
+
+    author, other := s.login("u1", rbac.RoleFounder), s.login("u2", rbac.RoleFounder)
+
+    if w := s.do("POST", "/api/projects", "", `{"title":"Solar kiosk"}`); w.Code != http.StatusUnauthorized { t.Fatalf("anonymous create: got %d", w.Code) }
+    if w := s.do("POST", "/api/projects", author, `{"summary":"no title"}`); w.Code != http.StatusBadRequest { t.Fatalf("invalid create: got %d", w.Code) }
+
+    w := s.do("POST", "/api/projects", author, `{"title":"Solar kiosk","summary":"Charging for markets","tags":["energy"],"authorId":"u2"}`)
+    if w.Code != http.StatusCreated { t.Fatalf("create: got %d %s", w.Code, w.Body) }
+    p := decode[Project](t, w)
+    if p.ID == "" || p.AuthorID != "u1" { t.Fatalf("create: server fields not set: %+v", p.ContentMeta) }
+    if _, err := s.repos.Moderation.Get(context.Background(), p.ID); err != nil { t.Fatalf("create: not queued for moderation: %v", err) }
+
+    w = s.do("GET", "/api/projects/"+p.ID, "", "")
+    if w.Code != http.StatusOK || decode[Project](t, w).Title != "Solar kiosk" { t.Fatalf("get: got %d %s", w.Code, w.Body) }
+
+    w = s.do("GET", "/api/projects", "", "")
+    if page := decode[struct{ Items []Project `json:"items"` }](t, w); w.Code != http.StatusOK || len(page.Items) != 1 { t.Fatalf("list: got %d %s", w.Code, w.Body) }
+
+    w = s.do("GET", "/api/search?q=kiosk", "", "")
+    if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), p.ID) { t.Fatalf("search: got %d %s", w.Code, w.Body) }
+
+    if w := s.do("PATCH", "/api/projects/"+p.ID, other, `{"title":"Taken"}`); w.Code != http.StatusForbidden { t.Fatalf("foreign update: got %d", w.Code) }
+    w = s.do("PATCH", "/api/projects/"+p.ID, author, `{"title":"Solar kiosk v2"}`)
+    if up := decode[Project](t, w); w.Code != http.StatusOK || up.Title != "Solar kiosk v2" || up.Summary != "Charging for markets" { t.Fatalf("patch: got %d %s", w.Code, w.Body) }
+
+    if w := s.do("DELETE", "/api/projects/"+p.ID, other, ""); w.Code != http.StatusForbidden { t.Fatalf("foreign delete: got %d", w.Code) }
+    if w := s.do("DELETE", "/api/projects/"+p.ID, author, ""); w.Code != http.StatusNoContent { t.Fatalf("delete: got %d", w.Code) }
+    if w := s.do("GET", "/api/projects/"+p.ID, "", ""); w.Code != http.StatusNotFound { t.Fatalf("get after delete: got %d", w.Code) }
+    w = s.do("GET", "/api/projects", "", "")
+    if page := decode[struct{ Items []Project `json:"items"` }](t, w); len(page.Items) != 0 { t.Fatalf("list after delete: %s", w.Body) }
+}
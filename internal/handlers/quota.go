@@ -1,22 +1,21 @@
 package handlers
 
 import (
-    "context"
     "net/http"
+
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/repo"
 )
 
-type QuotaHandler struct{ DB *mongo.Database }
+type QuotaHandler struct{ Billing repo.Billing }
 
-func NewQuota(db *mongo.Database) *QuotaHandler { return &QuotaHandler{DB: db} }
+func NewQuota(repos *repo.Repos) *QuotaHandler { return &QuotaHandler{Billing: repos.Billing} }
 
 func (h *QuotaHandler) Get(c *gin.Context) {
-    user := c.Query("userId")
-    ctx := context.Background()
-    var q Quota
-    err := h.DB.Collection("quotas").FindOne(ctx, bson.M{"userId": user}).Decode(&q)
+    user, ok := targetUserID(c)
+    if !ok { return }
+    q, err := h.Billing.Quota(c.Request.Context(), user)
     if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
     c.JSON(http.StatusOK, q)
-}
\ No newline at end of file
+}
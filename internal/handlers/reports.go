@@ -0,0 +1,174 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/http"
+    "sort"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "real_deal/internal/query"
+    "real_deal/internal/repo"
+)
+
+var reportSpec = query.Spec{
+    Filters:     map[string]string{"status": "status", "targetType": "targetType", "targetId": "targetId", "category": "category", "reporterId": "reporterId"},
+    Sorts:       map[string]string{"createdAt": "createdAt"},
+    DefaultSort: "-createdAt",
+}
+
+// ReportHandler takes user reports. Once Threshold different users have
+// open reports on a target it goes into the moderation queue; the
+// reviewer's decision there resolves the reports and tells the reporters.
+type ReportHandler struct {
+    Queue     *ModerationQueue
+    Threshold int
+}
+
+func NewReport(q *ModerationQueue, threshold int) *ReportHandler {
+    return &ReportHandler{Queue: q, Threshold: threshold}
+}
+
+// Create files a report. Reporting the same target again while the first
+// report is open returns that report unchanged.
+func (h *ReportHandler) Create(c *gin.Context) {
+    ctx := c.Request.Context()
+    var r Report
+    if err := c.ShouldBindJSON(&r); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    u := CurrentUser(c)
+    authorID, title, err := h.target(ctx, r.TargetType, r.TargetID)
+    if errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if authorID == u.ID { c.JSON(http.StatusBadRequest, gin.H{"error": "cannot report your own " + r.TargetType}); return }
+
+    r.ID, r.ReporterID, r.Status, r.ResolvedBy, r.ResolvedAt, r.CreatedAt = newID("rep"), u.ID, ReportOpen, "", nil, time.Now().UTC()
+    if err := h.Queue.Reports.Insert(ctx, &r); errors.Is(err, repo.ErrDuplicate) {
+        prev, err := h.Queue.Reports.OpenBy(ctx, u.ID, r.TargetType, r.TargetID)
+        if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+        c.JSON(http.StatusOK, prev)
+        return
+    } else if err != nil {
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+
+    open, err := h.openReports(ctx, r.TargetType, r.TargetID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if n := len(open); n >= h.Threshold {
+        seen := map[string]bool{}
+        var names []string
+        for _, o := range open {
+            if !seen[o.Category] { seen[o.Category] = true; names = append(names, o.Category) }
+        }
+        sort.Strings(names)
+        note := fmt.Sprintf("%d open reports: %s", n, strings.Join(names, ", "))
+        if err := h.Queue.Flag(ctx, r.TargetType, r.TargetID, authorID, title, n, note); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    }
+    c.JSON(http.StatusCreated, r)
+}
+
+// openReports lists the open reports on the target of the given type.
+func (h *ReportHandler) openReports(ctx context.Context, typ, id string) ([]Report, error) {
+    all, err := h.Queue.Reports.Open(ctx, id)
+    if err != nil { return nil, err }
+    var open []Report
+    for _, r := range all {
+        if r.TargetType == typ { open = append(open, r) }
+    }
+    return open, nil
+}
+
+// Mine lists the caller's reports, newest first, with their outcome.
+func (h *ReportHandler) Mine(c *gin.Context) {
+    listWith(c, reportSpec, func(q *query.Query) (*query.Page[Report], error) {
+        return h.Queue.Reports.List(c.Request.Context(), CurrentUser(c).ID, q)
+    })
+}
+
+// List is the reviewers' view of every report.
+func (h *ReportHandler) List(c *gin.Context) {
+    listWith(c, reportSpec, func(q *query.Query) (*query.Page[Report], error) {
+        return h.Queue.Reports.List(c.Request.Context(), "", q)
+    })
+}
+
+// Dismiss closes one open report without a moderation decision, for
+// reports that are plainly unfounded, and tells the reporter.
+func (h *ReportHandler) Dismiss(c *gin.Context) {
+    ctx := c.Request.Context()
+    r, err := h.Queue.Reports.Close(ctx, c.Param("id"), ReportDismissed, CurrentUser(c).ID, time.Now().UTC())
+    if errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "no open report"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    open, err := h.openReports(ctx, r.TargetType, r.TargetID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if err := h.Queue.Moderation.SetReports(ctx, r.TargetID, len(open)); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    _ = notify(ctx, h.Queue.Inbox, r.ReporterID, "report", reportFeedback[ReportDismissed], "report", r.ID)
+    c.JSON(http.StatusOK, r)
+}
+
+// target loads what a report points at and returns its author and a title
+// for the moderation queue. Deleted and rejected targets are not found.
+func (h *ReportHandler) target(ctx context.Context, typ, id string) (string, string, error) {
+    switch typ {
+    case "media":
+        a, err := h.Queue.Media.Get(ctx, id)
+        if err != nil { return "", "", err }
+        if a.Moderation == ModerationRejected { return "", "", repo.ErrNotFound }
+        return a.OwnerID, a.Title, nil
+    case "job":
+        j, err := h.Queue.Jobs.Get(ctx, id)
+        if err != nil { return "", "", err }
+        if !j.Live(time.Now()) { return "", "", repo.ErrNotFound }
+        return j.OwnerID, j.Title, nil
+    case "user":
+        u, err := h.Queue.Users.Get(ctx, id)
+        if err != nil { return "", "", err }
+        if u.Moderation == ModerationRejected { return "", "", repo.ErrNotFound }
+        return u.ID, u.Name, nil
+    }
+    var (
+        meta  *ContentMeta
+        title string
+    )
+    switch typ {
+    case "project":
+        p, err := h.Queue.Projects.Get(ctx, id)
+        if err != nil { return "", "", err }
+        meta, title = p.Meta(), p.Title
+    case "product":
+        p, err := h.Queue.Products.Get(ctx, id)
+        if err != nil { return "", "", err }
+        meta, title = p.Meta(), p.Name
+    case "post":
+        p, err := h.Queue.Posts.Get(ctx, id)
+        if err != nil { return "", "", err }
+        meta, title = p.Meta(), p.Title
+    default:
+        return "", "", repo.ErrNotFound
+    }
+    if !meta.Live() { return "", "", repo.ErrNotFound }
+    return meta.AuthorID, title, nil
+}
+
+var reportFeedback = map[string]string{
+    ReportActioned:  "你举报的内容已被处理，感谢你的反馈",
+    ReportDismissed: "你举报的内容经审核未发现违规，感谢你的反馈",
+}
+
+// resolveReports closes the open reports on a target after a moderation
+// decision and tells each reporter the outcome.
+func (q *ModerationQueue) resolveReports(ctx context.Context, targetID, outcome, by string) error {
+    open, err := q.Reports.Open(ctx, targetID)
+    if err != nil || len(open) == 0 { return err }
+    ids := make([]string, len(open))
+    for i, r := range open { ids[i] = r.ID }
+    if err := q.Reports.CloseAll(ctx, ids, outcome, by, time.Now().UTC()); err != nil { return err }
+    for _, r := range open {
+        _ = notify(ctx, q.Inbox, r.ReporterID, "report", reportFeedback[outcome], "report", r.ID)
+    }
+    return nil
+}
@@ -0,0 +1,221 @@
+package handlers
+
+import (
+    "github.com/gin-gonic/gin"
+
+    "real_deal/internal/rbac"
+)
+
+// Access is who may call a route: anyone, any signed-in user, or signed-in
+// users whose role grants Permission.
+type Access struct {
+    SignedIn   bool
+    Permission rbac.Permission
+}
+
+var (
+    Public   = Access{}
+    SignedIn = Access{SignedIn: true}
+)
+
+// Allow restricts a route to roles that grant p.
+func Allow(p rbac.Permission) Access { return Access{SignedIn: true, Permission: p} }
+
+// middleware is the check Access puts in front of a route: 401 without a
+// user, 403 when the role lacks the permission.
+func (a Access) middleware() []gin.HandlerFunc {
+    switch {
+    case a.Permission != "":
+        return []gin.HandlerFunc{Require(a.Permission)}
+    case a.SignedIn:
+        return []gin.HandlerFunc{RequireUser()}
+    }
+    return nil
+}
+
+// Route is one API endpoint and its policy. Path is relative to /api.
+type Route struct {
+    Method string
+    Path   string
+    Access Access
+    Handle gin.HandlerFunc
+}
+
+// Mount registers routes on g, each behind its Access check. g must run
+// Authenticate first.
+func Mount(g gin.IRoutes, routes []Route) {
+    for _, rt := range routes {
+        g.Handle(rt.Method, rt.Path, append(rt.Access.middleware(), rt.Handle)...)
+    }
+}
+
+// API holds the handlers behind the /api routes.
+type API struct {
+    Auth          *AuthHandler
+    OAuth         *OAuthHandler
+    Search        *SearchHandler
+    Feed          *FeedHandler
+    Explore       *ExploreHandler
+    Projects      *ProjectHandler
+    Products      *ProductHandler
+    Posts         *PostHandler
+    Jobs          *JobHandler
+    Applications  *ApplicationHandler
+    Companies     *CompanyHandler
+    Verifications *VerificationHandler
+    Compliance    *ComplianceHandler
+    Investors     *InvestorHandler
+    Pitch         *PitchHandler
+    DealRoom      *DealRoomHandler
+    Media         *MediaHandler
+    MediaAssets   *MediaAssetsHandler
+    Users         *UserHandler
+    Moderation    *ModerationHandler
+    Reports       *ReportHandler
+    Follows       *FollowHandler
+    Uploads       *UploadHandler
+    Transcodes    *TranscodeHandler
+    Inbox         *InboxHandler
+    Preferences   *PreferenceHandler
+    Usage         *UsageHandler
+    Quota         *QuotaHandler
+    Capacity      *CapacityHandler
+    JobSlots      *JobSlotHandler
+    Charges       *ChargeHandler
+}
+
+// Routes is the API's route table. Routes that act on the signed-in user
+// let admins pass ?userId=.
+func (a *API) Routes() []Route {
+    return []Route{
+        {"GET", "/search", Public, a.Search.Get},
+        {"GET", "/feed", Public, a.Feed.Get},
+        {"GET", "/explore", Public, a.Explore.Get},
+        {"GET", "/projects", Public, a.Projects.List},
+        {"GET", "/projects/:id", Public, a.Projects.Get},
+        {"GET", "/jobs", Public, a.Jobs.List},
+        {"GET", "/jobs/:id", Public, a.Jobs.Get},
+        {"GET", "/application-stages", Public, a.Applications.ListStages},
+        {"GET", "/companies/:id", Public, a.Companies.Get},
+        {"GET", "/products", Public, a.Products.List},
+        {"GET", "/products/:id", Public, a.Products.Get},
+        {"GET", "/posts", Public, a.Posts.List},
+        {"GET", "/posts/:id", Public, a.Posts.Get},
+        {"GET", "/company-verifications/:companyId", Public, a.Verifications.Company},
+        {"GET", "/job-compliance/:jobId", Public, a.Compliance.Job},
+        {"GET", "/investors", Public, a.Investors.List},
+        {"GET", "/pitch/:id", Public, a.Pitch.Get},
+        {"GET", "/deal-room/:id", Allow(rbac.DealRoomView), a.DealRoom.Get},
+        {"GET", "/media/:id", Public, a.Media.Get},
+        {"GET", "/media/:id/hls/*file", Public, a.Media.HLS},
+        {"GET", "/media-assets", Public, a.MediaAssets.List},
+        {"GET", "/users/:id", Public, a.Users.Get},
+        {"POST", "/login", Public, a.Auth.Login},
+        {"GET", "/login/verify", Public, a.Auth.VerifyPage},
+        {"POST", "/login/verify", Public, a.Auth.Verify},
+        {"POST", "/logout", Public, a.Auth.Logout},
+        {"GET", "/auth/providers", Public, a.OAuth.List},
+        {"GET", "/auth/:provider/start", Public, a.OAuth.Start},
+        {"GET", "/auth/:provider/callback", Public, a.OAuth.Callback},
+        {"POST", "/auth/:provider/callback", Public, a.OAuth.Callback},
+        {"POST", "/admin/users/merge", Allow(rbac.UsersAdmin), a.OAuth.Merge},
+
+        {"POST", "/projects", Allow(rbac.ContentWrite), a.Projects.Create},
+        {"PUT", "/projects/:id", Allow(rbac.ContentWrite), a.Projects.Update},
+        {"PATCH", "/projects/:id", Allow(rbac.ContentWrite), a.Projects.Update},
+        {"DELETE", "/projects/:id", Allow(rbac.ContentWrite), a.Projects.Delete},
+        {"POST", "/products", Allow(rbac.ContentWrite), a.Products.Create},
+        {"PUT", "/products/:id", Allow(rbac.ContentWrite), a.Products.Update},
+        {"PATCH", "/products/:id", Allow(rbac.ContentWrite), a.Products.Update},
+        {"DELETE", "/products/:id", Allow(rbac.ContentWrite), a.Products.Delete},
+        {"POST", "/posts", Allow(rbac.ContentWrite), a.Posts.Create},
+        {"PUT", "/posts/:id", Allow(rbac.ContentWrite), a.Posts.Update},
+        {"PATCH", "/posts/:id", Allow(rbac.ContentWrite), a.Posts.Update},
+        {"DELETE", "/posts/:id", Allow(rbac.ContentWrite), a.Posts.Delete},
+
+        {"GET", "/me/jobs", Allow(rbac.JobsWrite), a.Jobs.Mine},
+        {"POST", "/jobs", Allow(rbac.JobsWrite), a.Jobs.Create},
+        {"PUT", "/jobs/:id", Allow(rbac.JobsWrite), a.Jobs.Update},
+        {"PATCH", "/jobs/:id", Allow(rbac.JobsWrite), a.Jobs.Update},
+        {"POST", "/jobs/:id/publish", Allow(rbac.JobsWrite), a.Jobs.Publish},
+        {"POST", "/jobs/:id/pause", Allow(rbac.JobsWrite), a.Jobs.Pause},
+        {"POST", "/jobs/:id/close", Allow(rbac.JobsWrite), a.Jobs.Close},
+        {"POST", "/jobs/:id/reopen", Allow(rbac.JobsWrite), a.Jobs.Reopen},
+        {"GET", "/jobs/:id/applications", Allow(rbac.JobsWrite), a.Applications.ForJob},
+        {"POST", "/applications/:id/stage", Allow(rbac.JobsWrite), a.Applications.Move},
+        {"GET", "/job-slots", Allow(rbac.JobsWrite), a.JobSlots.Get},
+
+        {"POST", "/jobs/:id/applications", Allow(rbac.JobsApply), a.Applications.Apply},
+        {"POST", "/pitch", Allow(rbac.DealRoomAdmin), a.Pitch.Create},
+
+        {"GET", "/verification-requests", Allow(rbac.VerificationReview), a.Verifications.Queue},
+        {"POST", "/verification-requests/:id/approve", Allow(rbac.VerificationReview), a.Verifications.Approve},
+        {"POST", "/verification-requests/:id/reject", Allow(rbac.VerificationReview), a.Verifications.Reject},
+
+        {"GET", "/content-moderation", Allow(rbac.ModerationReview), a.Moderation.Queue},
+        {"POST", "/content-moderation/:id/claim", Allow(rbac.ModerationReview), a.Moderation.Claim},
+        {"POST", "/content-moderation/:id/release", Allow(rbac.ModerationReview), a.Moderation.Release},
+        {"POST", "/content-moderation/:id/approve", Allow(rbac.ModerationReview), a.Moderation.Approve},
+        {"POST", "/content-moderation/:id/reject", Allow(rbac.ModerationReview), a.Moderation.Reject},
+        {"POST", "/content-moderation/:id/escalate", Allow(rbac.ModerationReview), a.Moderation.Escalate},
+        {"GET", "/reports", Allow(rbac.ModerationReview), a.Reports.List},
+        {"POST", "/reports/:id/dismiss", Allow(rbac.ModerationReview), a.Reports.Dismiss},
+
+        {"GET", "/me", SignedIn, a.Auth.Me},
+        {"POST", "/logout/all", SignedIn, a.Auth.LogoutAll},
+        {"GET", "/sessions", SignedIn, a.Auth.ListSessions},
+        {"DELETE", "/sessions/:id", SignedIn, a.Auth.RevokeSession},
+        {"GET", "/me/identities", SignedIn, a.OAuth.ListIdentities},
+        {"POST", "/me/identities/:provider", SignedIn, a.OAuth.Bind},
+        {"DELETE", "/me/identities/:provider", SignedIn, a.OAuth.Unbind},
+        {"GET", "/me/applications", SignedIn, a.Applications.Mine},
+        {"GET", "/applications/:id", SignedIn, a.Applications.Get},
+        {"GET", "/applications/:id/events", SignedIn, a.Applications.Events},
+        {"GET", "/applications/:id/resume", SignedIn, a.Applications.Resume},
+        {"GET", "/me/follows", SignedIn, a.Follows.List},
+        {"POST", "/me/follows", SignedIn, a.Follows.Create},
+        {"DELETE", "/me/follows/:type/:target", SignedIn, a.Follows.Delete},
+        {"POST", "/companies", SignedIn, a.Companies.Create},
+        {"PUT", "/companies/:id", SignedIn, a.Companies.Update},
+        {"PATCH", "/companies/:id", SignedIn, a.Companies.Update},
+        {"GET", "/companies/:id/members", SignedIn, a.Companies.Members},
+        {"PATCH", "/companies/:id/members/:userId", SignedIn, a.Companies.SetRole},
+        {"DELETE", "/companies/:id/members/:userId", SignedIn, a.Companies.RemoveMember},
+        {"POST", "/companies/:id/transfer", SignedIn, a.Companies.Transfer},
+        {"GET", "/companies/:id/invitations", SignedIn, a.Companies.Invitations},
+        {"POST", "/companies/:id/invitations", SignedIn, a.Companies.Invite},
+        {"DELETE", "/companies/:id/invitations/:inviteId", SignedIn, a.Companies.Revoke},
+        {"GET", "/me/companies", SignedIn, a.Companies.Mine},
+        {"POST", "/companies/:id/verification-requests", SignedIn, a.Verifications.Create},
+        {"GET", "/companies/:id/verification-requests", SignedIn, a.Verifications.ForCompany},
+        {"GET", "/verification-requests/:id", SignedIn, a.Verifications.Get},
+        {"DELETE", "/verification-requests/:id", SignedIn, a.Verifications.Withdraw},
+        {"POST", "/verification-requests/:id/documents", SignedIn, a.Verifications.Upload},
+        {"GET", "/verification-requests/:id/documents/:docId", SignedIn, a.Verifications.Document},
+        {"POST", "/verification-requests/:id/submit", SignedIn, a.Verifications.Submit},
+        {"GET", "/companies/:id/domain", SignedIn, a.Verifications.Domain},
+        {"PUT", "/companies/:id/domain", SignedIn, a.Verifications.ClaimDomain},
+        {"POST", "/companies/:id/domain/check", SignedIn, a.Verifications.CheckDomain},
+        {"GET", "/content-moderation/:id", SignedIn, a.Moderation.Content},
+        {"POST", "/content-moderation/:id/appeal", SignedIn, a.Moderation.Appeal},
+        {"POST", "/media-uploads", SignedIn, a.Uploads.Create},
+        {"GET", "/media-uploads/:id", SignedIn, a.Uploads.Get},
+        {"POST", "/media-uploads/:id/finalize", SignedIn, a.Uploads.Finalize},
+        {"POST", "/media-uploads/:id/part-urls", SignedIn, a.Uploads.PartURLs},
+        {"GET", "/media-uploads/:id/parts", SignedIn, a.Uploads.Parts},
+        {"DELETE", "/media-uploads/:id", SignedIn, a.Uploads.Abort},
+        {"DELETE", "/media/:id", SignedIn, a.Media.Delete},
+        {"POST", "/media/:id/transcode", SignedIn, a.Transcodes.Retry},
+        {"POST", "/reports", SignedIn, a.Reports.Create},
+        {"GET", "/me/reports", SignedIn, a.Reports.Mine},
+        {"GET", "/me/invitations", SignedIn, a.Companies.MyInvitations},
+        {"POST", "/invitations/:id/accept", SignedIn, a.Companies.Accept},
+        {"POST", "/invitations/:id/decline", SignedIn, a.Companies.Decline},
+        {"GET", "/inbox", SignedIn, a.Inbox.List},
+        {"GET", "/notification-preferences", SignedIn, a.Preferences.Get},
+        {"GET", "/usage", SignedIn, a.Usage.Get},
+        {"GET", "/quota", SignedIn, a.Quota.Get},
+        {"GET", "/capacity-packs", SignedIn, a.Capacity.List},
+        {"GET", "/charges", SignedIn, a.Charges.List},
+    }
+}
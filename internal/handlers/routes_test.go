@@ -0,0 +1,187 @@
+package handlers
+
+import (
+    "net/http"
+    "regexp"
+    "strings"
+    "testing"
+
+    "github.com/gin-gonic/gin"
+
+    "real_deal/internal/rbac"
+)
+
+// routePolicy is who may call each route: "*" anyone, "user" any signed-in
+// user, otherwise the roles (besides admin) that may.
+var routePolicy = map[string]string{
+    "GET /search":                           "*",
+    "GET /feed":                             "*",
+    "GET /explore":                          "*",
+    "GET /projects":                         "*",
+    "GET /projects/:id":                     "*",
+    "GET /jobs":                             "*",
+    "GET /jobs/:id":                         "*",
+    "GET /application-stages":               "*",
+    "GET /companies/:id":                    "*",
+    "GET /products":                         "*",
+    "GET /products/:id":                     "*",
+    "GET /posts":                            "*",
+    "GET /posts/:id":                        "*",
+    "GET /company-verifications/:companyId": "*",
+    "GET /job-compliance/:jobId":            "*",
+    "GET /investors":                        "*",
+    "GET /pitch/:id":                        "*",
+    "GET /deal-room/:id":                    "founder investor",
+    "GET /media/:id":                        "*",
+    "GET /media/:id/hls/*file":              "*",
+    "GET /media-assets":                     "*",
+    "GET /users/:id":                        "*",
+    "POST /login":                           "*",
+    "GET /login/verify":                     "*",
+    "POST /login/verify":                    "*",
+    "POST /logout":                          "*",
+    "GET /auth/providers":                   "*",
+    "GET /auth/:provider/start":             "*",
+    "GET /auth/:provider/callback":          "*",
+    "POST /auth/:provider/callback":         "*",
+    "POST /admin/users/merge":               "",
+
+    "POST /projects":          "candidate recruiter founder investor moderator",
+    "PUT /projects/:id":       "candidate recruiter founder investor moderator",
+    "PATCH /projects/:id":     "candidate recruiter founder investor moderator",
+    "DELETE /projects/:id":    "candidate recruiter founder investor moderator",
+    "POST /products":          "candidate recruiter founder investor moderator",
+    "PUT /products/:id":       "candidate recruiter founder investor moderator",
+    "PATCH /products/:id":     "candidate recruiter founder investor moderator",
+    "DELETE /products/:id":    "candidate recruiter founder investor moderator",
+    "POST /posts":             "candidate recruiter founder investor moderator",
+    "PUT /posts/:id":          "candidate recruiter founder investor moderator",
+    "PATCH /posts/:id":        "candidate recruiter founder investor moderator",
+    "DELETE /posts/:id":       "candidate recruiter founder investor moderator",
+
+    "GET /me/jobs":                  "recruiter",
+    "POST /jobs":                    "recruiter",
+    "PUT /jobs/:id":                 "recruiter",
+    "PATCH /jobs/:id":               "recruiter",
+    "POST /jobs/:id/publish":        "recruiter",
+    "POST /jobs/:id/pause":          "recruiter",
+    "POST /jobs/:id/close":          "recruiter",
+    "POST /jobs/:id/reopen":         "recruiter",
+    "GET /jobs/:id/applications":    "recruiter",
+    "POST /applications/:id/stage":  "recruiter",
+    "GET /job-slots":                "recruiter",
+    "POST /jobs/:id/applications":   "candidate",
+    "POST /pitch":                   "founder",
+
+    "GET /verification-requests":              "moderator",
+    "POST /verification-requests/:id/approve":  "moderator",
+    "POST /verification-requests/:id/reject":   "moderator",
+    "GET /content-moderation":                 "moderator",
+    "POST /content-moderation/:id/claim":      "moderator",
+    "POST /content-moderation/:id/release":    "moderator",
+    "POST /content-moderation/:id/approve":    "moderator",
+    "POST /content-moderation/:id/reject":     "moderator",
+    "POST /content-moderation/:id/escalate":   "moderator",
+    "GET /reports":                            "moderator",
+    "POST /reports/:id/dismiss":               "moderator",
+
+    "GET /me":                                         "user",
+    "POST /logout/all":                                "user",
+    "GET /sessions":                                   "user",
+    "DELETE /sessions/:id":                            "user",
+    "GET /me/identities":                              "user",
+    "POST /me/identities/:provider":                   "user",
+    "DELETE /me/identities/:provider":                 "user",
+    "GET /me/applications":                            "user",
+    "GET /applications/:id":                           "user",
+    "GET /applications/:id/events":                    "user",
+    "GET /applications/:id/resume":                    "user",
+    "GET /me/follows":                                 "user",
+    "POST /me/follows":                                "user",
+    "DELETE /me/follows/:type/:target":                "user",
+    "POST /companies":                                 "user",
+    "PUT /companies/:id":                              "user",
+    "PATCH /companies/:id":                            "user",
+    "GET /companies/:id/members":                      "user",
+    "PATCH /companies/:id/members/:userId":            "user",
+    "DELETE /companies/:id/members/:userId":           "user",
+    "POST /companies/:id/transfer":                    "user",
+    "GET /companies/:id/invitations":                  "user",
+    "POST /companies/:id/invitations":                 "user",
+    "DELETE /companies/:id/invitations/:inviteId":     "user",
+    "GET /me/companies":                               "user",
+    "POST /companies/:id/verification-requests":       "user",
+    "GET /companies/:id/verification-requests":        "user",
+    "GET /verification-requests/:id":                  "user",
+    "DELETE /verification-requests/:id":               "user",
+    "POST /verification-requests/:id/documents":       "user",
+    "GET /verification-requests/:id/documents/:docId": "user",
+    "POST /verification-requests/:id/submit":          "user",
+    "GET /companies/:id/domain":                       "user",
+    "PUT /companies/:id/domain":                       "user",
+    "POST /companies/:id/domain/check":                "user",
+    "GET /content-moderation/:id":                     "user",
+    "POST /content-moderation/:id/appeal":             "user",
+    "POST /media-uploads":                             "user",
+    "GET /media-uploads/:id":                          "user",
+    "POST /media-uploads/:id/finalize":                "user",
+    "POST /media-uploads/:id/part-urls":               "user",
+    "GET /media-uploads/:id/parts":                    "user",
+    "DELETE /media-uploads/:id":                       "user",
+    "DELETE /media/:id":                               "user",
+    "POST /media/:id/transcode":                       "user",
+    "POST /reports":                                   "user",
+    "GET /me/reports":                                 "user",
+    "GET /me/invitations":                             "user",
+    "POST /invitations/:id/accept":                    "user",
+    "POST /invitations/:id/decline":                   "user",
+    "GET /inbox":                                      "user",
+    "GET /notification-preferences":                   "user",
+    "GET /usage":                                      "user",
+    "GET /quota":                                      "user",
+    "GET /capacity-packs":                             "user",
+    "GET /charges":                                    "user",
+}
+
+var routeParam = regexp.MustCompile(`[:*]\w+`)
+
+// TestRoutePolicies mounts the route table behind Authenticate, with every
+// handler stubbed to answer 200, and calls each route as every role.
+func TestRoutePolicies(t *testing.T) {
+    s := newTestServer(t)
+    routes := (&API{}).Routes()
+    ok := func(c *gin.Context) { c.Status(http.StatusOK) }
+    seen := map[string]bool{}
+    for i := range routes {
+        routes[i].Handle = ok
+        key := routes[i].Method + " " + routes[i].Path
+        if _, listed := routePolicy[key]; !listed { t.Errorf("%s has no expected policy", key) }
+        seen[key] = true
+    }
+    for key := range routePolicy {
+        if !seen[key] { t.Errorf("%s is expected but not routed", key) }
+    }
+    Mount(s.router.Group("/api", Authenticate(s.repos.Users, s.sessions)), routes)
+
+    callers := map[string]string{"anonymous": ""}
+    for _, role := range []string{rbac.RoleCandidate, rbac.RoleRecruiter, rbac.RoleFounder, rbac.RoleInvestor, rbac.RoleModerator, rbac.RoleAdmin} {
+        callers[role] = s.login("user-"+role, role)
+    }
+    for _, rt := range routes {
+        key := rt.Method + " " + rt.Path
+        policy := routePolicy[key]
+        path := "/api" + routeParam.ReplaceAllStringFunc(rt.Path, func(p string) string { return "x" + p[1:] })
+        for caller, tok := range callers {
+            want := http.StatusOK
+            switch {
+            case policy == "*":
+            case caller == "anonymous":
+                want = http.StatusUnauthorized
+            case policy == "user" || caller == rbac.RoleAdmin:
+            case !strings.Contains(" "+policy+" ", " "+caller+" "):
+                want = http.StatusForbidden
+            }
+            if w := s.do(rt.Method, path, tok, ""); w.Code != want { t.Errorf("%s as %s: got %d, want %d", key, caller, w.Code, want) }
+        }
+    }
+}
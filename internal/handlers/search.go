@@ -0,0 +1,46 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "log"
+    "net/http"
+    "strconv"
+    "strings"
+
+    "github.com/gin-gonic/gin"
+
+    "real_deal/internal/search"
+)
+
+type SearchHandler struct{ Index search.Engine }
+
+func NewSearch(idx search.Engine) *SearchHandler { return &SearchHandler{Index: idx} }
+
+// Get answers /api/search?q=&types=&limit=, where types is a comma-separated
+// subset of job, company, project, product, post and investor, and limit
+// caps the hits per type (default 5, at most 20).
+func (h *SearchHandler) Get(c *gin.Context) {
+    q := strings.TrimSpace(c.Query("q"))
+    if q == "" { c.JSON(http.StatusBadRequest, gin.H{"error": "q is required"}); return }
+    limit := 5
+    if l := c.Query("limit"); l != "" {
+        n, err := strconv.Atoi(l)
+        if err != nil || n < 1 || n > 20 { c.JSON(http.StatusBadRequest, gin.H{"error": "limit must be a number between 1 and 20"}); return }
+        limit = n
+    }
+    var types []string
+    if t := c.Query("types"); t != "" { types = strings.Split(t, ",") }
+    res, err := h.Index.Search(c.Request.Context(), q, types, limit)
+    if errors.Is(err, search.ErrEmptyQuery) { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, res)
+}
+
+// reindex refreshes a document's search entry after a write. The write has
+// already succeeded, so a failure is only logged; the entry is rebuilt on the
+// document's next write. A nil engine does nothing.
+func reindex(ctx context.Context, idx search.Engine, coll, id string) {
+    if idx == nil { return }
+    if err := idx.Refresh(ctx, coll, id); err != nil { log.Printf("search reindex %s/%s: %v", coll, id, err) }
+}
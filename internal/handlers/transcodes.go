@@ -0,0 +1,171 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "math"
+    "net/http"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "real_deal/internal/config"
+    "real_deal/internal/model"
+    "real_deal/internal/repo"
+    "real_deal/internal/storage"
+    "real_deal/internal/transcode"
+)
+
+// maxTranscodeAttempts bounds how often a job interrupted by a stopped
+// worker is picked up again.
+const maxTranscodeAttempts = 3
+
+// TranscodeHandler queues finalized videos for HLS transcoding and works
+// through the queue. Each video's length, rounded up to whole minutes, is
+// charged to the owner's transcodeMin before any encoding; a video the
+// remaining quota cannot cover is refused. The job's state is mirrored on
+// the asset's Transcode.
+type TranscodeHandler struct {
+    Jobs       repo.TranscodeJobs
+    Media      repo.MediaAssets
+    Billing    repo.Billing
+    Inbox      repo.Inbox
+    Transcoder *transcode.Transcoder
+    Cfg        *config.Config
+}
+
+func NewTranscode(repos *repo.Repos, t *transcode.Transcoder, cfg *config.Config) *TranscodeHandler {
+    return &TranscodeHandler{Jobs: repos.TranscodeJobs, Media: repos.Media, Billing: repos.Billing, Inbox: repos.Inbox, Transcoder: t, Cfg: cfg}
+}
+
+// Enqueue queues a video asset and marks it queued.
+func (h *TranscodeHandler) Enqueue(ctx context.Context, a *MediaAsset) error {
+    now := time.Now().UTC()
+    j := &TranscodeJob{ID: newID("tc"), AssetID: a.ID, OwnerID: a.OwnerID, Key: a.Key, ContentType: a.ContentType, Status: model.TranscodeQueued, CreatedAt: now}
+    if err := h.Jobs.Insert(ctx, j); err != nil { return err }
+    a.Transcode = &model.Transcode{Status: model.TranscodeQueued, UpdatedAt: now}
+    return h.Media.SetTranscode(ctx, a.ID, a.Transcode)
+}
+
+// Retry queues one of the caller's videos again after a failed or refused
+// run, e.g. once more transcode minutes were bought, or for the first time
+// if it was uploaded before transcoding existed.
+func (h *TranscodeHandler) Retry(c *gin.Context) {
+    ctx := c.Request.Context()
+    a, err := h.Media.Get(ctx, c.Param("id"))
+    if err != nil || a.OwnerID != CurrentUser(c).ID { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    if a.Type != "video" { c.JSON(http.StatusBadRequest, gin.H{"error": "only videos are transcoded"}); return }
+    if t := a.Transcode; t != nil && t.Status != model.TranscodeFailed && t.Status != model.TranscodeRefused { c.JSON(http.StatusConflict, gin.H{"error": "transcode " + t.Status}); return }
+    if err := h.Enqueue(ctx, a); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusAccepted, a)
+}
+
+// RunQueued works through queued jobs, oldest first, until none are left
+// or ctx is done, and returns how many it ran. Each job gets
+// Cfg.TranscodeTimeout of its own, so ctx only stops new jobs starting.
+func (h *TranscodeHandler) RunQueued(ctx context.Context) (int, error) {
+    n := 0
+    for ctx.Err() == nil {
+        now := time.Now().UTC()
+        j, err := h.Jobs.Claim(ctx, now, now.Add(h.Cfg.TranscodeTimeout))
+        if errors.Is(err, repo.ErrNotFound) { return n, nil }
+        if err != nil { return n, err }
+        jctx, cancel := context.WithTimeout(context.WithoutCancel(ctx), h.Cfg.TranscodeTimeout)
+        err = h.run(jctx, j)
+        cancel()
+        if err != nil { return n, err }
+        n++
+    }
+    return n, nil
+}
+
+// run transcodes one claimed job and records the outcome. Failures of the
+// video itself end the job; only bookkeeping errors are returned.
+func (h *TranscodeHandler) run(ctx context.Context, j *TranscodeJob) error {
+    if j.Attempts > maxTranscodeAttempts { return h.fail(ctx, j, fmt.Errorf("gave up after %d attempts", maxTranscodeAttempts)) }
+    err := h.Media.SetTranscode(ctx, j.AssetID, &model.Transcode{Status: model.TranscodeRunning, UpdatedAt: time.Now().UTC()})
+    if errors.Is(err, repo.ErrNotFound) { return h.finish(ctx, j, &model.Transcode{Status: model.TranscodeFailed, Error: "asset deleted"}) }
+    if err != nil { return err }
+
+    used, limit, err := h.minutes(ctx, j.OwnerID)
+    if err != nil { return err }
+    if j.Minutes == 0 && used >= limit {
+        return h.finish(ctx, j, &model.Transcode{Status: model.TranscodeRefused, Error: fmt.Sprintf("transcode quota used up (%d of %d minutes)", used, limit)})
+    }
+    // Jobs queued before they recorded it take the asset's content type.
+    if j.ContentType == "" {
+        if a, err := h.Media.Get(ctx, j.AssetID); err == nil { j.ContentType = a.ContentType }
+    }
+    src, err := h.Transcoder.Open(ctx, j.Key, j.ContentType)
+    if errors.Is(err, storage.ErrNotExist) { err = errors.New("source file deleted") }
+    if err != nil { return h.fail(ctx, j, err) }
+    defer src.Close()
+
+    if j.Minutes == 0 {
+        need := max(1, int(math.Ceil(src.Duration.Minutes())))
+        err := h.Billing.ChargeTranscode(ctx, j.OwnerID, need, limit)
+        if errors.Is(err, repo.ErrQuotaExceeded) {
+            return h.finish(ctx, j, &model.Transcode{Status: model.TranscodeRefused, DurationSec: src.Duration.Seconds(), Error: fmt.Sprintf("video needs %d transcode minutes, %d of %d used", need, used, limit)})
+        }
+        if err != nil { return err }
+        j.Minutes = need
+        if err := h.Jobs.SetMinutes(ctx, j.ID, need); err != nil { return err }
+    }
+
+    out, err := h.Transcoder.HLS(ctx, src, "transcodes/"+j.AssetID)
+    if err != nil { return h.fail(ctx, j, err) }
+    return h.finish(ctx, j, &model.Transcode{Status: model.TranscodeDone, DurationSec: src.Duration.Seconds(), Renditions: out.Renditions, Playlist: out.Playlist, Poster: out.Poster})
+}
+
+// fail ends a job that broke on the video or the tools, refunding its
+// minutes and removing partial output. The cause may be that the job ran
+// out of time, so the bookkeeping does not use the job's deadline.
+func (h *TranscodeHandler) fail(ctx context.Context, j *TranscodeJob, cause error) error {
+    ctx = context.WithoutCancel(ctx)
+    if j.Minutes > 0 {
+        if err := h.Billing.ReleaseTranscode(ctx, j.OwnerID, j.Minutes); err != nil { return err }
+        if err := h.Jobs.SetMinutes(ctx, j.ID, 0); err != nil { return err }
+        j.Minutes = 0
+    }
+    if _, err := storage.DeleteAll(ctx, h.Transcoder.Store, "transcodes/"+j.AssetID+"/"); err != nil { return err }
+    return h.finish(ctx, j, &model.Transcode{Status: model.TranscodeFailed, Error: cause.Error()})
+}
+
+var transcodeFeedback = map[string]string{
+    model.TranscodeFailed:  "视频转码失败，可稍后重试：",
+    model.TranscodeRefused: "视频转码时长额度不足，购买额度后可重试：",
+}
+
+// finish records a job's outcome on the job and the asset, and tells the
+// owner when the video could not be transcoded. Output written for an
+// asset deleted meanwhile is removed.
+func (h *TranscodeHandler) finish(ctx context.Context, j *TranscodeJob, t *model.Transcode) error {
+    now := time.Now().UTC()
+    t.UpdatedAt = now
+    if err := h.Jobs.Finish(ctx, j.ID, t.Status, t.Error, now); err != nil { return err }
+    err := h.Media.SetTranscode(ctx, j.AssetID, t)
+    if errors.Is(err, repo.ErrNotFound) {
+        _, err = storage.DeleteAll(ctx, h.Transcoder.Store, "transcodes/"+j.AssetID+"/")
+        return err
+    }
+    if err != nil { return err }
+    if text, ok := transcodeFeedback[t.Status]; ok {
+        title := j.AssetID
+        if a, err := h.Media.Get(ctx, j.AssetID); err == nil { title = a.Title }
+        _ = notify(ctx, h.Inbox, j.OwnerID, "media", text+title, "media", j.AssetID)
+    }
+    return nil
+}
+
+// minutes returns the user's transcode minutes used and limit. A user
+// without a quota record is not limited.
+func (h *TranscodeHandler) minutes(ctx context.Context, userID string) (int, int, error) {
+    limit := math.MaxInt
+    q, err := h.Billing.Quota(ctx, userID)
+    if err == nil { limit = q.TranscodeLimit } else if !errors.Is(err, repo.ErrNotFound) { return 0, 0, err }
+    used := 0
+    us, err := h.Billing.Usage(ctx, userID)
+    if err == nil { used = us.TranscodeMin } else if !errors.Is(err, repo.ErrNotFound) { return 0, 0, err }
+    return used, limit, nil
+}
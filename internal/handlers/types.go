@@ -1,56 +1,85 @@
 package handlers
 
-import "time"
-
-type MediaAsset struct {
-    ID         string    `json:"id"`
-    Type       string    `json:"type"`
-    Title      string    `json:"title"`
-    Key        string    `json:"key"`
-    ContentURL string    `json:"contentUrl"`
-    CreatedAt  time.Time `json:"createdAt"`
-}
-
-type Project struct {
-    ID        string      `json:"id"`
-    Title     string      `json:"title"`
-    Summary   string      `json:"summary"`
-    Tags      []string    `json:"tags"`
-    Media     []MediaAsset `json:"media"`
-}
-
-type Product struct {
-    ID      string   `json:"id"`
-    Name    string   `json:"name"`
-    Summary string   `json:"summary"`
-    Tags    []string `json:"tags"`
-    Media   []MediaAsset `json:"media"`
-}
-
-type Post struct {
-    ID      string    `json:"id"`
-    Title   string    `json:"title"`
-    Body    string    `json:"body"`
-    Tags    []string  `json:"tags"`
-    Created time.Time `json:"created"`
-}
-
-type Job struct {
-    ID       string   `json:"id"`
-    Title    string   `json:"title"`
-    Location string   `json:"location"`
-    Level    string   `json:"level"`
-    Salary   string   `json:"salary"`
-    Skills   []string `json:"skills"`
-}
-
-type Company struct {
-    ID          string   `json:"id"`
-    Name        string   `json:"name"`
-    Description string   `json:"description"`
-    Verified    bool     `json:"verified"`
-    Tags        []string `json:"tags"`
-}
+import (
+    "real_deal/internal/model"
+)
+
+// The aggregates behind the repositories live in internal/model.
+type (
+    User                   = model.User
+    MediaAsset             = model.MediaAsset
+    Job                    = model.Job
+    JobCompliance          = model.JobCompliance
+    Company                = model.Company
+    CompanyMember          = model.CompanyMember
+    CompanyInvitation      = model.CompanyInvitation
+    DealRoom               = model.DealRoom
+    Usage                  = model.Usage
+    Quota                  = model.Quota
+    CapacityPack           = model.CapacityPack
+    JobSlot                = model.JobSlot
+    Charge                 = model.Charge
+    ContentMeta            = model.ContentMeta
+    Project                = model.Project
+    Product                = model.Product
+    Post                   = model.Post
+    Application            = model.Application
+    ApplicationEvent       = model.ApplicationEvent
+    Follow                 = model.Follow
+    InboxItem              = model.InboxItem
+    CompanyVerification    = model.CompanyVerification
+    DomainVerification     = model.DomainVerification
+    VerificationRequest    = model.VerificationRequest
+    VerificationDocument   = model.VerificationDocument
+    ContentModeration      = model.ContentModeration
+    ModerationAppeal       = model.ModerationAppeal
+    ModerationEvent        = model.ModerationEvent
+    Report                 = model.Report
+    NotificationPreference = model.NotificationPreference
+    InvestorProfile        = model.InvestorProfile
+    PitchPage              = model.PitchPage
+    MediaUpload            = model.MediaUpload
+    TranscodeJob           = model.TranscodeJob
+)
+
+const (
+    JobDraft     = model.JobDraft
+    JobPublished = model.JobPublished
+    JobPaused    = model.JobPaused
+    JobClosed    = model.JobClosed
+    JobExpired   = model.JobExpired
+
+    StageRejected = model.StageRejected
+
+    VerificationPending  = model.VerificationPending
+    VerificationApproved = model.VerificationApproved
+    VerificationRejected = model.VerificationRejected
+    VerificationExpired  = model.VerificationExpired
+
+    DomainPending  = model.DomainPending
+    DomainVerified = model.DomainVerified
+
+    RequestDraft     = model.RequestDraft
+    RequestPending   = model.RequestPending
+    RequestApproved  = model.RequestApproved
+    RequestRejected  = model.RequestRejected
+    RequestWithdrawn = model.RequestWithdrawn
+
+    ModerationPending   = model.ModerationPending
+    ModerationEscalated = model.ModerationEscalated
+    ModerationApproved  = model.ModerationApproved
+    ModerationRejected  = model.ModerationRejected
+    ModerationAppealed  = model.ModerationAppealed
+
+    ReportOpen      = model.ReportOpen
+    ReportActioned  = model.ReportActioned
+    ReportDismissed = model.ReportDismissed
+
+    UploadPending   = model.UploadPending
+    UploadFinalized = model.UploadFinalized
+    UploadFailed    = model.UploadFailed
+    UploadAborted   = model.UploadAborted
+)
 
 type ExploreResponse struct {
     Projects []Project `json:"projects"`
@@ -58,79 +87,6 @@ type ExploreResponse struct {
     Posts    []Post    `json:"posts"`
     Jobs     []Job     `json:"jobs"`
     Companies []Company `json:"companies"`
+    // Errors names the sections that failed to load, with the reason.
+    Errors map[string]string `json:"errors,omitempty"`
 }
-
-type CompanyVerification struct {
-    CompanyID string `json:"companyId"`
-    Level     string `json:"level"`
-    Status    string `json:"status"`
-}
-
-type JobCompliance struct {
-    JobID  string `json:"jobId"`
-    Status string `json:"status"`
-    Notes  string `json:"notes"`
-}
-
-type ContentModeration struct {
-    ContentID string `json:"contentId"`
-    Status    string `json:"status"`
-    Notes     string `json:"notes"`
-}
-
-type NotificationPreference struct {
-    UserID string            `json:"userId"`
-    Prefs  map[string]string `json:"prefs"`
-}
-
-type Usage struct {
-    UserID     string  `json:"userId"`
-    StorageGB  float64 `json:"storageGb"`
-    BandwidthGB float64 `json:"bandwidthGb"`
-    TranscodeMin int   `json:"transcodeMin"`
-}
-
-type Quota struct {
-    UserID       string  `json:"userId"`
-    StorageLimit float64 `json:"storageLimit"`
-    BandwidthLimit float64 `json:"bandwidthLimit"`
-    TranscodeLimit int   `json:"transcodeLimit"`
-}
-
-type CapacityPack struct {
-    ID      string  `json:"id"`
-    UserID  string  `json:"userId"`
-    SizeGB  float64 `json:"sizeGb"`
-}
-
-type JobSlot struct {
-    UserID string `json:"userId"`
-    Slots  int    `json:"slots"`
-}
-
-type Charge struct {
-    ID        string  `json:"id"`
-    UserID    string  `json:"userId"`
-    AmountCNY float64 `json:"amountCny"`
-    Reason    string  `json:"reason"`
-}
-
-type InvestorProfile struct {
-    ID      string   `json:"id"`
-    Name    string   `json:"name"`
-    Thesis  string   `json:"thesis"`
-    Stages  []string `json:"stages"`
-    Regions []string `json:"regions"`
-}
-
-type PitchPage struct {
-    ID      string `json:"id"`
-    Title   string `json:"title"`
-    Company string `json:"company"`
-}
-
-type DealRoom struct {
-    ID      string `json:"id"`
-    PitchID string `json:"pitchId"`
-    Access  string `json:"access"`
-}
\ No newline at end of file
@@ -0,0 +1,349 @@
+package handlers
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "log"
+    "math"
+    "mime"
+    "net/http"
+    "path"
+    "regexp"
+    "strings"
+    "time"
+
+    "github.com/gin-gonic/gin"
+
+    "real_deal/internal/config"
+    "real_deal/internal/repo"
+    "real_deal/internal/storage"
+)
+
+// S3 limits on multipart uploads.
+const (
+    minPartSize = 5 << 20
+    maxParts    = 10000
+)
+
+// uploadTypes lists the content types accepted per media type.
+var uploadTypes = map[string][]string{
+    "image":    {"image/jpeg", "image/png", "image/webp", "image/gif"},
+    "video":    {"video/mp4", "video/webm", "video/quicktime"},
+    "document": {"application/pdf"},
+}
+
+// UploadHandler lets clients upload media straight to the object store: it
+// hands out a presigned PUT URL, or part URLs for a multipart upload, and on
+// finalize checks the stored object against what was declared, records the
+// MediaAsset, charges the owner's storage usage and queues the asset for
+// moderation, and videos for transcoding. A user has at most
+// Cfg.MediaMaxUploads unfinished uploads: Create reserves a slot, and an
+// upload gives it back once finalized, failed or aborted.
+type UploadHandler struct {
+    Uploads    repo.Uploads
+    Media      repo.MediaAssets
+    Billing    repo.Billing
+    Store      storage.Store
+    Queue      *ModerationQueue
+    Transcodes *TranscodeHandler
+    Cfg        *config.Config
+}
+
+func NewUpload(repos *repo.Repos, st storage.Store, q *ModerationQueue, t *TranscodeHandler, cfg *config.Config) *UploadHandler {
+    return &UploadHandler{Uploads: repos.Uploads, Media: repos.Media, Billing: repos.Billing, Store: st, Queue: q, Transcodes: t, Cfg: cfg}
+}
+
+// Create starts an upload and returns the URL to PUT the file to, or for a
+// multipart upload the part layout. The declared size must fit the per-type
+// limit and the caller's storage quota.
+func (h *UploadHandler) Create(c *gin.Context) {
+    ctx := c.Request.Context()
+    var u MediaUpload
+    if err := c.ShouldBindJSON(&u); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    ct, ok := uploadContentType(u.Type, u.ContentType)
+    if !ok { c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("%s uploads accept %s", u.Type, strings.Join(uploadTypes[u.Type], ", "))}); return }
+    maxMB := h.Cfg.MediaMaxMB[u.Type]
+    if u.Size > int64(maxMB)<<20 { c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("%s uploads are limited to %d MB", u.Type, maxMB)}); return }
+    owner := CurrentUser(c).ID
+    used, limit, err := h.storage(ctx, owner)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if used+gigabytes(u.Size) > limit { c.JSON(http.StatusPaymentRequired, gin.H{"error": repo.ErrQuotaExceeded.Error(), "code": "storage_quota_exceeded"}); return }
+    err = h.Uploads.Reserve(ctx, owner, h.Cfg.MediaMaxUploads)
+    if errors.Is(err, repo.ErrUploadSlots) { c.JSON(http.StatusTooManyRequests, gin.H{"error": fmt.Sprintf("at most %d unfinished uploads; finalize or abort one first", h.Cfg.MediaMaxUploads), "code": "too_many_uploads"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    inserted := false
+    defer func() {
+        if !inserted { h.release(ctx, owner) }
+    }()
+
+    now := time.Now().UTC()
+    ttl := h.Cfg.MediaUploadTTL
+    u.ID, u.OwnerID, u.ContentType, u.Status, u.Error = newID("media"), owner, ct, UploadPending, ""
+    u.Key = path.Join("uploads", owner, u.ID, safeFileName(u.FileName))
+    u.ExpiresAt, u.CreatedAt = now.Add(ttl), now
+    u.MultipartID, u.PartSize, u.Parts = "", 0, 0
+    if u.Multipart {
+        u.PartSize = partSize(u.Size, int64(h.Cfg.MediaPartMB)<<20)
+        u.Parts = int((u.Size + u.PartSize - 1) / u.PartSize)
+        if u.MultipartID, err = h.Store.NewMultipart(ctx, u.Key, u.ContentType); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+        if err := h.Uploads.Insert(ctx, &u); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+        inserted = true
+        c.JSON(http.StatusCreated, gin.H{"upload": u})
+        return
+    }
+    url, err := h.Store.PresignPut(ctx, u.Key, u.ContentType, ttl)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if err := h.Uploads.Insert(ctx, &u); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    inserted = true
+    c.JSON(http.StatusCreated, gin.H{"upload": u, "method": http.MethodPut, "url": url, "headers": gin.H{"Content-Type": u.ContentType}})
+}
+
+type partURLsReq struct {
+    Parts []int `json:"parts" binding:"required,min=1,max=100"`
+}
+
+// PartURLs presigns PUT URLs for parts of a multipart upload. Asking for
+// URLs keeps the upload from being swept as stale.
+func (h *UploadHandler) PartURLs(c *gin.Context) {
+    ctx := c.Request.Context()
+    var req partURLsReq
+    if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    u, ok := h.loadPending(c)
+    if !ok { return }
+    if u.MultipartID == "" { c.JSON(http.StatusConflict, gin.H{"error": "not a multipart upload"}); return }
+    ttl := h.Cfg.MediaUploadTTL
+    urls := make([]gin.H, 0, len(req.Parts))
+    for _, n := range req.Parts {
+        if n < 1 || n > u.Parts { c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("parts are numbered 1 to %d", u.Parts)}); return }
+        url, err := h.Store.PresignPart(ctx, u.Key, u.MultipartID, n, ttl)
+        if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+        urls = append(urls, gin.H{"part": n, "url": url, "size": u.PartLen(n)})
+    }
+    expires := time.Now().UTC().Add(ttl)
+    if err := h.Uploads.Extend(ctx, u.ID, expires); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, gin.H{"method": http.MethodPut, "urls": urls, "expiresAt": expires})
+}
+
+// Parts lists the parts the store has received, so an interrupted client
+// can resume with the missing ones.
+func (h *UploadHandler) Parts(c *gin.Context) {
+    u, ok := h.loadPending(c)
+    if !ok { return }
+    if u.MultipartID == "" { c.JSON(http.StatusConflict, gin.H{"error": "not a multipart upload"}); return }
+    parts, err := h.Store.ListParts(c.Request.Context(), u.Key, u.MultipartID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, gin.H{"parts": parts, "missing": missingParts(u, parts)})
+}
+
+// Abort gives up an unfinished upload and frees its slot.
+func (h *UploadHandler) Abort(c *gin.Context) {
+    u, ok := h.loadPending(c)
+    if !ok { return }
+    if err := h.abort(c.Request.Context(), u, "aborted by the uploader"); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.Status(http.StatusNoContent)
+}
+
+// SweepStale aborts pending uploads whose URLs expired more than
+// Cfg.MediaUploadStale ago, and returns how many it aborted.
+func (h *UploadHandler) SweepStale(ctx context.Context) (int, error) {
+    stale, err := h.Uploads.Stale(ctx, time.Now().UTC().Add(-h.Cfg.MediaUploadStale))
+    if err != nil { return 0, err }
+    n := 0
+    for i := range stale {
+        if err := h.abort(ctx, &stale[i], "abandoned"); err != nil { return n, err }
+        n++
+    }
+    return n, nil
+}
+
+// abort marks u aborted, frees its slot and drops whatever was uploaded
+// from the store.
+func (h *UploadHandler) abort(ctx context.Context, u *MediaUpload, reason string) error {
+    aborted, err := h.Uploads.Transition(ctx, u.ID, UploadPending, UploadAborted, reason)
+    if err != nil || !aborted { return err }
+    h.release(ctx, u.OwnerID)
+    if u.MultipartID == "" { return h.Store.Delete(ctx, u.Key) }
+    return h.Store.AbortMultipart(ctx, u.Key, u.MultipartID)
+}
+
+// Get returns one of the caller's uploads.
+func (h *UploadHandler) Get(c *gin.Context) {
+    u, ok := h.load(c)
+    if !ok { return }
+    c.JSON(http.StatusOK, u)
+}
+
+// Finalize turns an uploaded object into a MediaAsset, first completing a
+// multipart upload once every part is in. The object must match the
+// declared size and content type; a mismatch fails the upload for good.
+// Finalizing again returns the asset.
+func (h *UploadHandler) Finalize(c *gin.Context) {
+    ctx := c.Request.Context()
+    u, ok := h.load(c)
+    if !ok { return }
+    switch u.Status {
+    case UploadFinalized:
+        a, err := h.Media.Get(ctx, u.ID)
+        if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+        c.JSON(http.StatusOK, a)
+        return
+    case UploadFailed, UploadAborted:
+        c.JSON(http.StatusConflict, gin.H{"error": "upload " + u.Status + ": " + u.Error})
+        return
+    }
+
+    obj, err := h.Store.Stat(ctx, u.Key)
+    if errors.Is(err, storage.ErrNotExist) && u.MultipartID != "" {
+        if !h.complete(c, u) { return }
+        obj, err = h.Store.Stat(ctx, u.Key)
+    }
+    if errors.Is(err, storage.ErrNotExist) { c.JSON(http.StatusConflict, gin.H{"error": "nothing has been uploaded yet"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if obj.Size != u.Size { h.fail(c, u, fmt.Sprintf("uploaded %d bytes, declared %d", obj.Size, u.Size)); return }
+    if ct, _, _ := mime.ParseMediaType(obj.ContentType); !strings.EqualFold(ct, u.ContentType) { h.fail(c, u, fmt.Sprintf("uploaded %q, declared %q", obj.ContentType, u.ContentType)); return }
+
+    // Claim the upload first so concurrent finalizes charge it once.
+    claimed, err := h.Uploads.Transition(ctx, u.ID, UploadPending, UploadFinalized, "")
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if !claimed { c.JSON(http.StatusConflict, gin.H{"error": "upload changed concurrently, retry"}); return }
+    // The slot stays taken until the asset is stored: unclaiming back to
+    // pending keeps it, failing frees it.
+    unclaim := func(status, reason string) {
+        _, _ = h.Uploads.Transition(context.WithoutCancel(ctx), u.ID, UploadFinalized, status, reason)
+        if status == UploadFailed { h.release(ctx, u.OwnerID) }
+    }
+
+    _, limit, err := h.storage(ctx, u.OwnerID)
+    if err == nil { err = h.Billing.ChargeStorage(ctx, u.OwnerID, gigabytes(u.Size), limit) }
+    if errors.Is(err, repo.ErrQuotaExceeded) {
+        unclaim(UploadFailed, err.Error())
+        c.JSON(http.StatusPaymentRequired, gin.H{"error": err.Error(), "code": "storage_quota_exceeded"})
+        return
+    }
+    if err != nil { unclaim(UploadPending, ""); c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+
+    a := &MediaAsset{ID: u.ID, OwnerID: u.OwnerID, Type: u.Type, Title: u.Title, Key: u.Key, ContentType: u.ContentType, Size: u.Size, CreatedAt: time.Now().UTC()}
+    if err := h.Media.Insert(ctx, a); err != nil {
+        _ = h.Billing.ReleaseStorage(context.WithoutCancel(ctx), u.OwnerID, gigabytes(u.Size))
+        unclaim(UploadPending, "")
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    h.release(ctx, u.OwnerID)
+    if rejected, err := h.Queue.SubmitMedia(ctx, a); err != nil {
+        log.Printf("moderation of media %s: %v", a.ID, err)
+    } else if rejected {
+        a.Moderation = ModerationRejected
+    }
+    if a.Type == "video" && a.Moderation != ModerationRejected {
+        if err := h.Transcodes.Enqueue(ctx, a); err != nil { log.Printf("transcode of media %s: %v", a.ID, err) }
+    }
+    c.JSON(http.StatusCreated, a)
+}
+
+// complete assembles a multipart upload once the store has every part at
+// the expected size. It writes the error response itself.
+func (h *UploadHandler) complete(c *gin.Context, u *MediaUpload) bool {
+    ctx := c.Request.Context()
+    parts, err := h.Store.ListParts(ctx, u.Key, u.MultipartID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return false }
+    if missing := missingParts(u, parts); len(missing) > 0 {
+        c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("%d of %d parts uploaded", u.Parts-len(missing), u.Parts), "code": "parts_missing", "missing": missing})
+        return false
+    }
+    for _, p := range parts {
+        if p.Size != u.PartLen(p.Number) { h.fail(c, u, fmt.Sprintf("part %d has %d bytes, expected %d", p.Number, p.Size, u.PartLen(p.Number))); return false }
+    }
+    if err := h.Store.CompleteMultipart(ctx, u.Key, u.MultipartID, parts); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return false }
+    return true
+}
+
+// missingParts lists the part numbers the store does not have yet.
+func missingParts(u *MediaUpload, parts []storage.Part) []int {
+    have := make(map[int]bool, len(parts))
+    for _, p := range parts { have[p.Number] = true }
+    missing := []int{}
+    for n := 1; n <= u.Parts; n++ {
+        if !have[n] { missing = append(missing, n) }
+    }
+    return missing
+}
+
+// partSize picks the configured part size, raised so the upload fits in
+// the store's part limit.
+func partSize(size, want int64) int64 {
+    if want < minPartSize { want = minPartSize }
+    if min := (size + maxParts - 1) / maxParts; want < min { want = min }
+    return want
+}
+
+// fail marks an upload failed, deletes the mismatched object and answers
+// 422 with the reason.
+func (h *UploadHandler) fail(c *gin.Context, u *MediaUpload, reason string) {
+    ctx := c.Request.Context()
+    failed, err := h.Uploads.Transition(ctx, u.ID, UploadPending, UploadFailed, reason)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if failed { h.release(ctx, u.OwnerID) }
+    if u.MultipartID != "" { err = h.Store.AbortMultipart(ctx, u.Key, u.MultipartID) }
+    if err == nil { err = h.Store.Delete(ctx, u.Key) }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusUnprocessableEntity, gin.H{"error": "upload does not match: " + reason, "code": "upload_mismatch"})
+}
+
+// storage returns the user's storage use and limit in GB. A user without a
+// quota record is not limited.
+func (h *UploadHandler) storage(ctx context.Context, userID string) (float64, float64, error) {
+    limit := math.MaxFloat64
+    q, err := h.Billing.Quota(ctx, userID)
+    if err == nil { limit = q.StorageLimit } else if !errors.Is(err, repo.ErrNotFound) { return 0, 0, err }
+    used := 0.0
+    us, err := h.Billing.Usage(ctx, userID)
+    if err == nil { used = us.StorageGB } else if !errors.Is(err, repo.ErrNotFound) { return 0, 0, err }
+    return used, limit, nil
+}
+
+// release gives back one of the owner's upload slots. The request has
+// already succeeded or failed on its own, so a failure is only logged; the
+// owner is a slot short until the counter is corrected.
+func (h *UploadHandler) release(ctx context.Context, ownerID string) {
+    if err := h.Uploads.Release(context.WithoutCancel(ctx), ownerID); err != nil { log.Printf("release upload slot of %s: %v", ownerID, err) }
+}
+
+// loadPending is load for uploads still in progress.
+func (h *UploadHandler) loadPending(c *gin.Context) (*MediaUpload, bool) {
+    u, ok := h.load(c)
+    if ok && u.Status != UploadPending { c.JSON(http.StatusConflict, gin.H{"error": "upload is " + u.Status}); return nil, false }
+    return u, ok
+}
+
+// load reads the upload named by :id; other users' uploads are not found.
+func (h *UploadHandler) load(c *gin.Context) (*MediaUpload, bool) {
+    u, err := h.Uploads.Get(c.Request.Context(), c.Param("id"), CurrentUser(c).ID)
+    if errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return nil, false }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return nil, false }
+    return u, true
+}
+
+// uploadContentType normalises ct and checks it is allowed for the media
+// type.
+func uploadContentType(typ, ct string) (string, bool) {
+    ct, _, err := mime.ParseMediaType(ct)
+    if err != nil { return "", false }
+    for _, allowed := range uploadTypes[typ] {
+        if ct == allowed { return ct, true }
+    }
+    return "", false
+}
+
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]+`)
+
+// safeFileName keeps an object key readable without letting the client
+// shape its path.
+func safeFileName(name string) string {
+    name = strings.Trim(unsafeFileChars.ReplaceAllString(path.Base(strings.ReplaceAll(name, `\`, "/")), "_"), "._")
+    if name == "" { return "file" }
+    return name
+}
+
+func gigabytes(n int64) float64 { return float64(n) / (1 << 30) }
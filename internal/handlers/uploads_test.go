@@ -0,0 +1,85 @@
+package handlers
+
+import (
+    "context"
+    "net/http"
+    "sync"
+    "testing"
+    "time"
+
+    "real_deal/internal/config"
+    "real_deal/internal/screen"
+    "real_deal/internal/storage"
+)
+
+func newUploadServer(t *testing.T, max int) (*testServer, *UploadHandler, *storage.MemoryStore) {
+    s := newTestServer(t)
+    st := storage.NewMemory("http://localhost/objects", "secret")
+    cfg := &config.Config{MediaUploadTTL: time.Hour, MediaMaxMB: map[string]int{"image": 10}, MediaPartMB: 5, MediaMaxUploads: max}
+    queue := NewModerationQueue(s.repos, &screen.Policy{Screener: screen.Chain{}, RejectAt: 0.9, ReviewAt: 0.5})
+    h := NewUpload(s.repos, st, queue, NewTranscode(s.repos, nil, cfg), cfg)
+    me := s.router.Group("/api", Authenticate(s.repos.Users, s.sessions), RequireUser())
+    me.POST("/media-uploads", h.Create)
+    me.POST("/media-uploads/:id/finalize", h.Finalize)
+    me.DELETE("/media-uploads/:id", h.Abort)
+    return s, h, st
+}
+
+const imageUpload = `{"type":"image","title":"Logo","fileName":"logo.png","contentType":"image/png","size":4}`
+
+func TestUploadLimitUnderConcurrency(t *testing.T) {
+    s, _, _ := newUploadServer(t, 3)
+    tok := s.login("u1", "candidate")
+
+    var mu sync.Mutex
+    codes := map[int]int{}
+    var wg sync.WaitGroup
+    for i := 0; i < 20; i++ {
+        wg.Add(1)
+        go func() {
+            defer wg.Done()
+            w := s.do("POST", "/api/media-uploads", tok, imageUpload)
+            mu.Lock()
+            codes[w.Code]++
+            mu.Unlock()
+        }()
+    }
+    wg.Wait()
+    if codes[http.StatusCreated] != 3 || codes[http.StatusTooManyRequests] != 17 { t.Fatalf("got status counts %v, want 3 created and 17 refused", codes) }
+    if n := s.mem.UploadSlots["u1"]; n != 3 { t.Fatalf("slots taken = %d, want 3", n) }
+}
+
+func TestUploadSlotsFreed(t *testing.T) {
+    s, h, st := newUploadServer(t, 1)
+    tok := s.login("u1", "candidate")
+    create := func() *MediaUpload {
+        t.Helper()
+        w := s.do("POST", "/api/media-uploads", tok, imageUpload)
+        if w.Code != http.StatusCreated { t.Fatalf("create: got %d %s", w.Code, w.Body) }
+        return decode[struct{ Upload *MediaUpload `json:"upload"` }](t, w).Upload
+    }
+
+    // aborted
+    u := create()
+    if w := s.do("POST", "/api/media-uploads", tok, imageUpload); w.Code != http.StatusTooManyRequests { t.Fatalf("over limit: got %d", w.Code) }
+    if w := s.do("DELETE", "/api/media-uploads/"+u.ID, tok, ""); w.Code != http.StatusNoContent { t.Fatalf("abort: got %d", w.Code) }
+    if w := s.do("DELETE", "/api/media-uploads/"+u.ID, tok, ""); w.Code != http.StatusConflict { t.Fatalf("abort again: got %d", w.Code) }
+
+    // finalized
+    u = create()
+    if err := st.Put(context.Background(), u.Key, []byte("\x89PNG"), "image/png"); err != nil { t.Fatal(err) }
+    if w := s.do("POST", "/api/media-uploads/"+u.ID+"/finalize", tok, ""); w.Code != http.StatusCreated { t.Fatalf("finalize: got %d %s", w.Code, w.Body) }
+    if w := s.do("POST", "/api/media-uploads/"+u.ID+"/finalize", tok, ""); w.Code != http.StatusOK { t.Fatalf("finalize again: got %d %s", w.Code, w.Body) }
+
+    // failed: the stored object does not match the declared size
+    u = create()
+    if err := st.Put(context.Background(), u.Key, []byte("\x89PNG too long"), "image/png"); err != nil { t.Fatal(err) }
+    if w := s.do("POST", "/api/media-uploads/"+u.ID+"/finalize", tok, ""); w.Code != http.StatusUnprocessableEntity { t.Fatalf("mismatched finalize: got %d %s", w.Code, w.Body) }
+
+    // swept
+    u = create()
+    h.Cfg.MediaUploadStale = -2 * time.Hour
+    if n, err := h.SweepStale(context.Background()); err != nil || n != 1 { t.Fatalf("sweep: %d, %v", n, err) }
+    create()
+    if n := s.mem.UploadSlots["u1"]; n != 1 { t.Fatalf("slots taken = %d, want 1", n) }
+}
@@ -1,22 +1,21 @@
 package handlers
 
 import (
-    "context"
     "net/http"
+
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/repo"
 )
 
-type UsageHandler struct{ DB *mongo.Database }
+type UsageHandler struct{ Billing repo.Billing }
 
-func NewUsage(db *mongo.Database) *UsageHandler { return &UsageHandler{DB: db} }
+func NewUsage(repos *repo.Repos) *UsageHandler { return &UsageHandler{Billing: repos.Billing} }
 
 func (h *UsageHandler) Get(c *gin.Context) {
-    user := c.Query("userId")
-    ctx := context.Background()
-    var u Usage
-    err := h.DB.Collection("usage_meters").FindOne(ctx, bson.M{"userId": user}).Decode(&u)
+    user, ok := targetUserID(c)
+    if !ok { return }
+    u, err := h.Billing.Usage(c.Request.Context(), user)
     if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
     c.JSON(http.StatusOK, u)
-}
\ No newline at end of file
+}
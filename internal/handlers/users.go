@@ -1,22 +1,21 @@
 package handlers
 
 import (
-    "context"
     "net/http"
+
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/repo"
 )
 
-type UserHandler struct{ DB *mongo.Database }
+type UserHandler struct{ Users repo.Users }
 
-func NewUser(db *mongo.Database) *UserHandler { return &UserHandler{DB: db} }
+func NewUser(repos *repo.Repos) *UserHandler { return &UserHandler{Users: repos.Users} }
 
+// Get hides profiles rejected in moderation from everyone but the user
+// and moderators.
 func (h *UserHandler) Get(c *gin.Context) {
-    id := c.Param("id")
-    ctx := context.Background()
-    var u map[string]any
-    err := h.DB.Collection("users").FindOne(ctx, bson.M{"id": id}).Decode(&u)
-    if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
+    u, err := h.Users.Get(c.Request.Context(), c.Param("id"))
+    if err != nil || u.Moderation == ModerationRejected && !canSeeRejected(c, u.ID) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
     c.JSON(http.StatusOK, u)
-}
\ No newline at end of file
+}
@@ -2,21 +2,302 @@ package handlers
 
 import (
     "context"
+    "errors"
+    "io"
     "net/http"
+    "path"
+    "time"
+
     "github.com/gin-gonic/gin"
-    "go.mongodb.org/mongo-driver/bson"
-    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/domaincheck"
+    "real_deal/internal/model"
+    "real_deal/internal/query"
+    "real_deal/internal/rbac"
+    "real_deal/internal/repo"
+    "real_deal/internal/storage"
 )
 
-type VerificationHandler struct{ DB *mongo.Database }
+// verificationLevels are the levels a company can ask for, with the
+// documents each one needs.
+var verificationLevels = map[string][]string{
+    "basic":    {"business_license"},
+    "standard": {"business_license", "domain_proof"},
+    "advanced": {"business_license", "domain_proof", "address_proof"},
+}
+
+var documentTypes = map[string]bool{"application/pdf": true, "image/png": true, "image/jpeg": true}
+
+const maxDocumentSize = 10 << 20
 
-func NewVerification(db *mongo.Database) *VerificationHandler { return &VerificationHandler{DB: db} }
+var verificationSpec = query.Spec{
+    Filters:     map[string]string{"status": "status", "level": "level", "companyId": "companyId"},
+    Sorts:       map[string]string{"submittedAt": "submittedAt", "createdAt": "createdAt"},
+    DefaultSort: "submittedAt",
+}
 
+// VerificationHandler runs company verification: company admins upload
+// documents and submit a request for a level, reviewers approve or reject
+// it, and approvals lapse after TTL. Company.Verified follows the outcome.
+// Domains confirms domain claims, which stand in for a domain_proof document.
+type VerificationHandler struct {
+    Verifications repo.Verifications
+    Requests      repo.VerificationRequests
+    Companies     repo.Companies
+    Inbox         repo.Inbox
+    Store         storage.Store
+    Domains       *domaincheck.Checker
+    TTL           time.Duration
+}
+
+func NewVerification(repos *repo.Repos, st storage.Store, domains *domaincheck.Checker, ttl time.Duration) *VerificationHandler {
+    return &VerificationHandler{
+        Verifications: repos.Verifications, Requests: repos.VerificationRequests, Companies: repos.Companies, Inbox: repos.Inbox,
+        Store: st, Domains: domains, TTL: ttl,
+    }
+}
+
+// Company returns a company's current verification state.
 func (h *VerificationHandler) Company(c *gin.Context) {
-    id := c.Param("companyId")
-    ctx := context.Background()
-    var v CompanyVerification
-    err := h.DB.Collection("company_verifications").FindOne(ctx, bson.M{"companyId": id}).Decode(&v)
+    v, err := h.Verifications.Get(c.Request.Context(), c.Param("companyId"))
     if err != nil { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return }
     c.JSON(http.StatusOK, v)
-}
\ No newline at end of file
+}
+
+type verificationReq struct {
+    Level string `json:"level" binding:"required"`
+}
+
+// Create opens a draft request for a level; documents are added to it
+// before it is submitted.
+func (h *VerificationHandler) Create(c *gin.Context) {
+    var req verificationReq
+    if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    if _, ok := verificationLevels[req.Level]; !ok { c.JSON(http.StatusBadRequest, gin.H{"error": "unknown level"}); return }
+    companyID := c.Param("id")
+    if _, ok := companyRole(c, h.Companies, companyID, model.CompanyRoleAdmin); !ok { return }
+    r := VerificationRequest{
+        ID: newID("vr"), CompanyID: companyID, Level: req.Level, Status: RequestDraft, Open: true,
+        Documents: []VerificationDocument{}, CreatedBy: CurrentUser(c).ID, CreatedAt: time.Now().UTC(),
+    }
+    if err := h.Requests.Insert(c.Request.Context(), &r); err != nil {
+        if errors.Is(err, repo.ErrDuplicate) { c.JSON(http.StatusConflict, gin.H{"error": "the company already has an open verification request"}); return }
+        c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+        return
+    }
+    c.JSON(http.StatusCreated, r)
+}
+
+// ForCompany lists a company's requests, newest first.
+func (h *VerificationHandler) ForCompany(c *gin.Context) {
+    companyID := c.Param("id")
+    if !CurrentUser(c).Can(rbac.VerificationReview) {
+        if _, ok := companyRole(c, h.Companies, companyID, model.CompanyRoleAdmin); !ok { return }
+    }
+    items, err := h.Requests.ForCompany(c.Request.Context(), companyID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusOK, items)
+}
+
+// Queue is the reviewers' view of all requests, oldest submission first;
+// ?status=pending gives the work queue.
+func (h *VerificationHandler) Queue(c *gin.Context) {
+    listWith(c, verificationSpec, func(q *query.Query) (*query.Page[VerificationRequest], error) { return h.Requests.List(c.Request.Context(), q) })
+}
+
+func (h *VerificationHandler) Get(c *gin.Context) {
+    r, ok := h.load(c)
+    if !ok { return }
+    c.JSON(http.StatusOK, r)
+}
+
+// Upload adds a document to a draft request, from a multipart form with
+// `kind` and `file`.
+func (h *VerificationHandler) Upload(c *gin.Context) {
+    ctx := c.Request.Context()
+    r, ok := h.loadForCompany(c)
+    if !ok { return }
+    if r.Status != RequestDraft { c.JSON(http.StatusConflict, gin.H{"error": "documents can only be added to a draft"}); return }
+    kind := c.PostForm("kind")
+    if !documentKind(kind) { c.JSON(http.StatusBadRequest, gin.H{"error": "unknown document kind"}); return }
+    fh, err := c.FormFile("file")
+    if err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": "file required"}); return }
+    if fh.Size > maxDocumentSize { c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": "document larger than 10 MB"}); return }
+    ct := fh.Header.Get("Content-Type")
+    if !documentTypes[ct] { c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "documents must be PDF, PNG or JPEG"}); return }
+    f, err := fh.Open()
+    if err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    defer f.Close()
+    data, err := io.ReadAll(io.LimitReader(f, maxDocumentSize))
+    if err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+
+    d := VerificationDocument{ID: newID("doc"), Kind: kind, Name: path.Base(fh.Filename), ContentType: ct, Size: int64(len(data)), UploadedAt: time.Now().UTC()}
+    d.Key = "verifications/" + r.CompanyID + "/" + r.ID + "/" + d.ID + path.Ext(d.Name)
+    if err := h.Store.Put(ctx, d.Key, data, ct); err != nil { c.JSON(http.StatusBadGateway, gin.H{"error": "storage: " + err.Error()}); return }
+    err = h.Requests.AddDocument(ctx, r.ID, &d)
+    if errors.Is(err, repo.ErrConflict) { c.JSON(http.StatusConflict, gin.H{"error": "documents can only be added to a draft"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    c.JSON(http.StatusCreated, d)
+}
+
+func documentKind(kind string) bool {
+    for _, kinds := range verificationLevels {
+        for _, k := range kinds {
+            if k == kind { return true }
+        }
+    }
+    return false
+}
+
+// Document redirects to a short-lived link to one of the request's files.
+func (h *VerificationHandler) Document(c *gin.Context) {
+    r, ok := h.load(c)
+    if !ok { return }
+    for _, d := range r.Documents {
+        if d.ID != c.Param("docId") { continue }
+        url, err := h.Store.Presign(c.Request.Context(), d.Key, 5*time.Minute)
+        if err != nil { c.JSON(http.StatusBadGateway, gin.H{"error": "storage: " + err.Error()}); return }
+        c.Redirect(http.StatusFound, url)
+        return
+    }
+    c.JSON(http.StatusNotFound, gin.H{"error": "not found"})
+}
+
+// Submit sends a draft for review once it has every document its level
+// needs. A verified domain counts as the domain_proof document.
+func (h *VerificationHandler) Submit(c *gin.Context) {
+    ctx := c.Request.Context()
+    r, ok := h.loadForCompany(c)
+    if !ok { return }
+    if r.Status != RequestDraft { c.JSON(http.StatusConflict, gin.H{"error": "request is " + r.Status}); return }
+    have := map[string]bool{}
+    for _, d := range r.Documents { have[d.Kind] = true }
+    dom, err := h.domain(ctx, r.CompanyID)
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if dom != nil && dom.Status == DomainVerified { have["domain_proof"] = true }
+    var missing []string
+    for _, k := range verificationLevels[r.Level] {
+        if !have[k] { missing = append(missing, k) }
+    }
+    if len(missing) > 0 { c.JSON(http.StatusBadRequest, gin.H{"error": "missing documents", "missing": missing}); return }
+
+    now := time.Now().UTC()
+    err = h.Requests.Submit(ctx, r.ID, now)
+    if errors.Is(err, repo.ErrConflict) { c.JSON(http.StatusConflict, gin.H{"error": "request changed concurrently, retry"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if err := h.Verifications.MarkPending(ctx, r.CompanyID, r.Level, now); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    r.Status, r.SubmittedAt = RequestPending, &now
+    c.JSON(http.StatusOK, r)
+}
+
+// Withdraw closes a draft or pending request without a decision.
+func (h *VerificationHandler) Withdraw(c *gin.Context) {
+    ctx := c.Request.Context()
+    r, ok := h.loadForCompany(c)
+    if !ok { return }
+    if !r.Open { c.JSON(http.StatusConflict, gin.H{"error": "request is " + r.Status}); return }
+    now := time.Now().UTC()
+    err := h.Requests.Close(ctx, r.ID, r.Status, RequestWithdrawn, "", "", now)
+    if errors.Is(err, repo.ErrConflict) { c.JSON(http.StatusConflict, gin.H{"error": "request changed concurrently, retry"}); return }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if r.Status == RequestPending {
+        if err := h.Verifications.ClearPending(ctx, r.CompanyID, now); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    }
+    c.Status(http.StatusNoContent)
+}
+
+type decisionReq struct {
+    Reason string `json:"reason" binding:"max=2000"`
+}
+
+// Approve grants the request's level for TTL and marks the company verified.
+func (h *VerificationHandler) Approve(c *gin.Context) {
+    ctx := c.Request.Context()
+    var req decisionReq
+    if c.Request.ContentLength > 0 {
+        if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    }
+    r, ok := h.decide(c, RequestApproved, req.Reason)
+    if !ok { return }
+    now := *r.ReviewedAt
+    if err := h.Verifications.Approve(ctx, r.CompanyID, r.Level, req.Reason, now, now.Add(h.TTL)); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    if err := h.Companies.SetVerified(ctx, r.CompanyID, true); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    notifyCompany(ctx, h.Inbox, h.Companies, r.CompanyID, model.CompanyRoleAdmin, "企业认证已通过（"+r.Level+"）", "verification_request", r.ID)
+    c.JSON(http.StatusOK, r)
+}
+
+type rejectReq struct {
+    Reason string `json:"reason" binding:"required,max=2000"`
+}
+
+// Reject turns the request down with a reason. A company that is already
+// verified keeps its current level.
+func (h *VerificationHandler) Reject(c *gin.Context) {
+    ctx := c.Request.Context()
+    var req rejectReq
+    if err := c.ShouldBindJSON(&req); err != nil { c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()}); return }
+    r, ok := h.decide(c, RequestRejected, req.Reason)
+    if !ok { return }
+    if err := h.Verifications.Reject(ctx, r.CompanyID, r.Level, req.Reason, *r.ReviewedAt); err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return }
+    notifyCompany(ctx, h.Inbox, h.Companies, r.CompanyID, model.CompanyRoleAdmin, "企业认证未通过："+req.Reason, "verification_request", r.ID)
+    c.JSON(http.StatusOK, r)
+}
+
+// decide closes a pending request with status, writing the error response
+// itself when it cannot.
+func (h *VerificationHandler) decide(c *gin.Context, status, reason string) (*VerificationRequest, bool) {
+    r, ok := h.load(c)
+    if !ok { return nil, false }
+    if r.Status != RequestPending { c.JSON(http.StatusConflict, gin.H{"error": "request is " + r.Status}); return nil, false }
+    now := time.Now().UTC()
+    reviewer := CurrentUser(c).ID
+    err := h.Requests.Close(c.Request.Context(), r.ID, RequestPending, status, reviewer, reason, now)
+    if errors.Is(err, repo.ErrConflict) { c.JSON(http.StatusConflict, gin.H{"error": "request changed concurrently, retry"}); return nil, false }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return nil, false }
+    r.Status, r.Open, r.ReviewerID, r.ReviewedAt, r.Reason = status, false, reviewer, &now, reason
+    return r, true
+}
+
+// ExpireDue lapses approvals past their expiry and unmarks the companies;
+// they have to submit a new request to be verified again.
+func (h *VerificationHandler) ExpireDue(ctx context.Context) (int, error) {
+    now := time.Now().UTC()
+    due, err := h.Verifications.Due(ctx, now)
+    if err != nil { return 0, err }
+    n := 0
+    for _, v := range due {
+        expired, err := h.Verifications.Expire(ctx, &v, now)
+        if err != nil { return n, err }
+        if !expired { continue }
+        n++
+        if err := h.Companies.SetVerified(ctx, v.CompanyID, false); err != nil && !errors.Is(err, repo.ErrNotFound) { return n, err }
+        notifyCompany(ctx, h.Inbox, h.Companies, v.CompanyID, model.CompanyRoleAdmin, "企业认证已过期，请重新提交认证", "company", v.CompanyID)
+    }
+    return n, nil
+}
+
+// load fetches the request named by :id for a reviewer or an admin of its
+// company.
+func (h *VerificationHandler) load(c *gin.Context) (*VerificationRequest, bool) {
+    r, ok := h.get(c)
+    if !ok { return nil, false }
+    if CurrentUser(c).Can(rbac.VerificationReview) { return r, true }
+    if _, ok := companyRole(c, h.Companies, r.CompanyID, model.CompanyRoleAdmin); !ok { return nil, false }
+    return r, true
+}
+
+// loadForCompany is load for the company side only: reviewers do not edit
+// requests on a company's behalf.
+func (h *VerificationHandler) loadForCompany(c *gin.Context) (*VerificationRequest, bool) {
+    r, ok := h.get(c)
+    if !ok { return nil, false }
+    if _, ok := companyRole(c, h.Companies, r.CompanyID, model.CompanyRoleAdmin); !ok { return nil, false }
+    return r, true
+}
+
+func (h *VerificationHandler) get(c *gin.Context) (*VerificationRequest, bool) {
+    r, err := h.Requests.Get(c.Request.Context(), c.Param("id"))
+    if errors.Is(err, repo.ErrNotFound) { c.JSON(http.StatusNotFound, gin.H{"error": "not found"}); return nil, false }
+    if err != nil { c.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()}); return nil, false }
+    return r, true
+}
@@ -0,0 +1,90 @@
+package mail
+
+import (
+    "context"
+    "fmt"
+    "io"
+    "os"
+    "path/filepath"
+    "strings"
+    "sync"
+    "time"
+
+    "real_deal/internal/config"
+)
+
+type Message struct {
+    To      string
+    Subject string
+    Body    string
+}
+
+// Mailer delivers transactional mail such as login links.
+type Mailer interface {
+    Send(ctx context.Context, msg Message) error
+}
+
+// New picks a Mailer from MAIL_DRIVER. Only local drivers exist for now;
+// an SMTP or provider-backed driver plugs in here.
+func New(cfg *config.Config) (Mailer, error) {
+    switch cfg.MailDriver {
+    case "", "stdout":
+        return NewWriter(os.Stdout, cfg.MailFrom), nil
+    case "file":
+        return NewFile(cfg.MailDir, cfg.MailFrom)
+    default:
+        return nil, fmt.Errorf("unknown mail driver %q", cfg.MailDriver)
+    }
+}
+
+// WriterMailer prints each message to w, useful for local development.
+type WriterMailer struct {
+    mu   sync.Mutex
+    w    io.Writer
+    from string
+}
+
+func NewWriter(w io.Writer, from string) *WriterMailer { return &WriterMailer{w: w, from: from} }
+
+func (m *WriterMailer) Send(ctx context.Context, msg Message) error {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    _, err := io.WriteString(m.w, render(m.from, msg, time.Now()))
+    return err
+}
+
+// FileMailer writes each message as a .eml file under Dir.
+type FileMailer struct {
+    Dir  string
+    from string
+}
+
+func NewFile(dir, from string) (*FileMailer, error) {
+    if err := os.MkdirAll(dir, 0o755); err != nil { return nil, err }
+    return &FileMailer{Dir: dir, from: from}, nil
+}
+
+func (m *FileMailer) Send(ctx context.Context, msg Message) error {
+    now := time.Now()
+    name := fmt.Sprintf("%s_%s.eml", now.UTC().Format("20060102T150405.000000000"), sanitize(msg.To))
+    return os.WriteFile(filepath.Join(m.Dir, name), []byte(render(m.from, msg, now)), 0o644)
+}
+
+func render(from string, msg Message, now time.Time) string {
+    var b strings.Builder
+    fmt.Fprintf(&b, "From: %s\r\n", from)
+    fmt.Fprintf(&b, "To: %s\r\n", msg.To)
+    fmt.Fprintf(&b, "Subject: %s\r\n", msg.Subject)
+    fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+    b.WriteString("Content-Type: text/plain; charset=utf-8\r\n\r\n")
+    b.WriteString(msg.Body)
+    b.WriteString("\r\n")
+    return b.String()
+}
+
+func sanitize(s string) string {
+    return strings.Map(func(r rune) rune {
+        if r == '@' || r == '.' || r == '-' || r == '_' || (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') { return r }
+        return '_'
+    }, s)
+}
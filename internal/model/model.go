@@ -0,0 +1,582 @@
+// Package model holds the aggregates shared by the repositories and the HTTP
+// handlers.
+package model
+
+import (
+    "strings"
+    "time"
+
+    "real_deal/internal/compliance"
+    "real_deal/internal/rbac"
+)
+
+// User is an account. Moderation is "rejected" while a moderator has the
+// profile hidden after reports.
+type User struct {
+    ID         string     `json:"id" bson:"id"`
+    Name       string     `json:"name" bson:"name"`
+    Role       string     `json:"role" bson:"role"`
+    Email      string     `json:"email" bson:"email,omitempty"`
+    AvatarURL  string     `json:"avatarUrl,omitempty" bson:"avatarUrl,omitempty"`
+    Moderation string     `json:"moderation,omitempty" bson:"moderation,omitempty"`
+    CreatedAt  *time.Time `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
+}
+
+func (u *User) IsAdmin() bool { return u.Role == rbac.RoleAdmin }
+
+func (u *User) Can(p rbac.Permission) bool { return rbac.Can(u.Role, p) }
+
+// MediaAsset is an uploaded file. Seeded assets have no ContentType or
+// Size. Moderation is "rejected" while a moderator has it hidden.
+type MediaAsset struct {
+    ID          string    `json:"id" bson:"id"`
+    OwnerID     string    `json:"ownerId,omitempty" bson:"ownerId,omitempty"`
+    Type        string    `json:"type" bson:"type"`
+    Title       string    `json:"title" bson:"title"`
+    Key         string    `json:"key" bson:"key"`
+    ContentType string    `json:"contentType,omitempty" bson:"contentType,omitempty"`
+    Size        int64     `json:"size,omitempty" bson:"size,omitempty"`
+    ContentURL  string    `json:"contentUrl" bson:"contentUrl,omitempty"`
+    Moderation  string    `json:"moderation,omitempty" bson:"moderation,omitempty"`
+    Transcode   *Transcode `json:"transcode,omitempty" bson:"transcode,omitempty"`
+    CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// Transcode is where a video asset's HLS transcoding stands. Playlist and
+// Poster are store keys; the URLs are filled in when the asset is served.
+type Transcode struct {
+    Status      string    `json:"status" bson:"status"`
+    Error       string    `json:"error,omitempty" bson:"error,omitempty"`
+    DurationSec float64   `json:"durationSec,omitempty" bson:"durationSec,omitempty"`
+    Renditions  []string  `json:"renditions,omitempty" bson:"renditions,omitempty"`
+    Playlist    string    `json:"-" bson:"playlist,omitempty"`
+    Poster      string    `json:"-" bson:"poster,omitempty"`
+    PlaylistURL string    `json:"playlistUrl,omitempty" bson:"-"`
+    PosterURL   string    `json:"posterUrl,omitempty" bson:"-"`
+    UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+const (
+    TranscodeQueued  = "queued"
+    TranscodeRunning = "running"
+    TranscodeDone    = "done"
+    TranscodeFailed  = "failed"
+    // TranscodeRefused means the owner's transcode quota could not cover
+    // the video.
+    TranscodeRefused = "refused"
+)
+
+// Rejected is the Moderation value of hidden users, jobs and assets.
+const Rejected = "rejected"
+
+// MediaRejected is the Moderation value of a hidden asset.
+const MediaRejected = Rejected
+
+const (
+    JobDraft     = "draft"
+    JobPublished = "published"
+    JobPaused    = "paused"
+    JobClosed    = "closed"
+    JobExpired   = "expired"
+)
+
+// Job is a posting. Jobs seeded before the lifecycle existed have no Status
+// and are treated as published. A published or paused job holds one of its
+// owner's job slots until it is closed or expires.
+type Job struct {
+    ID          string     `json:"id" bson:"id"`
+    OwnerID     string     `json:"ownerId" bson:"ownerId"`
+    Title       string     `json:"title" bson:"title" binding:"required,max=120"`
+    Description string     `json:"description" bson:"description" binding:"max=10000"`
+    Location    string     `json:"location" bson:"location" binding:"max=60"`
+    Level       string     `json:"level" bson:"level" binding:"max=30"`
+    Salary      string     `json:"salary" bson:"salary" binding:"max=60"`
+    Skills      []string   `json:"skills" bson:"skills" binding:"max=20,dive,required,max=32"`
+    CompanyID   string     `json:"companyId,omitempty" bson:"companyId,omitempty"`
+    Status      string     `json:"status" bson:"status"`
+    SlotHeld    bool       `json:"-" bson:"slotHeld"`
+    ExpiresAt   *time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+    PublishedAt *time.Time `json:"publishedAt,omitempty" bson:"publishedAt,omitempty"`
+    // Moderation is "rejected" while a moderator has the job hidden.
+    Moderation  string     `json:"moderation,omitempty" bson:"moderation,omitempty"`
+    CreatedAt   time.Time  `json:"createdAt" bson:"createdAt"`
+    UpdatedAt   time.Time  `json:"updatedAt" bson:"updatedAt"`
+    // Compliance is returned with a create or update and stored apart.
+    Compliance *JobCompliance `json:"compliance,omitempty" bson:"-"`
+}
+
+// Live reports whether anyone may see the job at now.
+func (j *Job) Live(now time.Time) bool {
+    return (j.Status == "" || j.Status == JobPublished) && (j.ExpiresAt == nil || j.ExpiresAt.After(now)) && j.Moderation != Rejected
+}
+
+// Posting is the text the compliance rules check.
+func (j *Job) Posting() *compliance.Posting {
+    return &compliance.Posting{Title: j.Title, Description: j.Description, Location: j.Location, Level: j.Level, Salary: j.Salary, Skills: j.Skills}
+}
+
+// JobCompliance is the latest compliance check of a job, one per job.
+// Status is passed, warning or failed; seeded records have other statuses,
+// Notes only and no Rules.
+type JobCompliance struct {
+    JobID     string                  `json:"jobId" bson:"jobId"`
+    Status    string                  `json:"status" bson:"status"`
+    Notes     string                  `json:"notes" bson:"notes"`
+    Rules     []compliance.RuleResult `json:"rules,omitempty" bson:"rules,omitempty"`
+    CheckedAt *time.Time              `json:"checkedAt,omitempty" bson:"checkedAt,omitempty"`
+}
+
+// Company is a profile users create and run together. Verified is never
+// set from a request body.
+type Company struct {
+    ID          string     `json:"id" bson:"id"`
+    Name        string     `json:"name" bson:"name" binding:"required,max=80"`
+    Description string     `json:"description" bson:"description" binding:"max=5000"`
+    Website     string     `json:"website,omitempty" bson:"website,omitempty" binding:"omitempty,url,max=200"`
+    Verified    bool       `json:"verified" bson:"verified"`
+    Tags        []string   `json:"tags" bson:"tags" binding:"max=10,dive,required,max=24"`
+    CreatedAt   *time.Time `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
+    UpdatedAt   *time.Time `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
+}
+
+// Company member roles, from most to least privileged. A company has exactly
+// one owner; admins manage the profile and team; recruiters post jobs.
+const (
+    CompanyRoleOwner     = "owner"
+    CompanyRoleAdmin     = "admin"
+    CompanyRoleRecruiter = "recruiter"
+    CompanyRoleMember    = "member"
+)
+
+var companyRank = map[string]int{CompanyRoleOwner: 4, CompanyRoleAdmin: 3, CompanyRoleRecruiter: 2, CompanyRoleMember: 1}
+
+// ValidCompanyRole reports whether r is a role a member can be given or
+// invited with; ownership only changes hands by transfer.
+func ValidCompanyRole(r string) bool { return companyRank[r] > 0 && r != CompanyRoleOwner }
+
+type CompanyMember struct {
+    CompanyID string    `json:"companyId" bson:"companyId"`
+    UserID    string    `json:"userId" bson:"userId"`
+    Role      string    `json:"role" bson:"role"`
+    JoinedAt  time.Time `json:"joinedAt" bson:"joinedAt"`
+}
+
+// AtLeast reports whether the member's role is role or above it.
+func (m *CompanyMember) AtLeast(role string) bool { return companyRank[m.Role] >= companyRank[role] }
+
+// Outranks reports whether the member's role is strictly above role, which
+// is what managing someone holding role, or granting it, takes.
+func (m *CompanyMember) Outranks(role string) bool { return companyRank[m.Role] > companyRank[role] }
+
+const (
+    InvitePending  = "pending"
+    InviteAccepted = "accepted"
+    InviteDeclined = "declined"
+    InviteRevoked  = "revoked"
+)
+
+// CompanyInvitation asks whoever signs in with Email to join a company.
+type CompanyInvitation struct {
+    ID          string     `json:"id" bson:"id"`
+    CompanyID   string     `json:"companyId" bson:"companyId"`
+    Email       string     `json:"email" bson:"email"`
+    Role        string     `json:"role" bson:"role"`
+    InvitedBy   string     `json:"invitedBy" bson:"invitedBy"`
+    Status      string     `json:"status" bson:"status"`
+    CreatedAt   time.Time  `json:"createdAt" bson:"createdAt"`
+    ExpiresAt   time.Time  `json:"expiresAt" bson:"expiresAt"`
+    RespondedAt *time.Time `json:"respondedAt,omitempty" bson:"respondedAt,omitempty"`
+}
+
+type DealRoom struct {
+    ID      string `json:"id" bson:"id"`
+    PitchID string `json:"pitchId" bson:"pitchId"`
+    Access  string `json:"access" bson:"access"`
+}
+
+type Usage struct {
+    UserID       string  `json:"userId" bson:"userId"`
+    StorageGB    float64 `json:"storageGb" bson:"storageGb"`
+    BandwidthGB  float64 `json:"bandwidthGb" bson:"bandwidthGb"`
+    TranscodeMin int     `json:"transcodeMin" bson:"transcodeMin"`
+}
+
+type Quota struct {
+    UserID         string  `json:"userId" bson:"userId"`
+    StorageLimit   float64 `json:"storageLimit" bson:"storageLimit"`
+    BandwidthLimit float64 `json:"bandwidthLimit" bson:"bandwidthLimit"`
+    TranscodeLimit int     `json:"transcodeLimit" bson:"transcodeLimit"`
+}
+
+type CapacityPack struct {
+    ID     string  `json:"id" bson:"id"`
+    UserID string  `json:"userId" bson:"userId"`
+    SizeGB float64 `json:"sizeGb" bson:"sizeGb"`
+}
+
+type JobSlot struct {
+    UserID string `json:"userId" bson:"userId"`
+    Slots  int    `json:"slots" bson:"slots"`
+}
+
+type Charge struct {
+    ID        string  `json:"id" bson:"id"`
+    UserID    string  `json:"userId" bson:"userId"`
+    AmountCNY float64 `json:"amountCny" bson:"amountCny"`
+    Reason    string  `json:"reason" bson:"reason"`
+}
+
+// ContentMeta holds the server-managed fields of user-authored content. The
+// server sets every one of them; values sent by clients are ignored.
+// Moderation is "rejected" while a moderator has the content hidden.
+type ContentMeta struct {
+    ID         string     `json:"id" bson:"id"`
+    AuthorID   string     `json:"authorId" bson:"authorId"`
+    CreatedAt  time.Time  `json:"createdAt" bson:"createdAt"`
+    UpdatedAt  time.Time  `json:"updatedAt" bson:"updatedAt"`
+    DeletedAt  *time.Time `json:"-" bson:"deletedAt,omitempty"`
+    Moderation string     `json:"moderation,omitempty" bson:"moderation,omitempty"`
+}
+
+// Live reports whether anyone may see the content.
+func (m *ContentMeta) Live() bool { return m.DeletedAt == nil && m.Moderation != Rejected }
+
+type Project struct {
+    ContentMeta `bson:",inline"`
+    Title     string      `json:"title" bson:"title" binding:"required,max=120"`
+    Summary   string      `json:"summary" bson:"summary" binding:"max=2000"`
+    Tags      []string    `json:"tags" bson:"tags" binding:"max=10,dive,required,max=32"`
+    Media     []MediaAsset `json:"media" bson:"media"`
+}
+
+type Product struct {
+    ContentMeta `bson:",inline"`
+    Name    string   `json:"name" bson:"name" binding:"required,max=120"`
+    Summary string   `json:"summary" bson:"summary" binding:"max=2000"`
+    Tags    []string `json:"tags" bson:"tags" binding:"max=10,dive,required,max=32"`
+    Media   []MediaAsset `json:"media" bson:"media"`
+}
+
+type Post struct {
+    ContentMeta `bson:",inline"`
+    Title   string    `json:"title" bson:"title" binding:"required,max=200"`
+    Body    string    `json:"body" bson:"body" binding:"required,max=20000"`
+    Tags    []string  `json:"tags" bson:"tags" binding:"max=10,dive,required,max=32"`
+}
+
+func (p *Project) Meta() *ContentMeta { return &p.ContentMeta }
+func (p *Product) Meta() *ContentMeta { return &p.ContentMeta }
+func (p *Post) Meta() *ContentMeta    { return &p.ContentMeta }
+
+// ScreenText is the title and text screened before moderation.
+func (p *Project) ScreenText() (string, string) { return p.Title, p.Summary + "\n" + strings.Join(p.Tags, " ") }
+func (p *Product) ScreenText() (string, string) { return p.Name, p.Summary + "\n" + strings.Join(p.Tags, " ") }
+func (p *Post) ScreenText() (string, string)    { return p.Title, p.Body + "\n" + strings.Join(p.Tags, " ") }
+
+const StageRejected = "rejected"
+
+type Application struct {
+    ID          string    `json:"id" bson:"id"`
+    JobID       string    `json:"jobId" bson:"jobId"`
+    CandidateID string    `json:"candidateId" bson:"candidateId"`
+    RecruiterID string    `json:"recruiterId" bson:"recruiterId"`
+    ResumeID    string    `json:"resumeId" bson:"resumeId"`
+    CoverNote   string    `json:"coverNote" bson:"coverNote"`
+    Stage       string    `json:"stage" bson:"stage"`
+    CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+    UpdatedAt   time.Time `json:"updatedAt" bson:"updatedAt"`
+}
+
+// ApplicationEvent is the audit record of one stage change.
+type ApplicationEvent struct {
+    ApplicationID string    `json:"applicationId" bson:"applicationId"`
+    ActorID       string    `json:"actorId" bson:"actorId"`
+    From          string    `json:"from" bson:"from"`
+    To            string    `json:"to" bson:"to"`
+    Note          string    `json:"note" bson:"note"`
+    At            time.Time `json:"at" bson:"at"`
+}
+
+// Follow records that a user follows another user, a company or a tag. The
+// feed ranks followed authors, companies and tags higher.
+type Follow struct {
+    UserID    string    `json:"userId" bson:"userId"`
+    Type      string    `json:"type" bson:"type" binding:"required,oneof=user company tag"`
+    Target    string    `json:"target" bson:"target" binding:"required,max=64"`
+    CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+type InboxItem struct {
+    UserID    string    `json:"userId" bson:"userId"`
+    Type      string    `json:"type" bson:"type"`
+    Text      string    `json:"text" bson:"text"`
+    RefType   string    `json:"refType,omitempty" bson:"refType,omitempty"`
+    RefID     string    `json:"refId,omitempty" bson:"refId,omitempty"`
+    CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+const (
+    VerificationPending  = "pending"
+    VerificationApproved = "approved"
+    VerificationRejected = "rejected"
+    VerificationExpired  = "expired"
+)
+
+// CompanyVerification is a company's current verification state. Level is
+// the level granted, or the one asked for while a first request is pending;
+// PendingLevel is set while an approved company is being re-reviewed. Seeded
+// records have no ExpiresAt and never expire. A company that has only claimed
+// a domain has a record with just Domain.
+type CompanyVerification struct {
+    CompanyID    string              `json:"companyId" bson:"companyId"`
+    Level        string              `json:"level,omitempty" bson:"level"`
+    Status       string              `json:"status,omitempty" bson:"status"`
+    Domain       *DomainVerification `json:"domain,omitempty" bson:"domain,omitempty"`
+    PendingLevel string     `json:"pendingLevel,omitempty" bson:"pendingLevel,omitempty"`
+    Reason       string     `json:"reason,omitempty" bson:"reason,omitempty"`
+    ApprovedAt   *time.Time `json:"approvedAt,omitempty" bson:"approvedAt,omitempty"`
+    ExpiresAt    *time.Time `json:"expiresAt,omitempty" bson:"expiresAt,omitempty"`
+    UpdatedAt    *time.Time `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
+}
+
+const (
+    DomainPending  = "pending"
+    DomainVerified = "verified"
+)
+
+// DomainVerification is a company's claim on a domain. The company publishes
+// Token in DNS or a well-known file and a check confirms it; claiming a new
+// domain issues a new token and starts over.
+type DomainVerification struct {
+    Name       string     `json:"name" bson:"name"`
+    Token      string     `json:"-" bson:"token"`
+    Status     string     `json:"status" bson:"status"`
+    Method     string     `json:"method,omitempty" bson:"method,omitempty"`
+    ClaimedBy  string     `json:"claimedBy" bson:"claimedBy"`
+    ClaimedAt  time.Time  `json:"claimedAt" bson:"claimedAt"`
+    CheckedAt  *time.Time `json:"checkedAt,omitempty" bson:"checkedAt,omitempty"`
+    VerifiedAt *time.Time `json:"verifiedAt,omitempty" bson:"verifiedAt,omitempty"`
+    LastError  string     `json:"lastError,omitempty" bson:"lastError,omitempty"`
+}
+
+const (
+    RequestDraft     = "draft"
+    RequestPending   = "pending"
+    RequestApproved  = "approved"
+    RequestRejected  = "rejected"
+    RequestWithdrawn = "withdrawn"
+)
+
+// VerificationRequest is one submission for a verification level. Open is
+// set while it is a draft or pending; a company has at most one open request.
+type VerificationRequest struct {
+    ID          string                 `json:"id" bson:"id"`
+    CompanyID   string                 `json:"companyId" bson:"companyId"`
+    Level       string                 `json:"level" bson:"level"`
+    Status      string                 `json:"status" bson:"status"`
+    Open        bool                   `json:"-" bson:"open,omitempty"`
+    Documents   []VerificationDocument `json:"documents" bson:"documents"`
+    CreatedBy   string                 `json:"createdBy" bson:"createdBy"`
+    CreatedAt   time.Time              `json:"createdAt" bson:"createdAt"`
+    SubmittedAt *time.Time             `json:"submittedAt,omitempty" bson:"submittedAt,omitempty"`
+    ReviewerID  string                 `json:"reviewerId,omitempty" bson:"reviewerId,omitempty"`
+    ReviewedAt  *time.Time             `json:"reviewedAt,omitempty" bson:"reviewedAt,omitempty"`
+    Reason      string                 `json:"reason,omitempty" bson:"reason,omitempty"`
+}
+
+// VerificationDocument is an uploaded proof; the file itself is in the
+// object store under Key.
+type VerificationDocument struct {
+    ID          string    `json:"id" bson:"id"`
+    Kind        string    `json:"kind" bson:"kind"`
+    Name        string    `json:"name" bson:"name"`
+    ContentType string    `json:"contentType" bson:"contentType"`
+    Size        int64     `json:"size" bson:"size"`
+    Key         string    `json:"-" bson:"key"`
+    UploadedAt  time.Time `json:"uploadedAt" bson:"uploadedAt"`
+}
+
+const (
+    ModerationPending   = "pending"
+    ModerationEscalated = "escalated"
+    ModerationApproved  = "approved"
+    ModerationRejected  = "rejected"
+    ModerationAppealed  = "appealed"
+)
+
+// ContentModeration is the moderation record of one post, project, product
+// or media asset, or of a job or user that was reported. Notes holds the
+// last reviewer's note, or the screener's reasons when it flagged the
+// content, and Score the screener's score; Reports counts the open user
+// reports. History holds every action. Seeded records carry only
+// ContentID, Status and Notes.
+type ContentModeration struct {
+    ContentID   string            `json:"contentId" bson:"contentId"`
+    ContentType string            `json:"contentType,omitempty" bson:"contentType,omitempty"`
+    AuthorID    string            `json:"authorId,omitempty" bson:"authorId,omitempty"`
+    Title       string            `json:"title,omitempty" bson:"title,omitempty"`
+    Status      string            `json:"status" bson:"status"`
+    Notes       string            `json:"notes" bson:"notes"`
+    Score       float64           `json:"score" bson:"score"`
+    Reports     int               `json:"reports,omitempty" bson:"reports,omitempty"`
+    ClaimedBy   string            `json:"claimedBy,omitempty" bson:"claimedBy,omitempty"`
+    ClaimedAt   *time.Time        `json:"claimedAt,omitempty" bson:"claimedAt,omitempty"`
+    ReviewerID  string            `json:"reviewerId,omitempty" bson:"reviewerId,omitempty"`
+    ReviewedAt  *time.Time        `json:"reviewedAt,omitempty" bson:"reviewedAt,omitempty"`
+    Appeal      *ModerationAppeal `json:"appeal,omitempty" bson:"appeal,omitempty"`
+    History     []ModerationEvent `json:"history,omitempty" bson:"history,omitempty"`
+    CreatedAt   *time.Time        `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
+    UpdatedAt   *time.Time        `json:"updatedAt,omitempty" bson:"updatedAt,omitempty"`
+}
+
+// ModerationAppeal is the author's one appeal against a rejection. Outcome
+// is "upheld" or "overturned" once it is decided.
+type ModerationAppeal struct {
+    Reason    string    `json:"reason" bson:"reason"`
+    CreatedAt time.Time `json:"createdAt" bson:"createdAt"`
+    Outcome   string    `json:"outcome,omitempty" bson:"outcome,omitempty"`
+}
+
+type ModerationEvent struct {
+    Action string    `json:"action" bson:"action"`
+    By     string    `json:"by" bson:"by"`
+    Note   string    `json:"note,omitempty" bson:"note,omitempty"`
+    At     time.Time `json:"at" bson:"at"`
+}
+
+const (
+    ReportOpen      = "open"
+    ReportActioned  = "actioned"
+    ReportDismissed = "dismissed"
+)
+
+// Report is one user's report of a post, project, product, media asset,
+// job or user. A reporter has at most one open report per target. Status
+// is open until a reviewer decides: actioned when the target was rejected,
+// dismissed otherwise.
+type Report struct {
+    ID         string     `json:"id" bson:"id"`
+    ReporterID string     `json:"reporterId" bson:"reporterId"`
+    TargetType string     `json:"targetType" bson:"targetType" binding:"required,oneof=post project product media job user"`
+    TargetID   string     `json:"targetId" bson:"targetId" binding:"required,max=64"`
+    Category   string     `json:"category" bson:"category" binding:"required,oneof=spam abuse fraud fake_job inappropriate impersonation other"`
+    Text       string     `json:"text" bson:"text" binding:"max=2000"`
+    Status     string     `json:"status" bson:"status"`
+    ResolvedBy string     `json:"-" bson:"resolvedBy,omitempty"`
+    ResolvedAt *time.Time `json:"resolvedAt,omitempty" bson:"resolvedAt,omitempty"`
+    CreatedAt  time.Time  `json:"createdAt" bson:"createdAt"`
+}
+
+type NotificationPreference struct {
+    UserID string            `json:"userId"`
+    Prefs  map[string]string `json:"prefs"`
+}
+
+type InvestorProfile struct {
+    ID      string   `json:"id" bson:"id"`
+    Name    string   `json:"name" bson:"name"`
+    Thesis  string   `json:"thesis" bson:"thesis"`
+    Stages  []string `json:"stages" bson:"stages"`
+    Regions []string `json:"regions" bson:"regions"`
+}
+
+// PitchPage is a founder's pitch. Company is the display name; pages created
+// through the API also carry the CompanyID their owner belongs to.
+type PitchPage struct {
+    ID        string     `json:"id" bson:"id"`
+    Title     string     `json:"title" bson:"title" binding:"required,max=120"`
+    Company   string     `json:"company" bson:"company"`
+    CompanyID string     `json:"companyId,omitempty" bson:"companyId,omitempty" binding:"required"`
+    OwnerID   string     `json:"ownerId,omitempty" bson:"ownerId,omitempty"`
+    CreatedAt *time.Time `json:"createdAt,omitempty" bson:"createdAt,omitempty"`
+}
+
+const (
+    UploadPending   = "pending"
+    UploadFinalized = "finalized"
+    UploadFailed    = "failed"
+    UploadAborted   = "aborted"
+)
+
+// MediaUpload is a direct-to-storage upload. Its ID becomes the asset's once
+// finalized; Error says why a failed or aborted upload was refused.
+// Multipart uploads are sent in Parts parts of PartSize bytes (the last may
+// be shorter); ExpiresAt moves forward whenever part URLs are handed out.
+type MediaUpload struct {
+    ID          string    `json:"id" bson:"id"`
+    OwnerID     string    `json:"ownerId" bson:"ownerId"`
+    Type        string    `json:"type" bson:"type" binding:"required,oneof=image video document"`
+    Title       string    `json:"title" bson:"title" binding:"required,max=120"`
+    FileName    string    `json:"fileName" bson:"fileName" binding:"required,max=200"`
+    ContentType string    `json:"contentType" bson:"contentType" binding:"required,max=100"`
+    Size        int64     `json:"size" bson:"size" binding:"required,gt=0"`
+    Multipart   bool      `json:"multipart" bson:"multipart"`
+    MultipartID string    `json:"-" bson:"multipartId,omitempty"`
+    PartSize    int64     `json:"partSize,omitempty" bson:"partSize,omitempty"`
+    Parts       int       `json:"parts,omitempty" bson:"parts,omitempty"`
+    Key         string    `json:"key" bson:"key"`
+    Status      string    `json:"status" bson:"status"`
+    Error       string    `json:"error,omitempty" bson:"error,omitempty"`
+    ExpiresAt   time.Time `json:"expiresAt" bson:"expiresAt"`
+    CreatedAt   time.Time `json:"createdAt" bson:"createdAt"`
+}
+
+// PartLen is the expected size of part n.
+func (u *MediaUpload) PartLen(n int) int64 {
+    if n < u.Parts { return u.PartSize }
+    return u.Size - int64(u.Parts-1)*u.PartSize
+}
+
+// TranscodeJob is one transcoding run over a video asset. A running job
+// whose LockedUntil has passed was left by a stopped worker and is picked
+// up again; Minutes, once set, have been charged to the owner.
+type TranscodeJob struct {
+    ID          string     `json:"id" bson:"id"`
+    AssetID     string     `json:"assetId" bson:"assetId"`
+    OwnerID     string     `json:"ownerId" bson:"ownerId"`
+    Key         string     `json:"key" bson:"key"`
+    ContentType string     `json:"contentType,omitempty" bson:"contentType,omitempty"`
+    Status      string     `json:"status" bson:"status"`
+    Attempts    int        `json:"attempts" bson:"attempts"`
+    Minutes     int        `json:"minutes,omitempty" bson:"minutes,omitempty"`
+    Error       string     `json:"error,omitempty" bson:"error,omitempty"`
+    LockedUntil *time.Time `json:"-" bson:"lockedUntil,omitempty"`
+    CreatedAt   time.Time  `json:"createdAt" bson:"createdAt"`
+    FinishedAt  *time.Time `json:"finishedAt,omitempty" bson:"finishedAt,omitempty"`
+}
+
+// LoginToken is a one-time email login token. Only the SHA-256 of the token
+// is stored, so leaked records cannot be replayed.
+type LoginToken struct {
+    Hash      string     `bson:"hash"`
+    Email     string     `bson:"email"`
+    CreatedAt time.Time  `bson:"createdAt"`
+    ExpiresAt time.Time  `bson:"expiresAt"`
+    UsedAt    *time.Time `bson:"usedAt"`
+}
+
+// OAuthFlow is the server-side half of an in-flight OAuth login, stored
+// under the hash of its state parameter. Browser is the hash of the cookie
+// given to the browser that started it. UserID is set when an already
+// signed-in user is binding a new provider rather than logging in.
+type OAuthFlow struct {
+    Hash      string    `bson:"hash"`
+    Browser   string    `bson:"browser"`
+    Provider  string    `bson:"provider"`
+    Verifier  string    `bson:"verifier"`
+    Nonce     string    `bson:"nonce"`
+    UserID    string    `bson:"userId,omitempty"`
+    Merge     bool      `bson:"merge"`
+    ExpiresAt time.Time `bson:"expiresAt"`
+}
+
+// UserIdentity links an external provider subject to users.id.
+type UserIdentity struct {
+    UserID        string    `json:"userId" bson:"userId"`
+    Provider      string    `json:"provider" bson:"provider"`
+    Subject       string    `json:"subject" bson:"subject"`
+    Email         string    `json:"email" bson:"email"`
+    EmailVerified bool      `json:"emailVerified" bson:"emailVerified"`
+    Name          string    `json:"name" bson:"name"`
+    LinkedAt      time.Time `json:"linkedAt" bson:"linkedAt"`
+}
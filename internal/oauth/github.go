@@ -0,0 +1,54 @@
+package oauth
+
+import (
+    "context"
+    "errors"
+    "strings"
+)
+
+// GitHubProvider uses GitHub's OAuth apps, which are plain OAuth 2.0 (no ID
+// token) but do honour PKCE.
+type GitHubProvider struct {
+    Client  Client
+    BaseURL string // https://github.com
+    APIURL  string // https://api.github.com
+}
+
+func NewGitHub(cl Client) *GitHubProvider {
+    if len(cl.Scopes) == 0 { cl.Scopes = []string{"read:user", "user:email"} }
+    return &GitHubProvider{Client: cl, BaseURL: "https://github.com", APIURL: "https://api.github.com"}
+}
+
+func (p *GitHubProvider) Name() string { return "github" }
+
+func (p *GitHubProvider) AuthCodeURL(ctx context.Context, r AuthRequest) (string, error) {
+    r.Nonce = ""
+    return authURL(p.BaseURL+"/login/oauth/authorize", p.Client, r, nil)
+}
+
+func (p *GitHubProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+    tok, err := exchangeCode(ctx, p.Client, p.BaseURL+"/login/oauth/access_token", code, verifier)
+    if err != nil { return nil, err }
+    access, _ := tok["access_token"].(string)
+    if access == "" { return nil, errors.New("github: no access token") }
+    var u map[string]any
+    if err := getJSON(ctx, p.Client.http(), p.APIURL+"/user", access, &u); err != nil { return nil, err }
+    id := &Identity{Provider: "github", Subject: str(u, "id"), Name: str(u, "name"), AvatarURL: str(u, "avatar_url")}
+    if id.Name == "" { id.Name = str(u, "login") }
+    // The profile email may be unverified or hidden; the emails API says which is primary and verified.
+    var emails []struct {
+        Email    string `json:"email"`
+        Primary  bool   `json:"primary"`
+        Verified bool   `json:"verified"`
+    }
+    if err := getJSON(ctx, p.Client.http(), p.APIURL+"/user/emails", access, &emails); err == nil {
+        for _, e := range emails {
+            if e.Primary {
+                id.Email, id.EmailVerified = strings.ToLower(e.Email), e.Verified
+                break
+            }
+        }
+    }
+    if id.Subject == "" { return nil, errors.New("github: no user id") }
+    return id, nil
+}
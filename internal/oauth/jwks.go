@@ -0,0 +1,101 @@
+package oauth
+
+import (
+    "context"
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rsa"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "math/big"
+    "strings"
+)
+
+// jwk is one key of a JSON Web Key Set. Only RSA and P-256 keys are used.
+type jwk struct {
+    Kty string `json:"kty"`
+    Kid string `json:"kid"`
+    Use string `json:"use"`
+    N   string `json:"n"`
+    E   string `json:"e"`
+    Crv string `json:"crv"`
+    X   string `json:"x"`
+    Y   string `json:"y"`
+}
+
+func (k *jwk) publicKey() (crypto.PublicKey, error) {
+    num := func(s string) (*big.Int, error) {
+        b, err := base64.RawURLEncoding.DecodeString(s)
+        if err != nil || len(b) == 0 { return nil, fmt.Errorf("key %s: bad number", k.Kid) }
+        return new(big.Int).SetBytes(b), nil
+    }
+    switch k.Kty {
+    case "RSA":
+        n, err := num(k.N)
+        if err != nil { return nil, err }
+        e, err := num(k.E)
+        if err != nil { return nil, err }
+        if !e.IsInt64() || e.Int64() > 1<<31-1 { return nil, fmt.Errorf("key %s: bad exponent", k.Kid) }
+        return &rsa.PublicKey{N: n, E: int(e.Int64())}, nil
+    case "EC":
+        if k.Crv != "P-256" { return nil, fmt.Errorf("key %s: unsupported curve %s", k.Kid, k.Crv) }
+        x, err := num(k.X)
+        if err != nil { return nil, err }
+        y, err := num(k.Y)
+        if err != nil { return nil, err }
+        if !elliptic.P256().IsOnCurve(x, y) { return nil, fmt.Errorf("key %s: point not on curve", k.Kid) }
+        return &ecdsa.PublicKey{Curve: elliptic.P256(), X: x, Y: y}, nil
+    }
+    return nil, fmt.Errorf("key %s: unsupported type %s", k.Kid, k.Kty)
+}
+
+// fetchKeys loads the signing keys published at jwksURI by key id. Keys of
+// unsupported types are skipped.
+func fetchKeys(ctx context.Context, cl Client, jwksURI string) (map[string]crypto.PublicKey, error) {
+    var set struct{ Keys []jwk `json:"keys"` }
+    if err := getJSON(ctx, cl.http(), jwksURI, "", &set); err != nil { return nil, fmt.Errorf("jwks: %w", err) }
+    keys := map[string]crypto.PublicKey{}
+    for _, k := range set.Keys {
+        if k.Use != "" && k.Use != "sig" { continue }
+        if pub, err := k.publicKey(); err == nil { keys[k.Kid] = pub }
+    }
+    return keys, nil
+}
+
+// verifyJWS checks the signature of a compact JWS against key, which must
+// suit the header's alg: RS256 or ES256.
+func verifyJWS(parts []string, alg string, key crypto.PublicKey) error {
+    sig, err := base64.RawURLEncoding.DecodeString(parts[2])
+    if err != nil { return errors.New("id_token signature: bad encoding") }
+    sum := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+    switch k := key.(type) {
+    case *rsa.PublicKey:
+        if alg != "RS256" { break }
+        if rsa.VerifyPKCS1v15(k, crypto.SHA256, sum[:], sig) != nil { return errors.New("id_token signature invalid") }
+        return nil
+    case *ecdsa.PublicKey:
+        if alg != "ES256" { break }
+        if len(sig) != 64 { return errors.New("id_token signature invalid") }
+        r, s := new(big.Int).SetBytes(sig[:32]), new(big.Int).SetBytes(sig[32:])
+        if !ecdsa.Verify(k, sum[:], r, s) { return errors.New("id_token signature invalid") }
+        return nil
+    }
+    return fmt.Errorf("id_token signed with unsupported alg %q", alg)
+}
+
+// jwsHeader decodes the protected header of a compact JWS.
+func jwsHeader(part string) (alg, kid string, err error) {
+    b, err := base64.RawURLEncoding.DecodeString(part)
+    if err != nil { return "", "", fmt.Errorf("id_token header: %w", err) }
+    var h struct {
+        Alg string `json:"alg"`
+        Kid string `json:"kid"`
+    }
+    if err := json.Unmarshal(b, &h); err != nil { return "", "", fmt.Errorf("id_token header: %w", err) }
+    if h.Alg == "" || strings.EqualFold(h.Alg, "none") { return "", "", errors.New("id_token is not signed") }
+    return h.Alg, h.Kid, nil
+}
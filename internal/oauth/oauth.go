@@ -0,0 +1,176 @@
+package oauth
+
+import (
+    "context"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
+    "net/url"
+    "strings"
+    "time"
+
+    "real_deal/internal/config"
+)
+
+var ErrUnknownProvider = errors.New("unknown provider")
+
+// Identity is what a provider tells us about the person who signed in.
+type Identity struct {
+    Provider      string
+    Subject       string
+    Email         string
+    EmailVerified bool
+    Name          string
+    AvatarURL     string
+}
+
+// AuthRequest carries the per-login values that must round-trip through the
+// provider: state for CSRF, the PKCE challenge and the OIDC nonce.
+type AuthRequest struct {
+    State         string
+    CodeChallenge string
+    Nonce         string
+}
+
+// Provider runs the authorization-code flow against one identity provider.
+type Provider interface {
+    Name() string
+    AuthCodeURL(ctx context.Context, r AuthRequest) (string, error)
+    Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error)
+}
+
+// Client holds the registration shared by every provider type.
+type Client struct {
+    ID          string
+    Secret      string
+    RedirectURL string
+    Scopes      []string
+    HTTP        *http.Client
+}
+
+func (c Client) http() *http.Client {
+    if c.HTTP != nil { return c.HTTP }
+    return http.DefaultClient
+}
+
+// FromConfig builds a provider for each OAUTH_<NAME>_CLIENT_ID that is set.
+// Callbacks land on <PUBLIC_API_URL>/api/auth/<name>/callback.
+func FromConfig(cfg *config.Config, hc *http.Client) map[string]Provider {
+    out := map[string]Provider{}
+    for name, oc := range cfg.OAuth {
+        cl := Client{ID: oc.ClientID, Secret: oc.ClientSecret, RedirectURL: cfg.PublicAPIURL + "/api/auth/" + name + "/callback", HTTP: hc}
+        issuer := oc.Issuer
+        switch name {
+        case "github":
+            out[name] = NewGitHub(cl)
+        case "wechat":
+            out[name] = NewWeChat(cl)
+        case "google":
+            if issuer == "" { issuer = "https://accounts.google.com" }
+            out[name] = NewOIDC(name, issuer, cl)
+        case "linkedin":
+            if issuer == "" { issuer = "https://www.linkedin.com/oauth" }
+            out[name] = NewOIDC(name, issuer, cl)
+        case "apple":
+            if issuer == "" { issuer = "https://appleid.apple.com" }
+            p := NewOIDC(name, issuer, cl)
+            // Apple only returns email with form_post and never serves userinfo.
+            p.Client.Scopes = []string{"openid", "email", "name"}
+            p.ExtraParams = url.Values{"response_mode": {"form_post"}}
+            out[name] = p
+        default:
+            if issuer != "" { out[name] = NewOIDC(name, issuer, cl) }
+        }
+    }
+    return out
+}
+
+// CodeChallenge derives the S256 PKCE challenge for verifier.
+func CodeChallenge(verifier string) string {
+    sum := sha256.Sum256([]byte(verifier))
+    return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func authURL(endpoint string, cl Client, r AuthRequest, extra url.Values) (string, error) {
+    u, err := url.Parse(endpoint)
+    if err != nil { return "", err }
+    q := u.Query()
+    q.Set("response_type", "code")
+    q.Set("client_id", cl.ID)
+    q.Set("redirect_uri", cl.RedirectURL)
+    q.Set("scope", strings.Join(cl.Scopes, " "))
+    q.Set("state", r.State)
+    if r.CodeChallenge != "" {
+        q.Set("code_challenge", r.CodeChallenge)
+        q.Set("code_challenge_method", "S256")
+    }
+    if r.Nonce != "" { q.Set("nonce", r.Nonce) }
+    for k, vs := range extra { q[k] = vs }
+    u.RawQuery = q.Encode()
+    return u.String(), nil
+}
+
+// exchangeCode posts the authorization code to the token endpoint.
+func exchangeCode(ctx context.Context, cl Client, tokenURL, code, verifier string) (map[string]any, error) {
+    form := url.Values{
+        "grant_type":   {"authorization_code"},
+        "code":         {code},
+        "redirect_uri": {cl.RedirectURL},
+        "client_id":    {cl.ID},
+    }
+    if cl.Secret != "" { form.Set("client_secret", cl.Secret) }
+    if verifier != "" { form.Set("code_verifier", verifier) }
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, tokenURL, strings.NewReader(form.Encode()))
+    if err != nil { return nil, err }
+    req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+    req.Header.Set("Accept", "application/json")
+    var tok map[string]any
+    if err := doJSON(cl.http(), req, &tok); err != nil { return nil, fmt.Errorf("token exchange: %w", err) }
+    if e, _ := tok["error"].(string); e != "" { return nil, fmt.Errorf("token exchange: %s", e) }
+    return tok, nil
+}
+
+func getJSON(ctx context.Context, hc *http.Client, endpoint, accessToken string, out any) error {
+    req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+    if err != nil { return err }
+    req.Header.Set("Accept", "application/json")
+    if accessToken != "" { req.Header.Set("Authorization", "Bearer "+accessToken) }
+    return doJSON(hc, req, out)
+}
+
+func doJSON(hc *http.Client, req *http.Request, out any) error {
+    ctx, cancel := context.WithTimeout(req.Context(), 10*time.Second)
+    defer cancel()
+    resp, err := hc.Do(req.WithContext(ctx))
+    if err != nil { return err }
+    defer resp.Body.Close()
+    body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+    if err != nil { return err }
+    if resp.StatusCode/100 != 2 { return fmt.Errorf("%s %s: %s", req.Method, req.URL.Path, resp.Status) }
+    return json.Unmarshal(body, out)
+}
+
+func str(m map[string]any, key string) string {
+    switch v := m[key].(type) {
+    case string:
+        return v
+    case float64:
+        return fmt.Sprintf("%.0f", v)
+    }
+    return ""
+}
+
+// boolClaim accepts both true and "true": Apple sends email_verified as a string.
+func boolClaim(m map[string]any, key string) bool {
+    switch v := m[key].(type) {
+    case bool:
+        return v
+    case string:
+        return v == "true"
+    }
+    return false
+}
@@ -0,0 +1,148 @@
+package oauth
+
+import (
+    "context"
+    "crypto"
+    "encoding/base64"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "net/url"
+    "strings"
+    "sync"
+    "time"
+)
+
+// OIDCProvider speaks OpenID Connect against any issuer that publishes
+// /.well-known/openid-configuration, which is also how it is pointed at a
+// local fake issuer in development.
+type OIDCProvider struct {
+    name        string
+    Issuer      string
+    Client      Client
+    ExtraParams url.Values
+
+    mu   sync.Mutex
+    meta *discovery
+    keys map[string]crypto.PublicKey
+}
+
+type discovery struct {
+    Issuer           string `json:"issuer"`
+    AuthEndpoint     string `json:"authorization_endpoint"`
+    TokenEndpoint    string `json:"token_endpoint"`
+    UserInfoEndpoint string `json:"userinfo_endpoint"`
+    JWKSURI          string `json:"jwks_uri"`
+}
+
+func NewOIDC(name, issuer string, cl Client) *OIDCProvider {
+    if len(cl.Scopes) == 0 { cl.Scopes = []string{"openid", "email", "profile"} }
+    return &OIDCProvider{name: name, Issuer: strings.TrimRight(issuer, "/"), Client: cl}
+}
+
+func (p *OIDCProvider) Name() string { return p.name }
+
+// discover fetches provider metadata on first use and caches it; failures
+// are not cached so a provider that was down at boot recovers on its own.
+func (p *OIDCProvider) discover(ctx context.Context) (*discovery, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    if p.meta != nil { return p.meta, nil }
+    var d discovery
+    if err := getJSON(ctx, p.Client.http(), p.Issuer+"/.well-known/openid-configuration", "", &d); err != nil {
+        return nil, fmt.Errorf("%s discovery: %w", p.name, err)
+    }
+    if d.AuthEndpoint == "" || d.TokenEndpoint == "" { return nil, fmt.Errorf("%s discovery: missing endpoints", p.name) }
+    if d.Issuer == "" { d.Issuer = p.Issuer }
+    p.meta = &d
+    return p.meta, nil
+}
+
+func (p *OIDCProvider) AuthCodeURL(ctx context.Context, r AuthRequest) (string, error) {
+    d, err := p.discover(ctx)
+    if err != nil { return "", err }
+    return authURL(d.AuthEndpoint, p.Client, r, p.ExtraParams)
+}
+
+func (p *OIDCProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+    d, err := p.discover(ctx)
+    if err != nil { return nil, err }
+    tok, err := exchangeCode(ctx, p.Client, d.TokenEndpoint, code, verifier)
+    if err != nil { return nil, err }
+    claims := map[string]any{}
+    if raw, _ := tok["id_token"].(string); raw != "" {
+        if claims, err = p.idTokenClaims(ctx, d, raw, nonce); err != nil { return nil, err }
+    }
+    access, _ := tok["access_token"].(string)
+    if d.UserInfoEndpoint != "" && access != "" {
+        var info map[string]any
+        if err := getJSON(ctx, p.Client.http(), d.UserInfoEndpoint, access, &info); err != nil { return nil, fmt.Errorf("%s userinfo: %w", p.name, err) }
+        if sub := str(claims, "sub"); sub != "" && str(info, "sub") != sub { return nil, errors.New("userinfo subject mismatch") }
+        for k, v := range info { claims[k] = v }
+    }
+    id := &Identity{
+        Provider:      p.name,
+        Subject:       str(claims, "sub"),
+        Email:         strings.ToLower(str(claims, "email")),
+        EmailVerified: boolClaim(claims, "email_verified"),
+        Name:          str(claims, "name"),
+        AvatarURL:     str(claims, "picture"),
+    }
+    if id.Subject == "" { return nil, errors.New("provider returned no subject") }
+    return id, nil
+}
+
+// idTokenClaims validates the ID token's signature against the issuer's
+// published keys, then its issuer, audience, expiry and nonce.
+func (p *OIDCProvider) idTokenClaims(ctx context.Context, d *discovery, raw, nonce string) (map[string]any, error) {
+    parts := strings.Split(raw, ".")
+    if len(parts) != 3 { return nil, errors.New("malformed id_token") }
+    alg, kid, err := jwsHeader(parts[0])
+    if err != nil { return nil, err }
+    key, err := p.key(ctx, d, kid)
+    if err != nil { return nil, err }
+    if err := verifyJWS(parts, alg, key); err != nil { return nil, err }
+    payload, err := base64.RawURLEncoding.DecodeString(parts[1])
+    if err != nil { return nil, fmt.Errorf("id_token payload: %w", err) }
+    var claims map[string]any
+    if err := json.Unmarshal(payload, &claims); err != nil { return nil, fmt.Errorf("id_token payload: %w", err) }
+    if str(claims, "iss") != d.Issuer { return nil, errors.New("id_token issuer mismatch") }
+    if !hasAudience(claims["aud"], p.Client.ID) { return nil, errors.New("id_token audience mismatch") }
+    if exp, ok := claims["exp"].(float64); !ok || time.Unix(int64(exp), 0).Before(time.Now()) { return nil, errors.New("id_token expired") }
+    if nonce != "" && str(claims, "nonce") != nonce { return nil, errors.New("id_token nonce mismatch") }
+    return claims, nil
+}
+
+// key returns the issuer's signing key kid. An unknown kid refetches the
+// key set, so keys the issuer rotated in are picked up; a token without a
+// kid may use the only key there is.
+func (p *OIDCProvider) key(ctx context.Context, d *discovery, kid string) (crypto.PublicKey, error) {
+    p.mu.Lock()
+    defer p.mu.Unlock()
+    pick := func() (crypto.PublicKey, bool) {
+        if k, ok := p.keys[kid]; ok { return k, true }
+        if kid == "" && len(p.keys) == 1 {
+            for _, k := range p.keys { return k, true }
+        }
+        return nil, false
+    }
+    if k, ok := pick(); ok { return k, nil }
+    if d.JWKSURI == "" { return nil, fmt.Errorf("%s discovery: no jwks_uri", p.name) }
+    keys, err := fetchKeys(ctx, p.Client, d.JWKSURI)
+    if err != nil { return nil, fmt.Errorf("%s %w", p.name, err) }
+    p.keys = keys
+    if k, ok := pick(); ok { return k, nil }
+    return nil, fmt.Errorf("id_token signed with unknown key %q", kid)
+}
+
+func hasAudience(aud any, clientID string) bool {
+    switch v := aud.(type) {
+    case string:
+        return v == clientID
+    case []any:
+        for _, a := range v {
+            if s, _ := a.(string); s == clientID { return true }
+        }
+    }
+    return false
+}
@@ -0,0 +1,245 @@
+package oauth
+
+import (
+    "context"
+    "crypto"
+    "crypto/ecdsa"
+    "crypto/elliptic"
+    "crypto/rand"
+    "crypto/rsa"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/json"
+    "math/big"
+    "net/http"
+    "net/http/httptest"
+    "net/url"
+    "strings"
+    "testing"
+    "time"
+)
+
+// fakeIssuer is an OpenID provider serving discovery, JWKS, token and
+// userinfo endpoints. Each code it accepts maps to the ID token claims to
+// issue and the PKCE challenge the code was requested with.
+type fakeIssuer struct {
+    *httptest.Server
+    kid     string
+    key     crypto.Signer
+    alg     string
+    codes   map[string]grant
+    jwksHit int
+}
+
+type grant struct {
+    claims    map[string]any
+    challenge string
+}
+
+func newFakeIssuer(t *testing.T) *fakeIssuer {
+    t.Helper()
+    key, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil { t.Fatal(err) }
+    f := &fakeIssuer{kid: "k1", key: key, alg: "RS256", codes: map[string]grant{}}
+    mux := http.NewServeMux()
+    mux.HandleFunc("/.well-known/openid-configuration", func(w http.ResponseWriter, r *http.Request) {
+        writeJSON(w, map[string]string{
+            "issuer": f.URL, "authorization_endpoint": f.URL + "/authorize", "token_endpoint": f.URL + "/token",
+            "userinfo_endpoint": f.URL + "/userinfo", "jwks_uri": f.URL + "/jwks",
+        })
+    })
+    mux.HandleFunc("/jwks", func(w http.ResponseWriter, r *http.Request) {
+        f.jwksHit++
+        writeJSON(w, map[string]any{"keys": []any{f.jwk()}})
+    })
+    mux.HandleFunc("/token", func(w http.ResponseWriter, r *http.Request) {
+        g, ok := f.codes[r.PostFormValue("code")]
+        if !ok || r.PostFormValue("client_id") != "client" || CodeChallenge(r.PostFormValue("code_verifier")) != g.challenge {
+            w.WriteHeader(http.StatusBadRequest)
+            writeJSON(w, map[string]string{"error": "invalid_grant"})
+            return
+        }
+        delete(f.codes, r.PostFormValue("code"))
+        writeJSON(w, map[string]string{"access_token": "at-" + g.claims["sub"].(string), "id_token": f.sign(t, g.claims)})
+    })
+    mux.HandleFunc("/userinfo", func(w http.ResponseWriter, r *http.Request) {
+        sub := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer at-")
+        writeJSON(w, map[string]any{"sub": sub, "name": "Li Lei", "picture": "https://img.test/li.png"})
+    })
+    f.Server = httptest.NewServer(mux)
+    t.Cleanup(f.Close)
+    return f
+}
+
+func writeJSON(w http.ResponseWriter, v any) {
+    w.Header().Set("Content-Type", "application/json")
+    _ = json.NewEncoder(w).Encode(v)
+}
+
+func b64(b []byte) string { return base64.RawURLEncoding.EncodeToString(b) }
+
+func (f *fakeIssuer) jwk() map[string]string {
+    switch k := f.key.Public().(type) {
+    case *rsa.PublicKey:
+        return map[string]string{"kty": "RSA", "kid": f.kid, "use": "sig", "n": b64(k.N.Bytes()), "e": b64(big.NewInt(int64(k.E)).Bytes())}
+    case *ecdsa.PublicKey:
+        return map[string]string{"kty": "EC", "kid": f.kid, "crv": "P-256", "x": b64(k.X.FillBytes(make([]byte, 32))), "y": b64(k.Y.FillBytes(make([]byte, 32)))}
+    }
+    return nil
+}
+
+// sign issues a compact JWS over claims with the issuer's current key.
+func (f *fakeIssuer) sign(t *testing.T, claims map[string]any) string {
+    hdr, _ := json.Marshal(map[string]string{"alg": f.alg, "kid": f.kid, "typ": "JWT"})
+    body, _ := json.Marshal(claims)
+    input := b64(hdr) + "." + b64(body)
+    sum := sha256.Sum256([]byte(input))
+    var sig []byte
+    var err error
+    switch k := f.key.(type) {
+    case *rsa.PrivateKey:
+        sig, err = rsa.SignPKCS1v15(rand.Reader, k, crypto.SHA256, sum[:])
+    case *ecdsa.PrivateKey:
+        var r, s *big.Int
+        r, s, err = ecdsa.Sign(rand.Reader, k, sum[:])
+        sig = append(r.FillBytes(make([]byte, 32)), s.FillBytes(make([]byte, 32))...)
+    }
+    if err != nil { t.Fatal(err) }
+    return input + "." + b64(sig)
+}
+
+// claims are valid ID token claims for the provider's client and nonce.
+func (f *fakeIssuer) claims(nonce string) map[string]any {
+    return map[string]any{
+        "iss": f.URL, "aud": "client", "sub": "sub-1", "nonce": nonce, "exp": time.Now().Add(time.Hour).Unix(),
+        "email": "Li@Example.com", "email_verified": true,
+    }
+}
+
+// login runs AuthCodeURL and has the issuer grant a code for claims, as the
+// browser round trip would; it returns the code and the PKCE verifier.
+func (f *fakeIssuer) login(t *testing.T, p *OIDCProvider, nonce string, claims map[string]any) (string, string) {
+    t.Helper()
+    verifier := "verifier-" + nonce
+    u, err := p.AuthCodeURL(context.Background(), AuthRequest{State: "state-1", CodeChallenge: CodeChallenge(verifier), Nonce: nonce})
+    if err != nil { t.Fatal(err) }
+    parsed, _ := url.Parse(u)
+    q := parsed.Query()
+    if !strings.HasPrefix(u, f.URL+"/authorize?") || q.Get("state") != "state-1" || q.Get("nonce") != nonce || q.Get("code_challenge_method") != "S256" || q.Get("client_id") != "client" {
+        t.Fatalf("auth URL %s", u)
+    }
+    code := "code-" + nonce
+    f.codes[code] = grant{claims: claims, challenge: q.Get("code_challenge")}
+    return code, verifier
+}
+
+func newProvider(f *fakeIssuer) *OIDCProvider {
+    return NewOIDC("test", f.URL+"/", Client{ID: "client", Secret: "secret", RedirectURL: "https://app.test/cb", HTTP: f.Client()})
+}
+
+func TestOIDCExchange(t *testing.T) {
+    f := newFakeIssuer(t)
+    p := newProvider(f)
+    code, verifier := f.login(t, p, "n1", f.claims("n1"))
+    id, err := p.Exchange(context.Background(), code, verifier, "n1")
+    if err != nil { t.Fatal(err) }
+    want := Identity{Provider: "test", Subject: "sub-1", Email: "li@example.com", EmailVerified: true, Name: "Li Lei", AvatarURL: "https://img.test/li.png"}
+    if *id != want { t.Fatalf("got %+v, want %+v", *id, want) }
+
+    // The key set is cached across logins.
+    code, verifier = f.login(t, p, "n2", f.claims("n2"))
+    if _, err := p.Exchange(context.Background(), code, verifier, "n2"); err != nil { t.Fatal(err) }
+    if f.jwksHit != 1 { t.Fatalf("fetched JWKS %d times, want 1", f.jwksHit) }
+}
+
+func TestOIDCRejects(t *testing.T) {
+    other, err := rsa.GenerateKey(rand.Reader, 2048)
+    if err != nil { t.Fatal(err) }
+    tests := []struct {
+        name   string
+        claims func(f *fakeIssuer, c map[string]any)
+        token  func(f *fakeIssuer, raw string) string
+        nonce  string
+        wrongVerifier bool
+        want   string
+    }{
+        {name: "nonce mismatch", nonce: "other", want: "nonce mismatch"},
+        {name: "expired", claims: func(f *fakeIssuer, c map[string]any) { c["exp"] = time.Now().Add(-time.Minute).Unix() }, want: "expired"},
+        {name: "no expiry", claims: func(f *fakeIssuer, c map[string]any) { delete(c, "exp") }, want: "expired"},
+        {name: "wrong issuer", claims: func(f *fakeIssuer, c map[string]any) { c["iss"] = "https://evil.test" }, want: "issuer mismatch"},
+        {name: "wrong audience", claims: func(f *fakeIssuer, c map[string]any) { c["aud"] = []any{"someone-else"} }, want: "audience mismatch"},
+        {name: "signed by another key", token: func(f *fakeIssuer, raw string) string {
+            real := f.key
+            f.key = other
+            defer func() { f.key = real }()
+            return f.sign(nil, f.claims("n1"))
+        }, want: "signature invalid"},
+        {name: "payload tampered", token: func(f *fakeIssuer, raw string) string {
+            parts := strings.Split(raw, ".")
+            c := f.claims("n1")
+            c["sub"] = "admin"
+            body, _ := json.Marshal(c)
+            return parts[0] + "." + b64(body) + "." + parts[2]
+        }, want: "signature invalid"},
+        {name: "unsigned", token: func(f *fakeIssuer, raw string) string {
+            hdr, _ := json.Marshal(map[string]string{"alg": "none"})
+            return b64(hdr) + "." + strings.Split(raw, ".")[1] + "."
+        }, want: "not signed"},
+        {name: "unknown key", token: func(f *fakeIssuer, raw string) string {
+            f.kid = "k9"
+            defer func() { f.kid = "k1" }()
+            return f.sign(nil, f.claims("n1"))
+        }, want: "unknown key"},
+        {name: "PKCE verifier mismatch", wrongVerifier: true, want: "token exchange"},
+    }
+    for _, tt := range tests {
+        t.Run(tt.name, func(t *testing.T) {
+            f := newFakeIssuer(t)
+            p := newProvider(f)
+            c := f.claims("n1")
+            if tt.claims != nil { tt.claims(f, c) }
+            code, verifier := f.login(t, p, "n1", c)
+            if tt.token != nil {
+                raw := tt.token(f, f.sign(t, c))
+                f.codes[code] = grant{claims: c, challenge: f.codes[code].challenge}
+                p.Client.HTTP = tokenOverride(f, raw)
+            }
+            if tt.wrongVerifier { verifier = "guessed" }
+            nonce := "n1"
+            if tt.nonce != "" { nonce = tt.nonce }
+            _, err := p.Exchange(context.Background(), code, verifier, nonce)
+            if err == nil || !strings.Contains(err.Error(), tt.want) { t.Fatalf("got error %v, want %q", err, tt.want) }
+        })
+    }
+}
+
+// tokenOverride answers the token endpoint with raw as the ID token and
+// passes every other request through to the issuer.
+func tokenOverride(f *fakeIssuer, raw string) *http.Client {
+    return &http.Client{Transport: roundTrip(func(r *http.Request) (*http.Response, error) {
+        if r.URL.Path != "/token" { return f.Client().Transport.RoundTrip(r) }
+        w := httptest.NewRecorder()
+        writeJSON(w, map[string]string{"access_token": "at-sub-1", "id_token": raw})
+        return w.Result(), nil
+    })}
+}
+
+type roundTrip func(*http.Request) (*http.Response, error)
+
+func (f roundTrip) RoundTrip(r *http.Request) (*http.Response, error) { return f(r) }
+
+func TestOIDCKeyRotation(t *testing.T) {
+    f := newFakeIssuer(t)
+    p := newProvider(f)
+    code, verifier := f.login(t, p, "n1", f.claims("n1"))
+    if _, err := p.Exchange(context.Background(), code, verifier, "n1"); err != nil { t.Fatal(err) }
+
+    // The issuer moves to a P-256 key under a new kid; the cached set lacks
+    // it, so the provider refetches.
+    ec, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+    if err != nil { t.Fatal(err) }
+    f.key, f.kid, f.alg = ec, "k2", "ES256"
+    code, verifier = f.login(t, p, "n2", f.claims("n2"))
+    if _, err := p.Exchange(context.Background(), code, verifier, "n2"); err != nil { t.Fatal(err) }
+    if f.jwksHit != 2 { t.Fatalf("fetched JWKS %d times, want 2", f.jwksHit) }
+}
@@ -0,0 +1,51 @@
+package oauth
+
+import (
+    "context"
+    "errors"
+    "fmt"
+    "net/url"
+)
+
+// WeChatProvider implements WeChat Open Platform website login. WeChat
+// predates OAuth conventions: parameters are appid/secret, there is no PKCE
+// and no email, and the stable subject is unionid (falling back to openid).
+type WeChatProvider struct {
+    Client  Client
+    AuthURL string
+    APIURL  string
+}
+
+func NewWeChat(cl Client) *WeChatProvider {
+    if len(cl.Scopes) == 0 { cl.Scopes = []string{"snsapi_login"} }
+    return &WeChatProvider{Client: cl, AuthURL: "https://open.weixin.qq.com/connect/qrconnect", APIURL: "https://api.weixin.qq.com"}
+}
+
+func (p *WeChatProvider) Name() string { return "wechat" }
+
+func (p *WeChatProvider) AuthCodeURL(ctx context.Context, r AuthRequest) (string, error) {
+    q := url.Values{
+        "appid":         {p.Client.ID},
+        "redirect_uri":  {p.Client.RedirectURL},
+        "response_type": {"code"},
+        "scope":         {p.Client.Scopes[0]},
+        "state":         {r.State},
+    }
+    return p.AuthURL + "?" + q.Encode() + "#wechat_redirect", nil
+}
+
+func (p *WeChatProvider) Exchange(ctx context.Context, code, verifier, nonce string) (*Identity, error) {
+    q := url.Values{"appid": {p.Client.ID}, "secret": {p.Client.Secret}, "code": {code}, "grant_type": {"authorization_code"}}
+    var tok map[string]any
+    if err := getJSON(ctx, p.Client.http(), p.APIURL+"/sns/oauth2/access_token?"+q.Encode(), "", &tok); err != nil { return nil, err }
+    if code := str(tok, "errcode"); code != "" && code != "0" { return nil, fmt.Errorf("wechat: %s %s", code, str(tok, "errmsg")) }
+    access, openid := str(tok, "access_token"), str(tok, "openid")
+    if access == "" || openid == "" { return nil, errors.New("wechat: no access token") }
+    var info map[string]any
+    q = url.Values{"access_token": {access}, "openid": {openid}}
+    if err := getJSON(ctx, p.Client.http(), p.APIURL+"/sns/userinfo?"+q.Encode(), "", &info); err != nil { return nil, err }
+    id := &Identity{Provider: "wechat", Subject: str(info, "unionid"), Name: str(info, "nickname"), AvatarURL: str(info, "headimgurl")}
+    if id.Subject == "" { id.Subject = str(tok, "unionid") }
+    if id.Subject == "" { id.Subject = openid }
+    return id, nil
+}
@@ -0,0 +1,158 @@
+package query
+
+import (
+    "bytes"
+    "sort"
+    "strings"
+
+    "go.mongodb.org/mongo-driver/bson"
+)
+
+// Paginate applies q to items held in memory and returns the same page, in
+// the same order and with interchangeable cursors, as Find would for a
+// collection holding those items. It understands the filters Parse builds:
+// equality and $in on plain or array fields.
+func Paginate[T any](items []T, q *Query) (*Page[T], error) {
+    type row struct {
+        item T
+        raw  bson.Raw
+    }
+    var rows []row
+    for _, it := range items {
+        raw, err := bson.Marshal(it)
+        if err != nil { return nil, err }
+        if matches(raw, q.Filter) { rows = append(rows, row{it, raw}) }
+    }
+    total := int64(len(rows))
+
+    path := strings.Split(q.Field, ".")
+    key := func(r bson.Raw) (bson.RawValue, string) {
+        v, err := r.LookupErr(path...)
+        if err != nil { v = bson.RawValue{Type: bson.TypeNull} }
+        id, _ := r.Lookup("id").StringValueOK()
+        return v, id
+    }
+    // before reports whether a sorts before b in q's order.
+    before := func(av bson.RawValue, aid string, bv bson.RawValue, bid string) bool {
+        c := compare(av, bv)
+        if c == 0 || q.Field == "id" { c = strings.Compare(aid, bid) }
+        if q.Desc { return c > 0 }
+        return c < 0
+    }
+    sort.SliceStable(rows, func(i, j int) bool {
+        av, aid := key(rows[i].raw)
+        bv, bid := key(rows[j].raw)
+        return before(av, aid, bv, bid)
+    })
+
+    start := 0
+    if q.after != nil {
+        for start < len(rows) {
+            v, id := key(rows[start].raw)
+            if before(q.after.Value, q.after.ID, v, id) { break }
+            start++
+        }
+    }
+    page := &Page[T]{Items: []T{}, Total: total}
+    end := start + q.Limit
+    if end > len(rows) { end = len(rows) }
+    for _, r := range rows[start:end] { page.Items = append(page.Items, r.item) }
+    if end < len(rows) && end > start {
+        next, err := encode(q, rows[end-1].raw)
+        if err != nil { return nil, err }
+        page.NextCursor = next
+    }
+    return page, nil
+}
+
+// matches evaluates a Parse filter against a marshalled document.
+func matches(doc bson.Raw, filter bson.M) bool {
+    for field, want := range filter {
+        v, err := doc.LookupErr(strings.Split(field, ".")...)
+        if err != nil { return false }
+        var wants bson.A
+        if m, ok := want.(bson.M); ok {
+            wants, _ = m["$in"].(bson.A)
+        } else {
+            wants = bson.A{want}
+        }
+        if !anyEqual(v, wants) { return false }
+    }
+    return true
+}
+
+func anyEqual(v bson.RawValue, wants bson.A) bool {
+    vals := []bson.RawValue{v}
+    if v.Type == bson.TypeArray {
+        vals = nil
+        elems, _ := v.Array().Values()
+        vals = append(vals, elems...)
+    }
+    for _, w := range wants {
+        t, data, err := bson.MarshalValue(w)
+        if err != nil { continue }
+        for _, e := range vals {
+            if e.Type == t && bytes.Equal(e.Value, data) { return true }
+        }
+    }
+    return false
+}
+
+// compare orders two values the way MongoDB sorts them, for the types list
+// endpoints sort on: null, then numbers, strings, booleans and dates.
+func compare(a, b bson.RawValue) int {
+    ra, rb := typeRank(a), typeRank(b)
+    if ra != rb { return ra - rb }
+    switch ra {
+    case 1:
+        x, y := number(a), number(b)
+        switch {
+        case x < y:
+            return -1
+        case x > y:
+            return 1
+        }
+    case 2:
+        return strings.Compare(a.StringValue(), b.StringValue())
+    case 3:
+        x, y := a.Boolean(), b.Boolean()
+        if x != y { if x { return 1 }; return -1 }
+    case 4:
+        x, y := a.DateTime(), b.DateTime()
+        switch {
+        case x < y:
+            return -1
+        case x > y:
+            return 1
+        }
+    }
+    return 0
+}
+
+func number(v bson.RawValue) float64 {
+    switch v.Type {
+    case bson.TypeInt32:
+        return float64(v.Int32())
+    case bson.TypeInt64:
+        return float64(v.Int64())
+    case bson.TypeDouble:
+        return v.Double()
+    }
+    return 0
+}
+
+func typeRank(v bson.RawValue) int {
+    switch v.Type {
+    case bson.TypeNull, bson.TypeUndefined, 0:
+        return 0
+    case bson.TypeInt32, bson.TypeInt64, bson.TypeDouble, bson.TypeDecimal128:
+        return 1
+    case bson.TypeString:
+        return 2
+    case bson.TypeBoolean:
+        return 3
+    case bson.TypeDateTime:
+        return 4
+    }
+    return 5
+}
@@ -0,0 +1,279 @@
+package query
+
+import (
+    "encoding/base64"
+    "errors"
+    "net/url"
+    "slices"
+    "sort"
+    "strings"
+    "testing"
+
+    "go.mongodb.org/mongo-driver/bson"
+)
+
+type doc struct {
+    ID   string   `bson:"id"`
+    Rank *int     `bson:"rank,omitempty"`
+    Name string   `bson:"name"`
+    Tags []string `bson:"tags"`
+}
+
+func rank(n int) *int { return &n }
+
+var spec = Spec{
+    Filters:     map[string]string{"name": "name", "tag": "tags"},
+    Sorts:       map[string]string{"rank": "rank", "name": "name", "id": "id"},
+    DefaultSort: "rank",
+}
+
+// docs mixes missing ranks with repeated ones, listed out of id order.
+var docs = []doc{
+    {ID: "d5", Rank: rank(2), Name: "b", Tags: []string{"go"}},
+    {ID: "d1", Name: "a", Tags: []string{"go", "sql"}},
+    {ID: "d7", Rank: rank(1), Name: "a"},
+    {ID: "d3", Rank: rank(2), Name: "c", Tags: []string{"sql"}},
+    {ID: "d2", Rank: rank(3), Name: "b"},
+    {ID: "d6", Name: "c", Tags: []string{"rust"}},
+    {ID: "d4", Rank: rank(2), Name: "a", Tags: []string{"go"}},
+    {ID: "d8", Rank: rank(1), Name: "b", Tags: []string{"rust", "go"}},
+}
+
+func parse(t *testing.T, raw string) *Query {
+    t.Helper()
+    v, err := url.ParseQuery(raw)
+    if err != nil { t.Fatal(err) }
+    q, err := Parse(v, spec)
+    if err != nil { t.Fatalf("Parse(%q): %v", raw, err) }
+    return q
+}
+
+func ids(items []doc) []string {
+    var out []string
+    for _, d := range items { out = append(out, d.ID) }
+    return out
+}
+
+// walk follows NextCursor from the first page to the last and returns the
+// ids of every page.
+func walk(t *testing.T, raw string, page func(*Query) *Page[doc]) [][]string {
+    t.Helper()
+    var pages [][]string
+    cur := ""
+    for range len(docs) + 1 {
+        q := raw
+        if cur != "" { q += "&cursor=" + cur }
+        p := page(parse(t, q))
+        pages = append(pages, ids(p.Items))
+        if n := int64(len(slices.Concat(pages...))); p.NextCursor == "" && p.Total != n { t.Fatalf("%s: total %d, listed %d", raw, p.Total, n) }
+        if cur = p.NextCursor; cur == "" { return pages }
+    }
+    t.Fatalf("%s: cursor never ran out", raw)
+    return nil
+}
+
+func paginate(t *testing.T) func(*Query) *Page[doc] {
+    return func(q *Query) *Page[doc] {
+        p, err := Paginate(docs, q)
+        if err != nil { t.Fatal(err) }
+        return p
+    }
+}
+
+func TestPaginateOrder(t *testing.T) {
+    cases := []struct {
+        query string
+        want  [][]string
+    }{
+        // Missing ranks sort as null: first ascending, last descending, and
+        // equal ranks follow id in the same direction.
+        {"sort=rank&limit=3", [][]string{{"d1", "d6", "d7"}, {"d8", "d3", "d4"}, {"d5", "d2"}}},
+        {"sort=-rank&limit=3", [][]string{{"d2", "d5", "d4"}, {"d3", "d8", "d7"}, {"d6", "d1"}}},
+        {"sort=rank&limit=1", [][]string{{"d1"}, {"d6"}, {"d7"}, {"d8"}, {"d3"}, {"d4"}, {"d5"}, {"d2"}}},
+        {"sort=-rank&limit=2", [][]string{{"d2", "d5"}, {"d4", "d3"}, {"d8", "d7"}, {"d6", "d1"}}},
+        {"sort=rank&limit=8", [][]string{{"d1", "d6", "d7", "d8", "d3", "d4", "d5", "d2"}}},
+        {"sort=name&limit=3", [][]string{{"d1", "d4", "d7"}, {"d2", "d5", "d8"}, {"d3", "d6"}}},
+        {"sort=-id&limit=5", [][]string{{"d8", "d7", "d6", "d5", "d4"}, {"d3", "d2", "d1"}}},
+        {"name=a,c&sort=-rank&limit=2", [][]string{{"d4", "d3"}, {"d7", "d6"}, {"d1"}}},
+        {"tag=go&sort=rank&limit=2", [][]string{{"d1", "d8"}, {"d4", "d5"}}},
+        {"tag=rust,sql&sort=-name", [][]string{{"d6", "d3", "d8", "d1"}}},
+        {"name=z", [][]string{nil}},
+    }
+    for _, tc := range cases {
+        t.Run(tc.query, func(t *testing.T) {
+            if got := walk(t, tc.query, paginate(t)); !slices.EqualFunc(got, tc.want, slices.Equal) { t.Fatalf("pages %v, want %v", got, tc.want) }
+        })
+    }
+}
+
+func TestCursor(t *testing.T) {
+    p, err := Paginate(docs, parse(t, "sort=-rank&limit=3"))
+    if err != nil { t.Fatal(err) }
+    b, err := base64.RawURLEncoding.DecodeString(p.NextCursor)
+    if err != nil { t.Fatalf("cursor %q is not base64url: %v", p.NextCursor, err) }
+    var c cursor
+    if err := bson.Unmarshal(b, &c); err != nil { t.Fatal(err) }
+    if c.Sort != "-rank" || c.ID != "d4" || c.Value.Type != bson.TypeInt32 || c.Value.Int32() != 2 { t.Fatalf("cursor %+v, want -rank after d4 at 2", c) }
+
+    // The last item of a page may have no rank; its cursor holds null.
+    p, err = Paginate(docs, parse(t, "sort=rank&limit=2"))
+    if err != nil { t.Fatal(err) }
+    b, _ = base64.RawURLEncoding.DecodeString(p.NextCursor)
+    c = cursor{}
+    if err := bson.Unmarshal(b, &c); err != nil { t.Fatal(err) }
+    if c.ID != "d6" || c.Value.Type != bson.TypeNull { t.Fatalf("cursor %+v, want null after d6", c) }
+
+    bad := []string{
+        "sort=name&cursor=" + p.NextCursor,
+        "sort=-rank&cursor=" + p.NextCursor,
+        "cursor=not*base64",
+        "cursor=" + base64.RawURLEncoding.EncodeToString([]byte("junk")),
+    }
+    for _, raw := range bad {
+        v, _ := url.ParseQuery(raw)
+        if _, err := Parse(v, spec); !errors.Is(err, ErrBadCursor) { t.Errorf("Parse(%q) = %v, want ErrBadCursor", raw, err) }
+    }
+}
+
+func TestParse(t *testing.T) {
+    cases := []struct {
+        query string
+        err   error
+    }{
+        {"limit=0", ErrBadLimit},
+        {"limit=101", ErrBadLimit},
+        {"limit=ten", ErrBadLimit},
+        {"sort=title", ErrBadSort},
+        {"sort=-title", ErrBadSort},
+        {"limit=100&sort=-name", nil},
+    }
+    for _, tc := range cases {
+        v, _ := url.ParseQuery(tc.query)
+        if _, err := Parse(v, spec); !errors.Is(err, tc.err) { t.Errorf("Parse(%q) = %v, want %v", tc.query, err, tc.err) }
+    }
+    q := parse(t, "name=a&tag=go,+sql,")
+    if q.Filter["name"] != "a" { t.Errorf("name filter %v", q.Filter["name"]) }
+    if in, _ := q.Filter["tags"].(bson.M); in == nil || !slices.Equal(in["$in"].(bson.A), bson.A{"go", "sql"}) { t.Errorf("tags filter %v", q.Filter["tags"]) }
+    if q.Field != "rank" || q.Desc || q.Limit != DefaultLimit { t.Errorf("defaults %+v", q) }
+}
+
+// TestPaginateMatchesFind walks every listing through both Paginate and a
+// stand-in for Find that applies the same filters, cursor filter, sort and
+// limit the way MongoDB evaluates them, and expects the same pages.
+func TestPaginateMatchesFind(t *testing.T) {
+    find := func(q *Query) *Page[doc] {
+        var raws []bson.Raw
+        for _, d := range docs {
+            raw, err := bson.Marshal(d)
+            if err != nil { t.Fatal(err) }
+            raws = append(raws, raw)
+        }
+        match := q.Filter
+        total := 0
+        for _, r := range raws {
+            if mongoMatch(r, match) { total++ }
+        }
+        filter := match
+        if q.after != nil { filter = and(match, q.after.filter(q.Field, q.Desc)) }
+        var hits []bson.Raw
+        for _, r := range raws {
+            if mongoMatch(r, filter) { hits = append(hits, r) }
+        }
+        sort.SliceStable(hits, func(i, j int) bool {
+            c := compare(lookup(hits[i], q.Field), lookup(hits[j], q.Field))
+            if c == 0 { c = compare(lookup(hits[i], "id"), lookup(hits[j], "id")) }
+            if q.Desc { return c > 0 }
+            return c < 0
+        })
+        page := &Page[doc]{Items: []doc{}, Total: int64(total)}
+        for i, r := range hits {
+            if i == q.Limit {
+                next, err := encode(q, hits[i-1])
+                if err != nil { t.Fatal(err) }
+                page.NextCursor = next
+                break
+            }
+            var d doc
+            if err := bson.Unmarshal(r, &d); err != nil { t.Fatal(err) }
+            page.Items = append(page.Items, d)
+        }
+        return page
+    }
+    for _, srt := range []string{"rank", "-rank", "name", "-name", "id", "-id"} {
+        for _, extra := range []string{"", "&name=a,b", "&tag=go"} {
+            for _, limit := range []string{"1", "2", "3", "7"} {
+                raw := "sort=" + srt + "&limit=" + limit + extra
+                got, want := walk(t, raw, paginate(t)), walk(t, raw, find)
+                if !slices.EqualFunc(got, want, slices.Equal) { t.Errorf("%s: Paginate %v, Find %v", raw, got, want) }
+            }
+        }
+    }
+}
+
+func lookup(doc bson.Raw, field string) bson.RawValue {
+    v, err := doc.LookupErr(strings.Split(field, ".")...)
+    if err != nil { return bson.RawValue{Type: bson.TypeNull} }
+    return v
+}
+
+// mongoMatch evaluates the filters Find sends: $and, $or, equality and $in
+// (array fields match on any element), nil for null or missing, and $ne,
+// $gt and $lt, which like MongoDB's only compare values of the same type.
+func mongoMatch(doc bson.Raw, f bson.M) bool {
+    for key, want := range f {
+        switch key {
+        case "$and", "$or":
+            hit := false
+            for _, sub := range want.(bson.A) {
+                ok := mongoMatch(doc, sub.(bson.M))
+                if key == "$and" && !ok { return false }
+                hit = hit || ok
+            }
+            if key == "$or" && !hit { return false }
+            continue
+        }
+        v := lookup(doc, key)
+        ops, isOps := want.(bson.M)
+        if !isOps { ops = bson.M{"$eq": want} }
+        for op, arg := range ops {
+            if !mongoOp(v, op, arg) { return false }
+        }
+    }
+    return true
+}
+
+func mongoOp(v bson.RawValue, op string, arg any) bool {
+    var want bson.RawValue
+    switch a := arg.(type) {
+    case nil:
+        want = bson.RawValue{Type: bson.TypeNull}
+    case bson.RawValue:
+        want = a
+    case bson.A:
+        for _, x := range a {
+            if mongoOp(v, "$eq", x) { return true }
+        }
+        return false
+    default:
+        t, data, err := bson.MarshalValue(a)
+        if err != nil { return false }
+        want = bson.RawValue{Type: t, Value: data}
+    }
+    vals := []bson.RawValue{v}
+    if v.Type == bson.TypeArray { vals, _ = v.Array().Values() }
+    for _, x := range vals {
+        same := typeRank(x) == typeRank(want)
+        c := compare(x, want)
+        switch {
+        case op == "$in" || op == "$eq":
+            if same && c == 0 { return true }
+        case op == "$ne":
+            if !same || c != 0 { return true }
+        case op == "$gt":
+            if same && c > 0 { return true }
+        case op == "$lt":
+            if same && c < 0 { return true }
+        }
+    }
+    return false
+}
@@ -0,0 +1,175 @@
+// Package query turns list request parameters (?cursor=&limit=&sort= plus
+// field filters) into MongoDB queries and returns results as pages.
+package query
+
+import (
+    "context"
+    "encoding/base64"
+    "errors"
+    "net/url"
+    "strconv"
+    "strings"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+)
+
+const (
+    DefaultLimit = 20
+    MaxLimit     = 100
+)
+
+var (
+    ErrBadLimit  = errors.New("limit must be a number between 1 and 100")
+    ErrBadSort   = errors.New("unknown sort key")
+    ErrBadCursor = errors.New("invalid cursor")
+)
+
+// Spec describes what a list endpoint lets clients filter and sort on.
+// Filters and Sorts map query names to document fields. DefaultSort is a
+// Sorts key, prefixed with "-" for descending order.
+type Spec struct {
+    Filters     map[string]string
+    Sorts       map[string]string
+    DefaultSort string
+}
+
+// Query is a parsed list request. Documents are ordered by Field and then by
+// "id", which keeps the order total so cursors never skip or repeat items.
+type Query struct {
+    Filter bson.M
+    Sort   string
+    Field  string
+    Desc   bool
+    Limit  int
+    after  *cursor
+}
+
+// Page is the envelope every list endpoint returns. NextCursor is empty on
+// the last page; Total counts every match, not just this page.
+type Page[T any] struct {
+    Items      []T    `json:"items"`
+    NextCursor string `json:"nextCursor,omitempty"`
+    Total      int64  `json:"total"`
+}
+
+// cursor records the sort key it was issued for and the position of the last
+// item returned, so the next page starts strictly after it.
+type cursor struct {
+    Sort  string        `bson:"s"`
+    Value bson.RawValue `bson:"v"`
+    ID    string        `bson:"id"`
+}
+
+// Parse reads cursor, limit, sort and the spec's filters from v. A filter may
+// list several comma-separated values; documents matching any of them are
+// returned (for array fields such as skills, any overlap matches).
+func Parse(v url.Values, s Spec) (*Query, error) {
+    q := &Query{Filter: bson.M{}, Limit: DefaultLimit, Sort: s.DefaultSort}
+    if l := v.Get("limit"); l != "" {
+        n, err := strconv.Atoi(l)
+        if err != nil || n < 1 || n > MaxLimit { return nil, ErrBadLimit }
+        q.Limit = n
+    }
+    if srt := v.Get("sort"); srt != "" { q.Sort = srt }
+    key := strings.TrimPrefix(q.Sort, "-")
+    field, ok := s.Sorts[key]
+    if !ok { return nil, ErrBadSort }
+    q.Field, q.Desc = field, strings.HasPrefix(q.Sort, "-")
+
+    for name, field := range s.Filters {
+        raw := v.Get(name)
+        if raw == "" { continue }
+        var vals bson.A
+        for _, p := range strings.Split(raw, ",") {
+            if p = strings.TrimSpace(p); p != "" { vals = append(vals, p) }
+        }
+        if len(vals) == 1 { q.Filter[field] = vals[0] } else if len(vals) > 1 { q.Filter[field] = bson.M{"$in": vals} }
+    }
+
+    if cs := v.Get("cursor"); cs != "" {
+        b, err := base64.RawURLEncoding.DecodeString(cs)
+        if err != nil { return nil, ErrBadCursor }
+        var cur cursor
+        if err := bson.Unmarshal(b, &cur); err != nil || cur.Sort != q.Sort { return nil, ErrBadCursor }
+        q.after = &cur
+    }
+    return q, nil
+}
+
+// Find runs q against coll restricted to base and decodes one page into T.
+func Find[T any](ctx context.Context, coll *mongo.Collection, base bson.M, q *Query) (*Page[T], error) {
+    match := and(base, q.Filter)
+    total, err := coll.CountDocuments(ctx, match)
+    if err != nil { return nil, err }
+
+    filter := match
+    if q.after != nil { filter = and(match, q.after.filter(q.Field, q.Desc)) }
+    dir := 1
+    if q.Desc { dir = -1 }
+    sort := bson.D{{Key: q.Field, Value: dir}}
+    if q.Field != "id" { sort = append(sort, bson.E{Key: "id", Value: dir}) }
+    opts := options.Find().SetSort(sort).SetLimit(int64(q.Limit) + 1)
+    cur, err := coll.Find(ctx, filter, opts)
+    if err != nil { return nil, err }
+    defer cur.Close(ctx)
+
+    page := &Page[T]{Items: []T{}, Total: total}
+    var last bson.Raw
+    for cur.Next(ctx) {
+        if len(page.Items) == q.Limit {
+            next, err := encode(q, last)
+            if err != nil { return nil, err }
+            page.NextCursor = next
+            break
+        }
+        var item T
+        if err := cur.Decode(&item); err != nil { return nil, err }
+        page.Items = append(page.Items, item)
+        last = append(bson.Raw(nil), cur.Current...)
+    }
+    return page, cur.Err()
+}
+
+// filter matches the documents that sort after the cursor position. Missing
+// sort values sort as null, which MongoDB puts before every other value, so
+// in descending order they come after every non-null cursor value.
+func (c *cursor) filter(field string, desc bool) bson.M {
+    op := "$gt"
+    if desc { op = "$lt" }
+    if field == "id" { return bson.M{"id": bson.M{op: c.ID}} }
+    if c.Value.Type == bson.TypeNull {
+        if desc { return bson.M{field: nil, "id": bson.M{op: c.ID}} }
+        return bson.M{"$or": bson.A{bson.M{field: bson.M{"$ne": nil}}, bson.M{field: nil, "id": bson.M{op: c.ID}}}}
+    }
+    after := bson.A{
+        bson.M{field: bson.M{op: c.Value}},
+        bson.M{field: c.Value, "id": bson.M{op: c.ID}},
+    }
+    if desc { after = append(after, bson.M{field: nil}) }
+    return bson.M{"$or": after}
+}
+
+func encode(q *Query, last bson.Raw) (string, error) {
+    c := cursor{Sort: q.Sort, Value: bson.RawValue{Type: bson.TypeNull}}
+    if v, err := last.LookupErr(strings.Split(q.Field, ".")...); err == nil { c.Value = v }
+    if v, err := last.LookupErr("id"); err == nil { c.ID, _ = v.StringValueOK() }
+    b, err := bson.Marshal(c)
+    if err != nil { return "", err }
+    return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func and(parts ...bson.M) bson.M {
+    var all bson.A
+    for _, p := range parts {
+        if len(p) > 0 { all = append(all, p) }
+    }
+    switch len(all) {
+    case 0:
+        return bson.M{}
+    case 1:
+        return all[0].(bson.M)
+    }
+    return bson.M{"$and": all}
+}
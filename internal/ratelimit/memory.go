@@ -0,0 +1,36 @@
+package ratelimit
+
+import (
+    "context"
+    "sync"
+    "time"
+)
+
+// MemoryCounter is an in-process Counter for tests and single-node
+// development.
+type MemoryCounter struct {
+    mu   sync.Mutex
+    hits map[string]window
+    Now  func() time.Time
+}
+
+type window struct {
+    n     int
+    until time.Time
+}
+
+func NewMemory() *MemoryCounter { return &MemoryCounter{hits: map[string]window{}, Now: time.Now} }
+
+func (m *MemoryCounter) Hit(ctx context.Context, key string, d time.Duration) (int, time.Time, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    now := m.Now()
+    for k, w := range m.hits {
+        if !now.Before(w.until) { delete(m.hits, k) }
+    }
+    w, ok := m.hits[key]
+    if !ok { w.until = now.Add(d) }
+    w.n++
+    m.hits[key] = w
+    return w.n, w.until, nil
+}
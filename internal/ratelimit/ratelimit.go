@@ -0,0 +1,44 @@
+// Package ratelimit caps how often something may happen per key, such as
+// login mails per address or per client IP, in fixed windows counted in
+// Redis so every server instance shares them.
+package ratelimit
+
+import (
+    "context"
+    "errors"
+    "time"
+)
+
+var ErrLimited = errors.New("rate limit exceeded")
+
+// Counter counts hits on a key in a window that opens with the key's first
+// hit.
+type Counter interface {
+    // Hit records one hit on key and returns the hits in the current window,
+    // this one included, and when the window closes.
+    Hit(ctx context.Context, key string, window time.Duration) (int, time.Time, error)
+}
+
+// Limit allows Max hits per Window on each key. Name keeps the keys of
+// different limits on one Counter apart. A Max of 0 turns the limit off.
+type Limit struct {
+    Counter Counter
+    Name    string
+    Max     int
+    Window  time.Duration
+    Now     func() time.Time
+}
+
+func New(c Counter, name string, max int, window time.Duration) *Limit {
+    return &Limit{Counter: c, Name: name, Max: max, Window: window, Now: time.Now}
+}
+
+// Allow records a hit on key. Past Max it fails with ErrLimited and returns
+// how long until the window closes.
+func (l *Limit) Allow(ctx context.Context, key string) (time.Duration, error) {
+    if l == nil || l.Max <= 0 { return 0, nil }
+    n, reset, err := l.Counter.Hit(ctx, l.Name+":"+key, l.Window)
+    if err != nil { return 0, err }
+    if n > l.Max { return max(reset.Sub(l.Now()), time.Second), ErrLimited }
+    return 0, nil
+}
@@ -0,0 +1,40 @@
+package ratelimit
+
+import (
+    "context"
+    "errors"
+    "testing"
+    "time"
+)
+
+func TestLimit(t *testing.T) {
+    now := time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)
+    clock := func() time.Time { return now }
+    c := NewMemory()
+    c.Now = clock
+    l := New(c, "login", 3, time.Hour)
+    l.Now = clock
+    ctx := context.Background()
+    allow := func(key string) (time.Duration, bool) {
+        t.Helper()
+        wait, err := l.Allow(ctx, key)
+        if err != nil && !errors.Is(err, ErrLimited) { t.Fatal(err) }
+        return wait, err == nil
+    }
+
+    for i := range 3 {
+        if _, ok := allow("a"); !ok { t.Fatalf("hit %d refused", i+1) }
+        now = now.Add(10 * time.Minute)
+    }
+    // The window opened with the first hit, 30 minutes ago.
+    if wait, ok := allow("a"); ok || wait != 30*time.Minute { t.Fatalf("4th hit: allowed %v, wait %v", ok, wait) }
+    if _, ok := allow("b"); !ok { t.Fatal("another key was limited") }
+    if _, err := New(c, "other", 3, time.Hour).Allow(ctx, "a"); err != nil { t.Fatal("another limit's name shares the count") }
+
+    now = now.Add(30 * time.Minute)
+    if _, ok := allow("a"); !ok { t.Fatal("still limited in a new window") }
+
+    var off *Limit
+    if _, err := off.Allow(ctx, "a"); err != nil { t.Fatal(err) }
+    if _, err := New(c, "off", 0, time.Hour).Allow(ctx, "a"); err != nil { t.Fatal(err) }
+}
@@ -0,0 +1,28 @@
+package ratelimit
+
+import (
+    "context"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// RedisCounter keeps each count under ratelimit:<key>, expiring with its
+// window.
+type RedisCounter struct {
+    cli *redis.Client
+}
+
+func NewRedis(cli *redis.Client) *RedisCounter { return &RedisCounter{cli: cli} }
+
+// Hit opens the window with SET NX before counting, so the expiry is set
+// once by the first hit and a key can never be left without one.
+func (r *RedisCounter) Hit(ctx context.Context, key string, d time.Duration) (int, time.Time, error) {
+    key = "ratelimit:" + key
+    pipe := r.cli.TxPipeline()
+    pipe.SetNX(ctx, key, 0, d)
+    incr := pipe.Incr(ctx, key)
+    ttl := pipe.PTTL(ctx, key)
+    if _, err := pipe.Exec(ctx); err != nil { return 0, time.Time{}, err }
+    return int(incr.Val()), time.Now().Add(ttl.Val()), nil
+}
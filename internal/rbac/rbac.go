@@ -0,0 +1,51 @@
+package rbac
+
+type Permission string
+
+const (
+    ContentWrite       Permission = "content:write"
+    JobsWrite          Permission = "jobs:write"
+    JobsApply          Permission = "jobs:apply"
+    ModerationReview   Permission = "moderation:review"
+    VerificationReview Permission = "verification:review"
+    DealRoomView       Permission = "dealroom:view"
+    DealRoomAdmin      Permission = "dealroom:admin"
+    UsersAdmin         Permission = "users:admin"
+)
+
+// all is every permission, in display order; admin is granted all of them.
+var all = []Permission{ContentWrite, JobsWrite, JobsApply, ModerationReview, VerificationReview, DealRoomView, DealRoomAdmin, UsersAdmin}
+
+const (
+    RoleCandidate = "candidate"
+    RoleRecruiter = "recruiter"
+    RoleFounder   = "founder"
+    RoleInvestor  = "investor"
+    RoleModerator = "moderator"
+    RoleAdmin     = "admin"
+)
+
+// policy lists what each role may do. Admin is not listed: it holds every
+// permission, including ones added later.
+var policy = map[string][]Permission{
+    RoleCandidate: {ContentWrite, JobsApply},
+    RoleRecruiter: {ContentWrite, JobsWrite},
+    RoleFounder:   {ContentWrite, DealRoomView, DealRoomAdmin},
+    RoleInvestor:  {ContentWrite, DealRoomView},
+    RoleModerator: {ContentWrite, ModerationReview, VerificationReview},
+}
+
+// Can reports whether role grants p. Unknown roles grant nothing.
+func Can(role string, p Permission) bool {
+    if role == RoleAdmin { return true }
+    for _, q := range policy[role] {
+        if q == p { return true }
+    }
+    return false
+}
+
+// Permissions returns the permissions granted to role, for display.
+func Permissions(role string) []Permission {
+    if role == RoleAdmin { return append([]Permission(nil), all...) }
+    return append([]Permission(nil), policy[role]...)
+}
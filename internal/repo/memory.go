@@ -0,0 +1,1365 @@
+package repo
+
+import (
+    "context"
+    "sort"
+    "sync"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/bson/primitive"
+
+    "real_deal/internal/model"
+    "real_deal/internal/query"
+)
+
+// Memory holds every aggregate in process memory. Its fields are exported so
+// callers can seed data directly; take mu when doing so concurrently.
+type Memory struct {
+    mu            sync.Mutex
+    Users         map[string]model.User
+    Jobs          map[string]model.Job
+    JobStats      map[string]map[string]int
+    Compliance    map[string]model.JobCompliance
+    Companies     map[string]model.Company
+    Members       []model.CompanyMember
+    Invitations   map[string]model.CompanyInvitation
+    Media         map[string]model.MediaAsset
+    DealRooms     map[string]model.DealRoom
+    Usage         map[string]model.Usage
+    Quotas        map[string]model.Quota
+    JobSlots      map[string]model.JobSlot
+    CapacityPacks []model.CapacityPack
+    Charges       []model.Charge
+
+    Projects             map[string]model.Project
+    Products             map[string]model.Product
+    Posts                map[string]model.Post
+    ContentStats         map[string]map[string]int
+    Applications         map[string]model.Application
+    ApplicationEvents    []model.ApplicationEvent
+    Follows              []model.Follow
+    Inbox                []model.InboxItem
+    Preferences          map[string]model.NotificationPreference
+    Investors            map[string]model.InvestorProfile
+    Pitches              map[string]model.PitchPage
+    Moderation           map[string]model.ContentModeration
+    Reports              map[string]model.Report
+    Verifications        map[string]model.CompanyVerification
+    VerificationRequests map[string]model.VerificationRequest
+    Uploads              map[string]model.MediaUpload
+    UploadSlots          map[string]int
+    TranscodeJobs        map[string]model.TranscodeJob
+    LoginTokens          map[string]model.LoginToken
+    OAuthStates          map[string]model.OAuthFlow
+    Identities           []model.UserIdentity
+}
+
+// NewMemory returns empty in-memory repositories and the store behind them.
+// The content repositories hold on to the Projects, Products and Posts maps,
+// so seed those in place rather than replacing them.
+func NewMemory() (*Repos, *Memory) {
+    m := &Memory{
+        Users: map[string]model.User{}, Jobs: map[string]model.Job{}, JobStats: map[string]map[string]int{},
+        Compliance: map[string]model.JobCompliance{},
+        Companies: map[string]model.Company{}, Invitations: map[string]model.CompanyInvitation{}, Media: map[string]model.MediaAsset{}, DealRooms: map[string]model.DealRoom{},
+        Usage: map[string]model.Usage{}, Quotas: map[string]model.Quota{}, JobSlots: map[string]model.JobSlot{},
+        Projects: map[string]model.Project{}, Products: map[string]model.Product{}, Posts: map[string]model.Post{}, ContentStats: map[string]map[string]int{},
+        Applications: map[string]model.Application{}, Preferences: map[string]model.NotificationPreference{},
+        Investors: map[string]model.InvestorProfile{}, Pitches: map[string]model.PitchPage{},
+        Moderation: map[string]model.ContentModeration{}, Reports: map[string]model.Report{},
+        Verifications: map[string]model.CompanyVerification{}, VerificationRequests: map[string]model.VerificationRequest{},
+        Uploads: map[string]model.MediaUpload{}, UploadSlots: map[string]int{}, TranscodeJobs: map[string]model.TranscodeJob{},
+        LoginTokens: map[string]model.LoginToken{}, OAuthStates: map[string]model.OAuthFlow{},
+    }
+    return &Repos{
+        Users: memUsers{m}, Jobs: memJobs{m}, Companies: memCompanies{m},
+        Media: memMedia{m}, DealRooms: memDealRooms{m}, Billing: memBilling{m},
+        Projects:     memContent[model.Project, *model.Project]{m, m.Projects},
+        Products:     memContent[model.Product, *model.Product]{m, m.Products},
+        Posts:        memContent[model.Post, *model.Post]{m, m.Posts},
+        Applications: memApplications{m}, Follows: memFollows{m}, Inbox: memInbox{m}, Preferences: memPreferences{m},
+        Investors: memInvestors{m}, Pitches: memPitches{m}, Moderation: memModeration{m}, Reports: memReports{m},
+        Verifications: memVerifications{m}, VerificationRequests: memVerificationRequests{m},
+        Uploads: memUploads{m}, TranscodeJobs: memTranscodeJobs{m},
+        LoginTokens: memLoginTokens{m}, OAuthStates: memOAuthStates{m}, Identities: memIdentities{m},
+        Documents: memDocuments{m},
+    }, m
+}
+
+// values copies the values of items out under the lock, in no particular
+// order.
+func values[T any](m *Memory, items map[string]T) []T {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    out := make([]T, 0, len(items))
+    for _, v := range items { out = append(out, v) }
+    return out
+}
+
+// get copies the value for id out of items under the lock.
+func get[T any](m *Memory, items map[string]T, id string) (*T, error) {
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    v, ok := items[id]
+    if !ok { return nil, ErrNotFound }
+    return &v, nil
+}
+
+type memUsers struct{ m *Memory }
+
+// Merged users are only tombstones in Mongo; Memory does not keep them.
+func (r memUsers) Get(ctx context.Context, id string) (*model.User, error) { return get(r.m, r.m.Users, id) }
+
+func (r memUsers) ByEmail(ctx context.Context, email string) (*model.User, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    for _, u := range r.m.Users {
+        if u.Email != "" && u.Email == email { return &u, nil }
+    }
+    return nil, ErrNotFound
+}
+
+func (r memUsers) Create(ctx context.Context, u *model.User) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if _, ok := r.m.Users[u.ID]; ok { return ErrDuplicate }
+    for _, o := range r.m.Users {
+        if u.Email != "" && o.Email == u.Email { return ErrDuplicate }
+    }
+    r.m.Users[u.ID] = *u
+    return nil
+}
+
+func (r memUsers) SetModeration(ctx context.Context, id, status string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    u, ok := r.m.Users[id]
+    if !ok { return ErrNotFound }
+    u.Moderation = status
+    r.m.Users[id] = u
+    return nil
+}
+
+type memJobs struct{ m *Memory }
+
+func (r memJobs) Get(ctx context.Context, id string) (*model.Job, error) { return get(r.m, r.m.Jobs, id) }
+
+func (r memJobs) GetMany(ctx context.Context, ids []string) ([]model.Job, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    items := []model.Job{}
+    for _, id := range ids {
+        if j, ok := r.m.Jobs[id]; ok { items = append(items, j) }
+    }
+    return items, nil
+}
+
+func (r memJobs) List(ctx context.Context, f JobFilter, q *query.Query) (*query.Page[model.Job], error) {
+    r.m.mu.Lock()
+    var items []model.Job
+    for _, j := range r.m.Jobs {
+        if f.Live && !j.Live(f.Now) { continue }
+        if f.OwnerID != "" && j.OwnerID != f.OwnerID { continue }
+        items = append(items, j)
+    }
+    r.m.mu.Unlock()
+    return query.Paginate(items, q)
+}
+
+func (r memJobs) Insert(ctx context.Context, j *model.Job) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if _, ok := r.m.Jobs[j.ID]; ok { return ErrDuplicate }
+    r.m.Jobs[j.ID] = *j
+    return nil
+}
+
+func (r memJobs) Save(ctx context.Context, j, prev *model.Job) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    cur, ok := r.m.Jobs[prev.ID]
+    if !ok || cur.Status != prev.Status || cur.SlotHeld != prev.SlotHeld { return ErrConflict }
+    next := *j
+    next.Moderation = cur.Moderation
+    r.m.Jobs[prev.ID] = next
+    return nil
+}
+
+func (r memJobs) Due(ctx context.Context, now time.Time) ([]model.Job, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    var due []model.Job
+    for _, j := range r.m.Jobs {
+        if (j.Status == model.JobPublished || j.Status == model.JobPaused) && j.ExpiresAt != nil && !j.ExpiresAt.After(now) { due = append(due, j) }
+    }
+    return due, nil
+}
+
+func (r memJobs) Bump(ctx context.Context, id, stat string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if r.m.JobStats[id] == nil { r.m.JobStats[id] = map[string]int{} }
+    r.m.JobStats[id][stat]++
+    return nil
+}
+
+func (r memJobs) Compliance(ctx context.Context, jobID string) (*model.JobCompliance, error) {
+    return get(r.m, r.m.Compliance, jobID)
+}
+
+func (r memJobs) SetCompliance(ctx context.Context, jc *model.JobCompliance) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    r.m.Compliance[jc.JobID] = *jc
+    return nil
+}
+
+func (r memJobs) SetModeration(ctx context.Context, id, status string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    j, ok := r.m.Jobs[id]
+    if !ok { return ErrNotFound }
+    j.Moderation = status
+    r.m.Jobs[id] = j
+    return nil
+}
+
+type memCompanies struct{ m *Memory }
+
+func (r memCompanies) Get(ctx context.Context, id string) (*model.Company, error) { return get(r.m, r.m.Companies, id) }
+
+func (r memCompanies) GetMany(ctx context.Context, ids []string) ([]model.Company, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    items := []model.Company{}
+    for _, id := range ids {
+        if co, ok := r.m.Companies[id]; ok { items = append(items, co) }
+    }
+    return items, nil
+}
+
+func (r memCompanies) List(ctx context.Context, q *query.Query) (*query.Page[model.Company], error) {
+    return query.Paginate(values(r.m, r.m.Companies), q)
+}
+
+func (r memCompanies) Create(ctx context.Context, co *model.Company, ownerID string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if _, ok := r.m.Companies[co.ID]; ok { return ErrDuplicate }
+    r.m.Companies[co.ID] = *co
+    r.m.Members = append(r.m.Members, model.CompanyMember{CompanyID: co.ID, UserID: ownerID, Role: model.CompanyRoleOwner, JoinedAt: time.Now().UTC()})
+    return nil
+}
+
+func (r memCompanies) Update(ctx context.Context, co *model.Company) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    cur, ok := r.m.Companies[co.ID]
+    if !ok { return ErrNotFound }
+    cur.Name, cur.Description, cur.Website, cur.Tags, cur.UpdatedAt = co.Name, co.Description, co.Website, co.Tags, co.UpdatedAt
+    r.m.Companies[co.ID] = cur
+    return nil
+}
+
+func (r memCompanies) SetVerified(ctx context.Context, id string, verified bool) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    co, ok := r.m.Companies[id]
+    if !ok { return ErrNotFound }
+    co.Verified = verified
+    r.m.Companies[id] = co
+    return nil
+}
+
+// member returns the index of the membership in r.m.Members, or -1. The
+// caller holds the lock.
+func (r memCompanies) member(companyID, userID string) int {
+    for i, m := range r.m.Members {
+        if m.CompanyID == companyID && m.UserID == userID { return i }
+    }
+    return -1
+}
+
+func (r memCompanies) Member(ctx context.Context, companyID, userID string) (*model.CompanyMember, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    i := r.member(companyID, userID)
+    if i < 0 { return nil, ErrNotFound }
+    m := r.m.Members[i]
+    return &m, nil
+}
+
+func (r memCompanies) Members(ctx context.Context, companyID string) ([]model.CompanyMember, error) {
+    return r.members(func(m model.CompanyMember) bool { return m.CompanyID == companyID }), nil
+}
+
+func (r memCompanies) MembershipsOf(ctx context.Context, userID string) ([]model.CompanyMember, error) {
+    return r.members(func(m model.CompanyMember) bool { return m.UserID == userID }), nil
+}
+
+func (r memCompanies) members(keep func(model.CompanyMember) bool) []model.CompanyMember {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    items := []model.CompanyMember{}
+    for _, m := range r.m.Members {
+        if keep(m) { items = append(items, m) }
+    }
+    sort.SliceStable(items, func(i, j int) bool { return items[i].JoinedAt.Before(items[j].JoinedAt) })
+    return items
+}
+
+func (r memCompanies) AddMember(ctx context.Context, m *model.CompanyMember) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if r.member(m.CompanyID, m.UserID) >= 0 { return ErrDuplicate }
+    r.m.Members = append(r.m.Members, *m)
+    return nil
+}
+
+func (r memCompanies) SetRole(ctx context.Context, companyID, userID, from, to string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    i := r.member(companyID, userID)
+    if i < 0 || r.m.Members[i].Role != from { return ErrConflict }
+    r.m.Members[i].Role = to
+    return nil
+}
+
+func (r memCompanies) RemoveMember(ctx context.Context, companyID, userID string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    i := r.member(companyID, userID)
+    if i < 0 { return ErrNotFound }
+    r.m.Members = append(r.m.Members[:i], r.m.Members[i+1:]...)
+    return nil
+}
+
+func (r memCompanies) TransferOwnership(ctx context.Context, companyID, fromID, toID string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    from, to := r.member(companyID, fromID), r.member(companyID, toID)
+    if from < 0 || to < 0 || r.m.Members[from].Role != model.CompanyRoleOwner || r.m.Members[to].Role == model.CompanyRoleOwner { return ErrConflict }
+    r.m.Members[from].Role, r.m.Members[to].Role = model.CompanyRoleAdmin, model.CompanyRoleOwner
+    return nil
+}
+
+func (r memCompanies) Invite(ctx context.Context, inv *model.CompanyInvitation) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    for _, o := range r.m.Invitations {
+        if o.ID == inv.ID || (o.CompanyID == inv.CompanyID && o.Email == inv.Email && o.Status == model.InvitePending) { return ErrDuplicate }
+    }
+    r.m.Invitations[inv.ID] = *inv
+    return nil
+}
+
+func (r memCompanies) Invitation(ctx context.Context, id string) (*model.CompanyInvitation, error) {
+    return get(r.m, r.m.Invitations, id)
+}
+
+func (r memCompanies) Invitations(ctx context.Context, companyID string) ([]model.CompanyInvitation, error) {
+    return r.invitations(func(inv model.CompanyInvitation) bool { return inv.CompanyID == companyID }), nil
+}
+
+func (r memCompanies) PendingFor(ctx context.Context, email string) ([]model.CompanyInvitation, error) {
+    return r.invitations(func(inv model.CompanyInvitation) bool { return inv.Email == email && inv.Status == model.InvitePending }), nil
+}
+
+func (r memCompanies) invitations(keep func(model.CompanyInvitation) bool) []model.CompanyInvitation {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    items := []model.CompanyInvitation{}
+    for _, inv := range r.m.Invitations {
+        if keep(inv) { items = append(items, inv) }
+    }
+    sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt.After(items[j].CreatedAt) })
+    return items
+}
+
+func (r memCompanies) CloseInvitation(ctx context.Context, id, status string, at time.Time) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    inv, ok := r.m.Invitations[id]
+    if !ok || inv.Status != model.InvitePending { return ErrConflict }
+    inv.Status, inv.RespondedAt = status, &at
+    r.m.Invitations[id] = inv
+    return nil
+}
+
+type memMedia struct{ m *Memory }
+
+func (r memMedia) Get(ctx context.Context, id string) (*model.MediaAsset, error) { return get(r.m, r.m.Media, id) }
+
+func (r memMedia) List(ctx context.Context, q *query.Query) (*query.Page[model.MediaAsset], error) {
+    r.m.mu.Lock()
+    var items []model.MediaAsset
+    for _, a := range r.m.Media {
+        if a.Moderation != model.MediaRejected { items = append(items, a) }
+    }
+    r.m.mu.Unlock()
+    return query.Paginate(items, q)
+}
+
+func (r memMedia) Insert(ctx context.Context, a *model.MediaAsset) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if _, ok := r.m.Media[a.ID]; ok { return ErrDuplicate }
+    r.m.Media[a.ID] = *a
+    return nil
+}
+
+func (r memMedia) SetModeration(ctx context.Context, id, status string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    a, ok := r.m.Media[id]
+    if !ok { return ErrNotFound }
+    a.Moderation = status
+    r.m.Media[id] = a
+    return nil
+}
+
+func (r memMedia) SetTranscode(ctx context.Context, id string, t *model.Transcode) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    a, ok := r.m.Media[id]
+    if !ok { return ErrNotFound }
+    a.Transcode = t
+    r.m.Media[id] = a
+    return nil
+}
+
+func (r memMedia) Delete(ctx context.Context, id string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if _, ok := r.m.Media[id]; !ok { return ErrNotFound }
+    delete(r.m.Media, id)
+    return nil
+}
+
+type memDealRooms struct{ m *Memory }
+
+func (r memDealRooms) Get(ctx context.Context, id string) (*model.DealRoom, error) { return get(r.m, r.m.DealRooms, id) }
+
+type memBilling struct{ m *Memory }
+
+func (r memBilling) Usage(ctx context.Context, userID string) (*model.Usage, error) { return get(r.m, r.m.Usage, userID) }
+
+func (r memBilling) Quota(ctx context.Context, userID string) (*model.Quota, error) { return get(r.m, r.m.Quotas, userID) }
+
+func (r memBilling) JobSlots(ctx context.Context, userID string) (*model.JobSlot, error) { return get(r.m, r.m.JobSlots, userID) }
+
+func (r memBilling) TakeJobSlot(ctx context.Context, userID string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    s := r.m.JobSlots[userID]
+    if s.Slots <= 0 { return ErrNoJobSlots }
+    s.Slots--
+    r.m.JobSlots[userID] = s
+    return nil
+}
+
+func (r memBilling) ReleaseJobSlot(ctx context.Context, userID string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    s := r.m.JobSlots[userID]
+    s.UserID = userID
+    s.Slots++
+    r.m.JobSlots[userID] = s
+    return nil
+}
+
+func (r memBilling) ChargeStorage(ctx context.Context, userID string, gb, limit float64) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    u := r.m.Usage[userID]
+    if u.StorageGB+gb > limit { return ErrQuotaExceeded }
+    u.UserID = userID
+    u.StorageGB += gb
+    r.m.Usage[userID] = u
+    return nil
+}
+
+func (r memBilling) ReleaseStorage(ctx context.Context, userID string, gb float64) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    u, ok := r.m.Usage[userID]
+    if !ok { return nil }
+    u.StorageGB -= gb
+    r.m.Usage[userID] = u
+    return nil
+}
+
+func (r memBilling) ChargeTranscode(ctx context.Context, userID string, min, limit int) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    u := r.m.Usage[userID]
+    if u.TranscodeMin+min > limit { return ErrQuotaExceeded }
+    u.UserID = userID
+    u.TranscodeMin += min
+    r.m.Usage[userID] = u
+    return nil
+}
+
+func (r memBilling) ReleaseTranscode(ctx context.Context, userID string, min int) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    u, ok := r.m.Usage[userID]
+    if !ok { return nil }
+    u.TranscodeMin -= min
+    r.m.Usage[userID] = u
+    return nil
+}
+
+func (r memBilling) CapacityPacks(ctx context.Context, userID string, q *query.Query) (*query.Page[model.CapacityPack], error) {
+    r.m.mu.Lock()
+    var items []model.CapacityPack
+    for _, p := range r.m.CapacityPacks {
+        if p.UserID == userID { items = append(items, p) }
+    }
+    r.m.mu.Unlock()
+    return query.Paginate(items, q)
+}
+
+func (r memBilling) Charges(ctx context.Context, userID string, q *query.Query) (*query.Page[model.Charge], error) {
+    r.m.mu.Lock()
+    var items []model.Charge
+    for _, ch := range r.m.Charges {
+        if ch.UserID == userID { items = append(items, ch) }
+    }
+    r.m.mu.Unlock()
+    return query.Paginate(items, q)
+}
+
+// sameTime compares two optional times the way a Mongo equality filter
+// would.
+func sameTime(a, b *time.Time) bool {
+    if a == nil || b == nil { return a == b }
+    return a.Equal(*b)
+}
+
+type memContent[T any, PT contentPtr[T]] struct {
+    m     *Memory
+    items map[string]T
+}
+
+func (r memContent[T, PT]) Get(ctx context.Context, id string) (*T, error) {
+    v, err := get(r.m, r.items, id)
+    if err != nil { return nil, err }
+    if PT(v).Meta().DeletedAt != nil { return nil, ErrNotFound }
+    return v, nil
+}
+
+func (r memContent[T, PT]) List(ctx context.Context, q *query.Query) (*query.Page[T], error) {
+    r.m.mu.Lock()
+    var items []T
+    for _, v := range r.items {
+        if PT(&v).Meta().Live() { items = append(items, v) }
+    }
+    r.m.mu.Unlock()
+    return query.Paginate(items, q)
+}
+
+func (r memContent[T, PT]) Insert(ctx context.Context, v *T) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    id := PT(v).Meta().ID
+    if _, ok := r.items[id]; ok { return ErrDuplicate }
+    r.items[id] = *v
+    return nil
+}
+
+func (r memContent[T, PT]) Update(ctx context.Context, v *T) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    id := PT(v).Meta().ID
+    cur, ok := r.items[id]
+    if !ok || PT(&cur).Meta().DeletedAt != nil { return ErrNotFound }
+    r.items[id] = *v
+    return nil
+}
+
+func (r memContent[T, PT]) Delete(ctx context.Context, id string, at time.Time) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    v, ok := r.items[id]
+    meta := PT(&v).Meta()
+    if !ok || meta.DeletedAt != nil { return ErrNotFound }
+    meta.DeletedAt, meta.UpdatedAt = &at, at
+    r.items[id] = v
+    return nil
+}
+
+func (r memContent[T, PT]) Bump(ctx context.Context, id, stat string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if r.m.ContentStats[id] == nil { r.m.ContentStats[id] = map[string]int{} }
+    r.m.ContentStats[id][stat]++
+    return nil
+}
+
+func (r memContent[T, PT]) SetModeration(ctx context.Context, id, status string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    v, ok := r.items[id]
+    if !ok { return ErrNotFound }
+    PT(&v).Meta().Moderation = status
+    r.items[id] = v
+    return nil
+}
+
+type memApplications struct{ m *Memory }
+
+func (r memApplications) Get(ctx context.Context, id string) (*model.Application, error) {
+    return get(r.m, r.m.Applications, id)
+}
+
+func (r memApplications) List(ctx context.Context, f ApplicationFilter, q *query.Query) (*query.Page[model.Application], error) {
+    r.m.mu.Lock()
+    var items []model.Application
+    for _, a := range r.m.Applications {
+        if f.CandidateID != "" && a.CandidateID != f.CandidateID { continue }
+        if f.JobID != "" && a.JobID != f.JobID { continue }
+        items = append(items, a)
+    }
+    r.m.mu.Unlock()
+    return query.Paginate(items, q)
+}
+
+// applied reports whether the candidate applied to the job. The caller
+// holds the lock.
+func (m *Memory) applied(jobID, candidateID string) bool {
+    for _, a := range m.Applications {
+        if a.JobID == jobID && a.CandidateID == candidateID { return true }
+    }
+    return false
+}
+
+func (r memApplications) Insert(ctx context.Context, a *model.Application) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if _, ok := r.m.Applications[a.ID]; ok || r.m.applied(a.JobID, a.CandidateID) { return ErrDuplicate }
+    r.m.Applications[a.ID] = *a
+    return nil
+}
+
+func (r memApplications) Move(ctx context.Context, id, from, to string, at time.Time) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    a, ok := r.m.Applications[id]
+    if !ok || a.Stage != from { return ErrConflict }
+    a.Stage, a.UpdatedAt = to, at
+    r.m.Applications[id] = a
+    return nil
+}
+
+func (r memApplications) AddEvent(ctx context.Context, e *model.ApplicationEvent) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    r.m.ApplicationEvents = append(r.m.ApplicationEvents, *e)
+    return nil
+}
+
+func (r memApplications) Events(ctx context.Context, applicationID string) ([]model.ApplicationEvent, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    items := []model.ApplicationEvent{}
+    for _, e := range r.m.ApplicationEvents {
+        if e.ApplicationID == applicationID { items = append(items, e) }
+    }
+    sort.SliceStable(items, func(i, j int) bool { return items[i].At.Before(items[j].At) })
+    return items, nil
+}
+
+type memFollows struct{ m *Memory }
+
+// follows returns the index of the follow in m.Follows, or -1. The caller
+// holds the lock.
+func (m *Memory) follows(userID, typ, target string) int {
+    for i, f := range m.Follows {
+        if f.UserID == userID && f.Type == typ && f.Target == target { return i }
+    }
+    return -1
+}
+
+func (r memFollows) List(ctx context.Context, userID string) ([]model.Follow, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    items := []model.Follow{}
+    for _, f := range r.m.Follows {
+        if f.UserID == userID { items = append(items, f) }
+    }
+    sort.SliceStable(items, func(i, j int) bool { return items[i].CreatedAt.After(items[j].CreatedAt) })
+    return items, nil
+}
+
+func (r memFollows) Add(ctx context.Context, f *model.Follow) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if r.m.follows(f.UserID, f.Type, f.Target) >= 0 { return ErrDuplicate }
+    r.m.Follows = append(r.m.Follows, *f)
+    return nil
+}
+
+func (r memFollows) Remove(ctx context.Context, userID, typ, target string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    i := r.m.follows(userID, typ, target)
+    if i < 0 { return ErrNotFound }
+    r.m.Follows = append(r.m.Follows[:i], r.m.Follows[i+1:]...)
+    return nil
+}
+
+type memInbox struct{ m *Memory }
+
+func (r memInbox) List(ctx context.Context, userID string, q *query.Query) (*query.Page[model.InboxItem], error) {
+    r.m.mu.Lock()
+    var items []model.InboxItem
+    for _, it := range r.m.Inbox {
+        if it.UserID == userID { items = append(items, it) }
+    }
+    r.m.mu.Unlock()
+    return query.Paginate(items, q)
+}
+
+func (r memInbox) Add(ctx context.Context, it *model.InboxItem) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    r.m.Inbox = append(r.m.Inbox, *it)
+    return nil
+}
+
+type memPreferences struct{ m *Memory }
+
+func (r memPreferences) Get(ctx context.Context, userID string) (*model.NotificationPreference, error) {
+    return get(r.m, r.m.Preferences, userID)
+}
+
+type memInvestors struct{ m *Memory }
+
+func (r memInvestors) List(ctx context.Context, q *query.Query) (*query.Page[model.InvestorProfile], error) {
+    return query.Paginate(values(r.m, r.m.Investors), q)
+}
+
+type memPitches struct{ m *Memory }
+
+func (r memPitches) Get(ctx context.Context, id string) (*model.PitchPage, error) { return get(r.m, r.m.Pitches, id) }
+
+func (r memPitches) Insert(ctx context.Context, p *model.PitchPage) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if _, ok := r.m.Pitches[p.ID]; ok { return ErrDuplicate }
+    r.m.Pitches[p.ID] = *p
+    return nil
+}
+
+type memModeration struct{ m *Memory }
+
+func (r memModeration) Get(ctx context.Context, contentID string) (*model.ContentModeration, error) {
+    return get(r.m, r.m.Moderation, contentID)
+}
+
+func (r memModeration) List(ctx context.Context, q *query.Query) (*query.Page[model.ContentModeration], error) {
+    return query.Paginate(values(r.m, r.m.Moderation), q)
+}
+
+// history appends evs to a copy of h, so stored records never share an
+// array.
+func history(h []model.ModerationEvent, evs ...model.ModerationEvent) []model.ModerationEvent {
+    return append(append([]model.ModerationEvent{}, h...), evs...)
+}
+
+func (r memModeration) Resubmit(ctx context.Context, m *model.ContentModeration, evs []model.ModerationEvent) (bool, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    cur, ok := r.m.Moderation[m.ContentID]
+    if !ok || cur.Status != model.ModerationPending && cur.Status != model.ModerationApproved { return false, nil }
+    cur.Status, cur.Title, cur.Score, cur.Notes, cur.UpdatedAt = m.Status, m.Title, m.Score, m.Notes, m.UpdatedAt
+    if m.ReviewerID != "" { cur.ReviewerID, cur.ReviewedAt = m.ReviewerID, m.ReviewedAt }
+    if m.Status == model.ModerationRejected { cur.ClaimedBy, cur.ClaimedAt = "", nil }
+    cur.History = history(cur.History, evs...)
+    r.m.Moderation[m.ContentID] = cur
+    return true, nil
+}
+
+func (r memModeration) Open(ctx context.Context, m *model.ContentModeration) (bool, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if cur, ok := r.m.Moderation[m.ContentID]; ok {
+        cur.Title = m.Title
+        r.m.Moderation[m.ContentID] = cur
+        return false, nil
+    }
+    r.m.Moderation[m.ContentID] = *m
+    return true, nil
+}
+
+func (r memModeration) Flag(ctx context.Context, m *model.ContentModeration, ev model.ModerationEvent) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    cur, ok := r.m.Moderation[m.ContentID]
+    switch {
+    case !ok:
+        cur = *m
+    case cur.Status == model.ModerationApproved:
+        cur.Status, cur.Notes, cur.Reports, cur.UpdatedAt = model.ModerationPending, m.Notes, m.Reports, m.UpdatedAt
+        cur.ClaimedBy, cur.ClaimedAt = "", nil
+        cur.History = history(cur.History, ev)
+    default:
+        cur.Reports = m.Reports
+    }
+    r.m.Moderation[m.ContentID] = cur
+    return nil
+}
+
+func (r memModeration) Save(ctx context.Context, m, prev *model.ContentModeration, ev model.ModerationEvent) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    cur, ok := r.m.Moderation[prev.ContentID]
+    if !ok || cur.Status != prev.Status || !sameTime(cur.UpdatedAt, prev.UpdatedAt) { return ErrConflict }
+    cur.Status, cur.Notes, cur.UpdatedAt = m.Status, m.Notes, m.UpdatedAt
+    cur.ClaimedBy, cur.ClaimedAt = m.ClaimedBy, m.ClaimedAt
+    if m.ClaimedBy == "" { cur.ClaimedAt = nil }
+    if m.ReviewerID != "" { cur.ReviewerID, cur.ReviewedAt = m.ReviewerID, m.ReviewedAt }
+    if m.Appeal != nil { cur.Appeal = m.Appeal }
+    if m.Reports != prev.Reports { cur.Reports = m.Reports }
+    cur.History = history(cur.History, ev)
+    r.m.Moderation[prev.ContentID] = cur
+    *m = cur
+    return nil
+}
+
+func (r memModeration) SetReports(ctx context.Context, contentID string, n int) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    cur, ok := r.m.Moderation[contentID]
+    if !ok || cur.Reports <= 0 { return nil }
+    cur.Reports = n
+    r.m.Moderation[contentID] = cur
+    return nil
+}
+
+type memReports struct{ m *Memory }
+
+// openReport returns the reporter's open report on a target, or nil. The
+// caller holds the lock.
+func (m *Memory) openReport(reporterID, targetType, targetID string) *model.Report {
+    for _, rep := range m.Reports {
+        if rep.ReporterID == reporterID && rep.TargetType == targetType && rep.TargetID == targetID && rep.Status == model.ReportOpen { return &rep }
+    }
+    return nil
+}
+
+func (r memReports) Insert(ctx context.Context, rep *model.Report) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if _, ok := r.m.Reports[rep.ID]; ok { return ErrDuplicate }
+    if rep.Status == model.ReportOpen && r.m.openReport(rep.ReporterID, rep.TargetType, rep.TargetID) != nil { return ErrDuplicate }
+    r.m.Reports[rep.ID] = *rep
+    return nil
+}
+
+func (r memReports) OpenBy(ctx context.Context, reporterID, targetType, targetID string) (*model.Report, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if rep := r.m.openReport(reporterID, targetType, targetID); rep != nil { return rep, nil }
+    return nil, ErrNotFound
+}
+
+func (r memReports) Open(ctx context.Context, targetID string) ([]model.Report, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    items := []model.Report{}
+    for _, rep := range r.m.Reports {
+        if rep.TargetID == targetID && rep.Status == model.ReportOpen { items = append(items, rep) }
+    }
+    sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt.Before(items[j].CreatedAt) })
+    return items, nil
+}
+
+func (r memReports) List(ctx context.Context, reporterID string, q *query.Query) (*query.Page[model.Report], error) {
+    r.m.mu.Lock()
+    var items []model.Report
+    for _, rep := range r.m.Reports {
+        if reporterID == "" || rep.ReporterID == reporterID { items = append(items, rep) }
+    }
+    r.m.mu.Unlock()
+    return query.Paginate(items, q)
+}
+
+func (r memReports) Close(ctx context.Context, id, status, by string, at time.Time) (*model.Report, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    rep, ok := r.m.Reports[id]
+    if !ok || rep.Status != model.ReportOpen { return nil, ErrNotFound }
+    rep.Status, rep.ResolvedBy, rep.ResolvedAt = status, by, &at
+    r.m.Reports[id] = rep
+    return &rep, nil
+}
+
+func (r memReports) CloseAll(ctx context.Context, ids []string, status, by string, at time.Time) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    for _, id := range ids {
+        rep, ok := r.m.Reports[id]
+        if !ok || rep.Status != model.ReportOpen { continue }
+        rep.Status, rep.ResolvedBy, rep.ResolvedAt = status, by, &at
+        r.m.Reports[id] = rep
+    }
+    return nil
+}
+
+type memVerifications struct{ m *Memory }
+
+// detached copies v's domain claim, which callers update in place.
+func detached(v model.CompanyVerification) model.CompanyVerification {
+    if v.Domain != nil { d := *v.Domain; v.Domain = &d }
+    return v
+}
+
+func (r memVerifications) Get(ctx context.Context, companyID string) (*model.CompanyVerification, error) {
+    v, err := get(r.m, r.m.Verifications, companyID)
+    if err != nil { return nil, err }
+    *v = detached(*v)
+    return v, nil
+}
+
+// update applies f to the company's record, a new one if it has none, and
+// stores the result. f returns false to leave the store unchanged.
+func (r memVerifications) update(companyID string, f func(v *model.CompanyVerification) bool) bool {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    v, ok := r.m.Verifications[companyID]
+    if !ok { v = model.CompanyVerification{CompanyID: companyID} }
+    if !f(&v) { return false }
+    r.m.Verifications[companyID] = v
+    return true
+}
+
+func (r memVerifications) MarkPending(ctx context.Context, companyID, level string, at time.Time) error {
+    r.update(companyID, func(v *model.CompanyVerification) bool {
+        if v.Status == model.VerificationApproved {
+            v.PendingLevel = level
+        } else {
+            v.Level, v.Status, v.Reason, v.PendingLevel = level, model.VerificationPending, "", ""
+        }
+        v.UpdatedAt = &at
+        return true
+    })
+    return nil
+}
+
+func (r memVerifications) ClearPending(ctx context.Context, companyID string, at time.Time) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    v, ok := r.m.Verifications[companyID]
+    if !ok { return nil }
+    if v.Status == model.VerificationPending {
+        if v.Domain == nil { delete(r.m.Verifications, companyID); return nil }
+        v.Level, v.Status = "", ""
+    }
+    v.PendingLevel, v.UpdatedAt = "", &at
+    r.m.Verifications[companyID] = v
+    return nil
+}
+
+func (r memVerifications) Approve(ctx context.Context, companyID, level, reason string, at, expires time.Time) error {
+    r.update(companyID, func(v *model.CompanyVerification) bool {
+        v.Level, v.Status, v.Reason, v.PendingLevel = level, model.VerificationApproved, reason, ""
+        v.ApprovedAt, v.ExpiresAt, v.UpdatedAt = &at, &expires, &at
+        return true
+    })
+    return nil
+}
+
+func (r memVerifications) Reject(ctx context.Context, companyID, level, reason string, at time.Time) error {
+    r.update(companyID, func(v *model.CompanyVerification) bool {
+        if v.Status == model.VerificationApproved {
+            v.PendingLevel = ""
+        } else {
+            v.Level, v.Status = level, model.VerificationRejected
+        }
+        v.Reason, v.UpdatedAt = reason, &at
+        return true
+    })
+    return nil
+}
+
+func (r memVerifications) Due(ctx context.Context, now time.Time) ([]model.CompanyVerification, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    var due []model.CompanyVerification
+    for _, v := range r.m.Verifications {
+        if v.Status == model.VerificationApproved && v.ExpiresAt != nil && !v.ExpiresAt.After(now) { due = append(due, detached(v)) }
+    }
+    return due, nil
+}
+
+func (r memVerifications) Expire(ctx context.Context, v *model.CompanyVerification, at time.Time) (bool, error) {
+    return r.update(v.CompanyID, func(cur *model.CompanyVerification) bool {
+        if cur.Status != model.VerificationApproved || !sameTime(cur.ExpiresAt, v.ExpiresAt) { return false }
+        cur.Status, cur.UpdatedAt = model.VerificationExpired, &at
+        return true
+    }), nil
+}
+
+func (r memVerifications) ClaimDomain(ctx context.Context, companyID string, d *model.DomainVerification) error {
+    r.update(companyID, func(v *model.CompanyVerification) bool {
+        dv, at := *d, d.ClaimedAt
+        v.Domain, v.UpdatedAt = &dv, &at
+        return true
+    })
+    return nil
+}
+
+func (r memVerifications) CheckedDomain(ctx context.Context, companyID string, d *model.DomainVerification, at time.Time) (bool, error) {
+    return r.update(companyID, func(v *model.CompanyVerification) bool {
+        if v.Domain == nil || v.Domain.Token != d.Token || v.Domain.Status != model.DomainPending { return false }
+        dv := *v.Domain
+        dv.CheckedAt = d.CheckedAt
+        if d.Status == model.DomainVerified {
+            dv.Status, dv.Method, dv.VerifiedAt, dv.LastError = d.Status, d.Method, d.VerifiedAt, ""
+        } else {
+            dv.LastError = d.LastError
+        }
+        v.Domain, v.UpdatedAt = &dv, &at
+        return true
+    }), nil
+}
+
+func (r memVerifications) PendingDomains(ctx context.Context, claimedAfter, checkedBefore time.Time) ([]model.CompanyVerification, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    var due []model.CompanyVerification
+    for _, v := range r.m.Verifications {
+        d := v.Domain
+        if d == nil || d.Status != model.DomainPending || d.ClaimedAt.Before(claimedAfter) { continue }
+        if d.CheckedAt == nil || !d.CheckedAt.After(checkedBefore) { due = append(due, detached(v)) }
+    }
+    return due, nil
+}
+
+type memVerificationRequests struct{ m *Memory }
+
+func (r memVerificationRequests) Get(ctx context.Context, id string) (*model.VerificationRequest, error) {
+    return get(r.m, r.m.VerificationRequests, id)
+}
+
+func (r memVerificationRequests) Insert(ctx context.Context, vr *model.VerificationRequest) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    for _, o := range r.m.VerificationRequests {
+        if o.ID == vr.ID || (vr.Open && o.Open && o.CompanyID == vr.CompanyID) { return ErrDuplicate }
+    }
+    r.m.VerificationRequests[vr.ID] = *vr
+    return nil
+}
+
+func (r memVerificationRequests) ForCompany(ctx context.Context, companyID string) ([]model.VerificationRequest, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    items := []model.VerificationRequest{}
+    for _, vr := range r.m.VerificationRequests {
+        if vr.CompanyID == companyID { items = append(items, vr) }
+    }
+    sort.Slice(items, func(i, j int) bool { return items[i].CreatedAt.After(items[j].CreatedAt) })
+    return items, nil
+}
+
+func (r memVerificationRequests) List(ctx context.Context, q *query.Query) (*query.Page[model.VerificationRequest], error) {
+    r.m.mu.Lock()
+    var items []model.VerificationRequest
+    for _, vr := range r.m.VerificationRequests {
+        if vr.Status != model.RequestDraft { items = append(items, vr) }
+    }
+    r.m.mu.Unlock()
+    return query.Paginate(items, q)
+}
+
+// update applies f to the request if it is in status from, failing with
+// ErrConflict otherwise.
+func (r memVerificationRequests) update(id, from string, f func(vr *model.VerificationRequest)) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    vr, ok := r.m.VerificationRequests[id]
+    if !ok || vr.Status != from { return ErrConflict }
+    f(&vr)
+    r.m.VerificationRequests[id] = vr
+    return nil
+}
+
+func (r memVerificationRequests) AddDocument(ctx context.Context, id string, d *model.VerificationDocument) error {
+    return r.update(id, model.RequestDraft, func(vr *model.VerificationRequest) {
+        vr.Documents = append(append([]model.VerificationDocument{}, vr.Documents...), *d)
+    })
+}
+
+func (r memVerificationRequests) Submit(ctx context.Context, id string, at time.Time) error {
+    return r.update(id, model.RequestDraft, func(vr *model.VerificationRequest) { vr.Status, vr.SubmittedAt = model.RequestPending, &at })
+}
+
+func (r memVerificationRequests) Close(ctx context.Context, id, from, to, reviewerID, reason string, at time.Time) error {
+    return r.update(id, from, func(vr *model.VerificationRequest) {
+        vr.Status, vr.Open = to, false
+        if reviewerID != "" { vr.ReviewerID, vr.ReviewedAt = reviewerID, &at }
+        if reason != "" { vr.Reason = reason }
+    })
+}
+
+type memUploads struct{ m *Memory }
+
+func (r memUploads) Get(ctx context.Context, id, ownerID string) (*model.MediaUpload, error) {
+    u, err := get(r.m, r.m.Uploads, id)
+    if err != nil { return nil, err }
+    if u.OwnerID != ownerID { return nil, ErrNotFound }
+    return u, nil
+}
+
+func (r memUploads) Insert(ctx context.Context, u *model.MediaUpload) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if _, ok := r.m.Uploads[u.ID]; ok { return ErrDuplicate }
+    r.m.Uploads[u.ID] = *u
+    return nil
+}
+
+func (r memUploads) Reserve(ctx context.Context, ownerID string, max int) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if r.m.UploadSlots[ownerID] >= max { return ErrUploadSlots }
+    r.m.UploadSlots[ownerID]++
+    return nil
+}
+
+func (r memUploads) Release(ctx context.Context, ownerID string) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if r.m.UploadSlots[ownerID] > 0 { r.m.UploadSlots[ownerID]-- }
+    return nil
+}
+
+func (r memUploads) Extend(ctx context.Context, id string, expires time.Time) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    u, ok := r.m.Uploads[id]
+    if !ok || u.Status != model.UploadPending { return nil }
+    u.ExpiresAt = expires
+    r.m.Uploads[id] = u
+    return nil
+}
+
+func (r memUploads) Transition(ctx context.Context, id, from, to, reason string) (bool, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    u, ok := r.m.Uploads[id]
+    if !ok || u.Status != from { return false, nil }
+    u.Status = to
+    if reason != "" { u.Error = reason }
+    r.m.Uploads[id] = u
+    return true, nil
+}
+
+func (r memUploads) Stale(ctx context.Context, cutoff time.Time) ([]model.MediaUpload, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    var stale []model.MediaUpload
+    for _, u := range r.m.Uploads {
+        if u.Status == model.UploadPending && !u.ExpiresAt.After(cutoff) { stale = append(stale, u) }
+    }
+    return stale, nil
+}
+
+type memTranscodeJobs struct{ m *Memory }
+
+func (r memTranscodeJobs) Insert(ctx context.Context, j *model.TranscodeJob) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if _, ok := r.m.TranscodeJobs[j.ID]; ok { return ErrDuplicate }
+    r.m.TranscodeJobs[j.ID] = *j
+    return nil
+}
+
+func (r memTranscodeJobs) Claim(ctx context.Context, now, until time.Time) (*model.TranscodeJob, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    var next *model.TranscodeJob
+    for _, j := range r.m.TranscodeJobs {
+        stopped := j.Status == model.TranscodeRunning && j.LockedUntil != nil && j.LockedUntil.Before(now)
+        if j.Status != model.TranscodeQueued && !stopped { continue }
+        if next == nil || j.CreatedAt.Before(next.CreatedAt) { next = &j }
+    }
+    if next == nil { return nil, ErrNotFound }
+    next.Status, next.LockedUntil = model.TranscodeRunning, &until
+    next.Attempts++
+    r.m.TranscodeJobs[next.ID] = *next
+    return next, nil
+}
+
+func (r memTranscodeJobs) SetMinutes(ctx context.Context, id string, min int) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    j, ok := r.m.TranscodeJobs[id]
+    if !ok { return nil }
+    j.Minutes = min
+    r.m.TranscodeJobs[id] = j
+    return nil
+}
+
+func (r memTranscodeJobs) Finish(ctx context.Context, id, status, errText string, at time.Time) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    j, ok := r.m.TranscodeJobs[id]
+    if !ok { return nil }
+    j.Status, j.Error, j.FinishedAt, j.LockedUntil = status, errText, &at, nil
+    r.m.TranscodeJobs[id] = j
+    return nil
+}
+
+type memLoginTokens struct{ m *Memory }
+
+func (r memLoginTokens) Issue(ctx context.Context, t *model.LoginToken) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    for hash, old := range r.m.LoginTokens {
+        if old.Email == t.Email && old.UsedAt == nil { delete(r.m.LoginTokens, hash) }
+    }
+    if _, ok := r.m.LoginTokens[t.Hash]; ok { return ErrDuplicate }
+    r.m.LoginTokens[t.Hash] = *t
+    return nil
+}
+
+func (r memLoginTokens) Redeem(ctx context.Context, hash string, now time.Time) (string, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    t, ok := r.m.LoginTokens[hash]
+    if !ok || t.UsedAt != nil || !t.ExpiresAt.After(now) { return "", ErrNotFound }
+    t.UsedAt = &now
+    r.m.LoginTokens[hash] = t
+    return t.Email, nil
+}
+
+type memOAuthStates struct{ m *Memory }
+
+func (r memOAuthStates) Insert(ctx context.Context, f *model.OAuthFlow) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    if _, ok := r.m.OAuthStates[f.Hash]; ok { return ErrDuplicate }
+    r.m.OAuthStates[f.Hash] = *f
+    return nil
+}
+
+func (r memOAuthStates) Take(ctx context.Context, hash, provider string, now time.Time) (*model.OAuthFlow, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    f, ok := r.m.OAuthStates[hash]
+    if !ok || f.Provider != provider || !f.ExpiresAt.After(now) { return nil, ErrNotFound }
+    delete(r.m.OAuthStates, hash)
+    return &f, nil
+}
+
+type memIdentities struct{ m *Memory }
+
+func (r memIdentities) Find(ctx context.Context, provider, subject string) (*model.UserIdentity, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    for _, ui := range r.m.Identities {
+        if ui.Provider == provider && ui.Subject == subject { return &ui, nil }
+    }
+    return nil, ErrNotFound
+}
+
+// List keeps insertion order, which is link order.
+func (r memIdentities) List(ctx context.Context, userID string) ([]model.UserIdentity, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    items := []model.UserIdentity{}
+    for _, ui := range r.m.Identities {
+        if ui.UserID == userID { items = append(items, ui) }
+    }
+    return items, nil
+}
+
+func (r memIdentities) Link(ctx context.Context, ui *model.UserIdentity) error {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    for _, cur := range r.m.Identities {
+        if cur.Provider != ui.Provider || cur.Subject != ui.Subject { continue }
+        if cur.UserID == ui.UserID { return nil }
+        return ErrIdentityInUse
+    }
+    for _, cur := range r.m.Identities {
+        if cur.Provider == ui.Provider && cur.UserID == ui.UserID { return ErrProviderBound }
+    }
+    r.m.Identities = append(r.m.Identities, *ui)
+    return nil
+}
+
+func (r memIdentities) Unlink(ctx context.Context, userID, provider string) (bool, error) {
+    r.m.mu.Lock()
+    defer r.m.mu.Unlock()
+    kept := r.m.Identities[:0]
+    for _, ui := range r.m.Identities {
+        if ui.UserID != userID || ui.Provider != provider { kept = append(kept, ui) }
+    }
+    removed := len(kept) < len(r.m.Identities)
+    r.m.Identities = kept
+    return removed, nil
+}
+
+// memDocuments stores nothing of its own: it renders the typed records as
+// the documents Mongo would hold, engagement counters included.
+type memDocuments struct{ m *Memory }
+
+func (r memDocuments) Newest(ctx context.Context, coll string, now time.Time, limit int64) ([]bson.M, error) {
+    m := r.m
+    m.mu.Lock()
+    var items []any
+    stats := m.ContentStats
+    switch coll {
+    case "projects":
+        for _, v := range m.Projects {
+            if v.Live() { items = append(items, v) }
+        }
+    case "products":
+        for _, v := range m.Products {
+            if v.Live() { items = append(items, v) }
+        }
+    case "posts":
+        for _, v := range m.Posts {
+            if v.Live() { items = append(items, v) }
+        }
+    case "jobs":
+        for _, j := range m.Jobs {
+            if j.Live(now) { items = append(items, j) }
+        }
+        stats = m.JobStats
+    case "companies":
+        for _, co := range m.Companies { items = append(items, co) }
+    case "investor_profiles":
+        for _, p := range m.Investors { items = append(items, p) }
+    }
+    docs := make([]bson.M, 0, len(items))
+    for _, v := range items {
+        d, err := fields(v)
+        if err != nil { m.mu.Unlock(); return nil, err }
+        id, _ := d["id"].(string)
+        if st := stats[id]; len(st) > 0 {
+            counters := bson.M{}
+            for k, n := range st { counters[k] = int64(n) }
+            d["stats"] = counters
+        }
+        docs = append(docs, d)
+    }
+    m.mu.Unlock()
+
+    sort.Slice(docs, func(i, j int) bool {
+        ti, _ := docs[i]["createdAt"].(primitive.DateTime)
+        tj, _ := docs[j]["createdAt"].(primitive.DateTime)
+        if ti != tj { return ti > tj }
+        ii, _ := docs[i]["id"].(string)
+        ij, _ := docs[j]["id"].(string)
+        return ii > ij
+    })
+    if limit > 0 && int64(len(docs)) > limit { docs = docs[:limit] }
+    return docs, nil
+}
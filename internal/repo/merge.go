@@ -0,0 +1,308 @@
+package repo
+
+import (
+    "context"
+    "errors"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+
+    "real_deal/internal/model"
+)
+
+// userRefs are collections holding any number of documents per user; a merge
+// simply re-points them.
+var userRefs = []struct{ Coll, Field string }{
+    {"user_identities", "userId"},
+    {"inbox_items", "userId"},
+    {"charges", "userId"},
+    {"capacity_packs", "userId"},
+    {"projects", "authorId"},
+    {"products", "authorId"},
+    {"posts", "authorId"},
+    {"jobs", "ownerId"},
+    {"applications", "recruiterId"},
+    {"application_events", "actorId"},
+    {"company_invitations", "invitedBy"},
+    {"pitch_pages", "ownerId"},
+    {"transcode_jobs", "ownerId"},
+    {"media_assets", "ownerId"},
+    {"media_uploads", "ownerId"},
+    {"verification_requests", "createdBy"},
+    {"verification_requests", "reviewerId"},
+    {"content_moderation", "authorId"},
+    {"content_moderation", "reviewerId"},
+}
+
+// userSets are like userRefs but unique per user and some other key (a user
+// applies to a job once, follows a tag once). Documents are moved one by one
+// and dropped when the surviving user already has the same one.
+// company_members is a set too, but keeps the higher role: see mergeMembers.
+var userSets = []struct{ Coll, Field string }{
+    {"applications", "candidateId"},
+    {"follows", "userId"},
+    {"reports", "reporterId"},
+}
+
+// userSingletons hold one document per user. When both users have one, the
+// Sum fields are added into the surviving document and the other is dropped;
+// without Sum fields the surviving user's document simply wins.
+var userSingletons = []struct {
+    Coll string
+    Sum  []string
+}{
+    {"job_slots", []string{"slots"}},
+    {"usage_meters", []string{"storageGb", "bandwidthGb", "transcodeMin"}},
+    {"upload_slots", []string{"open"}},
+    {"quotas", nil},
+    {"notification_preferences", nil},
+}
+
+// profileFields are copied from the merged-away user when the survivor lacks them.
+var profileFields = []string{"name", "email", "avatarUrl"}
+
+// Merge keeps fromID as a tombstone with mergedInto set and its email
+// cleared.
+func (r mongoUsers) Merge(ctx context.Context, fromID, intoID string) error {
+    from, err := findOne[bson.M](ctx, r.c, bson.M{"id": fromID, "mergedInto": liveUser})
+    if err != nil { return err }
+    into, err := findOne[bson.M](ctx, r.c, bson.M{"id": intoID, "mergedInto": liveUser})
+    if err != nil { return err }
+
+    for _, ref := range userRefs {
+        if _, err := r.db.Collection(ref.Coll).UpdateMany(ctx, bson.M{ref.Field: fromID}, bson.M{"$set": bson.M{ref.Field: intoID}}); err != nil { return err }
+    }
+    for _, set := range userSets {
+        if err := mergeSet(ctx, r.db.Collection(set.Coll), set.Field, fromID, intoID); err != nil { return err }
+    }
+    if err := mergeMembers(ctx, r.db.Collection("company_members"), fromID, intoID); err != nil { return err }
+    for _, s := range userSingletons {
+        if err := mergeSingleton(ctx, r.db.Collection(s.Coll), fromID, intoID, s.Sum); err != nil { return err }
+    }
+
+    fill := bson.M{}
+    for _, f := range profileFields {
+        if v, ok := (*from)[f].(string); ok && v != "" {
+            if cur, _ := (*into)[f].(string); cur == "" { fill[f] = v }
+        }
+    }
+    // Clear the tombstone's email first so the unique address can move over.
+    if _, err := r.c.UpdateOne(ctx, bson.M{"id": fromID}, bson.M{
+        "$set":   bson.M{"mergedInto": intoID, "mergedAt": time.Now()},
+        "$unset": bson.M{"email": ""},
+    }); err != nil { return err }
+    if len(fill) > 0 {
+        if _, err := r.c.UpdateOne(ctx, bson.M{"id": intoID}, bson.M{"$set": fill}); err != nil { return err }
+    }
+    return nil
+}
+
+func mergeSet(ctx context.Context, c *mongo.Collection, field, fromID, intoID string) error {
+    cur, err := c.Find(ctx, bson.M{field: fromID})
+    if err != nil { return err }
+    var docs []bson.M
+    if err := cur.All(ctx, &docs); err != nil { return err }
+    for _, d := range docs {
+        _, err := c.UpdateOne(ctx, bson.M{"_id": d["_id"]}, bson.M{"$set": bson.M{field: intoID}})
+        if mongo.IsDuplicateKeyError(err) { _, err = c.DeleteOne(ctx, bson.M{"_id": d["_id"]}) }
+        if err != nil { return err }
+    }
+    return nil
+}
+
+// mergeMembers moves fromID's company memberships to intoID. Where intoID
+// is already a member, it keeps the higher of the two roles, so a company
+// whose owner was merged away still has one.
+func mergeMembers(ctx context.Context, c *mongo.Collection, fromID, intoID string) error {
+    cur, err := c.Find(ctx, bson.M{"userId": fromID})
+    if err != nil { return err }
+    var docs []model.CompanyMember
+    if err := cur.All(ctx, &docs); err != nil { return err }
+    for _, m := range docs {
+        _, err := c.UpdateOne(ctx, bson.M{"companyId": m.CompanyID, "userId": fromID}, bson.M{"$set": bson.M{"userId": intoID}})
+        if !mongo.IsDuplicateKeyError(err) {
+            if err != nil { return err }
+            continue
+        }
+        kept, err := findOne[model.CompanyMember](ctx, c, bson.M{"companyId": m.CompanyID, "userId": intoID})
+        if err != nil { return err }
+        if !kept.AtLeast(m.Role) {
+            if _, err := c.UpdateOne(ctx, bson.M{"companyId": m.CompanyID, "userId": intoID}, bson.M{"$set": bson.M{"role": m.Role}}); err != nil { return err }
+        }
+        if _, err := c.DeleteOne(ctx, bson.M{"companyId": m.CompanyID, "userId": fromID}); err != nil { return err }
+    }
+    return nil
+}
+
+func mergeSingleton(ctx context.Context, c *mongo.Collection, fromID, intoID string, sum []string) error {
+    var doc bson.M
+    err := c.FindOne(ctx, bson.M{"userId": fromID}).Decode(&doc)
+    if errors.Is(err, mongo.ErrNoDocuments) { return nil }
+    if err != nil { return err }
+    n, err := c.CountDocuments(ctx, bson.M{"userId": intoID})
+    if err != nil { return err }
+    if n == 0 {
+        _, err = c.UpdateOne(ctx, bson.M{"userId": fromID}, bson.M{"$set": bson.M{"userId": intoID}})
+        return err
+    }
+    inc := bson.M{}
+    for _, f := range sum {
+        if v, ok := doc[f]; ok { inc[f] = v }
+    }
+    if len(inc) > 0 {
+        if _, err := c.UpdateOne(ctx, bson.M{"userId": intoID}, bson.M{"$inc": inc}); err != nil { return err }
+    }
+    _, err = c.DeleteMany(ctx, bson.M{"userId": fromID})
+    return err
+}
+
+// Merge moves the same references as the Mongo version and then drops
+// fromID, as Memory keeps no tombstones.
+func (r memUsers) Merge(ctx context.Context, fromID, intoID string) error {
+    m := r.m
+    m.mu.Lock()
+    defer m.mu.Unlock()
+    from, ok := m.Users[fromID]
+    if !ok { return ErrNotFound }
+    into, ok := m.Users[intoID]
+    if !ok { return ErrNotFound }
+    repoint := func(id *string) {
+        if *id == fromID { *id = intoID }
+    }
+
+    for i := range m.Identities { repoint(&m.Identities[i].UserID) }
+    for i := range m.Inbox { repoint(&m.Inbox[i].UserID) }
+    for i := range m.Charges { repoint(&m.Charges[i].UserID) }
+    for i := range m.CapacityPacks { repoint(&m.CapacityPacks[i].UserID) }
+    for id, v := range m.Projects {
+        repoint(&v.AuthorID)
+        m.Projects[id] = v
+    }
+    for id, v := range m.Products {
+        repoint(&v.AuthorID)
+        m.Products[id] = v
+    }
+    for id, v := range m.Posts {
+        repoint(&v.AuthorID)
+        m.Posts[id] = v
+    }
+    for id, j := range m.Jobs {
+        repoint(&j.OwnerID)
+        m.Jobs[id] = j
+    }
+    for i := range m.ApplicationEvents { repoint(&m.ApplicationEvents[i].ActorID) }
+    for id, inv := range m.Invitations {
+        repoint(&inv.InvitedBy)
+        m.Invitations[id] = inv
+    }
+    for id, p := range m.Pitches {
+        repoint(&p.OwnerID)
+        m.Pitches[id] = p
+    }
+    for id, j := range m.TranscodeJobs {
+        repoint(&j.OwnerID)
+        m.TranscodeJobs[id] = j
+    }
+    for id, a := range m.Media {
+        repoint(&a.OwnerID)
+        m.Media[id] = a
+    }
+    for id, u := range m.Uploads {
+        repoint(&u.OwnerID)
+        m.Uploads[id] = u
+    }
+    for id, vr := range m.VerificationRequests {
+        repoint(&vr.CreatedBy)
+        repoint(&vr.ReviewerID)
+        m.VerificationRequests[id] = vr
+    }
+    for id, cm := range m.Moderation {
+        repoint(&cm.AuthorID)
+        repoint(&cm.ReviewerID)
+        m.Moderation[id] = cm
+    }
+
+    // The unique sets: fromID's entry is dropped when intoID has the same.
+    for id, a := range m.Applications {
+        repoint(&a.RecruiterID)
+        if a.CandidateID == fromID {
+            if m.applied(a.JobID, intoID) { delete(m.Applications, id); continue }
+            a.CandidateID = intoID
+        }
+        m.Applications[id] = a
+    }
+    follows := m.Follows[:0]
+    for _, f := range m.Follows {
+        if f.UserID == fromID && m.follows(intoID, f.Type, f.Target) >= 0 { continue }
+        repoint(&f.UserID)
+        follows = append(follows, f)
+    }
+    m.Follows = follows
+    // Memberships keep the higher role, as mergeMembers does.
+    fromRoles := map[string]model.CompanyMember{}
+    for _, cm := range m.Members {
+        if cm.UserID == fromID { fromRoles[cm.CompanyID] = cm }
+    }
+    members := []model.CompanyMember{}
+    for _, cm := range m.Members {
+        if cm.UserID == fromID && (memCompanies{m}).member(cm.CompanyID, intoID) >= 0 { continue }
+        if from, ok := fromRoles[cm.CompanyID]; ok && cm.UserID == intoID && !cm.AtLeast(from.Role) { cm.Role = from.Role }
+        repoint(&cm.UserID)
+        members = append(members, cm)
+    }
+    m.Members = members
+    for id, rep := range m.Reports {
+        if rep.ReporterID != fromID { continue }
+        rep.ReporterID = intoID
+        if rep.Status == model.ReportOpen && m.openReport(intoID, rep.TargetType, rep.TargetID) != nil {
+            delete(m.Reports, id)
+            continue
+        }
+        m.Reports[id] = rep
+    }
+
+    // The singletons: counters are added up, anything else stays intoID's.
+    if s, ok := m.JobSlots[fromID]; ok {
+        t, had := m.JobSlots[intoID]
+        if had { t.Slots += s.Slots } else { t = model.JobSlot{UserID: intoID, Slots: s.Slots} }
+        m.JobSlots[intoID] = t
+        delete(m.JobSlots, fromID)
+    }
+    if n, ok := m.UploadSlots[fromID]; ok {
+        m.UploadSlots[intoID] += n
+        delete(m.UploadSlots, fromID)
+    }
+    if u, ok := m.Usage[fromID]; ok {
+        t, had := m.Usage[intoID]
+        if had {
+            t.StorageGB, t.BandwidthGB, t.TranscodeMin = t.StorageGB+u.StorageGB, t.BandwidthGB+u.BandwidthGB, t.TranscodeMin+u.TranscodeMin
+        } else {
+            t = u
+            t.UserID = intoID
+        }
+        m.Usage[intoID] = t
+        delete(m.Usage, fromID)
+    }
+    if q, ok := m.Quotas[fromID]; ok {
+        if _, had := m.Quotas[intoID]; !had {
+            q.UserID = intoID
+            m.Quotas[intoID] = q
+        }
+        delete(m.Quotas, fromID)
+    }
+    if p, ok := m.Preferences[fromID]; ok {
+        if _, had := m.Preferences[intoID]; !had {
+            p.UserID = intoID
+            m.Preferences[intoID] = p
+        }
+        delete(m.Preferences, fromID)
+    }
+
+    if into.Name == "" { into.Name = from.Name }
+    if into.Email == "" { into.Email = from.Email }
+    if into.AvatarURL == "" { into.AvatarURL = from.AvatarURL }
+    m.Users[intoID] = into
+    delete(m.Users, fromID)
+    return nil
+}
@@ -0,0 +1,986 @@
+package repo
+
+import (
+    "context"
+    "errors"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "real_deal/internal/model"
+    "real_deal/internal/query"
+)
+
+// NewMongo returns repositories backed by db.
+func NewMongo(db *mongo.Database) *Repos {
+    return &Repos{
+        Users:                mongoUsers{db.Collection("users"), db},
+        Jobs:                 mongoJobs{db.Collection("jobs"), db.Collection("job_compliance")},
+        Companies:            mongoCompanies{db},
+        Media:                mongoMedia{db.Collection("media_assets")},
+        DealRooms:            mongoDealRooms{db.Collection("deal_rooms")},
+        Billing:              mongoBilling{db},
+        Projects:             mongoContent[model.Project, *model.Project]{db.Collection("projects")},
+        Products:             mongoContent[model.Product, *model.Product]{db.Collection("products")},
+        Posts:                mongoContent[model.Post, *model.Post]{db.Collection("posts")},
+        Applications:         mongoApplications{db.Collection("applications"), db.Collection("application_events")},
+        Follows:              mongoFollows{db.Collection("follows")},
+        Inbox:                mongoInbox{db.Collection("inbox_items")},
+        Preferences:          mongoPreferences{db.Collection("notification_preferences")},
+        Investors:            mongoInvestors{db.Collection("investor_profiles")},
+        Pitches:              mongoPitches{db.Collection("pitch_pages")},
+        Moderation:           mongoModeration{db.Collection("content_moderation")},
+        Reports:              mongoReports{db.Collection("reports")},
+        Verifications:        mongoVerifications{db.Collection("company_verifications")},
+        VerificationRequests: mongoVerificationRequests{db.Collection("verification_requests")},
+        Uploads:              mongoUploads{db.Collection("media_uploads")},
+        TranscodeJobs:        mongoTranscodeJobs{db.Collection("transcode_jobs")},
+        LoginTokens:          mongoLoginTokens{db.Collection("login_tokens")},
+        OAuthStates:          mongoOAuthStates{db.Collection("oauth_states")},
+        Identities:           mongoIdentities{db.Collection("user_identities")},
+        Documents:            mongoDocuments{db},
+    }
+}
+
+// EnsureIndexes creates the indexes the Mongo repositories rely on.
+func EnsureIndexes(ctx context.Context, db *mongo.Database) error {
+    _, err := db.Collection("users").Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+        {Keys: bson.D{{Key: "email", Value: 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"email": bson.M{"$type": "string"}})},
+    })
+    if err != nil { return err }
+    if _, err := db.Collection("company_members").Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "companyId", Value: 1}, {Key: "userId", Value: 1}}, Options: options.Index().SetUnique(true)},
+        {Keys: bson.D{{Key: "userId", Value: 1}}},
+    }); err != nil { return err }
+    if _, err := db.Collection("job_compliance").Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys: bson.D{{Key: "jobId", Value: 1}}, Options: options.Index().SetUnique(true),
+    }); err != nil { return err }
+    _, err = db.Collection("company_invitations").Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+        {Keys: bson.D{{Key: "email", Value: 1}, {Key: "status", Value: 1}}},
+        // one pending invitation per company and address
+        {Keys: bson.D{{Key: "companyId", Value: 1}, {Key: "email", Value: 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"status": model.InvitePending})},
+    })
+    if err != nil { return err }
+    if _, err := db.Collection("applications").Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "jobId", Value: 1}, {Key: "candidateId", Value: 1}}, Options: options.Index().SetUnique(true)},
+        {Keys: bson.D{{Key: "candidateId", Value: 1}, {Key: "updatedAt", Value: -1}}},
+    }); err != nil { return err }
+    if _, err := db.Collection("application_events").Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys: bson.D{{Key: "applicationId", Value: 1}, {Key: "at", Value: 1}},
+    }); err != nil { return err }
+    if _, err := db.Collection("follows").Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys: bson.D{{Key: "userId", Value: 1}, {Key: "type", Value: 1}, {Key: "target", Value: 1}}, Options: options.Index().SetUnique(true),
+    }); err != nil { return err }
+    if _, err := db.Collection("content_moderation").Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "contentId", Value: 1}}, Options: options.Index().SetUnique(true)},
+        {Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}},
+    }); err != nil { return err }
+    if _, err := db.Collection("reports").Indexes().CreateMany(ctx, []mongo.IndexModel{
+        // one open report per reporter and target
+        {Keys: bson.D{{Key: "reporterId", Value: 1}, {Key: "targetType", Value: 1}, {Key: "targetId", Value: 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"status": model.ReportOpen})},
+        {Keys: bson.D{{Key: "targetId", Value: 1}, {Key: "status", Value: 1}}},
+        {Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: -1}}},
+    }); err != nil { return err }
+    if _, err := db.Collection("company_verifications").Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys: bson.D{{Key: "companyId", Value: 1}}, Options: options.Index().SetUnique(true),
+    }); err != nil { return err }
+    if _, err := db.Collection("verification_requests").Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+        // one open request per company
+        {Keys: bson.D{{Key: "companyId", Value: 1}}, Options: options.Index().SetUnique(true).SetPartialFilterExpression(bson.M{"open": true})},
+        {Keys: bson.D{{Key: "status", Value: 1}, {Key: "submittedAt", Value: 1}}},
+    }); err != nil { return err }
+    if _, err := db.Collection("media_uploads").Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+        {Keys: bson.D{{Key: "status", Value: 1}, {Key: "expiresAt", Value: 1}}},
+    }); err != nil { return err }
+    if _, err := db.Collection("upload_slots").Indexes().CreateOne(ctx, mongo.IndexModel{
+        Keys: bson.D{{Key: "userId", Value: 1}}, Options: options.Index().SetUnique(true),
+    }); err != nil { return err }
+    // Mongo drops expired login tokens and OAuth states on its own.
+    for _, coll := range []string{"login_tokens", "oauth_states"} {
+        if _, err := db.Collection(coll).Indexes().CreateMany(ctx, []mongo.IndexModel{
+            {Keys: bson.D{{Key: "hash", Value: 1}}, Options: options.Index().SetUnique(true)},
+            {Keys: bson.D{{Key: "expiresAt", Value: 1}}, Options: options.Index().SetExpireAfterSeconds(0)},
+        }); err != nil { return err }
+    }
+    if _, err := db.Collection("user_identities").Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "provider", Value: 1}, {Key: "subject", Value: 1}}, Options: options.Index().SetUnique(true)},
+        {Keys: bson.D{{Key: "userId", Value: 1}}},
+    }); err != nil { return err }
+    _, err = db.Collection("transcode_jobs").Indexes().CreateMany(ctx, []mongo.IndexModel{
+        {Keys: bson.D{{Key: "id", Value: 1}}, Options: options.Index().SetUnique(true)},
+        {Keys: bson.D{{Key: "status", Value: 1}, {Key: "createdAt", Value: 1}}},
+        {Keys: bson.D{{Key: "assetId", Value: 1}, {Key: "createdAt", Value: -1}}},
+    })
+    return err
+}
+
+// findAll decodes every document matching filter, in the given order.
+func findAll[T any](ctx context.Context, c *mongo.Collection, filter bson.M, sort bson.D) ([]T, error) {
+    cur, err := c.Find(ctx, filter, options.Find().SetSort(sort))
+    if err != nil { return nil, err }
+    items := []T{}
+    err = cur.All(ctx, &items)
+    return items, err
+}
+
+// findOne decodes the document matching filter, mapping "no documents" to
+// ErrNotFound.
+func findOne[T any](ctx context.Context, c *mongo.Collection, filter bson.M) (*T, error) {
+    var v T
+    err := c.FindOne(ctx, filter).Decode(&v)
+    if errors.Is(err, mongo.ErrNoDocuments) { return nil, ErrNotFound }
+    if err != nil { return nil, err }
+    return &v, nil
+}
+
+func insert(ctx context.Context, c *mongo.Collection, v any) error {
+    _, err := c.InsertOne(ctx, v)
+    if mongo.IsDuplicateKeyError(err) { return ErrDuplicate }
+    return err
+}
+
+// mongoUsers keeps db for Merge, which touches every collection that
+// refers to a user.
+type mongoUsers struct {
+    c  *mongo.Collection
+    db *mongo.Database
+}
+
+var liveUser = bson.M{"$exists": false}
+
+func (r mongoUsers) Get(ctx context.Context, id string) (*model.User, error) {
+    return findOne[model.User](ctx, r.c, bson.M{"id": id, "mergedInto": liveUser})
+}
+
+func (r mongoUsers) ByEmail(ctx context.Context, email string) (*model.User, error) {
+    return findOne[model.User](ctx, r.c, bson.M{"email": email, "mergedInto": liveUser})
+}
+
+func (r mongoUsers) Create(ctx context.Context, u *model.User) error { return insert(ctx, r.c, u) }
+
+func (r mongoUsers) SetModeration(ctx context.Context, id, status string) error {
+    return setModeration(ctx, r.c, bson.M{"id": id, "mergedInto": liveUser}, status)
+}
+
+type mongoJobs struct{ c, compliance *mongo.Collection }
+
+// LiveJobs matches the jobs anyone may see: published (or seeded before the
+// lifecycle existed), not past their expiry, even if the sweeper has not
+// run yet, and not rejected in moderation. It is the Mongo form of
+// model.Job.Live.
+func LiveJobs(now time.Time) bson.M {
+    return bson.M{
+        "status":     bson.M{"$in": bson.A{model.JobPublished, nil}},
+        "$or":        bson.A{bson.M{"expiresAt": nil}, bson.M{"expiresAt": bson.M{"$gt": now}}},
+        "moderation": bson.M{"$ne": model.Rejected},
+    }
+}
+
+// LiveContent matches the projects, products and posts anyone may see: not
+// deleted and not rejected in moderation. It takes now only to fit beside
+// LiveJobs.
+func LiveContent(time.Time) bson.M {
+    return bson.M{"deletedAt": bson.M{"$exists": false}, "moderation": bson.M{"$ne": model.Rejected}}
+}
+
+// statusValue and slotValue turn a decoded field back into a filter that
+// also matches seeded jobs, which have neither field stored.
+func statusValue(s string) any {
+    if s == "" { return nil }
+    return s
+}
+
+func slotValue(held bool) any {
+    if held { return true }
+    return bson.M{"$ne": true}
+}
+
+func (r mongoJobs) Get(ctx context.Context, id string) (*model.Job, error) {
+    return findOne[model.Job](ctx, r.c, bson.M{"id": id})
+}
+
+func (r mongoJobs) GetMany(ctx context.Context, ids []string) ([]model.Job, error) {
+    return findAll[model.Job](ctx, r.c, bson.M{"id": bson.M{"$in": ids}}, bson.D{{Key: "id", Value: 1}})
+}
+
+func (r mongoJobs) List(ctx context.Context, f JobFilter, q *query.Query) (*query.Page[model.Job], error) {
+    base := bson.M{}
+    if f.Live { base = LiveJobs(f.Now) }
+    if f.OwnerID != "" { base["ownerId"] = f.OwnerID }
+    return query.Find[model.Job](ctx, r.c, base, q)
+}
+
+func (r mongoJobs) Insert(ctx context.Context, j *model.Job) error { return insert(ctx, r.c, j) }
+
+// Save $sets the job's fields rather than replacing the document, so its
+// stats and search entry survive; a cleared expiry is unset. Moderation is
+// cleared on the copy so omitempty leaves it out.
+func (r mongoJobs) Save(ctx context.Context, j, prev *model.Job) error {
+    set := *j
+    set.Moderation = ""
+    upd := bson.M{"$set": &set}
+    if j.ExpiresAt == nil { upd["$unset"] = bson.M{"expiresAt": ""} }
+    res, err := r.c.UpdateOne(ctx, bson.M{"id": prev.ID, "status": statusValue(prev.Status), "slotHeld": slotValue(prev.SlotHeld)}, upd)
+    if err != nil { return err }
+    if res.MatchedCount == 0 { return ErrConflict }
+    return nil
+}
+
+func (r mongoJobs) Due(ctx context.Context, now time.Time) ([]model.Job, error) {
+    cur, err := r.c.Find(ctx, bson.M{"status": bson.M{"$in": bson.A{model.JobPublished, model.JobPaused}}, "expiresAt": bson.M{"$lte": now}})
+    if err != nil { return nil, err }
+    var due []model.Job
+    err = cur.All(ctx, &due)
+    return due, err
+}
+
+func (r mongoJobs) Bump(ctx context.Context, id, stat string) error {
+    _, err := r.c.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$inc": bson.M{"stats." + stat: 1}})
+    return err
+}
+
+func (r mongoJobs) Compliance(ctx context.Context, jobID string) (*model.JobCompliance, error) {
+    return findOne[model.JobCompliance](ctx, r.compliance, bson.M{"jobId": jobID})
+}
+
+func (r mongoJobs) SetCompliance(ctx context.Context, jc *model.JobCompliance) error {
+    _, err := r.compliance.ReplaceOne(ctx, bson.M{"jobId": jc.JobID}, jc, options.Replace().SetUpsert(true))
+    return err
+}
+
+func (r mongoJobs) SetModeration(ctx context.Context, id, status string) error {
+    return setModeration(ctx, r.c, bson.M{"id": id}, status)
+}
+
+type mongoCompanies struct{ db *mongo.Database }
+
+func (r mongoCompanies) Get(ctx context.Context, id string) (*model.Company, error) {
+    return findOne[model.Company](ctx, r.db.Collection("companies"), bson.M{"id": id})
+}
+
+func (r mongoCompanies) GetMany(ctx context.Context, ids []string) ([]model.Company, error) {
+    return findAll[model.Company](ctx, r.db.Collection("companies"), bson.M{"id": bson.M{"$in": ids}}, bson.D{{Key: "id", Value: 1}})
+}
+
+func (r mongoCompanies) List(ctx context.Context, q *query.Query) (*query.Page[model.Company], error) {
+    return query.Find[model.Company](ctx, r.db.Collection("companies"), bson.M{}, q)
+}
+
+func (r mongoCompanies) Create(ctx context.Context, co *model.Company, ownerID string) error {
+    if err := insert(ctx, r.db.Collection("companies"), co); err != nil { return err }
+    err := insert(ctx, r.db.Collection("company_members"), &model.CompanyMember{CompanyID: co.ID, UserID: ownerID, Role: model.CompanyRoleOwner, JoinedAt: time.Now().UTC()})
+    if err != nil { _, _ = r.db.Collection("companies").DeleteOne(ctx, bson.M{"id": co.ID}) }
+    return err
+}
+
+func (r mongoCompanies) Update(ctx context.Context, co *model.Company) error {
+    set := bson.M{"name": co.Name, "description": co.Description, "tags": co.Tags, "updatedAt": co.UpdatedAt}
+    upd := bson.M{"$set": set}
+    if co.Website != "" { set["website"] = co.Website } else { upd["$unset"] = bson.M{"website": ""} }
+    res, err := r.db.Collection("companies").UpdateOne(ctx, bson.M{"id": co.ID}, upd)
+    if err != nil { return err }
+    if res.MatchedCount == 0 { return ErrNotFound }
+    return nil
+}
+
+func (r mongoCompanies) SetVerified(ctx context.Context, id string, verified bool) error {
+    res, err := r.db.Collection("companies").UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": bson.M{"verified": verified}})
+    if err != nil { return err }
+    if res.MatchedCount == 0 { return ErrNotFound }
+    return nil
+}
+
+func (r mongoCompanies) Member(ctx context.Context, companyID, userID string) (*model.CompanyMember, error) {
+    return findOne[model.CompanyMember](ctx, r.db.Collection("company_members"), bson.M{"companyId": companyID, "userId": userID})
+}
+
+func (r mongoCompanies) Members(ctx context.Context, companyID string) ([]model.CompanyMember, error) {
+    return findAll[model.CompanyMember](ctx, r.db.Collection("company_members"), bson.M{"companyId": companyID}, bson.D{{Key: "joinedAt", Value: 1}})
+}
+
+func (r mongoCompanies) MembershipsOf(ctx context.Context, userID string) ([]model.CompanyMember, error) {
+    return findAll[model.CompanyMember](ctx, r.db.Collection("company_members"), bson.M{"userId": userID}, bson.D{{Key: "joinedAt", Value: 1}})
+}
+
+func (r mongoCompanies) AddMember(ctx context.Context, m *model.CompanyMember) error {
+    return insert(ctx, r.db.Collection("company_members"), m)
+}
+
+func (r mongoCompanies) SetRole(ctx context.Context, companyID, userID, from, to string) error {
+    res, err := r.db.Collection("company_members").UpdateOne(ctx, bson.M{"companyId": companyID, "userId": userID, "role": from}, bson.M{"$set": bson.M{"role": to}})
+    if err != nil { return err }
+    if res.MatchedCount == 0 { return ErrConflict }
+    return nil
+}
+
+func (r mongoCompanies) RemoveMember(ctx context.Context, companyID, userID string) error {
+    res, err := r.db.Collection("company_members").DeleteOne(ctx, bson.M{"companyId": companyID, "userId": userID})
+    if err != nil { return err }
+    if res.DeletedCount == 0 { return ErrNotFound }
+    return nil
+}
+
+// TransferOwnership promotes the new owner before demoting the old one, so
+// an interrupted transfer leaves two owners rather than none.
+func (r mongoCompanies) TransferOwnership(ctx context.Context, companyID, fromID, toID string) error {
+    members := r.db.Collection("company_members")
+    res, err := members.UpdateOne(ctx, bson.M{"companyId": companyID, "userId": toID, "role": bson.M{"$ne": model.CompanyRoleOwner}}, bson.M{"$set": bson.M{"role": model.CompanyRoleOwner}})
+    if err != nil { return err }
+    if res.MatchedCount == 0 { return ErrConflict }
+    res, err = members.UpdateOne(ctx, bson.M{"companyId": companyID, "userId": fromID, "role": model.CompanyRoleOwner}, bson.M{"$set": bson.M{"role": model.CompanyRoleAdmin}})
+    if err != nil { return err }
+    if res.MatchedCount == 0 {
+        _, _ = members.UpdateOne(ctx, bson.M{"companyId": companyID, "userId": toID}, bson.M{"$set": bson.M{"role": model.CompanyRoleAdmin}})
+        return ErrConflict
+    }
+    return nil
+}
+
+func (r mongoCompanies) Invite(ctx context.Context, inv *model.CompanyInvitation) error {
+    return insert(ctx, r.db.Collection("company_invitations"), inv)
+}
+
+func (r mongoCompanies) Invitation(ctx context.Context, id string) (*model.CompanyInvitation, error) {
+    return findOne[model.CompanyInvitation](ctx, r.db.Collection("company_invitations"), bson.M{"id": id})
+}
+
+func (r mongoCompanies) Invitations(ctx context.Context, companyID string) ([]model.CompanyInvitation, error) {
+    return findAll[model.CompanyInvitation](ctx, r.db.Collection("company_invitations"), bson.M{"companyId": companyID}, bson.D{{Key: "createdAt", Value: -1}})
+}
+
+func (r mongoCompanies) PendingFor(ctx context.Context, email string) ([]model.CompanyInvitation, error) {
+    return findAll[model.CompanyInvitation](ctx, r.db.Collection("company_invitations"), bson.M{"email": email, "status": model.InvitePending}, bson.D{{Key: "createdAt", Value: -1}})
+}
+
+func (r mongoCompanies) CloseInvitation(ctx context.Context, id, status string, at time.Time) error {
+    res, err := r.db.Collection("company_invitations").UpdateOne(ctx, bson.M{"id": id, "status": model.InvitePending}, bson.M{"$set": bson.M{"status": status, "respondedAt": at}})
+    if err != nil { return err }
+    if res.MatchedCount == 0 { return ErrConflict }
+    return nil
+}
+
+type mongoMedia struct{ c *mongo.Collection }
+
+func (r mongoMedia) Get(ctx context.Context, id string) (*model.MediaAsset, error) {
+    return findOne[model.MediaAsset](ctx, r.c, bson.M{"id": id})
+}
+
+func (r mongoMedia) List(ctx context.Context, q *query.Query) (*query.Page[model.MediaAsset], error) {
+    return query.Find[model.MediaAsset](ctx, r.c, bson.M{"moderation": bson.M{"$ne": model.MediaRejected}}, q)
+}
+
+func (r mongoMedia) Insert(ctx context.Context, m *model.MediaAsset) error { return insert(ctx, r.c, m) }
+
+func (r mongoMedia) SetModeration(ctx context.Context, id, status string) error {
+    return setModeration(ctx, r.c, bson.M{"id": id}, status)
+}
+
+func (r mongoMedia) SetTranscode(ctx context.Context, id string, t *model.Transcode) error {
+    res, err := r.c.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": bson.M{"transcode": t}})
+    if err != nil { return err }
+    if res.MatchedCount == 0 { return ErrNotFound }
+    return nil
+}
+
+func (r mongoMedia) Delete(ctx context.Context, id string) error {
+    res, err := r.c.DeleteOne(ctx, bson.M{"id": id})
+    if err != nil { return err }
+    if res.DeletedCount == 0 { return ErrNotFound }
+    return nil
+}
+
+// setModeration sets or, for "", unsets the moderation field of the
+// document matching filter.
+func setModeration(ctx context.Context, c *mongo.Collection, filter bson.M, status string) error {
+    upd := bson.M{"$set": bson.M{"moderation": status}}
+    if status == "" { upd = bson.M{"$unset": bson.M{"moderation": ""}} }
+    res, err := c.UpdateOne(ctx, filter, upd)
+    if err != nil { return err }
+    if res.MatchedCount == 0 { return ErrNotFound }
+    return nil
+}
+
+type mongoDealRooms struct{ c *mongo.Collection }
+
+func (r mongoDealRooms) Get(ctx context.Context, id string) (*model.DealRoom, error) {
+    return findOne[model.DealRoom](ctx, r.c, bson.M{"id": id})
+}
+
+type mongoBilling struct{ db *mongo.Database }
+
+func (r mongoBilling) Usage(ctx context.Context, userID string) (*model.Usage, error) {
+    return findOne[model.Usage](ctx, r.db.Collection("usage_meters"), bson.M{"userId": userID})
+}
+
+func (r mongoBilling) Quota(ctx context.Context, userID string) (*model.Quota, error) {
+    return findOne[model.Quota](ctx, r.db.Collection("quotas"), bson.M{"userId": userID})
+}
+
+func (r mongoBilling) JobSlots(ctx context.Context, userID string) (*model.JobSlot, error) {
+    return findOne[model.JobSlot](ctx, r.db.Collection("job_slots"), bson.M{"userId": userID})
+}
+
+func (r mongoBilling) TakeJobSlot(ctx context.Context, userID string) error {
+    err := r.db.Collection("job_slots").FindOneAndUpdate(ctx,
+        bson.M{"userId": userID, "slots": bson.M{"$gt": 0}},
+        bson.M{"$inc": bson.M{"slots": -1}}).Err()
+    if errors.Is(err, mongo.ErrNoDocuments) { return ErrNoJobSlots }
+    return err
+}
+
+func (r mongoBilling) ReleaseJobSlot(ctx context.Context, userID string) error {
+    _, err := r.db.Collection("job_slots").UpdateOne(ctx, bson.M{"userId": userID}, bson.M{"$inc": bson.M{"slots": 1}}, options.Update().SetUpsert(true))
+    return err
+}
+
+func (r mongoBilling) ChargeStorage(ctx context.Context, userID string, gb, limit float64) error {
+    return charge(ctx, r.db.Collection("usage_meters"), userID, "storageGb", gb, limit)
+}
+
+func (r mongoBilling) ReleaseStorage(ctx context.Context, userID string, gb float64) error {
+    _, err := r.db.Collection("usage_meters").UpdateOne(ctx, bson.M{"userId": userID}, bson.M{"$inc": bson.M{"storageGb": -gb}})
+    return err
+}
+
+func (r mongoBilling) ChargeTranscode(ctx context.Context, userID string, min, limit int) error {
+    return charge(ctx, r.db.Collection("usage_meters"), userID, "transcodeMin", min, limit)
+}
+
+func (r mongoBilling) ReleaseTranscode(ctx context.Context, userID string, min int) error {
+    _, err := r.db.Collection("usage_meters").UpdateOne(ctx, bson.M{"userId": userID}, bson.M{"$inc": bson.M{"transcodeMin": -min}})
+    return err
+}
+
+// charge increments a usage meter field by n only while it stays within
+// limit; a user without a meter gets one.
+func charge[N int | float64](ctx context.Context, c *mongo.Collection, userID, field string, n, limit N) error {
+    res, err := c.UpdateOne(ctx, bson.M{"userId": userID, "$or": bson.A{bson.M{field: bson.M{"$lte": limit - n}}, bson.M{field: nil}}}, bson.M{"$inc": bson.M{field: n}})
+    if err != nil { return err }
+    if res.MatchedCount > 0 { return nil }
+    cnt, err := c.CountDocuments(ctx, bson.M{"userId": userID})
+    if err != nil { return err }
+    if cnt > 0 || n > limit { return ErrQuotaExceeded }
+    _, err = c.UpdateOne(ctx, bson.M{"userId": userID}, bson.M{"$inc": bson.M{field: n}}, options.Update().SetUpsert(true))
+    return err
+}
+
+func (r mongoBilling) CapacityPacks(ctx context.Context, userID string, q *query.Query) (*query.Page[model.CapacityPack], error) {
+    return query.Find[model.CapacityPack](ctx, r.db.Collection("capacity_packs"), bson.M{"userId": userID}, q)
+}
+
+func (r mongoBilling) Charges(ctx context.Context, userID string, q *query.Query) (*query.Page[model.Charge], error) {
+    return query.Find[model.Charge](ctx, r.db.Collection("charges"), bson.M{"userId": userID}, q)
+}
+
+// fields turns v into a document of its stored fields, for upserts that
+// only write v when they insert.
+func fields(v any) (bson.M, error) {
+    raw, err := bson.Marshal(v)
+    if err != nil { return nil, err }
+    var doc bson.M
+    err = bson.Unmarshal(raw, &doc)
+    return doc, err
+}
+
+type mongoContent[T any, PT contentPtr[T]] struct{ c *mongo.Collection }
+
+var notDeleted = bson.M{"$exists": false}
+
+func (r mongoContent[T, PT]) Get(ctx context.Context, id string) (*T, error) {
+    return findOne[T](ctx, r.c, bson.M{"id": id, "deletedAt": notDeleted})
+}
+
+func (r mongoContent[T, PT]) List(ctx context.Context, q *query.Query) (*query.Page[T], error) {
+    return query.Find[T](ctx, r.c, LiveContent(time.Time{}), q)
+}
+
+func (r mongoContent[T, PT]) Insert(ctx context.Context, v *T) error { return insert(ctx, r.c, v) }
+
+// Update $sets the fields rather than replacing the document, so its stats
+// and search entry survive.
+func (r mongoContent[T, PT]) Update(ctx context.Context, v *T) error {
+    res, err := r.c.UpdateOne(ctx, bson.M{"id": PT(v).Meta().ID, "deletedAt": notDeleted}, bson.M{"$set": v})
+    if err != nil { return err }
+    if res.MatchedCount == 0 { return ErrNotFound }
+    return nil
+}
+
+func (r mongoContent[T, PT]) Delete(ctx context.Context, id string, at time.Time) error {
+    res, err := r.c.UpdateOne(ctx, bson.M{"id": id, "deletedAt": notDeleted}, bson.M{"$set": bson.M{"deletedAt": at, "updatedAt": at}})
+    if err != nil { return err }
+    if res.MatchedCount == 0 { return ErrNotFound }
+    return nil
+}
+
+func (r mongoContent[T, PT]) Bump(ctx context.Context, id, stat string) error {
+    _, err := r.c.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$inc": bson.M{"stats." + stat: 1}})
+    return err
+}
+
+func (r mongoContent[T, PT]) SetModeration(ctx context.Context, id, status string) error {
+    return setModeration(ctx, r.c, bson.M{"id": id}, status)
+}
+
+type mongoApplications struct{ c, events *mongo.Collection }
+
+func (r mongoApplications) Get(ctx context.Context, id string) (*model.Application, error) {
+    return findOne[model.Application](ctx, r.c, bson.M{"id": id})
+}
+
+func (r mongoApplications) List(ctx context.Context, f ApplicationFilter, q *query.Query) (*query.Page[model.Application], error) {
+    base := bson.M{}
+    if f.CandidateID != "" { base["candidateId"] = f.CandidateID }
+    if f.JobID != "" { base["jobId"] = f.JobID }
+    return query.Find[model.Application](ctx, r.c, base, q)
+}
+
+func (r mongoApplications) Insert(ctx context.Context, a *model.Application) error { return insert(ctx, r.c, a) }
+
+func (r mongoApplications) Move(ctx context.Context, id, from, to string, at time.Time) error {
+    res, err := r.c.UpdateOne(ctx, bson.M{"id": id, "stage": from}, bson.M{"$set": bson.M{"stage": to, "updatedAt": at}})
+    if err != nil { return err }
+    if res.MatchedCount == 0 { return ErrConflict }
+    return nil
+}
+
+func (r mongoApplications) AddEvent(ctx context.Context, e *model.ApplicationEvent) error {
+    _, err := r.events.InsertOne(ctx, e)
+    return err
+}
+
+func (r mongoApplications) Events(ctx context.Context, applicationID string) ([]model.ApplicationEvent, error) {
+    return findAll[model.ApplicationEvent](ctx, r.events, bson.M{"applicationId": applicationID}, bson.D{{Key: "at", Value: 1}})
+}
+
+type mongoFollows struct{ c *mongo.Collection }
+
+func (r mongoFollows) List(ctx context.Context, userID string) ([]model.Follow, error) {
+    return findAll[model.Follow](ctx, r.c, bson.M{"userId": userID}, bson.D{{Key: "createdAt", Value: -1}})
+}
+
+func (r mongoFollows) Add(ctx context.Context, f *model.Follow) error { return insert(ctx, r.c, f) }
+
+func (r mongoFollows) Remove(ctx context.Context, userID, typ, target string) error {
+    res, err := r.c.DeleteOne(ctx, bson.M{"userId": userID, "type": typ, "target": target})
+    if err != nil { return err }
+    if res.DeletedCount == 0 { return ErrNotFound }
+    return nil
+}
+
+type mongoInbox struct{ c *mongo.Collection }
+
+func (r mongoInbox) List(ctx context.Context, userID string, q *query.Query) (*query.Page[model.InboxItem], error) {
+    return query.Find[model.InboxItem](ctx, r.c, bson.M{"userId": userID}, q)
+}
+
+func (r mongoInbox) Add(ctx context.Context, it *model.InboxItem) error {
+    _, err := r.c.InsertOne(ctx, it)
+    return err
+}
+
+type mongoPreferences struct{ c *mongo.Collection }
+
+func (r mongoPreferences) Get(ctx context.Context, userID string) (*model.NotificationPreference, error) {
+    return findOne[model.NotificationPreference](ctx, r.c, bson.M{"userId": userID})
+}
+
+type mongoInvestors struct{ c *mongo.Collection }
+
+func (r mongoInvestors) List(ctx context.Context, q *query.Query) (*query.Page[model.InvestorProfile], error) {
+    return query.Find[model.InvestorProfile](ctx, r.c, nil, q)
+}
+
+type mongoPitches struct{ c *mongo.Collection }
+
+func (r mongoPitches) Get(ctx context.Context, id string) (*model.PitchPage, error) {
+    return findOne[model.PitchPage](ctx, r.c, bson.M{"id": id})
+}
+
+func (r mongoPitches) Insert(ctx context.Context, p *model.PitchPage) error { return insert(ctx, r.c, p) }
+
+type mongoModeration struct{ c *mongo.Collection }
+
+var unsetClaim = bson.M{"claimedBy": "", "claimedAt": ""}
+
+func (r mongoModeration) Get(ctx context.Context, contentID string) (*model.ContentModeration, error) {
+    return findOne[model.ContentModeration](ctx, r.c, bson.M{"contentId": contentID})
+}
+
+func (r mongoModeration) List(ctx context.Context, q *query.Query) (*query.Page[model.ContentModeration], error) {
+    return query.Find[model.ContentModeration](ctx, r.c, bson.M{}, q)
+}
+
+func (r mongoModeration) Resubmit(ctx context.Context, m *model.ContentModeration, evs []model.ModerationEvent) (bool, error) {
+    set := bson.M{"status": m.Status, "title": m.Title, "score": m.Score, "notes": m.Notes, "updatedAt": m.UpdatedAt}
+    if m.ReviewerID != "" { set["reviewerId"], set["reviewedAt"] = m.ReviewerID, m.ReviewedAt }
+    upd := bson.M{"$set": set, "$push": bson.M{"history": bson.M{"$each": evs}}}
+    if m.Status == model.ModerationRejected { upd["$unset"] = unsetClaim }
+    res, err := r.c.UpdateOne(ctx, bson.M{"contentId": m.ContentID, "status": bson.M{"$in": bson.A{model.ModerationPending, model.ModerationApproved}}}, upd)
+    if err != nil { return false, err }
+    return res.MatchedCount > 0, nil
+}
+
+func (r mongoModeration) Open(ctx context.Context, m *model.ContentModeration) (bool, error) {
+    doc, err := fields(m)
+    if err != nil { return false, err }
+    delete(doc, "contentId")
+    delete(doc, "title")
+    res, err := r.c.UpdateOne(ctx, bson.M{"contentId": m.ContentID}, bson.M{"$set": bson.M{"title": m.Title}, "$setOnInsert": doc}, options.Update().SetUpsert(true))
+    if err != nil { return false, err }
+    return res.UpsertedCount > 0, nil
+}
+
+func (r mongoModeration) Flag(ctx context.Context, m *model.ContentModeration, ev model.ModerationEvent) error {
+    res, err := r.c.UpdateOne(ctx, bson.M{"contentId": m.ContentID, "status": model.ModerationApproved}, bson.M{
+        "$set":   bson.M{"status": model.ModerationPending, "notes": m.Notes, "reports": m.Reports, "updatedAt": m.UpdatedAt},
+        "$unset": unsetClaim,
+        "$push":  bson.M{"history": ev},
+    })
+    if err != nil || res.MatchedCount > 0 { return err }
+    doc, err := fields(m)
+    if err != nil { return err }
+    delete(doc, "contentId")
+    delete(doc, "reports")
+    _, err = r.c.UpdateOne(ctx, bson.M{"contentId": m.ContentID}, bson.M{"$set": bson.M{"reports": m.Reports}, "$setOnInsert": doc}, options.Update().SetUpsert(true))
+    return err
+}
+
+// Save matches the stored record on status and updatedAt, which every
+// change but a report count moves; the count is only written when it
+// changed, so a concurrent report is not lost.
+func (r mongoModeration) Save(ctx context.Context, m, prev *model.ContentModeration, ev model.ModerationEvent) error {
+    set := bson.M{"status": m.Status, "notes": m.Notes, "updatedAt": m.UpdatedAt}
+    unset := bson.M{}
+    if m.ClaimedBy != "" { set["claimedBy"], set["claimedAt"] = m.ClaimedBy, m.ClaimedAt } else { unset = unsetClaim }
+    if m.ReviewerID != "" { set["reviewerId"], set["reviewedAt"] = m.ReviewerID, m.ReviewedAt }
+    if m.Appeal != nil { set["appeal"] = m.Appeal }
+    if m.Reports != prev.Reports { set["reports"] = m.Reports }
+    upd := bson.M{"$set": set, "$push": bson.M{"history": ev}}
+    if len(unset) > 0 { upd["$unset"] = unset }
+    var updatedAt any
+    if prev.UpdatedAt != nil { updatedAt = *prev.UpdatedAt }
+    var out model.ContentModeration
+    err := r.c.FindOneAndUpdate(ctx, bson.M{"contentId": prev.ContentID, "status": prev.Status, "updatedAt": updatedAt}, upd,
+        options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&out)
+    if errors.Is(err, mongo.ErrNoDocuments) { return ErrConflict }
+    if err != nil { return err }
+    *m = out
+    return nil
+}
+
+func (r mongoModeration) SetReports(ctx context.Context, contentID string, n int) error {
+    _, err := r.c.UpdateOne(ctx, bson.M{"contentId": contentID, "reports": bson.M{"$gt": 0}}, bson.M{"$set": bson.M{"reports": n}})
+    return err
+}
+
+type mongoReports struct{ c *mongo.Collection }
+
+func (r mongoReports) Insert(ctx context.Context, rep *model.Report) error { return insert(ctx, r.c, rep) }
+
+func (r mongoReports) OpenBy(ctx context.Context, reporterID, targetType, targetID string) (*model.Report, error) {
+    return findOne[model.Report](ctx, r.c, bson.M{"reporterId": reporterID, "targetType": targetType, "targetId": targetID, "status": model.ReportOpen})
+}
+
+func (r mongoReports) Open(ctx context.Context, targetID string) ([]model.Report, error) {
+    return findAll[model.Report](ctx, r.c, bson.M{"targetId": targetID, "status": model.ReportOpen}, bson.D{{Key: "createdAt", Value: 1}})
+}
+
+func (r mongoReports) List(ctx context.Context, reporterID string, q *query.Query) (*query.Page[model.Report], error) {
+    base := bson.M{}
+    if reporterID != "" { base["reporterId"] = reporterID }
+    return query.Find[model.Report](ctx, r.c, base, q)
+}
+
+func (r mongoReports) Close(ctx context.Context, id, status, by string, at time.Time) (*model.Report, error) {
+    var rep model.Report
+    err := r.c.FindOneAndUpdate(ctx, bson.M{"id": id, "status": model.ReportOpen},
+        bson.M{"$set": bson.M{"status": status, "resolvedBy": by, "resolvedAt": at}},
+        options.FindOneAndUpdate().SetReturnDocument(options.After)).Decode(&rep)
+    if errors.Is(err, mongo.ErrNoDocuments) { return nil, ErrNotFound }
+    if err != nil { return nil, err }
+    return &rep, nil
+}
+
+func (r mongoReports) CloseAll(ctx context.Context, ids []string, status, by string, at time.Time) error {
+    _, err := r.c.UpdateMany(ctx, bson.M{"id": bson.M{"$in": ids}, "status": model.ReportOpen}, bson.M{"$set": bson.M{"status": status, "resolvedBy": by, "resolvedAt": at}})
+    return err
+}
+
+type mongoVerifications struct{ c *mongo.Collection }
+
+func (r mongoVerifications) Get(ctx context.Context, companyID string) (*model.CompanyVerification, error) {
+    return findOne[model.CompanyVerification](ctx, r.c, bson.M{"companyId": companyID})
+}
+
+func (r mongoVerifications) MarkPending(ctx context.Context, companyID, level string, at time.Time) error {
+    res, err := r.c.UpdateOne(ctx, bson.M{"companyId": companyID, "status": model.VerificationApproved}, bson.M{"$set": bson.M{"pendingLevel": level, "updatedAt": at}})
+    if err != nil || res.MatchedCount > 0 { return err }
+    _, err = r.c.UpdateOne(ctx, bson.M{"companyId": companyID},
+        bson.M{"$set": bson.M{"level": level, "status": model.VerificationPending, "updatedAt": at}, "$unset": bson.M{"reason": "", "pendingLevel": ""}},
+        options.Update().SetUpsert(true))
+    return err
+}
+
+func (r mongoVerifications) ClearPending(ctx context.Context, companyID string, at time.Time) error {
+    if _, err := r.c.DeleteOne(ctx, bson.M{"companyId": companyID, "status": model.VerificationPending, "domain": bson.M{"$exists": false}}); err != nil { return err }
+    if _, err := r.c.UpdateOne(ctx, bson.M{"companyId": companyID, "status": model.VerificationPending}, bson.M{"$unset": bson.M{"level": "", "status": ""}}); err != nil { return err }
+    _, err := r.c.UpdateOne(ctx, bson.M{"companyId": companyID}, bson.M{"$set": bson.M{"updatedAt": at}, "$unset": bson.M{"pendingLevel": ""}})
+    return err
+}
+
+func (r mongoVerifications) Approve(ctx context.Context, companyID, level, reason string, at, expires time.Time) error {
+    set := bson.M{"level": level, "status": model.VerificationApproved, "approvedAt": at, "expiresAt": expires, "updatedAt": at}
+    unset := bson.M{"pendingLevel": ""}
+    if reason != "" { set["reason"] = reason } else { unset["reason"] = "" }
+    _, err := r.c.UpdateOne(ctx, bson.M{"companyId": companyID}, bson.M{"$set": set, "$unset": unset}, options.Update().SetUpsert(true))
+    return err
+}
+
+func (r mongoVerifications) Reject(ctx context.Context, companyID, level, reason string, at time.Time) error {
+    res, err := r.c.UpdateOne(ctx, bson.M{"companyId": companyID, "status": model.VerificationApproved}, bson.M{"$set": bson.M{"reason": reason, "updatedAt": at}, "$unset": bson.M{"pendingLevel": ""}})
+    if err != nil || res.MatchedCount > 0 { return err }
+    _, err = r.c.UpdateOne(ctx, bson.M{"companyId": companyID}, bson.M{"$set": bson.M{"level": level, "status": model.VerificationRejected, "reason": reason, "updatedAt": at}}, options.Update().SetUpsert(true))
+    return err
+}
+
+func (r mongoVerifications) Due(ctx context.Context, now time.Time) ([]model.CompanyVerification, error) {
+    return findAll[model.CompanyVerification](ctx, r.c, bson.M{"status": model.VerificationApproved, "expiresAt": bson.M{"$lte": now}}, bson.D{{Key: "expiresAt", Value: 1}})
+}
+
+func (r mongoVerifications) Expire(ctx context.Context, v *model.CompanyVerification, at time.Time) (bool, error) {
+    res, err := r.c.UpdateOne(ctx, bson.M{"companyId": v.CompanyID, "status": model.VerificationApproved, "expiresAt": v.ExpiresAt}, bson.M{"$set": bson.M{"status": model.VerificationExpired, "updatedAt": at}})
+    if err != nil { return false, err }
+    return res.ModifiedCount > 0, nil
+}
+
+func (r mongoVerifications) ClaimDomain(ctx context.Context, companyID string, d *model.DomainVerification) error {
+    _, err := r.c.UpdateOne(ctx, bson.M{"companyId": companyID}, bson.M{"$set": bson.M{"domain": d, "updatedAt": d.ClaimedAt}}, options.Update().SetUpsert(true))
+    return err
+}
+
+func (r mongoVerifications) CheckedDomain(ctx context.Context, companyID string, d *model.DomainVerification, at time.Time) (bool, error) {
+    set := bson.M{"domain.checkedAt": d.CheckedAt, "updatedAt": at}
+    upd := bson.M{"$set": set}
+    if d.Status == model.DomainVerified {
+        set["domain.status"], set["domain.method"], set["domain.verifiedAt"] = d.Status, d.Method, d.VerifiedAt
+        upd["$unset"] = bson.M{"domain.lastError": ""}
+    } else {
+        set["domain.lastError"] = d.LastError
+    }
+    res, err := r.c.UpdateOne(ctx, bson.M{"companyId": companyID, "domain.token": d.Token, "domain.status": model.DomainPending}, upd)
+    if err != nil { return false, err }
+    return res.ModifiedCount > 0, nil
+}
+
+func (r mongoVerifications) PendingDomains(ctx context.Context, claimedAfter, checkedBefore time.Time) ([]model.CompanyVerification, error) {
+    return findAll[model.CompanyVerification](ctx, r.c, bson.M{
+        "domain.status":    model.DomainPending,
+        "domain.claimedAt": bson.M{"$gte": claimedAfter},
+        "$or": bson.A{
+            bson.M{"domain.checkedAt": bson.M{"$exists": false}},
+            bson.M{"domain.checkedAt": bson.M{"$lte": checkedBefore}},
+        },
+    }, bson.D{{Key: "domain.claimedAt", Value: 1}})
+}
+
+type mongoVerificationRequests struct{ c *mongo.Collection }
+
+func (r mongoVerificationRequests) Get(ctx context.Context, id string) (*model.VerificationRequest, error) {
+    return findOne[model.VerificationRequest](ctx, r.c, bson.M{"id": id})
+}
+
+func (r mongoVerificationRequests) Insert(ctx context.Context, vr *model.VerificationRequest) error { return insert(ctx, r.c, vr) }
+
+func (r mongoVerificationRequests) ForCompany(ctx context.Context, companyID string) ([]model.VerificationRequest, error) {
+    return findAll[model.VerificationRequest](ctx, r.c, bson.M{"companyId": companyID}, bson.D{{Key: "createdAt", Value: -1}})
+}
+
+func (r mongoVerificationRequests) List(ctx context.Context, q *query.Query) (*query.Page[model.VerificationRequest], error) {
+    return query.Find[model.VerificationRequest](ctx, r.c, bson.M{"status": bson.M{"$ne": model.RequestDraft}}, q)
+}
+
+func (r mongoVerificationRequests) AddDocument(ctx context.Context, id string, d *model.VerificationDocument) error {
+    return r.update(ctx, bson.M{"id": id, "status": model.RequestDraft}, bson.M{"$push": bson.M{"documents": d}})
+}
+
+func (r mongoVerificationRequests) Submit(ctx context.Context, id string, at time.Time) error {
+    return r.update(ctx, bson.M{"id": id, "status": model.RequestDraft}, bson.M{"$set": bson.M{"status": model.RequestPending, "submittedAt": at}})
+}
+
+func (r mongoVerificationRequests) Close(ctx context.Context, id, from, to, reviewerID, reason string, at time.Time) error {
+    set := bson.M{"status": to}
+    if reviewerID != "" { set["reviewerId"], set["reviewedAt"] = reviewerID, at }
+    if reason != "" { set["reason"] = reason }
+    return r.update(ctx, bson.M{"id": id, "status": from}, bson.M{"$set": set, "$unset": bson.M{"open": ""}})
+}
+
+// update applies upd to the request matching filter, failing with
+// ErrConflict when none does.
+func (r mongoVerificationRequests) update(ctx context.Context, filter, upd bson.M) error {
+    res, err := r.c.UpdateOne(ctx, filter, upd)
+    if err != nil { return err }
+    if res.MatchedCount == 0 { return ErrConflict }
+    return nil
+}
+
+type mongoUploads struct{ c *mongo.Collection }
+
+func (r mongoUploads) Get(ctx context.Context, id, ownerID string) (*model.MediaUpload, error) {
+    return findOne[model.MediaUpload](ctx, r.c, bson.M{"id": id, "ownerId": ownerID})
+}
+
+func (r mongoUploads) Insert(ctx context.Context, u *model.MediaUpload) error { return insert(ctx, r.c, u) }
+
+// Reserve increments the owner's counter in upload_slots while it is below
+// max. A full counter does not match, so the upsert inserts a second
+// document for the owner and the unique index rejects it.
+func (r mongoUploads) Reserve(ctx context.Context, ownerID string, max int) error {
+    if max < 1 { return ErrUploadSlots }
+    _, err := r.slots().UpdateOne(ctx, bson.M{"userId": ownerID, "open": bson.M{"$lt": max}}, bson.M{"$inc": bson.M{"open": 1}}, options.Update().SetUpsert(true))
+    if mongo.IsDuplicateKeyError(err) { return ErrUploadSlots }
+    return err
+}
+
+func (r mongoUploads) Release(ctx context.Context, ownerID string) error {
+    _, err := r.slots().UpdateOne(ctx, bson.M{"userId": ownerID, "open": bson.M{"$gt": 0}}, bson.M{"$inc": bson.M{"open": -1}})
+    return err
+}
+
+func (r mongoUploads) slots() *mongo.Collection { return r.c.Database().Collection("upload_slots") }
+
+func (r mongoUploads) Extend(ctx context.Context, id string, expires time.Time) error {
+    _, err := r.c.UpdateOne(ctx, bson.M{"id": id, "status": model.UploadPending}, bson.M{"$set": bson.M{"expiresAt": expires}})
+    return err
+}
+
+func (r mongoUploads) Transition(ctx context.Context, id, from, to, reason string) (bool, error) {
+    set := bson.M{"status": to}
+    if reason != "" { set["error"] = reason }
+    res, err := r.c.UpdateOne(ctx, bson.M{"id": id, "status": from}, bson.M{"$set": set})
+    if err != nil { return false, err }
+    return res.MatchedCount > 0, nil
+}
+
+func (r mongoUploads) Stale(ctx context.Context, cutoff time.Time) ([]model.MediaUpload, error) {
+    return findAll[model.MediaUpload](ctx, r.c, bson.M{"status": model.UploadPending, "expiresAt": bson.M{"$lte": cutoff}}, bson.D{{Key: "expiresAt", Value: 1}})
+}
+
+type mongoTranscodeJobs struct{ c *mongo.Collection }
+
+func (r mongoTranscodeJobs) Insert(ctx context.Context, j *model.TranscodeJob) error { return insert(ctx, r.c, j) }
+
+func (r mongoTranscodeJobs) Claim(ctx context.Context, now, until time.Time) (*model.TranscodeJob, error) {
+    var j model.TranscodeJob
+    err := r.c.FindOneAndUpdate(ctx,
+        bson.M{"$or": bson.A{bson.M{"status": model.TranscodeQueued}, bson.M{"status": model.TranscodeRunning, "lockedUntil": bson.M{"$lt": now}}}},
+        bson.M{"$set": bson.M{"status": model.TranscodeRunning, "lockedUntil": until}, "$inc": bson.M{"attempts": 1}},
+        options.FindOneAndUpdate().SetSort(bson.D{{Key: "createdAt", Value: 1}}).SetReturnDocument(options.After)).Decode(&j)
+    if errors.Is(err, mongo.ErrNoDocuments) { return nil, ErrNotFound }
+    if err != nil { return nil, err }
+    return &j, nil
+}
+
+func (r mongoTranscodeJobs) SetMinutes(ctx context.Context, id string, min int) error {
+    upd := bson.M{"$set": bson.M{"minutes": min}}
+    if min == 0 { upd = bson.M{"$unset": bson.M{"minutes": ""}} }
+    _, err := r.c.UpdateOne(ctx, bson.M{"id": id}, upd)
+    return err
+}
+
+func (r mongoTranscodeJobs) Finish(ctx context.Context, id, status, errText string, at time.Time) error {
+    _, err := r.c.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": bson.M{"status": status, "error": errText, "finishedAt": at}, "$unset": bson.M{"lockedUntil": ""}})
+    return err
+}
+
+type mongoDocuments struct{ db *mongo.Database }
+
+// visibleIn matches the documents of coll anyone may see at now.
+func visibleIn(coll string, now time.Time) bson.M {
+    switch coll {
+    case "projects", "products", "posts":
+        return LiveContent(now)
+    case "jobs":
+        return LiveJobs(now)
+    }
+    return bson.M{}
+}
+
+func (r mongoDocuments) Newest(ctx context.Context, coll string, now time.Time, limit int64) ([]bson.M, error) {
+    opts := options.Find().
+        SetSort(bson.D{{Key: "createdAt", Value: -1}, {Key: "id", Value: -1}}).
+        SetProjection(bson.M{"_id": 0, "search": 0})
+    if limit > 0 { opts.SetLimit(limit) }
+    cur, err := r.db.Collection(coll).Find(ctx, visibleIn(coll, now), opts)
+    if err != nil { return nil, err }
+    docs := []bson.M{}
+    err = cur.All(ctx, &docs)
+    return docs, err
+}
+
+type mongoLoginTokens struct{ c *mongo.Collection }
+
+func (r mongoLoginTokens) Issue(ctx context.Context, t *model.LoginToken) error {
+    if _, err := r.c.DeleteMany(ctx, bson.M{"email": t.Email, "usedAt": nil}); err != nil { return err }
+    return insert(ctx, r.c, t)
+}
+
+// Redeem matches and marks the token in one FindOneAndUpdate.
+func (r mongoLoginTokens) Redeem(ctx context.Context, hash string, now time.Time) (string, error) {
+    var t model.LoginToken
+    err := r.c.FindOneAndUpdate(ctx,
+        bson.M{"hash": hash, "usedAt": nil, "expiresAt": bson.M{"$gt": now}},
+        bson.M{"$set": bson.M{"usedAt": now}},
+    ).Decode(&t)
+    if errors.Is(err, mongo.ErrNoDocuments) { return "", ErrNotFound }
+    if err != nil { return "", err }
+    return t.Email, nil
+}
+
+type mongoOAuthStates struct{ c *mongo.Collection }
+
+func (r mongoOAuthStates) Insert(ctx context.Context, f *model.OAuthFlow) error { return insert(ctx, r.c, f) }
+
+func (r mongoOAuthStates) Take(ctx context.Context, hash, provider string, now time.Time) (*model.OAuthFlow, error) {
+    var f model.OAuthFlow
+    err := r.c.FindOneAndDelete(ctx, bson.M{"hash": hash, "provider": provider, "expiresAt": bson.M{"$gt": now}}).Decode(&f)
+    if errors.Is(err, mongo.ErrNoDocuments) { return nil, ErrNotFound }
+    if err != nil { return nil, err }
+    return &f, nil
+}
+
+type mongoIdentities struct{ c *mongo.Collection }
+
+func (r mongoIdentities) Find(ctx context.Context, provider, subject string) (*model.UserIdentity, error) {
+    return findOne[model.UserIdentity](ctx, r.c, bson.M{"provider": provider, "subject": subject})
+}
+
+func (r mongoIdentities) List(ctx context.Context, userID string) ([]model.UserIdentity, error) {
+    return findAll[model.UserIdentity](ctx, r.c, bson.M{"userId": userID}, bson.D{{Key: "linkedAt", Value: 1}})
+}
+
+func (r mongoIdentities) Link(ctx context.Context, ui *model.UserIdentity) error {
+    existing, err := r.Find(ctx, ui.Provider, ui.Subject)
+    if err == nil {
+        if existing.UserID == ui.UserID { return nil }
+        return ErrIdentityInUse
+    }
+    if !errors.Is(err, ErrNotFound) { return err }
+    n, err := r.c.CountDocuments(ctx, bson.M{"userId": ui.UserID, "provider": ui.Provider})
+    if err != nil { return err }
+    if n > 0 { return ErrProviderBound }
+    err = insert(ctx, r.c, ui)
+    if errors.Is(err, ErrDuplicate) { return ErrIdentityInUse }
+    return err
+}
+
+func (r mongoIdentities) Unlink(ctx context.Context, userID, provider string) (bool, error) {
+    res, err := r.c.DeleteMany(ctx, bson.M{"userId": userID, "provider": provider})
+    if err != nil { return false, err }
+    return res.DeletedCount > 0, nil
+}
@@ -0,0 +1,422 @@
+// Package repo defines typed repositories for the aggregates the handlers
+// work with, with a MongoDB implementation for the server and an in-memory
+// one that needs no external services.
+package repo
+
+import (
+    "context"
+    "errors"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+
+    "real_deal/internal/model"
+    "real_deal/internal/query"
+)
+
+var (
+    ErrNotFound      = errors.New("not found")
+    ErrDuplicate     = errors.New("already exists")
+    ErrConflict      = errors.New("changed concurrently, retry")
+    ErrNoJobSlots    = errors.New("no job slots left; buy a job slot pack to publish more jobs")
+    ErrQuotaExceeded = errors.New("storage quota exceeded; buy a capacity pack to upload more")
+    ErrUploadSlots   = errors.New("too many unfinished uploads")
+    ErrProviderBound = errors.New("provider already bound to this account")
+    ErrIdentityInUse = errors.New("identity is linked to another account")
+)
+
+// Users never return accounts that were merged into another one.
+type Users interface {
+    Get(ctx context.Context, id string) (*model.User, error)
+    ByEmail(ctx context.Context, email string) (*model.User, error)
+    // Create fails with ErrDuplicate when the id or email is taken.
+    Create(ctx context.Context, u *model.User) error
+    // SetModeration sets the user's Moderation; "" clears it.
+    SetModeration(ctx context.Context, id, status string) error
+    // Merge folds fromID into intoID: everything fromID owns moves to
+    // intoID, intoID's missing profile fields are filled in from fromID,
+    // and fromID can no longer sign in. Both users must exist.
+    Merge(ctx context.Context, fromID, intoID string) error
+}
+
+// JobFilter restricts a job listing. Live keeps only jobs anyone may see at
+// Now; OwnerID keeps one owner's jobs in every state.
+type JobFilter struct {
+    Live    bool
+    Now     time.Time
+    OwnerID string
+}
+
+type Jobs interface {
+    Get(ctx context.Context, id string) (*model.Job, error)
+    // GetMany returns the jobs among ids that exist, in no particular order.
+    GetMany(ctx context.Context, ids []string) ([]model.Job, error)
+    List(ctx context.Context, f JobFilter, q *query.Query) (*query.Page[model.Job], error)
+    Insert(ctx context.Context, j *model.Job) error
+    // Save stores j only if the stored job still has prev's status and slot
+    // state, and fails with ErrConflict otherwise. It keeps the stored
+    // Moderation, which only SetModeration changes.
+    Save(ctx context.Context, j, prev *model.Job) error
+    // Due lists published or paused jobs whose expiry is not after now.
+    Due(ctx context.Context, now time.Time) ([]model.Job, error)
+    // Bump increments one of the job's engagement counters.
+    Bump(ctx context.Context, id, stat string) error
+    Compliance(ctx context.Context, jobID string) (*model.JobCompliance, error)
+    // SetCompliance replaces the job's compliance record.
+    SetCompliance(ctx context.Context, jc *model.JobCompliance) error
+    // SetModeration sets the job's Moderation; "" clears it.
+    SetModeration(ctx context.Context, id, status string) error
+}
+
+// Companies covers company profiles together with their members and
+// invitations.
+type Companies interface {
+    Get(ctx context.Context, id string) (*model.Company, error)
+    // GetMany returns the companies among ids that exist, in no particular
+    // order.
+    GetMany(ctx context.Context, ids []string) ([]model.Company, error)
+    List(ctx context.Context, q *query.Query) (*query.Page[model.Company], error)
+    // Create stores co with ownerID as its only member and owner.
+    Create(ctx context.Context, co *model.Company, ownerID string) error
+    // Update saves the editable profile fields; it never changes Verified.
+    Update(ctx context.Context, co *model.Company) error
+    // SetVerified is called by the verification workflow only.
+    SetVerified(ctx context.Context, id string, verified bool) error
+
+    Member(ctx context.Context, companyID, userID string) (*model.CompanyMember, error)
+    Members(ctx context.Context, companyID string) ([]model.CompanyMember, error)
+    MembershipsOf(ctx context.Context, userID string) ([]model.CompanyMember, error)
+    // AddMember fails with ErrDuplicate when the user is already a member.
+    AddMember(ctx context.Context, m *model.CompanyMember) error
+    // SetRole changes a member's role only if it is still from, failing with
+    // ErrConflict otherwise.
+    SetRole(ctx context.Context, companyID, userID, from, to string) error
+    RemoveMember(ctx context.Context, companyID, userID string) error
+    // TransferOwnership makes toID, already a member, the owner and fromID
+    // an admin.
+    TransferOwnership(ctx context.Context, companyID, fromID, toID string) error
+
+    // Invite fails with ErrDuplicate when the address already has a pending
+    // invitation to the company.
+    Invite(ctx context.Context, inv *model.CompanyInvitation) error
+    Invitation(ctx context.Context, id string) (*model.CompanyInvitation, error)
+    Invitations(ctx context.Context, companyID string) ([]model.CompanyInvitation, error)
+    // PendingFor lists the invitations waiting for email, expired or not.
+    PendingFor(ctx context.Context, email string) ([]model.CompanyInvitation, error)
+    // CloseInvitation moves a pending invitation to status, failing with
+    // ErrConflict if it was no longer pending.
+    CloseInvitation(ctx context.Context, id, status string, at time.Time) error
+}
+
+// MediaAssets.List leaves out assets rejected in moderation; Get returns
+// them.
+type MediaAssets interface {
+    Get(ctx context.Context, id string) (*model.MediaAsset, error)
+    List(ctx context.Context, q *query.Query) (*query.Page[model.MediaAsset], error)
+    Insert(ctx context.Context, m *model.MediaAsset) error
+    // SetModeration sets the asset's Moderation; "" clears it.
+    SetModeration(ctx context.Context, id, status string) error
+    SetTranscode(ctx context.Context, id string, t *model.Transcode) error
+    Delete(ctx context.Context, id string) error
+}
+
+type DealRooms interface {
+    Get(ctx context.Context, id string) (*model.DealRoom, error)
+}
+
+// Billing covers the per-user metering and purchase records.
+type Billing interface {
+    Usage(ctx context.Context, userID string) (*model.Usage, error)
+    Quota(ctx context.Context, userID string) (*model.Quota, error)
+    JobSlots(ctx context.Context, userID string) (*model.JobSlot, error)
+    // TakeJobSlot atomically uses one slot, failing with ErrNoJobSlots
+    // instead of going below zero.
+    TakeJobSlot(ctx context.Context, userID string) error
+    ReleaseJobSlot(ctx context.Context, userID string) error
+    // ChargeStorage adds gb to the user's storage usage, failing with
+    // ErrQuotaExceeded instead of going above limit.
+    ChargeStorage(ctx context.Context, userID string, gb, limit float64) error
+    ReleaseStorage(ctx context.Context, userID string, gb float64) error
+    // ChargeTranscode adds min to the user's transcode minutes, failing
+    // with ErrQuotaExceeded instead of going above limit.
+    ChargeTranscode(ctx context.Context, userID string, min, limit int) error
+    ReleaseTranscode(ctx context.Context, userID string, min int) error
+    CapacityPacks(ctx context.Context, userID string, q *query.Query) (*query.Page[model.CapacityPack], error)
+    Charges(ctx context.Context, userID string, q *query.Query) (*query.Page[model.Charge], error)
+}
+
+// Content covers one kind of user-authored content: projects, products or
+// posts. Deleted content is never returned; List also leaves out content
+// rejected in moderation.
+type Content[T any] interface {
+    Get(ctx context.Context, id string) (*T, error)
+    List(ctx context.Context, q *query.Query) (*query.Page[T], error)
+    Insert(ctx context.Context, v *T) error
+    // Update saves v over the stored document, keeping its engagement
+    // counters and search entry.
+    Update(ctx context.Context, v *T) error
+    // Delete soft-deletes: the document stays for audit.
+    Delete(ctx context.Context, id string, at time.Time) error
+    // Bump increments one of the document's engagement counters.
+    Bump(ctx context.Context, id, stat string) error
+    // SetModeration sets the content's Moderation; "" clears it.
+    SetModeration(ctx context.Context, id, status string) error
+}
+
+// contentPtr is satisfied by *model.Project, *model.Product and *model.Post,
+// letting one implementation of Content serve all three.
+type contentPtr[T any] interface {
+    *T
+    Meta() *model.ContentMeta
+}
+
+// ApplicationFilter narrows an application listing to one candidate's or
+// one job's.
+type ApplicationFilter struct {
+    CandidateID string
+    JobID       string
+}
+
+// Applications covers job applications and their stage history.
+type Applications interface {
+    Get(ctx context.Context, id string) (*model.Application, error)
+    List(ctx context.Context, f ApplicationFilter, q *query.Query) (*query.Page[model.Application], error)
+    // Insert fails with ErrDuplicate when the candidate already applied to
+    // the job.
+    Insert(ctx context.Context, a *model.Application) error
+    // Move changes the application's stage only if it is still from,
+    // failing with ErrConflict otherwise.
+    Move(ctx context.Context, id, from, to string, at time.Time) error
+    AddEvent(ctx context.Context, e *model.ApplicationEvent) error
+    // Events lists the application's stage changes, oldest first.
+    Events(ctx context.Context, applicationID string) ([]model.ApplicationEvent, error)
+}
+
+type Follows interface {
+    // List returns the user's follows, newest first.
+    List(ctx context.Context, userID string) ([]model.Follow, error)
+    // Add fails with ErrDuplicate when the user already follows the target.
+    Add(ctx context.Context, f *model.Follow) error
+    Remove(ctx context.Context, userID, typ, target string) error
+}
+
+type Inbox interface {
+    List(ctx context.Context, userID string, q *query.Query) (*query.Page[model.InboxItem], error)
+    Add(ctx context.Context, it *model.InboxItem) error
+}
+
+type Preferences interface {
+    Get(ctx context.Context, userID string) (*model.NotificationPreference, error)
+}
+
+type Investors interface {
+    List(ctx context.Context, q *query.Query) (*query.Page[model.InvestorProfile], error)
+}
+
+type Pitches interface {
+    Get(ctx context.Context, id string) (*model.PitchPage, error)
+    Insert(ctx context.Context, p *model.PitchPage) error
+}
+
+// Moderation covers the moderation records, one per piece of content, or
+// reported job or user, keyed by ContentID.
+type Moderation interface {
+    Get(ctx context.Context, contentID string) (*model.ContentModeration, error)
+    List(ctx context.Context, q *query.Query) (*query.Page[model.ContentModeration], error)
+    // Resubmit records a new screening of content whose record is pending
+    // or approved: the record takes r's status, title, score, notes and
+    // reviewer, loses its claim when r is rejected and gets evs appended.
+    // It reports whether there was such a record.
+    Resubmit(ctx context.Context, r *model.ContentModeration, evs []model.ModerationEvent) (bool, error)
+    // Open stores r unless the content already has a record, in which case
+    // only the title is updated. It reports whether r was stored.
+    Open(ctx context.Context, r *model.ContentModeration) (bool, error)
+    // Flag puts reported content in front of reviewers: an approved record
+    // becomes pending with r's notes and report count and without a claim,
+    // a missing one is stored as r, and any other only takes the count. ev
+    // is appended to an approved record's history.
+    Flag(ctx context.Context, r *model.ContentModeration, ev model.ModerationEvent) error
+    // Save stores r's status, notes, report count, claim, reviewer, appeal
+    // and UpdatedAt and appends ev, only if the stored record still has
+    // prev's status and UpdatedAt; it fails with ErrConflict otherwise. r
+    // is updated to the stored record.
+    Save(ctx context.Context, r, prev *model.ContentModeration, ev model.ModerationEvent) error
+    // SetReports updates the report count of a record that has reports.
+    SetReports(ctx context.Context, contentID string, n int) error
+}
+
+type Reports interface {
+    // Insert fails with ErrDuplicate when the reporter already has an open
+    // report on the target.
+    Insert(ctx context.Context, r *model.Report) error
+    // OpenBy returns the reporter's open report on a target.
+    OpenBy(ctx context.Context, reporterID, targetType, targetID string) (*model.Report, error)
+    // Open lists the open reports on the target with the given id.
+    Open(ctx context.Context, targetID string) ([]model.Report, error)
+    // List pages through every report, or one reporter's when reporterID is
+    // set.
+    List(ctx context.Context, reporterID string, q *query.Query) (*query.Page[model.Report], error)
+    // Close resolves one open report with status and returns it, failing
+    // with ErrNotFound when it is not open.
+    Close(ctx context.Context, id, status, by string, at time.Time) (*model.Report, error)
+    // CloseAll resolves the reports among ids that are still open.
+    CloseAll(ctx context.Context, ids []string, status, by string, at time.Time) error
+}
+
+// Verifications covers the companies' verification state, one record per
+// company, domain claims included.
+type Verifications interface {
+    Get(ctx context.Context, companyID string) (*model.CompanyVerification, error)
+    // MarkPending records a submitted request for level: an approved
+    // company keeps its level and only gains PendingLevel, any other becomes
+    // pending at level.
+    MarkPending(ctx context.Context, companyID, level string, at time.Time) error
+    // ClearPending undoes MarkPending: a first-time request leaves nothing
+    // behind but the domain claim, a re-review only drops its PendingLevel.
+    ClearPending(ctx context.Context, companyID string, at time.Time) error
+    // Approve grants level until expires; an empty reason clears the last
+    // one.
+    Approve(ctx context.Context, companyID, level, reason string, at, expires time.Time) error
+    // Reject records a turned-down request: an approved company keeps its
+    // level with the reason, any other becomes rejected at level.
+    Reject(ctx context.Context, companyID, level, reason string, at time.Time) error
+    // Due lists approvals whose expiry is not after now.
+    Due(ctx context.Context, now time.Time) ([]model.CompanyVerification, error)
+    // Expire lapses v's approval if it is still approved with the same
+    // expiry, reporting whether it did.
+    Expire(ctx context.Context, v *model.CompanyVerification, at time.Time) (bool, error)
+    // ClaimDomain replaces the company's domain claim.
+    ClaimDomain(ctx context.Context, companyID string, d *model.DomainVerification) error
+    // CheckedDomain records the outcome of a check held in d, only while
+    // the claim is pending with d's token; it reports whether it did.
+    CheckedDomain(ctx context.Context, companyID string, d *model.DomainVerification, at time.Time) (bool, error)
+    // PendingDomains lists pending claims made after claimedAfter that
+    // were never checked or last checked before checkedBefore.
+    PendingDomains(ctx context.Context, claimedAfter, checkedBefore time.Time) ([]model.CompanyVerification, error)
+}
+
+// VerificationRequests covers the requests companies submit for a
+// verification level.
+type VerificationRequests interface {
+    Get(ctx context.Context, id string) (*model.VerificationRequest, error)
+    // Insert fails with ErrDuplicate when the company already has an open
+    // request.
+    Insert(ctx context.Context, r *model.VerificationRequest) error
+    // ForCompany lists a company's requests, newest first.
+    ForCompany(ctx context.Context, companyID string) ([]model.VerificationRequest, error)
+    // List pages through the submitted requests; drafts are left out.
+    List(ctx context.Context, q *query.Query) (*query.Page[model.VerificationRequest], error)
+    // AddDocument appends d to a draft, failing with ErrConflict when the
+    // request is no longer one.
+    AddDocument(ctx context.Context, id string, d *model.VerificationDocument) error
+    // Submit sends a draft for review, failing with ErrConflict when it is
+    // no longer one.
+    Submit(ctx context.Context, id string, at time.Time) error
+    // Close ends an open request that is still in status from with status
+    // to, recording the reviewer and reason when set. It fails with
+    // ErrConflict when the request changed.
+    Close(ctx context.Context, id, from, to, reviewerID, reason string, at time.Time) error
+}
+
+type Uploads interface {
+    // Get returns the owner's upload; other users' uploads are not found.
+    Get(ctx context.Context, id, ownerID string) (*model.MediaUpload, error)
+    Insert(ctx context.Context, u *model.MediaUpload) error
+    // Reserve atomically takes one of the owner's max unfinished-upload
+    // slots, failing with ErrUploadSlots when all are taken.
+    Reserve(ctx context.Context, ownerID string, max int) error
+    // Release gives back a slot taken by Reserve; it never goes below zero.
+    Release(ctx context.Context, ownerID string) error
+    // Extend moves a pending upload's expiry.
+    Extend(ctx context.Context, id string, expires time.Time) error
+    // Transition moves the upload from status from to status to,
+    // recording reason as its Error when set, and reports whether it was
+    // still in from.
+    Transition(ctx context.Context, id, from, to, reason string) (bool, error)
+    // Stale lists pending uploads that expired before cutoff.
+    Stale(ctx context.Context, cutoff time.Time) ([]model.MediaUpload, error)
+}
+
+type TranscodeJobs interface {
+    Insert(ctx context.Context, j *model.TranscodeJob) error
+    // Claim takes the oldest queued job, or a running one whose lock
+    // expired before now, counts the attempt and locks it until until. It
+    // fails with ErrNotFound when there is none.
+    Claim(ctx context.Context, now, until time.Time) (*model.TranscodeJob, error)
+    // SetMinutes records the minutes charged for the job; 0 clears them.
+    SetMinutes(ctx context.Context, id string, min int) error
+    // Finish records the job's outcome and drops its lock.
+    Finish(ctx context.Context, id, status, errText string, at time.Time) error
+}
+
+// LoginTokens holds one-time email login tokens by hash.
+type LoginTokens interface {
+    // Issue stores t, discarding the address's earlier unused tokens so
+    // only the latest link works.
+    Issue(ctx context.Context, t *model.LoginToken) error
+    // Redeem marks the unused, unexpired token with hash used at now and
+    // returns its email, or ErrNotFound. Of two concurrent redeems only one
+    // succeeds.
+    Redeem(ctx context.Context, hash string, now time.Time) (string, error)
+}
+
+// OAuthStates holds in-flight OAuth logins.
+type OAuthStates interface {
+    Insert(ctx context.Context, f *model.OAuthFlow) error
+    // Take deletes and returns the provider's flow stored under hash if it
+    // has not expired by now, or fails with ErrNotFound.
+    Take(ctx context.Context, hash, provider string, now time.Time) (*model.OAuthFlow, error)
+}
+
+// Identities links provider subjects to users.
+type Identities interface {
+    // Find fails with ErrNotFound when the subject has never been linked.
+    Find(ctx context.Context, provider, subject string) (*model.UserIdentity, error)
+    // List returns the user's identities, oldest link first.
+    List(ctx context.Context, userID string) ([]model.UserIdentity, error)
+    // Link stores ui; linking a subject to its user again does nothing. It
+    // fails with ErrIdentityInUse when another user holds the subject and
+    // with ErrProviderBound when the user already has the provider.
+    Link(ctx context.Context, ui *model.UserIdentity) error
+    // Unlink removes the user's identity for provider and reports whether
+    // there was one.
+    Unlink(ctx context.Context, userID, provider string) (bool, error)
+}
+
+// Documents reads whole stored documents, stored-only fields such as the
+// engagement counters included, for the feed and the search index.
+type Documents interface {
+    // Newest returns up to limit (0 for all) of the newest documents of
+    // coll anyone may see at now, without _id and the search entry. coll
+    // is projects, products, posts, jobs, companies or investor_profiles.
+    Newest(ctx context.Context, coll string, now time.Time, limit int64) ([]bson.M, error)
+}
+
+// Repos bundles one implementation of every repository.
+type Repos struct {
+    Users                Users
+    Jobs                 Jobs
+    Companies            Companies
+    Media                MediaAssets
+    DealRooms            DealRooms
+    Billing              Billing
+    Projects             Content[model.Project]
+    Products             Content[model.Product]
+    Posts                Content[model.Post]
+    Applications         Applications
+    Follows              Follows
+    Inbox                Inbox
+    Preferences          Preferences
+    Investors            Investors
+    Pitches              Pitches
+    Moderation           Moderation
+    Reports              Reports
+    Verifications        Verifications
+    VerificationRequests VerificationRequests
+    Uploads              Uploads
+    TranscodeJobs        TranscodeJobs
+    LoginTokens          LoginTokens
+    OAuthStates          OAuthStates
+    Identities           Identities
+    Documents            Documents
+}
@@ -0,0 +1,38 @@
+package screen
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "fmt"
+    "net/http"
+    "time"
+)
+
+// Hook asks an external classifier: it POSTs the Input as JSON to URL and
+// expects `200 {"score", "reasons"}` back.
+type Hook struct {
+    URL    string
+    Client *http.Client
+}
+
+func NewHook(url string, timeout time.Duration) *Hook {
+    return &Hook{URL: url, Client: &http.Client{Timeout: timeout}}
+}
+
+func (h *Hook) Screen(ctx context.Context, in *Input) (*Verdict, error) {
+    body, err := json.Marshal(in)
+    if err != nil { return nil, err }
+    req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.URL, bytes.NewReader(body))
+    if err != nil { return nil, err }
+    req.Header.Set("Content-Type", "application/json")
+    res, err := h.Client.Do(req)
+    if err != nil { return nil, fmt.Errorf("screen hook: %w", err) }
+    defer res.Body.Close()
+    if res.StatusCode != http.StatusOK { return nil, fmt.Errorf("screen hook: status %d", res.StatusCode) }
+    var v Verdict
+    if err := json.NewDecoder(res.Body).Decode(&v); err != nil { return nil, fmt.Errorf("screen hook: %w", err) }
+    if v.Score < 0 || v.Score > 1 { return nil, fmt.Errorf("screen hook: score %v out of range", v.Score) }
+    if v.Reasons == nil { v.Reasons = []string{} }
+    return &v, nil
+}
@@ -0,0 +1,102 @@
+package screen
+
+import (
+    "bufio"
+    "context"
+    "embed"
+    "fmt"
+    "io"
+    "os"
+    "regexp"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+//go:embed blocklists/*.txt
+var builtin embed.FS
+
+// Entry is one blocklist line: a case-insensitive RE2 pattern, the category
+// it belongs to and the score a match contributes.
+type Entry struct {
+    Score    float64
+    Category string
+    Pattern  *regexp.Regexp
+}
+
+// Keywords screens against blocklists. Within a category the highest score
+// counts; categories combine as independent signals, 1 - Π(1 - score), so
+// two borderline hits weigh more than one.
+type Keywords struct {
+    Entries []Entry
+}
+
+// LoadKeywords reads the blocklist files at paths, or the built-in Chinese
+// and English lists when there are none.
+func LoadKeywords(paths []string) (*Keywords, error) {
+    k := &Keywords{}
+    if len(paths) == 0 {
+        files, _ := builtin.ReadDir("blocklists")
+        for _, f := range files {
+            r, err := builtin.Open("blocklists/" + f.Name())
+            if err != nil { return nil, err }
+            entries, err := ParseBlocklist(r, f.Name())
+            r.Close()
+            if err != nil { return nil, err }
+            k.Entries = append(k.Entries, entries...)
+        }
+        return k, nil
+    }
+    for _, p := range paths {
+        r, err := os.Open(p)
+        if err != nil { return nil, err }
+        entries, err := ParseBlocklist(r, p)
+        r.Close()
+        if err != nil { return nil, err }
+        k.Entries = append(k.Entries, entries...)
+    }
+    return k, nil
+}
+
+var entryLine = regexp.MustCompile(`^(\S+)\s+(\S+)\s+(.+)$`)
+
+// ParseBlocklist reads lines of "score category pattern"; blank lines and
+// lines starting with # are skipped. name is used in errors.
+func ParseBlocklist(r io.Reader, name string) ([]Entry, error) {
+    var out []Entry
+    sc := bufio.NewScanner(r)
+    for n := 1; sc.Scan(); n++ {
+        line := strings.TrimSpace(sc.Text())
+        if line == "" || strings.HasPrefix(line, "#") { continue }
+        m := entryLine.FindStringSubmatch(line)
+        if m == nil { return nil, fmt.Errorf("%s:%d: want \"score category pattern\"", name, n) }
+        score, err := strconv.ParseFloat(m[1], 64)
+        if err != nil || score <= 0 || score > 1 { return nil, fmt.Errorf("%s:%d: score must be in (0, 1]", name, n) }
+        re, err := regexp.Compile(`(?i)` + m[3])
+        if err != nil { return nil, fmt.Errorf("%s:%d: %v", name, n, err) }
+        out = append(out, Entry{Score: score, Category: m[2], Pattern: re})
+    }
+    return out, sc.Err()
+}
+
+func (k *Keywords) Screen(ctx context.Context, in *Input) (*Verdict, error) {
+    text := in.all()
+    best := map[string]float64{}
+    matched := map[string]string{}
+    for _, e := range k.Entries {
+        m := e.Pattern.FindString(text)
+        if m == "" || e.Score <= best[e.Category] { continue }
+        best[e.Category], matched[e.Category] = e.Score, m
+    }
+    v := &Verdict{Reasons: []string{}}
+    clean := 1.0
+    cats := make([]string, 0, len(best))
+    for c := range best { cats = append(cats, c) }
+    sort.Strings(cats)
+    for _, c := range cats {
+        clean *= 1 - best[c]
+        v.Reasons = append(v.Reasons, fmt.Sprintf("%s: %q", c, matched[c]))
+    }
+    v.Score = 1 - clean
+    return v, nil
+}
@@ -0,0 +1,83 @@
+// Package screen scores user text and media metadata for policy violations
+// before moderators see it. A Screener returns a score from 0 (clean) to 1
+// (certain violation) with its reasons; a Policy turns the score into an
+// action.
+package screen
+
+import (
+    "context"
+    "errors"
+    "strings"
+)
+
+// Input is what gets screened. Type is the kind of content (post, project,
+// product, job, media); Meta carries media metadata such as the file name.
+type Input struct {
+    Type  string            `json:"type"`
+    ID    string            `json:"id"`
+    Title string            `json:"title"`
+    Text  string            `json:"text"`
+    Meta  map[string]string `json:"meta,omitempty"`
+}
+
+// all joins every screened field, one per line.
+func (in *Input) all() string {
+    parts := []string{in.Title, in.Text}
+    for _, v := range in.Meta { parts = append(parts, v) }
+    return strings.Join(parts, "\n")
+}
+
+type Verdict struct {
+    Score   float64  `json:"score"`
+    Reasons []string `json:"reasons"`
+}
+
+type Screener interface {
+    Screen(ctx context.Context, in *Input) (*Verdict, error)
+}
+
+// Chain runs every screener and keeps the highest score with every reason.
+// A screener that fails is skipped and its error returned with the verdict
+// of the others.
+type Chain []Screener
+
+func (ch Chain) Screen(ctx context.Context, in *Input) (*Verdict, error) {
+    out := &Verdict{Reasons: []string{}}
+    var errs []error
+    for _, s := range ch {
+        v, err := s.Screen(ctx, in)
+        if err != nil { errs = append(errs, err); continue }
+        if v.Score > out.Score { out.Score = v.Score }
+        out.Reasons = append(out.Reasons, v.Reasons...)
+    }
+    return out, errors.Join(errs...)
+}
+
+const (
+    Allow  = "allow"
+    Review = "review"
+    Reject = "reject"
+)
+
+// Policy screens input and decides on it: a score of at least RejectAt is
+// rejected outright, at least ReviewAt is flagged for review.
+type Policy struct {
+    Screener Screener
+    RejectAt float64
+    ReviewAt float64
+}
+
+// Decide screens in and returns the verdict and the action. Screening
+// fails open: on an error the verdict holds what was reached without it,
+// and the error is returned for logging.
+func (p *Policy) Decide(ctx context.Context, in *Input) (*Verdict, string, error) {
+    v, err := p.Screener.Screen(ctx, in)
+    if v == nil { v = &Verdict{Reasons: []string{}} }
+    switch {
+    case v.Score >= p.RejectAt:
+        return v, Reject, err
+    case v.Score >= p.ReviewAt:
+        return v, Review, err
+    }
+    return v, Allow, err
+}
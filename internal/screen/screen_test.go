@@ -0,0 +1,147 @@
+package screen
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "math"
+    "net/http"
+    "net/http/httptest"
+    "strings"
+    "testing"
+)
+
+func near(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+
+func TestParseBlocklist(t *testing.T) {
+    entries, err := ParseBlocklist(strings.NewReader("# scores\n\n0.9 spam  buy\\s+now\n  1 scam (?:wire|汇款)\n0.25 tone idiot\n"), "list.txt")
+    if err != nil { t.Fatal(err) }
+    if len(entries) != 3 || entries[0].Category != "spam" || !near(entries[0].Score, 0.9) || !entries[0].Pattern.MatchString("BUY  NOW") || !entries[1].Pattern.MatchString("请汇款") {
+        t.Fatalf("entries %+v", entries)
+    }
+    bad := []struct{ list, err string }{
+        {"0.5 spam", "list.txt:1: want \"score category pattern\""},
+        {"# ok\nhigh spam buy", "list.txt:2: score must be in (0, 1]"},
+        {"0 spam buy", "list.txt:1: score must be in (0, 1]"},
+        {"1.5 spam buy", "list.txt:1: score must be in (0, 1]"},
+        {"-0.5 spam buy", "list.txt:1: score must be in (0, 1]"},
+        {"\n\n0.5 spam buy(", "list.txt:3: error parsing regexp"},
+    }
+    for _, tc := range bad {
+        if _, err := ParseBlocklist(strings.NewReader(tc.list), "list.txt"); err == nil || !strings.HasPrefix(err.Error(), tc.err) { t.Errorf("%q: got %v, want %s", tc.list, err, tc.err) }
+    }
+}
+
+func TestKeywordsScore(t *testing.T) {
+    entries, err := ParseBlocklist(strings.NewReader("0.5 spam buy now\n0.8 spam free money\n0.5 scam wire transfer\n0.2 tone idiot\n"), "list.txt")
+    if err != nil { t.Fatal(err) }
+    k := &Keywords{Entries: entries}
+    cases := []struct {
+        text    string
+        score   float64
+        reasons []string
+    }{
+        {"hello", 0, []string{}},
+        {"buy now", 0.5, []string{`spam: "buy now"`}},
+        // Within a category only the highest score counts.
+        {"buy now, free money", 0.8, []string{`spam: "free money"`}},
+        // Across categories: 1 - (1-0.5)(1-0.5) = 0.75.
+        {"buy now by wire transfer", 0.75, []string{`scam: "wire transfer"`, `spam: "buy now"`}},
+        // 1 - 0.2 * 0.5 * 0.8 = 0.92.
+        {"Idiot! Free money by wire transfer", 0.92, []string{`scam: "wire transfer"`, `spam: "Free money"`, `tone: "Idiot"`}},
+    }
+    for _, tc := range cases {
+        v, err := k.Screen(context.Background(), &Input{Title: "t", Text: tc.text})
+        if err != nil { t.Fatal(err) }
+        if !near(v.Score, tc.score) || strings.Join(v.Reasons, "|") != strings.Join(tc.reasons, "|") { t.Errorf("%q: got %v %q, want %v %q", tc.text, v.Score, v.Reasons, tc.score, tc.reasons) }
+    }
+    // Media metadata is screened with the text.
+    v, _ := k.Screen(context.Background(), &Input{Meta: map[string]string{"filename": "free money.mp4"}})
+    if !near(v.Score, 0.8) { t.Errorf("metadata score %v", v.Score) }
+}
+
+func TestLoadBuiltin(t *testing.T) {
+    k, err := LoadKeywords(nil)
+    if err != nil { t.Fatal(err) }
+    if len(k.Entries) == 0 { t.Fatal("no built-in entries") }
+}
+
+type fixed struct {
+    v   *Verdict
+    err error
+}
+
+func (f fixed) Screen(ctx context.Context, in *Input) (*Verdict, error) { return f.v, f.err }
+
+func TestChainFailsOpen(t *testing.T) {
+    down := errors.New("hook down")
+    ch := Chain{
+        fixed{&Verdict{Score: 0.3, Reasons: []string{"a"}}, nil},
+        fixed{nil, down},
+        fixed{&Verdict{Score: 0.6, Reasons: []string{"b"}}, nil},
+    }
+    v, err := ch.Screen(context.Background(), &Input{})
+    if !errors.Is(err, down) { t.Fatalf("err %v", err) }
+    if !near(v.Score, 0.6) || strings.Join(v.Reasons, ",") != "a,b" { t.Fatalf("verdict %+v", v) }
+
+    v, err = Chain{fixed{nil, down}}.Screen(context.Background(), &Input{})
+    if !errors.Is(err, down) || v == nil || v.Score != 0 || v.Reasons == nil { t.Fatalf("all failed: %+v, %v", v, err) }
+}
+
+func TestPolicy(t *testing.T) {
+    down := errors.New("down")
+    cases := []struct {
+        s      Screener
+        action string
+        err    error
+    }{
+        {fixed{&Verdict{Score: 0}, nil}, Allow, nil},
+        {fixed{&Verdict{Score: 0.49}, nil}, Allow, nil},
+        {fixed{&Verdict{Score: 0.5}, nil}, Review, nil},
+        {fixed{&Verdict{Score: 0.89}, nil}, Review, nil},
+        {fixed{&Verdict{Score: 0.9}, nil}, Reject, nil},
+        {fixed{&Verdict{Score: 1}, nil}, Reject, nil},
+        // A failed screener allows rather than blocks, with the error.
+        {fixed{nil, down}, Allow, down},
+        {Chain{fixed{nil, down}, fixed{&Verdict{Score: 0.95}, nil}}, Reject, down},
+    }
+    for i, tc := range cases {
+        p := &Policy{Screener: tc.s, RejectAt: 0.9, ReviewAt: 0.5}
+        v, action, err := p.Decide(context.Background(), &Input{})
+        if v == nil || action != tc.action || !errors.Is(err, tc.err) { t.Errorf("case %d: got %+v %s %v, want %s %v", i, v, action, err, tc.action, tc.err) }
+    }
+}
+
+func TestHook(t *testing.T) {
+    cases := []struct {
+        status int
+        body   string
+        score  float64
+        err    string
+    }{
+        {200, `{"score":0.4,"reasons":["nsfw"]}`, 0.4, ""},
+        {200, `{"score":0}`, 0, ""},
+        {500, `{"score":0.4}`, 0, "status 500"},
+        {204, ``, 0, "status 204"},
+        {200, `{"score":1.5}`, 0, "out of range"},
+        {200, `{"score":-0.1}`, 0, "out of range"},
+        {200, `not json`, 0, "invalid character"},
+    }
+    for _, tc := range cases {
+        var got Input
+        srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+            if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" { t.Errorf("%s %s", r.Method, r.Header.Get("Content-Type")) }
+            if err := json.NewDecoder(r.Body).Decode(&got); err != nil { t.Error(err) }
+            w.WriteHeader(tc.status)
+            w.Write([]byte(tc.body))
+        }))
+        v, err := NewHook(srv.URL, 0).Screen(context.Background(), &Input{Type: "post", ID: "post_1", Text: "hi"})
+        srv.Close()
+        if got.ID != "post_1" || got.Text != "hi" { t.Errorf("hook got %+v", got) }
+        if tc.err != "" {
+            if err == nil || !strings.Contains(err.Error(), tc.err) { t.Errorf("%d %s: got %+v, %v; want %s", tc.status, tc.body, v, err, tc.err) }
+            continue
+        }
+        if err != nil || !near(v.Score, tc.score) || v.Reasons == nil { t.Errorf("%d %s: got %+v, %v", tc.status, tc.body, v, err) }
+    }
+}
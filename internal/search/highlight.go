@@ -0,0 +1,51 @@
+package search
+
+import (
+    "html"
+    "strings"
+)
+
+// snippetRunes is roughly how much text a highlight snippet shows.
+const snippetRunes = 80
+
+// highlight marks every occurrence of terms in s (case-insensitively) with
+// <em>…</em> and trims the result to a snippet around the first match.
+// Adjacent or overlapping matches, as produced by CJK bigrams, are merged
+// into one marked span. It reports false when nothing matched.
+func highlight(s string, terms []string) (string, bool) {
+    rs := []rune(s)
+    lower := []rune(strings.ToLower(s))
+    if len(lower) != len(rs) { lower = rs }
+    marked := make([]bool, len(rs))
+    found := false
+    for _, t := range terms {
+        tr := []rune(t)
+        if len(tr) == 0 { continue }
+        for i := 0; i+len(tr) <= len(lower); i++ {
+            if string(lower[i:i+len(tr)]) != t { continue }
+            for j := i; j < i+len(tr); j++ { marked[j] = true }
+            found = true
+        }
+    }
+    if !found { return "", false }
+
+    first := 0
+    for !marked[first] { first++ }
+    start, end := 0, len(rs)
+    if len(rs) > snippetRunes {
+        start = first - snippetRunes/4
+        if start < 0 { start = 0 }
+        end = start + snippetRunes
+        if end > len(rs) { end, start = len(rs), len(rs)-snippetRunes }
+    }
+
+    var b strings.Builder
+    if start > 0 { b.WriteString("…") }
+    for i := start; i < end; i++ {
+        if marked[i] && (i == start || !marked[i-1]) { b.WriteString("<em>") }
+        b.WriteString(html.EscapeString(string(rs[i])))
+        if marked[i] && (i == end-1 || !marked[i+1]) { b.WriteString("</em>") }
+    }
+    if end < len(rs) { b.WriteString("…") }
+    return b.String(), true
+}
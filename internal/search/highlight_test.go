@@ -0,0 +1,40 @@
+package search
+
+import (
+    "strings"
+    "testing"
+)
+
+func TestHighlight(t *testing.T) {
+    long := strings.Repeat("x", 100)
+    cases := []struct {
+        text  string
+        terms []string
+        want  string
+    }{
+        {"Senior Go Engineer", []string{"go"}, "Senior <em>Go</em> Engineer"},
+        {"go going GO", []string{"go"}, "<em>go</em> <em>go</em>ing <em>GO</em>"},
+        {"Senior Go Engineer", []string{"rust"}, ""},
+        {"Senior Go Engineer", []string{""}, ""},
+        // Bigrams overlap and neighbouring terms touch; either way they
+        // form one span.
+        {"招聘前端工程师", []string{"前端", "端工", "工程", "程师"}, "招聘<em>前端工程师</em>"},
+        {"前端工程师", []string{"前端", "工程"}, "<em>前端工程</em>师"},
+        {"前端与工程", []string{"前端", "工程"}, "<em>前端</em>与<em>工程</em>"},
+        {"Go<script>", []string{"go"}, "<em>Go</em>&lt;script&gt;"},
+        {"<b>Go</b>", []string{"b"}, "&lt;<em>b</em>&gt;Go&lt;/<em>b</em>&gt;"},
+        // Long text is cut to 80 runes, starting 20 before the first match…
+        {long + "go" + long, []string{"go"}, "…" + strings.Repeat("x", 20) + "<em>go</em>" + strings.Repeat("x", 58) + "…"},
+        // …but not before the start…
+        {"go" + long, []string{"go"}, "<em>go</em>" + strings.Repeat("x", 78) + "…"},
+        {"xxxxxgo" + long, []string{"go"}, "xxxxx<em>go</em>" + strings.Repeat("x", 73) + "…"},
+        // …or past the end.
+        {long + "go", []string{"go"}, "…" + strings.Repeat("x", 78) + "<em>go</em>"},
+        // A span cut by the snippet edge is still closed.
+        {long + "go" + long, []string{strings.Repeat("x", 30) + "go" + strings.Repeat("x", 70)}, "…" + strings.Repeat("x", 20) + "<em>" + strings.Repeat("x", 30) + "go" + strings.Repeat("x", 28) + "</em>…"},
+    }
+    for _, tc := range cases {
+        got, ok := highlight(tc.text, tc.terms)
+        if got != tc.want || ok != (tc.want != "") { t.Errorf("highlight(%q, %q) = %q, %v; want %q", tc.text, tc.terms, got, ok, tc.want) }
+    }
+}
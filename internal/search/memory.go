@@ -0,0 +1,67 @@
+package search
+
+import (
+    "context"
+    "sort"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+
+    "real_deal/internal/repo"
+)
+
+// Memory searches the documents of a repository without any index: each
+// query tokenizes every visible document and scores matched terms with the
+// weights of the Mongo text index. It suits tests and small local setups.
+type Memory struct {
+    Docs      repo.Documents
+    Tokenizer Tokenizer
+    Sources   []Source
+}
+
+func NewMemory(docs repo.Documents, t Tokenizer) *Memory { return &Memory{Docs: docs, Tokenizer: t, Sources: Sources} }
+
+// Refresh does nothing: documents are tokenized when searched.
+func (x *Memory) Refresh(ctx context.Context, coll, id string) error { return nil }
+
+func (x *Memory) Search(ctx context.Context, q string, types []string, limit int) (*Results, error) {
+    terms := x.Tokenizer.Tokens(q)
+    if len(terms) == 0 { return nil, ErrEmptyQuery }
+    want := map[string]bool{}
+    for _, t := range types { want[t] = true }
+    now := time.Now()
+    res := &Results{Query: q, Groups: []Group{}}
+    for _, s := range x.Sources {
+        if len(want) > 0 && !want[s.Type] { continue }
+        docs, err := x.Docs.Newest(ctx, s.Coll, now, 0)
+        if err != nil { return nil, err }
+        var hits []Hit
+        for _, d := range docs {
+            if score := x.score(s, d, terms); score > 0 { hits = append(hits, s.hit(d, terms, score)) }
+        }
+        if len(hits) == 0 { continue }
+        sort.SliceStable(hits, func(i, j int) bool { return hits[i].Score > hits[j].Score })
+        g := Group{Type: s.Type, Total: int64(len(hits)), Hits: hits}
+        if len(g.Hits) > limit { g.Hits = g.Hits[:limit] }
+        res.Groups = append(res.Groups, g)
+    }
+    sortGroups(res.Groups)
+    return res, nil
+}
+
+// score weighs each query term found in the title, tags and body fields
+// 10, 5 and 1, like the search_text index.
+func (x *Memory) score(s Source, doc bson.M, terms []string) float64 {
+    var score float64
+    for _, f := range []struct {
+        fields []string
+        weight float64
+    }{{s.Title, 10}, {s.Tags, 5}, {s.Body, 1}} {
+        has := map[string]bool{}
+        for _, t := range x.Tokenizer.Tokens(text(doc, f.fields)) { has[t] = true }
+        for _, t := range terms {
+            if has[t] { score += f.weight }
+        }
+    }
+    return score
+}
@@ -0,0 +1,200 @@
+// Package search provides full-text search over the public collections. Each
+// searchable document carries a computed "search" sub-document holding the
+// tokenizer output of its fields, covered by a weighted MongoDB text index.
+package search
+
+import (
+    "context"
+    "errors"
+    "sort"
+    "strings"
+    "time"
+
+    "go.mongodb.org/mongo-driver/bson"
+    "go.mongodb.org/mongo-driver/mongo"
+    "go.mongodb.org/mongo-driver/mongo/options"
+
+    "real_deal/internal/repo"
+)
+
+var ErrEmptyQuery = errors.New("query has no searchable terms")
+
+// Source describes one searchable collection. Title, Tags and Body list the
+// document fields indexed at decreasing weight; Visible restricts results to
+// what anonymous readers may see.
+type Source struct {
+    Type    string
+    Coll    string
+    Title   []string
+    Tags    []string
+    Body    []string
+    Visible func(now time.Time) bson.M
+}
+
+// Sources are the collections behind /api/search.
+var Sources = []Source{
+    {Type: "job", Coll: "jobs", Title: []string{"title"}, Tags: []string{"skills", "location", "level"}, Body: []string{"description"}, Visible: repo.LiveJobs},
+    {Type: "company", Coll: "companies", Title: []string{"name"}, Tags: []string{"tags"}, Body: []string{"description"}},
+    {Type: "project", Coll: "projects", Title: []string{"title"}, Tags: []string{"tags"}, Body: []string{"summary"}, Visible: repo.LiveContent},
+    {Type: "product", Coll: "products", Title: []string{"name"}, Tags: []string{"tags"}, Body: []string{"summary"}, Visible: repo.LiveContent},
+    {Type: "post", Coll: "posts", Title: []string{"title"}, Tags: []string{"tags"}, Body: []string{"body"}, Visible: repo.LiveContent},
+    {Type: "investor", Coll: "investor_profiles", Title: []string{"name"}, Tags: []string{"stages", "regions"}, Body: []string{"thesis"}},
+}
+
+// Engine is what the handlers search through: Index over MongoDB, or Memory
+// over the in-memory repositories.
+type Engine interface {
+    // Refresh brings the search entry of a document up to date after a
+    // write.
+    Refresh(ctx context.Context, coll, id string) error
+    // Search runs q against the sources whose type is in types (all when
+    // empty) and returns up to limit hits per type. Groups are ordered by
+    // their best hit and types without matches are left out.
+    Search(ctx context.Context, q string, types []string, limit int) (*Results, error)
+}
+
+// Index keeps the search sub-documents up to date and runs queries.
+type Index struct {
+    DB        *mongo.Database
+    Tokenizer Tokenizer
+    Sources   []Source
+}
+
+func NewIndex(db *mongo.Database, t Tokenizer) *Index { return &Index{DB: db, Tokenizer: t, Sources: Sources} }
+
+// EnsureIndexes creates the text index on every source and fills in the
+// search sub-document of documents that lack one or were indexed with a
+// different tokenizer.
+func (x *Index) EnsureIndexes(ctx context.Context) error {
+    for _, s := range x.Sources {
+        coll := x.DB.Collection(s.Coll)
+        _, err := coll.Indexes().CreateOne(ctx, mongo.IndexModel{
+            Keys: bson.D{{Key: "search.title", Value: "text"}, {Key: "search.tags", Value: "text"}, {Key: "search.body", Value: "text"}},
+            Options: options.Index().SetName("search_text").SetDefaultLanguage("none").
+                SetWeights(bson.D{{Key: "search.title", Value: 10}, {Key: "search.tags", Value: 5}, {Key: "search.body", Value: 1}}),
+        })
+        if err != nil { return err }
+        cur, err := coll.Find(ctx, bson.M{"search.tokenizer": bson.M{"$ne": x.Tokenizer.Name()}})
+        if err != nil { return err }
+        for cur.Next(ctx) {
+            var doc bson.M
+            if err := cur.Decode(&doc); err != nil { cur.Close(ctx); return err }
+            if _, err := coll.UpdateOne(ctx, bson.M{"_id": doc["_id"]}, bson.M{"$set": bson.M{"search": x.entry(s, doc)}}); err != nil { cur.Close(ctx); return err }
+        }
+        err = cur.Err()
+        cur.Close(ctx)
+        if err != nil { return err }
+    }
+    return nil
+}
+
+// Refresh recomputes the search sub-document of the document with the given
+// id after a write. A nil Index does nothing, so callers need not check.
+func (x *Index) Refresh(ctx context.Context, coll, id string) error {
+    if x == nil { return nil }
+    for _, s := range x.Sources {
+        if s.Coll != coll { continue }
+        c := x.DB.Collection(coll)
+        var doc bson.M
+        if err := c.FindOne(ctx, bson.M{"id": id}).Decode(&doc); err != nil { return err }
+        _, err := c.UpdateOne(ctx, bson.M{"id": id}, bson.M{"$set": bson.M{"search": x.entry(s, doc)}})
+        return err
+    }
+    return nil
+}
+
+func (x *Index) entry(s Source, doc bson.M) bson.M {
+    terms := func(fields []string) string { return strings.Join(x.Tokenizer.Tokens(text(doc, fields)), " ") }
+    return bson.M{"title": terms(s.Title), "tags": terms(s.Tags), "body": terms(s.Body), "tokenizer": x.Tokenizer.Name()}
+}
+
+// Hit is one search result. Highlights hold, per matched field, a snippet of
+// the field with matched terms wrapped in <em>; the text is HTML-escaped.
+type Hit struct {
+    Type       string            `json:"type"`
+    ID         string            `json:"id"`
+    Title      string            `json:"title"`
+    Score      float64           `json:"score"`
+    Highlights map[string]string `json:"highlights"`
+    Item       bson.M            `json:"item"`
+}
+
+// Group holds the hits of one type, best first. Total counts every match.
+type Group struct {
+    Type  string `json:"type"`
+    Total int64  `json:"total"`
+    Hits  []Hit  `json:"hits"`
+}
+
+type Results struct {
+    Query  string  `json:"query"`
+    Groups []Group `json:"groups"`
+}
+
+func (x *Index) Search(ctx context.Context, q string, types []string, limit int) (*Results, error) {
+    terms := x.Tokenizer.Tokens(q)
+    if len(terms) == 0 { return nil, ErrEmptyQuery }
+    want := map[string]bool{}
+    for _, t := range types { want[t] = true }
+    now := time.Now()
+    res := &Results{Query: q, Groups: []Group{}}
+    for _, s := range x.Sources {
+        if len(want) > 0 && !want[s.Type] { continue }
+        filter := bson.M{"$text": bson.M{"$search": strings.Join(terms, " ")}}
+        if s.Visible != nil {
+            for k, v := range s.Visible(now) { filter[k] = v }
+        }
+        coll := x.DB.Collection(s.Coll)
+        total, err := coll.CountDocuments(ctx, filter)
+        if err != nil { return nil, err }
+        if total == 0 { continue }
+        opts := options.Find().
+            SetProjection(bson.M{"_id": 0, "search": 0, "score": bson.M{"$meta": "textScore"}}).
+            SetSort(bson.D{{Key: "score", Value: bson.M{"$meta": "textScore"}}}).
+            SetLimit(int64(limit))
+        cur, err := coll.Find(ctx, filter, opts)
+        if err != nil { return nil, err }
+        var docs []bson.M
+        err = cur.All(ctx, &docs)
+        if err != nil { return nil, err }
+        g := Group{Type: s.Type, Total: total, Hits: []Hit{}}
+        for _, d := range docs {
+            score, _ := d["score"].(float64)
+            delete(d, "score")
+            g.Hits = append(g.Hits, s.hit(d, terms, score))
+        }
+        res.Groups = append(res.Groups, g)
+    }
+    sortGroups(res.Groups)
+    return res, nil
+}
+
+// hit builds the result for doc, highlighting terms in every indexed field.
+func (s Source) hit(doc bson.M, terms []string, score float64) Hit {
+    id, _ := doc["id"].(string)
+    h := Hit{Type: s.Type, ID: id, Title: text(doc, s.Title), Score: score, Highlights: map[string]string{}, Item: doc}
+    for _, f := range append(append(append([]string{}, s.Title...), s.Tags...), s.Body...) {
+        if hl, ok := highlight(text(doc, []string{f}), terms); ok { h.Highlights[f] = hl }
+    }
+    return h
+}
+
+func sortGroups(gs []Group) {
+    sort.SliceStable(gs, func(i, j int) bool { return gs[i].Hits[0].Score > gs[j].Hits[0].Score })
+}
+
+// text joins the string values (or string array elements) of fields.
+func text(doc bson.M, fields []string) string {
+    var parts []string
+    for _, f := range fields {
+        switch v := doc[f].(type) {
+        case string:
+            parts = append(parts, v)
+        case bson.A:
+            for _, e := range v {
+                if s, ok := e.(string); ok { parts = append(parts, s) }
+            }
+        }
+    }
+    return strings.Join(parts, " ")
+}
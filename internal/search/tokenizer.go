@@ -0,0 +1,78 @@
+package search
+
+import (
+    "fmt"
+    "strings"
+    "unicode"
+)
+
+// Tokenizer splits text into the terms stored in the text index and looked
+// up at query time. MongoDB's own text index only splits on whitespace and
+// punctuation, which leaves a Chinese sentence as a single term, so documents
+// are indexed on tokenizer output instead of their raw fields.
+type Tokenizer interface {
+    Name() string
+    Tokens(text string) []string
+}
+
+// NewTokenizer returns the tokenizer called name ("bigram" or "words").
+func NewTokenizer(name string) (Tokenizer, error) {
+    switch name {
+    case "", "bigram":
+        return Bigram{}, nil
+    case "words":
+        return Words{}, nil
+    }
+    return nil, fmt.Errorf("unknown search tokenizer %q", name)
+}
+
+// Words lowercases text and splits it on anything that is not a letter or a
+// digit. It suits languages that separate words with spaces.
+type Words struct{}
+
+func (Words) Name() string { return "words" }
+
+func (Words) Tokens(text string) []string {
+    return strings.FieldsFunc(strings.ToLower(text), func(r rune) bool { return !unicode.IsLetter(r) && !unicode.IsDigit(r) })
+}
+
+// Bigram behaves like Words but breaks runs of CJK characters into
+// overlapping pairs, so "前端工程师" yields 前端, 端工, 工程 and 程师 and a search
+// for "工程师" matches without a dictionary. A lone CJK character is kept as is.
+type Bigram struct{}
+
+func (Bigram) Name() string { return "bigram" }
+
+func (Bigram) Tokens(text string) []string {
+    var out []string
+    for _, w := range (Words{}).Tokens(text) {
+        var run []rune
+        flush := func() {
+            switch {
+            case len(run) == 1:
+                out = append(out, string(run))
+            case len(run) > 1:
+                for i := 0; i+1 < len(run); i++ { out = append(out, string(run[i:i+2])) }
+            }
+            run = run[:0]
+        }
+        start := 0
+        rs := []rune(w)
+        for i, r := range rs {
+            if isCJK(r) {
+                if start < i { out = append(out, string(rs[start:i])) }
+                run = append(run, r)
+                start = i + 1
+                continue
+            }
+            flush()
+        }
+        flush()
+        if start < len(rs) { out = append(out, string(rs[start:])) }
+    }
+    return out
+}
+
+func isCJK(r rune) bool {
+    return unicode.Is(unicode.Han, r) || unicode.Is(unicode.Hiragana, r) || unicode.Is(unicode.Katakana, r) || unicode.Is(unicode.Hangul, r)
+}
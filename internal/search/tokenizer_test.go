@@ -0,0 +1,40 @@
+package search
+
+import (
+    "slices"
+    "testing"
+)
+
+func TestTokens(t *testing.T) {
+    cases := []struct {
+        text   string
+        words  []string
+        bigram []string
+    }{
+        {"", nil, nil},
+        {"Senior Go Engineer", []string{"senior", "go", "engineer"}, []string{"senior", "go", "engineer"}},
+        {"前端工程师", []string{"前端工程师"}, []string{"前端", "端工", "工程", "程师"}},
+        {"前端工程师（Next.js）", []string{"前端工程师", "next", "js"}, []string{"前端", "端工", "工程", "程师", "next", "js"}},
+        {"React前端", []string{"react前端"}, []string{"react", "前端"}},
+        {"前端React开发", []string{"前端react开发"}, []string{"前端", "react", "开发"}},
+        {"AI工程师2025", []string{"ai工程师2025"}, []string{"ai", "工程", "程师", "2025"}},
+        {"a前b", []string{"a前b"}, []string{"a", "前", "b"}},
+        {"招 人", []string{"招", "人"}, []string{"招", "人"}},
+        {"Go/招", []string{"go", "招"}, []string{"go", "招"}},
+        {"東京のエンジニア", []string{"東京のエンジニア"}, []string{"東京", "京の", "のエ", "エン", "ンジ", "ジニ", "ニア"}},
+        {"서울 개발자", []string{"서울", "개발자"}, []string{"서울", "개발", "발자"}},
+        {"C++ & Node.js!", []string{"c", "node", "js"}, []string{"c", "node", "js"}},
+    }
+    for _, tc := range cases {
+        if got := (Words{}).Tokens(tc.text); !slices.Equal(got, tc.words) { t.Errorf("Words(%q) = %q, want %q", tc.text, got, tc.words) }
+        if got := (Bigram{}).Tokens(tc.text); !slices.Equal(got, tc.bigram) { t.Errorf("Bigram(%q) = %q, want %q", tc.text, got, tc.bigram) }
+    }
+}
+
+func TestNewTokenizer(t *testing.T) {
+    for name, want := range map[string]string{"": "bigram", "bigram": "bigram", "words": "words"} {
+        tok, err := NewTokenizer(name)
+        if err != nil || tok.Name() != want { t.Errorf("NewTokenizer(%q) = %v, %v", name, tok, err) }
+    }
+    if _, err := NewTokenizer("jieba"); err == nil { t.Error("unknown tokenizer accepted") }
+}
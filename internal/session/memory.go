@@ -0,0 +1,74 @@
+package session
+
+import (
+    "context"
+    "sort"
+    "sync"
+    "time"
+)
+
+// MemoryStore is an in-process Store for tests and single-node development.
+type MemoryStore struct {
+    mu       sync.Mutex
+    ttl      time.Duration
+    sessions map[string]Session
+    Now      func() time.Time
+}
+
+func NewMemory(ttl time.Duration) *MemoryStore {
+    return &MemoryStore{ttl: ttl, sessions: map[string]Session{}, Now: time.Now}
+}
+
+func (s *MemoryStore) Create(ctx context.Context, userID string, meta Meta) (string, *Session, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    tok, sess, err := newSession(userID, meta, s.Now(), s.ttl)
+    if err != nil { return "", nil, err }
+    s.sessions[sess.ID] = *sess
+    return tok, sess, nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, token string) (*Session, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    id := IDFor(token)
+    sess, ok := s.sessions[id]
+    now := s.Now()
+    if !ok || !now.Before(sess.ExpiresAt) {
+        delete(s.sessions, id)
+        return nil, ErrNotFound
+    }
+    sess.LastSeen = now
+    sess.ExpiresAt = now.Add(s.ttl)
+    s.sessions[id] = sess
+    return &sess, nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, userID string) ([]Session, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    now := s.Now()
+    out := []Session{}
+    for id, sess := range s.sessions {
+        if !now.Before(sess.ExpiresAt) { delete(s.sessions, id); continue }
+        if sess.UserID == userID { out = append(out, sess) }
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+    return out, nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, id string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.sessions, id)
+    return nil
+}
+
+func (s *MemoryStore) DeleteUser(ctx context.Context, userID string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    for id, sess := range s.sessions {
+        if sess.UserID == userID { delete(s.sessions, id) }
+    }
+    return nil
+}
@@ -0,0 +1,114 @@
+package session
+
+import (
+    "context"
+    "encoding/json"
+    "errors"
+    "sort"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// RedisStore keeps each session as a JSON string under session:<id> with a
+// TTL, plus a user_sessions:<userId> set used for listing and bulk logout.
+type RedisStore struct {
+    cli *redis.Client
+    ttl time.Duration
+    Now func() time.Time
+}
+
+func NewRedis(cli *redis.Client, ttl time.Duration) *RedisStore {
+    return &RedisStore{cli: cli, ttl: ttl, Now: time.Now}
+}
+
+func sessionKey(id string) string { return "session:" + id }
+func userKey(userID string) string { return "user_sessions:" + userID }
+
+func (s *RedisStore) Create(ctx context.Context, userID string, meta Meta) (string, *Session, error) {
+    tok, sess, err := newSession(userID, meta, s.Now(), s.ttl)
+    if err != nil { return "", nil, err }
+    if err := s.save(ctx, sess); err != nil { return "", nil, err }
+    return tok, sess, nil
+}
+
+func (s *RedisStore) Get(ctx context.Context, token string) (*Session, error) {
+    if token == "" { return nil, ErrNotFound }
+    sess, err := s.load(ctx, IDFor(token))
+    if err != nil { return nil, err }
+    now := s.Now()
+    sess.LastSeen = now
+    sess.ExpiresAt = now.Add(s.ttl)
+    if err := s.touch(ctx, sess); err != nil { return nil, err }
+    return sess, nil
+}
+
+func (s *RedisStore) List(ctx context.Context, userID string) ([]Session, error) {
+    ids, err := s.cli.SMembers(ctx, userKey(userID)).Result()
+    if err != nil { return nil, err }
+    out := []Session{}
+    for _, id := range ids {
+        sess, err := s.load(ctx, id)
+        if errors.Is(err, ErrNotFound) {
+            // expired by TTL; drop the dangling index entry
+            s.cli.SRem(ctx, userKey(userID), id)
+            continue
+        }
+        if err != nil { return nil, err }
+        out = append(out, *sess)
+    }
+    sort.Slice(out, func(i, j int) bool { return out[i].LastSeen.After(out[j].LastSeen) })
+    return out, nil
+}
+
+func (s *RedisStore) Delete(ctx context.Context, id string) error {
+    sess, err := s.load(ctx, id)
+    if errors.Is(err, ErrNotFound) { return nil }
+    if err != nil { return err }
+    pipe := s.cli.TxPipeline()
+    pipe.Del(ctx, sessionKey(id))
+    pipe.SRem(ctx, userKey(sess.UserID), id)
+    _, err = pipe.Exec(ctx)
+    return err
+}
+
+func (s *RedisStore) DeleteUser(ctx context.Context, userID string) error {
+    ids, err := s.cli.SMembers(ctx, userKey(userID)).Result()
+    if err != nil { return err }
+    keys := []string{userKey(userID)}
+    for _, id := range ids { keys = append(keys, sessionKey(id)) }
+    return s.cli.Del(ctx, keys...).Err()
+}
+
+func (s *RedisStore) save(ctx context.Context, sess *Session) error {
+    b, err := json.Marshal(sess)
+    if err != nil { return err }
+    pipe := s.cli.TxPipeline()
+    pipe.Set(ctx, sessionKey(sess.ID), b, s.ttl)
+    pipe.SAdd(ctx, userKey(sess.UserID), sess.ID)
+    pipe.Expire(ctx, userKey(sess.UserID), s.ttl)
+    _, err = pipe.Exec(ctx)
+    return err
+}
+
+// touch slides an existing session's TTL. SET XX and EXPIRE never create
+// keys, so a Delete or DeleteUser racing with Get stays deleted.
+func (s *RedisStore) touch(ctx context.Context, sess *Session) error {
+    b, err := json.Marshal(sess)
+    if err != nil { return err }
+    pipe := s.cli.TxPipeline()
+    set := pipe.SetXX(ctx, sessionKey(sess.ID), b, s.ttl)
+    pipe.Expire(ctx, userKey(sess.UserID), s.ttl)
+    if _, err := pipe.Exec(ctx); err != nil && !errors.Is(err, redis.Nil) { return err }
+    if !set.Val() { return ErrNotFound }
+    return nil
+}
+
+func (s *RedisStore) load(ctx context.Context, id string) (*Session, error) {
+    b, err := s.cli.Get(ctx, sessionKey(id)).Bytes()
+    if errors.Is(err, redis.Nil) { return nil, ErrNotFound }
+    if err != nil { return nil, err }
+    var sess Session
+    if err := json.Unmarshal(b, &sess); err != nil { return nil, err }
+    return &sess, nil
+}
@@ -0,0 +1,192 @@
+package session
+
+import (
+    "bufio"
+    "fmt"
+    "io"
+    "net"
+    "strconv"
+    "strings"
+    "sync"
+    "testing"
+    "time"
+
+    "github.com/redis/go-redis/v9"
+)
+
+// fakeRedis speaks enough RESP2 for RedisStore: strings with expiry, sets,
+// DEL, EXPIRE and MULTI/EXEC. Keys expire against now, so tests move the
+// server's clock together with the store's.
+type fakeRedis struct {
+    mu      sync.Mutex
+    now     func() time.Time
+    strs    map[string]string
+    sets    map[string]map[string]bool
+    expires map[string]time.Time
+    // afterGet runs when a GET has been answered from the data but before
+    // the reply is sent, to change the data under a client mid-request.
+    afterGet func(key string)
+}
+
+func newFakeRedis(t *testing.T, now func() time.Time) (*fakeRedis, *redis.Client) {
+    ln, err := net.Listen("tcp", "127.0.0.1:0")
+    if err != nil { t.Fatal(err) }
+    f := &fakeRedis{now: now, strs: map[string]string{}, sets: map[string]map[string]bool{}, expires: map[string]time.Time{}}
+    go func() {
+        for {
+            c, err := ln.Accept()
+            if err != nil { return }
+            go f.serve(c)
+        }
+    }()
+    cli := redis.NewClient(&redis.Options{Addr: ln.Addr().String(), Protocol: 2, DisableIndentity: true})
+    t.Cleanup(func() { cli.Close(); ln.Close() })
+    return f, cli
+}
+
+func (f *fakeRedis) serve(c net.Conn) {
+    defer c.Close()
+    r, w := bufio.NewReader(c), bufio.NewWriter(c)
+    var queued [][]string
+    inTx := false
+    for {
+        args, err := readCommand(r)
+        if err != nil { return }
+        switch cmd := strings.ToUpper(args[0]); {
+        case cmd == "MULTI":
+            inTx, queued = true, nil
+            w.WriteString("+OK\r\n")
+        case cmd == "EXEC":
+            fmt.Fprintf(w, "*%d\r\n", len(queued))
+            for _, q := range queued { w.WriteString(f.run(q)) }
+            inTx = false
+        case inTx:
+            queued = append(queued, args)
+            w.WriteString("+QUEUED\r\n")
+        default:
+            w.WriteString(f.run(args))
+        }
+        if err := w.Flush(); err != nil { return }
+    }
+}
+
+func readCommand(r *bufio.Reader) ([]string, error) {
+    line, err := r.ReadString('\n')
+    if err != nil { return nil, err }
+    n, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+    if err != nil { return nil, err }
+    args := make([]string, n)
+    for i := range args {
+        if line, err = r.ReadString('\n'); err != nil { return nil, err }
+        size, err := strconv.Atoi(strings.TrimSpace(line)[1:])
+        if err != nil { return nil, err }
+        buf := make([]byte, size+2)
+        if _, err := io.ReadFull(r, buf); err != nil { return nil, err }
+        args[i] = string(buf[:size])
+    }
+    return args, nil
+}
+
+func bulk(s string) string { return fmt.Sprintf("$%d\r\n%s\r\n", len(s), s) }
+
+func integer(n int) string { return fmt.Sprintf(":%d\r\n", n) }
+
+// run executes one command and returns its encoded reply.
+func (f *fakeRedis) run(args []string) string {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.expire()
+    switch strings.ToUpper(args[0]) {
+    case "PING":
+        return "+PONG\r\n"
+    case "GET":
+        v, ok := f.strs[args[1]]
+        if f.afterGet != nil { f.afterGet(args[1]) }
+        if !ok { return "$-1\r\n" }
+        return bulk(v)
+    case "SET":
+        key, val := args[1], args[2]
+        var ttl time.Duration
+        xx := false
+        for i := 3; i < len(args); i++ {
+            switch strings.ToUpper(args[i]) {
+            case "EX", "PX":
+                n, _ := strconv.Atoi(args[i+1])
+                ttl = time.Duration(n) * time.Second
+                if strings.ToUpper(args[i]) == "PX" { ttl = time.Duration(n) * time.Millisecond }
+                i++
+            case "XX":
+                xx = true
+            }
+        }
+        if _, ok := f.strs[key]; xx && !ok { return "$-1\r\n" }
+        f.strs[key] = val
+        delete(f.expires, key)
+        if ttl > 0 { f.expires[key] = f.now().Add(ttl) }
+        return "+OK\r\n"
+    case "DEL":
+        n := 0
+        for _, k := range args[1:] {
+            if f.exists(k) { n++ }
+            f.remove(k)
+        }
+        return integer(n)
+    case "EXPIRE", "PEXPIRE":
+        if !f.exists(args[1]) { return integer(0) }
+        n, _ := strconv.Atoi(args[2])
+        unit := time.Second
+        if strings.ToUpper(args[0]) == "PEXPIRE" { unit = time.Millisecond }
+        f.expires[args[1]] = f.now().Add(time.Duration(n) * unit)
+        return integer(1)
+    case "SADD":
+        set := f.sets[args[1]]
+        if set == nil { set = map[string]bool{}; f.sets[args[1]] = set }
+        n := 0
+        for _, m := range args[2:] {
+            if !set[m] { set[m] = true; n++ }
+        }
+        return integer(n)
+    case "SREM":
+        n := 0
+        for _, m := range args[2:] {
+            if f.sets[args[1]][m] { delete(f.sets[args[1]], m); n++ }
+        }
+        if len(f.sets[args[1]]) == 0 { f.remove(args[1]) }
+        return integer(n)
+    case "SMEMBERS":
+        out := fmt.Sprintf("*%d\r\n", len(f.sets[args[1]]))
+        for m := range f.sets[args[1]] { out += bulk(m) }
+        return out
+    }
+    return "-ERR unknown command '" + args[0] + "'\r\n"
+}
+
+func (f *fakeRedis) exists(key string) bool {
+    _, s := f.strs[key]
+    _, set := f.sets[key]
+    return s || set
+}
+
+func (f *fakeRedis) remove(key string) {
+    delete(f.strs, key)
+    delete(f.sets, key)
+    delete(f.expires, key)
+}
+
+func (f *fakeRedis) expire() {
+    now := f.now()
+    for k, at := range f.expires {
+        if !now.Before(at) { f.remove(k) }
+    }
+}
+
+// keys lists every live key.
+func (f *fakeRedis) keys() []string {
+    f.mu.Lock()
+    defer f.mu.Unlock()
+    f.expire()
+    var out []string
+    for k := range f.strs { out = append(out, k) }
+    for k := range f.sets { out = append(out, k) }
+    return out
+}
@@ -0,0 +1,67 @@
+package session
+
+import (
+    "context"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "encoding/hex"
+    "errors"
+    "time"
+)
+
+var ErrNotFound = errors.New("session not found")
+
+// Session is the server-side record behind a session cookie. ID is the
+// SHA-256 of the cookie token: it is safe to show in session listings and to
+// use for revocation, but cannot be turned back into a working cookie.
+type Session struct {
+    ID        string    `json:"id"`
+    UserID    string    `json:"userId"`
+    UserAgent string    `json:"userAgent"`
+    IP        string    `json:"ip"`
+    CreatedAt time.Time `json:"createdAt"`
+    LastSeen  time.Time `json:"lastSeen"`
+    ExpiresAt time.Time `json:"expiresAt"`
+}
+
+type Meta struct {
+    UserAgent string
+    IP        string
+}
+
+// Store keeps sessions with a sliding expiry: every successful Get pushes
+// ExpiresAt out by the store's TTL.
+type Store interface {
+    Create(ctx context.Context, userID string, meta Meta) (token string, s *Session, err error)
+    Get(ctx context.Context, token string) (*Session, error)
+    List(ctx context.Context, userID string) ([]Session, error)
+    Delete(ctx context.Context, id string) error
+    DeleteUser(ctx context.Context, userID string) error
+}
+
+// IDFor maps a cookie token to its session ID.
+func IDFor(token string) string {
+    sum := sha256.Sum256([]byte(token))
+    return hex.EncodeToString(sum[:])
+}
+
+func newToken() (string, error) {
+    b := make([]byte, 32)
+    if _, err := rand.Read(b); err != nil { return "", err }
+    return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+func newSession(userID string, meta Meta, now time.Time, ttl time.Duration) (string, *Session, error) {
+    tok, err := newToken()
+    if err != nil { return "", nil, err }
+    return tok, &Session{
+        ID:        IDFor(tok),
+        UserID:    userID,
+        UserAgent: meta.UserAgent,
+        IP:        meta.IP,
+        CreatedAt: now,
+        LastSeen:  now,
+        ExpiresAt: now.Add(ttl),
+    }, nil
+}
@@ -0,0 +1,131 @@
+package session
+
+import (
+    "context"
+    "errors"
+    "slices"
+    "sync"
+    "testing"
+    "time"
+)
+
+// clock is a settable time shared by a store and, for Redis, the server.
+type clock struct {
+    mu  sync.Mutex
+    now time.Time
+}
+
+func (c *clock) Now() time.Time {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    return c.now
+}
+
+func (c *clock) Advance(d time.Duration) {
+    c.mu.Lock()
+    defer c.mu.Unlock()
+    c.now = c.now.Add(d)
+}
+
+func newClock() *clock { return &clock{now: time.Date(2026, 3, 1, 9, 0, 0, 0, time.UTC)} }
+
+const ttl = time.Hour
+
+// testStore runs the same session lifecycle against any Store.
+func testStore(t *testing.T, s Store, clk *clock) {
+    ctx := context.Background()
+    create := func(user string) (string, *Session) {
+        t.Helper()
+        tok, sess, err := s.Create(ctx, user, Meta{UserAgent: "test", IP: "192.0.2.1"})
+        if err != nil { t.Fatal(err) }
+        if sess.ID != IDFor(tok) || sess.UserID != user || !sess.ExpiresAt.Equal(clk.Now().Add(ttl)) { t.Fatalf("created %+v", sess) }
+        return tok, sess
+    }
+    alive := func(tok string) bool {
+        t.Helper()
+        sess, err := s.Get(ctx, tok)
+        if errors.Is(err, ErrNotFound) { return false }
+        if err != nil { t.Fatal(err) }
+        if !sess.LastSeen.Equal(clk.Now()) || !sess.ExpiresAt.Equal(clk.Now().Add(ttl)) { t.Fatalf("Get did not slide the expiry: %+v at %v", sess, clk.Now()) }
+        return true
+    }
+    list := func(user string) []string {
+        t.Helper()
+        all, err := s.List(ctx, user)
+        if err != nil { t.Fatal(err) }
+        var ids []string
+        for _, sess := range all { ids = append(ids, sess.ID) }
+        return ids
+    }
+
+    a1, s1 := create("u1")
+    clk.Advance(time.Minute)
+    a2, s2 := create("u1")
+    b1, _ := create("u2")
+
+    // a1 is used within its TTL and slides; a2 and b1 never are and lapse.
+    clk.Advance(50 * time.Minute)
+    if !alive(a1) { t.Fatal("a1 expired before its TTL") }
+    if got := list("u1"); !slices.Equal(got, []string{s1.ID, s2.ID}) { t.Fatalf("u1 sessions %v, want a1 then a2 by last use", got) }
+    clk.Advance(30 * time.Minute)
+    if alive(a2) || alive(b1) { t.Fatal("unused sessions outlived their TTL") }
+    if !alive(a1) { t.Fatal("a1 expired although it was used") }
+    if got := list("u2"); len(got) != 0 { t.Fatalf("u2 sessions %v after expiry", got) }
+
+    clk.Advance(time.Minute)
+    a3, s3 := create("u1")
+    if got := list("u1"); !slices.Equal(got, []string{s3.ID, s1.ID}) { t.Fatalf("u1 sessions %v, want a3 then a1", got) }
+
+    // Deleting one session leaves the others.
+    if err := s.Delete(ctx, s3.ID); err != nil { t.Fatal(err) }
+    if err := s.Delete(ctx, "unknown"); err != nil { t.Fatalf("deleting an unknown session: %v", err) }
+    if alive(a3) { t.Fatal("deleted session still works") }
+    if got := list("u1"); !slices.Equal(got, []string{s1.ID}) { t.Fatalf("u1 sessions %v after delete, want a1", got) }
+
+    c1, _ := create("u2")
+    if err := s.DeleteUser(ctx, "u1"); err != nil { t.Fatal(err) }
+    if alive(a1) { t.Fatal("session survived DeleteUser") }
+    if got := list("u1"); len(got) != 0 { t.Fatalf("u1 sessions %v after DeleteUser", got) }
+    if !alive(c1) { t.Fatal("DeleteUser removed another user's session") }
+
+    if _, err := s.Get(ctx, ""); !errors.Is(err, ErrNotFound) { t.Fatalf("empty token: %v", err) }
+}
+
+func TestMemoryStore(t *testing.T) {
+    clk := newClock()
+    s := NewMemory(ttl)
+    s.Now = clk.Now
+    testStore(t, s, clk)
+}
+
+func TestRedisStore(t *testing.T) {
+    clk := newClock()
+    _, cli := newFakeRedis(t, clk.Now)
+    s := NewRedis(cli, ttl)
+    s.Now = clk.Now
+    testStore(t, s, clk)
+}
+
+// A Delete or DeleteUser landing between Get's read and its expiry update
+// must not bring the session or the user's index back.
+func TestRedisTouchNeverRecreates(t *testing.T) {
+    ctx := context.Background()
+    clk := newClock()
+    srv, cli := newFakeRedis(t, clk.Now)
+    s := NewRedis(cli, ttl)
+    s.Now = clk.Now
+    tok, sess, err := s.Create(ctx, "u1", Meta{})
+    if err != nil { t.Fatal(err) }
+    srv.mu.Lock()
+    srv.afterGet = func(key string) {
+        if key != sessionKey(sess.ID) { return }
+        // Both leave neither key behind.
+        srv.remove(key)
+        srv.remove(userKey("u1"))
+        srv.afterGet = nil
+    }
+    srv.mu.Unlock()
+    if _, err := s.Get(ctx, tok); !errors.Is(err, ErrNotFound) { t.Fatalf("Get = %v, want ErrNotFound", err) }
+    if got := srv.keys(); len(got) != 0 { t.Fatalf("keys %v after Get, want none", got) }
+    if _, err := s.Get(ctx, tok); !errors.Is(err, ErrNotFound) { t.Fatalf("second Get = %v", err) }
+}
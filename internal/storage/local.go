@@ -0,0 +1,251 @@
+package storage
+
+import (
+    "bytes"
+    "context"
+    "crypto/md5"
+    "crypto/rand"
+    "encoding/hex"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "path/filepath"
+    "sort"
+    "strconv"
+    "strings"
+)
+
+// LocalFSStore keeps objects as files under a directory, for development
+// without object storage. Its URLs are served by this server.
+//
+// Under Root, objects/ holds each object at its key, meta/ its content
+// type, multipart/ one directory of parts per unfinished upload and tmp/
+// files being written, which are renamed into place once complete.
+type LocalFSStore struct {
+    *signer
+    Root string
+}
+
+// NewLocal stores objects under root and signs its URLs with secret,
+// serving them under base, the public URL ObjectHandler is mounted at.
+func NewLocal(root, base, secret string) *LocalFSStore {
+    return &LocalFSStore{signer: newSigner(base, secret), Root: root}
+}
+
+func (s *LocalFSStore) EnsureBucket(ctx context.Context) error {
+    for _, dir := range []string{"objects", "meta", "multipart", "tmp"} {
+        if err := os.MkdirAll(filepath.Join(s.Root, dir), 0o755); err != nil { return err }
+    }
+    return nil
+}
+
+func (s *LocalFSStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+    return s.PutReader(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+}
+
+func (s *LocalFSStore) PutReader(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+    if !validKey(key) { return ErrInvalidKey }
+    if _, err := s.write(s.path("objects", key), r, size, nil); err != nil { return err }
+    _, err := s.write(s.path("meta", key), strings.NewReader(contentType), -1, nil)
+    return err
+}
+
+func (s *LocalFSStore) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+    if !validKey(key) { return nil, nil, ErrNotExist }
+    f, err := os.Open(s.path("objects", key))
+    if err != nil { return nil, nil, notFound(err) }
+    obj, err := s.stat(key, f)
+    if err != nil { f.Close(); return nil, nil, err }
+    return f, obj, nil
+}
+
+func (s *LocalFSStore) Stat(ctx context.Context, key string) (*Object, error) {
+    if !validKey(key) { return nil, ErrNotExist }
+    f, err := os.Open(s.path("objects", key))
+    if err != nil { return nil, notFound(err) }
+    defer f.Close()
+    return s.stat(key, f)
+}
+
+func (s *LocalFSStore) Delete(ctx context.Context, key string) error {
+    if !validKey(key) { return nil }
+    for _, area := range []string{"objects", "meta"} {
+        if err := os.Remove(s.path(area, key)); err != nil && !errors.Is(err, fs.ErrNotExist) { return err }
+        s.prune(area, key)
+    }
+    return nil
+}
+
+// List walks only the directory the prefix ends in, then sorts, since
+// directory order is not key order ("a/b" sorts after "a-c").
+func (s *LocalFSStore) List(ctx context.Context, prefix, after string, limit int) (*ObjectPage, error) {
+    if limit <= 0 { limit = 1000 }
+    base := filepath.Join(s.Root, "objects")
+    start := base
+    if i := strings.LastIndex(prefix, "/"); i >= 0 {
+        if !validKey(prefix[:i]) { return page(nil, limit, nil), nil }
+        start = s.path("objects", prefix[:i])
+    }
+    infos := map[string]*Object{}
+    var keys []string
+    err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+        if errors.Is(err, fs.ErrNotExist) { return nil }
+        if err != nil || d.IsDir() { return err }
+        rel, err := filepath.Rel(base, p)
+        if err != nil { return err }
+        key := filepath.ToSlash(rel)
+        if !strings.HasPrefix(key, prefix) || key <= after { return nil }
+        fi, err := d.Info()
+        if err != nil { return err }
+        infos[key] = &Object{Key: key, Size: fi.Size(), ContentType: s.contentType(key), LastModified: fi.ModTime().UTC()}
+        keys = append(keys, key)
+        return nil
+    })
+    if err != nil { return nil, err }
+    sort.Strings(keys)
+    return page(keys, limit, func(k string) *Object { return infos[k] }), nil
+}
+
+func (s *LocalFSStore) Copy(ctx context.Context, src, dst string) error {
+    if !validKey(dst) { return ErrInvalidKey }
+    r, obj, err := s.Get(ctx, src)
+    if err != nil { return err }
+    defer r.Close()
+    return s.PutReader(ctx, dst, r, obj.Size, obj.ContentType)
+}
+
+func (s *LocalFSStore) NewMultipart(ctx context.Context, key, contentType string) (string, error) {
+    if !validKey(key) { return "", ErrInvalidKey }
+    b := make([]byte, 16)
+    if _, err := rand.Read(b); err != nil { return "", err }
+    id := hex.EncodeToString(b)
+    _, err := s.write(filepath.Join(s.Root, "multipart", id, "upload"), strings.NewReader(key+"\n"+contentType), -1, nil)
+    return id, err
+}
+
+func (s *LocalFSStore) PutPart(ctx context.Context, key, uploadID string, part int, r io.Reader) (*Part, error) {
+    dir, _, err := s.upload(key, uploadID)
+    if err != nil { return nil, err }
+    h := md5.New()
+    n, err := s.write(filepath.Join(dir, strconv.Itoa(part)), r, -1, h)
+    if err != nil { return nil, err }
+    p := &Part{Number: part, Size: n, ETag: hex.EncodeToString(h.Sum(nil))}
+    _, err = s.write(filepath.Join(dir, strconv.Itoa(part)+".etag"), strings.NewReader(p.ETag), -1, nil)
+    return p, err
+}
+
+func (s *LocalFSStore) ListParts(ctx context.Context, key, uploadID string) ([]Part, error) {
+    dir, _, err := s.upload(key, uploadID)
+    if err != nil { return nil, err }
+    entries, err := os.ReadDir(dir)
+    if err != nil { return nil, err }
+    parts := []Part{}
+    for _, e := range entries {
+        n, err := strconv.Atoi(e.Name())
+        if err != nil { continue }
+        fi, err := e.Info()
+        if err != nil { return nil, err }
+        tag, err := os.ReadFile(filepath.Join(dir, e.Name()+".etag"))
+        if errors.Is(err, fs.ErrNotExist) { continue }
+        if err != nil { return nil, err }
+        parts = append(parts, Part{Number: n, Size: fi.Size(), ETag: string(tag)})
+    }
+    sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+    return parts, nil
+}
+
+// CompleteMultipart joins parts, which must each have been uploaded with
+// the given ETag, in the order given. The parts are opened one at a time,
+// as an upload may have thousands.
+func (s *LocalFSStore) CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error {
+    dir, contentType, err := s.upload(key, uploadID)
+    if err != nil { return err }
+    for _, p := range parts {
+        tag, err := os.ReadFile(filepath.Join(dir, strconv.Itoa(p.Number)+".etag"))
+        if err != nil || string(tag) != p.ETag { return fmt.Errorf("part %d was not uploaded", p.Number) }
+    }
+    pr, pw := io.Pipe()
+    defer pr.Close()
+    go func() {
+        for _, p := range parts {
+            f, err := os.Open(filepath.Join(dir, strconv.Itoa(p.Number)))
+            if err != nil { pw.CloseWithError(err); return }
+            _, err = io.Copy(pw, f)
+            f.Close()
+            if err != nil { pw.CloseWithError(err); return }
+        }
+        pw.Close()
+    }()
+    if err := s.PutReader(ctx, key, pr, -1, contentType); err != nil { return err }
+    return os.RemoveAll(dir)
+}
+
+func (s *LocalFSStore) AbortMultipart(ctx context.Context, key, uploadID string) error {
+    dir, _, err := s.upload(key, uploadID)
+    if errors.Is(err, ErrNoUpload) { return nil }
+    if err != nil { return err }
+    return os.RemoveAll(dir)
+}
+
+// upload returns the parts directory and content type of a multipart
+// upload of key.
+func (s *LocalFSStore) upload(key, uploadID string) (string, string, error) {
+    if !validKey(uploadID) || strings.Contains(uploadID, "/") { return "", "", ErrNoUpload }
+    dir := filepath.Join(s.Root, "multipart", uploadID)
+    b, err := os.ReadFile(filepath.Join(dir, "upload"))
+    if errors.Is(err, fs.ErrNotExist) { return "", "", ErrNoUpload }
+    if err != nil { return "", "", err }
+    k, contentType, _ := strings.Cut(string(b), "\n")
+    if k != key { return "", "", ErrNoUpload }
+    return dir, contentType, nil
+}
+
+func (s *LocalFSStore) path(area, key string) string {
+    return filepath.Join(s.Root, area, filepath.FromSlash(key))
+}
+
+// write copies r to a file in tmp/ and renames it to path, so readers never
+// see a partial file. Unless size is -1, r must hold exactly size bytes.
+// h, when set, also sees what is written.
+func (s *LocalFSStore) write(path string, r io.Reader, size int64, h io.Writer) (int64, error) {
+    if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil { return 0, err }
+    tmp := filepath.Join(s.Root, "tmp")
+    if err := os.MkdirAll(tmp, 0o755); err != nil { return 0, err }
+    f, err := os.CreateTemp(tmp, "put-*")
+    if err != nil { return 0, err }
+    defer os.Remove(f.Name())
+    var w io.Writer = f
+    if h != nil { w = io.MultiWriter(f, h) }
+    if size >= 0 { r = io.LimitReader(r, size) }
+    n, err := io.Copy(w, r)
+    if err == nil && size >= 0 && n != size { err = io.ErrUnexpectedEOF }
+    if cerr := f.Close(); err == nil { err = cerr }
+    if err != nil { return n, err }
+    return n, os.Rename(f.Name(), path)
+}
+
+func (s *LocalFSStore) stat(key string, f *os.File) (*Object, error) {
+    fi, err := f.Stat()
+    if err != nil { return nil, err }
+    if fi.IsDir() { return nil, ErrNotExist }
+    return &Object{Key: key, Size: fi.Size(), ContentType: s.contentType(key), LastModified: fi.ModTime().UTC()}, nil
+}
+
+func (s *LocalFSStore) contentType(key string) string {
+    b, _ := os.ReadFile(s.path("meta", key))
+    return string(b)
+}
+
+// prune removes the directories left empty by deleting key.
+func (s *LocalFSStore) prune(area, key string) {
+    for dir := filepath.Dir(key); dir != "."; dir = filepath.Dir(dir) {
+        if os.Remove(s.path(area, dir)) != nil { return }
+    }
+}
+
+func notFound(err error) error {
+    if errors.Is(err, fs.ErrNotExist) { return ErrNotExist }
+    return err
+}
@@ -0,0 +1,187 @@
+package storage
+
+import (
+    "bytes"
+    "context"
+    "crypto/md5"
+    "encoding/hex"
+    "fmt"
+    "io"
+    "sort"
+    "strings"
+    "sync"
+    "time"
+)
+
+// MemoryStore keeps objects in process memory, for tests and for running
+// the server without object storage. Its URLs are served by this server.
+type MemoryStore struct {
+    *signer
+    mu      sync.Mutex
+    objects map[string]*memObject
+    uploads map[string]*memUpload
+    seq     int
+}
+
+type memObject struct {
+    data        []byte
+    contentType string
+    modified    time.Time
+}
+
+type memUpload struct {
+    key         string
+    contentType string
+    parts       map[int][]byte
+}
+
+// NewMemory signs its URLs with secret and serves them under base, the
+// public URL ObjectHandler is mounted at.
+func NewMemory(base, secret string) *MemoryStore {
+    return &MemoryStore{signer: newSigner(base, secret), objects: map[string]*memObject{}, uploads: map[string]*memUpload{}}
+}
+
+func (s *MemoryStore) EnsureBucket(ctx context.Context) error { return nil }
+
+func (s *MemoryStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
+    return s.PutReader(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+}
+
+func (s *MemoryStore) PutReader(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+    if !validKey(key) { return ErrInvalidKey }
+    if size >= 0 { r = io.LimitReader(r, size) }
+    data, err := io.ReadAll(r)
+    if err != nil { return err }
+    if size >= 0 && int64(len(data)) != size { return io.ErrUnexpectedEOF }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.objects[key] = &memObject{data: data, contentType: contentType, modified: time.Now().UTC()}
+    return nil
+}
+
+func (s *MemoryStore) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    o, ok := s.objects[key]
+    if !ok { return nil, nil, ErrNotExist }
+    return readSeekCloser{bytes.NewReader(o.data)}, o.info(key), nil
+}
+
+func (s *MemoryStore) Stat(ctx context.Context, key string) (*Object, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    o, ok := s.objects[key]
+    if !ok { return nil, ErrNotExist }
+    return o.info(key), nil
+}
+
+func (s *MemoryStore) Delete(ctx context.Context, key string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.objects, key)
+    return nil
+}
+
+func (s *MemoryStore) List(ctx context.Context, prefix, after string, limit int) (*ObjectPage, error) {
+    if limit <= 0 { limit = 1000 }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    var keys []string
+    for k := range s.objects {
+        if strings.HasPrefix(k, prefix) && k > after { keys = append(keys, k) }
+    }
+    sort.Strings(keys)
+    return page(keys, limit, func(k string) *Object { return s.objects[k].info(k) }), nil
+}
+
+func (s *MemoryStore) Copy(ctx context.Context, src, dst string) error {
+    if !validKey(dst) { return ErrInvalidKey }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    o, ok := s.objects[src]
+    if !ok { return ErrNotExist }
+    s.objects[dst] = &memObject{data: o.data, contentType: o.contentType, modified: time.Now().UTC()}
+    return nil
+}
+
+func (s *MemoryStore) NewMultipart(ctx context.Context, key, contentType string) (string, error) {
+    if !validKey(key) { return "", ErrInvalidKey }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    s.seq++
+    id := fmt.Sprintf("mem-%d", s.seq)
+    s.uploads[id] = &memUpload{key: key, contentType: contentType, parts: map[int][]byte{}}
+    return id, nil
+}
+
+func (s *MemoryStore) PutPart(ctx context.Context, key, uploadID string, part int, r io.Reader) (*Part, error) {
+    data, err := io.ReadAll(r)
+    if err != nil { return nil, err }
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    u, ok := s.uploads[uploadID]
+    if !ok || u.key != key { return nil, ErrNoUpload }
+    u.parts[part] = data
+    return &Part{Number: part, Size: int64(len(data)), ETag: etag(data)}, nil
+}
+
+func (s *MemoryStore) ListParts(ctx context.Context, key, uploadID string) ([]Part, error) {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    u, ok := s.uploads[uploadID]
+    if !ok || u.key != key { return nil, ErrNoUpload }
+    parts := []Part{}
+    for n, data := range u.parts { parts = append(parts, Part{Number: n, Size: int64(len(data)), ETag: etag(data)}) }
+    sort.Slice(parts, func(i, j int) bool { return parts[i].Number < parts[j].Number })
+    return parts, nil
+}
+
+// CompleteMultipart joins parts, which must each have been uploaded with
+// the given ETag, in the order given.
+func (s *MemoryStore) CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    u, ok := s.uploads[uploadID]
+    if !ok || u.key != key { return ErrNoUpload }
+    var buf bytes.Buffer
+    for _, p := range parts {
+        data, ok := u.parts[p.Number]
+        if !ok || etag(data) != p.ETag { return fmt.Errorf("part %d was not uploaded", p.Number) }
+        buf.Write(data)
+    }
+    s.objects[key] = &memObject{data: buf.Bytes(), contentType: u.contentType, modified: time.Now().UTC()}
+    delete(s.uploads, uploadID)
+    return nil
+}
+
+func (s *MemoryStore) AbortMultipart(ctx context.Context, key, uploadID string) error {
+    s.mu.Lock()
+    defer s.mu.Unlock()
+    delete(s.uploads, uploadID)
+    return nil
+}
+
+func (o *memObject) info(key string) *Object {
+    return &Object{Key: key, Size: int64(len(o.data)), ContentType: o.contentType, LastModified: o.modified}
+}
+
+// page cuts a List page of at most limit objects from sorted keys.
+func page(keys []string, limit int, info func(string) *Object) *ObjectPage {
+    p := &ObjectPage{Objects: []Object{}}
+    if len(keys) > limit {
+        keys = keys[:limit]
+        p.Next = keys[limit-1]
+    }
+    for _, k := range keys { p.Objects = append(p.Objects, *info(k)) }
+    return p
+}
+
+func etag(data []byte) string {
+    sum := md5.Sum(data)
+    return hex.EncodeToString(sum[:])
+}
+
+// readSeekCloser lets ObjectHandler serve ranges of an in-memory object.
+type readSeekCloser struct{ *bytes.Reader }
+
+func (readSeekCloser) Close() error { return nil }
@@ -0,0 +1,104 @@
+package storage
+
+import (
+    "context"
+    "crypto/hmac"
+    "crypto/rand"
+    "crypto/sha256"
+    "encoding/base64"
+    "errors"
+    "io"
+    "io/fs"
+    "net/http"
+    "net/url"
+    "strconv"
+    "strings"
+    "time"
+)
+
+// Served is a Store without an object storage service behind it: its
+// presigned URLs point back at this server, under the base URL it was made
+// with, and handlers.ObjectHandler answers them.
+type Served interface {
+    Store
+    // Verify checks that r carries a valid, unexpired signature for a
+    // request of its method on key.
+    Verify(r *http.Request, key string) error
+    // PutPart stores one part of a multipart upload.
+    PutPart(ctx context.Context, key, uploadID string, part int, r io.Reader) (*Part, error)
+}
+
+var (
+    ErrBadSignature = errors.New("invalid or expired signature")
+    ErrInvalidKey   = errors.New("invalid object key")
+    ErrNoUpload     = errors.New("no such multipart upload")
+)
+
+// signer makes and checks the URLs of a Served store. A URL signs its
+// method, key, expiry and, for uploads, the Content-Type the PUT must send
+// and the multipart upload and part it is for.
+type signer struct {
+    base   string
+    secret []byte
+}
+
+// newSigner signs URLs under base with secret; an empty secret is replaced
+// by a random one, so URLs do not survive a restart.
+func newSigner(base, secret string) *signer {
+    key := []byte(secret)
+    if len(key) == 0 {
+        key = make([]byte, 32)
+        _, _ = rand.Read(key)
+    }
+    return &signer{base: strings.TrimRight(base, "/"), secret: key}
+}
+
+func (s *signer) url(method, key, contentType string, params url.Values, exp time.Duration) (string, error) {
+    if !validKey(key) { return "", ErrInvalidKey }
+    if params == nil { params = url.Values{} }
+    params.Set("expires", strconv.FormatInt(time.Now().Add(exp).Unix(), 10))
+    params.Set("signature", s.sign(method, key, contentType, params))
+    segs := strings.Split(key, "/")
+    for i, seg := range segs { segs[i] = url.PathEscape(seg) }
+    return s.base + "/" + strings.Join(segs, "/") + "?" + params.Encode(), nil
+}
+
+func (s *signer) sign(method, key, contentType string, q url.Values) string {
+    mac := hmac.New(sha256.New, s.secret)
+    for _, v := range []string{method, key, contentType, q.Get("expires"), q.Get("uploadId"), q.Get("partNumber")} {
+        mac.Write([]byte(v))
+        mac.Write([]byte{'\n'})
+    }
+    return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// Verify treats HEAD as GET. A PUT of a whole object must send the
+// Content-Type it was signed for.
+func (s *signer) Verify(r *http.Request, key string) error {
+    q := r.URL.Query()
+    exp, err := strconv.ParseInt(q.Get("expires"), 10, 64)
+    if err != nil || time.Now().Unix() > exp { return ErrBadSignature }
+    method, contentType := r.Method, ""
+    if method == http.MethodHead { method = http.MethodGet }
+    if method == http.MethodPut && q.Get("uploadId") == "" { contentType = r.Header.Get("Content-Type") }
+    if !hmac.Equal([]byte(q.Get("signature")), []byte(s.sign(method, key, contentType, q))) { return ErrBadSignature }
+    return nil
+}
+
+func (s *signer) Presign(ctx context.Context, key string, exp time.Duration) (string, error) {
+    return s.url(http.MethodGet, key, "", nil, exp)
+}
+
+func (s *signer) PresignPut(ctx context.Context, key, contentType string, exp time.Duration) (string, error) {
+    return s.url(http.MethodPut, key, contentType, nil, exp)
+}
+
+// PresignPart leaves the part's Content-Type unsigned; parts take the
+// upload's.
+func (s *signer) PresignPart(ctx context.Context, key, uploadID string, part int, exp time.Duration) (string, error) {
+    return s.url(http.MethodPut, key, "", url.Values{"uploadId": {uploadID}, "partNumber": {strconv.Itoa(part)}}, exp)
+}
+
+// validKey accepts slash-separated keys without empty, "." or ".."
+// elements, which keeps LocalFSStore's files under its root.
+func validKey(key string) bool { return key != "." && fs.ValidPath(key) }
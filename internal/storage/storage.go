@@ -3,7 +3,12 @@ package storage
 import (
     "bytes"
     "context"
+    "errors"
+    "fmt"
+    "io"
+    "net/http"
     "net/url"
+    "strconv"
     "time"
 
     "github.com/minio/minio-go/v7"
@@ -11,16 +16,98 @@ import (
     "real_deal/internal/config"
 )
 
+// ErrNotExist is returned for a key with no object.
+var ErrNotExist = errors.New("object does not exist")
+
 type Object struct {
-    Key  string
-    Size int64
-    URL  string
+    Key          string
+    Size         int64
+    ContentType  string
+    LastModified time.Time
+    URL          string
+}
+
+// ObjectPage is one page of a List. Next is the after argument for the
+// following page, "" on the last one.
+type ObjectPage struct {
+    Objects []Object
+    Next    string
 }
 
 type Store interface {
+    // Put stores a small object held in memory; PutReader streams one of
+    // size bytes, or of unknown size when size is -1.
     Put(ctx context.Context, key string, data []byte, contentType string) error
+    PutReader(ctx context.Context, key string, r io.Reader, size int64, contentType string) error
+    // Get streams the object at key; the caller closes it. It fails with
+    // ErrNotExist when there is none.
+    Get(ctx context.Context, key string) (io.ReadCloser, *Object, error)
+    // Delete removes the object at key; a missing object is not an error.
+    Delete(ctx context.Context, key string) error
+    // List returns up to limit objects under prefix in key order, starting
+    // after the key after.
+    List(ctx context.Context, prefix, after string, limit int) (*ObjectPage, error)
+    // Copy copies src to dst within the bucket, failing with ErrNotExist
+    // when there is no src.
+    Copy(ctx context.Context, src, dst string) error
     Presign(ctx context.Context, key string, exp time.Duration) (string, error)
+    // PresignPut returns a URL the client uploads key to directly; the
+    // upload must send contentType as its Content-Type.
+    PresignPut(ctx context.Context, key, contentType string, exp time.Duration) (string, error)
+    // Stat fails with ErrNotExist when there is no object at key.
+    Stat(ctx context.Context, key string) (*Object, error)
     EnsureBucket(ctx context.Context) error
+
+    // Multipart uploads: the client PUTs each part to a presigned URL and
+    // the server completes the upload from the parts the store has.
+    NewMultipart(ctx context.Context, key, contentType string) (string, error)
+    PresignPart(ctx context.Context, key, uploadID string, part int, exp time.Duration) (string, error)
+    ListParts(ctx context.Context, key, uploadID string) ([]Part, error)
+    CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error
+    AbortMultipart(ctx context.Context, key, uploadID string) error
+}
+
+// Part is an uploaded part of a multipart upload.
+type Part struct {
+    Number int    `json:"part"`
+    Size   int64  `json:"size"`
+    ETag   string `json:"etag"`
+}
+
+// ObjectPath is where ObjectHandler serves the URLs of a Served store,
+// under PUBLIC_API_URL.
+const ObjectPath = "/objects"
+
+// New picks a Store from STORAGE_DRIVER: "minio", "local" (files under
+// STORAGE_DIR) or "memory". The last two need no object storage service.
+func New(cfg *config.Config) (Store, error) {
+    base := cfg.PublicAPIURL + ObjectPath
+    switch cfg.StorageDriver {
+    case "", "minio":
+        return NewMinio(cfg)
+    case "local":
+        return NewLocal(cfg.StorageDir, base, cfg.StorageSecret), nil
+    case "memory":
+        return NewMemory(base, cfg.StorageSecret), nil
+    default:
+        return nil, fmt.Errorf("unknown storage driver %q", cfg.StorageDriver)
+    }
+}
+
+// DeleteAll deletes every object whose key starts with prefix and returns
+// how many it deleted.
+func DeleteAll(ctx context.Context, st Store, prefix string) (int, error) {
+    n, after := 0, ""
+    for {
+        page, err := st.List(ctx, prefix, after, 1000)
+        if err != nil { return n, err }
+        for _, o := range page.Objects {
+            if err := st.Delete(ctx, o.Key); err != nil { return n, err }
+            n++
+        }
+        if page.Next == "" { return n, nil }
+        after = page.Next
+    }
 }
 
 type MinioStore struct {
@@ -53,11 +140,48 @@ func (s *MinioStore) EnsureBucket(ctx context.Context) error {
 }
 
 func (s *MinioStore) Put(ctx context.Context, key string, data []byte, contentType string) error {
-    _, err := s.cli.PutObject(ctx, s.bucket, key,
-        bytes.NewReader(data), int64(len(data)), minio.PutObjectOptions{ContentType: contentType})
+    return s.PutReader(ctx, key, bytes.NewReader(data), int64(len(data)), contentType)
+}
+
+func (s *MinioStore) PutReader(ctx context.Context, key string, r io.Reader, size int64, contentType string) error {
+    _, err := s.cli.PutObject(ctx, s.bucket, key, r, size, minio.PutObjectOptions{ContentType: contentType})
     return err
 }
 
+func (s *MinioStore) Get(ctx context.Context, key string) (io.ReadCloser, *Object, error) {
+    obj, err := s.cli.GetObject(ctx, s.bucket, key, minio.GetObjectOptions{})
+    if err != nil { return nil, nil, notExist(err) }
+    info, err := obj.Stat()
+    if err != nil { obj.Close(); return nil, nil, notExist(err) }
+    return obj, object(info), nil
+}
+
+func (s *MinioStore) Delete(ctx context.Context, key string) error {
+    return s.cli.RemoveObject(ctx, s.bucket, key, minio.RemoveObjectOptions{})
+}
+
+func (s *MinioStore) List(ctx context.Context, prefix, after string, limit int) (*ObjectPage, error) {
+    // Stop the listing goroutine once the page is full.
+    ctx, cancel := context.WithCancel(ctx)
+    defer cancel()
+    if limit <= 0 { limit = 1000 }
+    page := &ObjectPage{Objects: []Object{}}
+    for info := range s.cli.ListObjects(ctx, s.bucket, minio.ListObjectsOptions{Prefix: prefix, StartAfter: after, Recursive: true}) {
+        if info.Err != nil { return nil, info.Err }
+        if len(page.Objects) == limit {
+            page.Next = page.Objects[limit-1].Key
+            break
+        }
+        page.Objects = append(page.Objects, *object(info))
+    }
+    return page, nil
+}
+
+func (s *MinioStore) Copy(ctx context.Context, src, dst string) error {
+    _, err := s.cli.CopyObject(ctx, minio.CopyDestOptions{Bucket: s.bucket, Object: dst}, minio.CopySrcOptions{Bucket: s.bucket, Object: src})
+    return notExist(err)
+}
+
 func (s *MinioStore) Presign(ctx context.Context, key string, exp time.Duration) (string, error) {
     reqParams := make(url.Values)
     u, err := s.cli.PresignedGetObject(ctx, s.bucket, key, exp, reqParams)
@@ -65,4 +189,66 @@ func (s *MinioStore) Presign(ctx context.Context, key string, exp time.Duration)
     return u.String(), nil
 }
 
+func (s *MinioStore) PresignPut(ctx context.Context, key, contentType string, exp time.Duration) (string, error) {
+    u, err := s.cli.PresignHeader(ctx, http.MethodPut, s.bucket, key, exp, nil, http.Header{"Content-Type": []string{contentType}})
+    if err != nil { return "", err }
+    return u.String(), nil
+}
+
+func (s *MinioStore) Stat(ctx context.Context, key string) (*Object, error) {
+    info, err := s.cli.StatObject(ctx, s.bucket, key, minio.StatObjectOptions{})
+    if err != nil { return nil, notExist(err) }
+    return object(info), nil
+}
+
+func object(info minio.ObjectInfo) *Object {
+    return &Object{Key: info.Key, Size: info.Size, ContentType: info.ContentType, LastModified: info.LastModified}
+}
+
+// notExist maps MinIO's missing-key error to ErrNotExist.
+func notExist(err error) error {
+    if minio.ToErrorResponse(err).Code == "NoSuchKey" { return ErrNotExist }
+    return err
+}
+
+func (s *MinioStore) NewMultipart(ctx context.Context, key, contentType string) (string, error) {
+    return minio.Core{Client: s.cli}.NewMultipartUpload(ctx, s.bucket, key, minio.PutObjectOptions{ContentType: contentType})
+}
+
+func (s *MinioStore) PresignPart(ctx context.Context, key, uploadID string, part int, exp time.Duration) (string, error) {
+    params := url.Values{"partNumber": {strconv.Itoa(part)}, "uploadId": {uploadID}}
+    u, err := s.cli.Presign(ctx, http.MethodPut, s.bucket, key, exp, params)
+    if err != nil { return "", err }
+    return u.String(), nil
+}
+
+func (s *MinioStore) ListParts(ctx context.Context, key, uploadID string) ([]Part, error) {
+    core := minio.Core{Client: s.cli}
+    parts := []Part{}
+    marker := 0
+    for {
+        res, err := core.ListObjectParts(ctx, s.bucket, key, uploadID, marker, 1000)
+        if err != nil { return nil, err }
+        for _, p := range res.ObjectParts {
+            parts = append(parts, Part{Number: p.PartNumber, Size: p.Size, ETag: p.ETag})
+        }
+        if !res.IsTruncated { return parts, nil }
+        marker = res.NextPartNumberMarker
+    }
+}
+
+func (s *MinioStore) CompleteMultipart(ctx context.Context, key, uploadID string, parts []Part) error {
+    done := make([]minio.CompletePart, len(parts))
+    for i, p := range parts { done[i] = minio.CompletePart{PartNumber: p.Number, ETag: p.ETag} }
+    _, err := minio.Core{Client: s.cli}.CompleteMultipartUpload(ctx, s.bucket, key, uploadID, done, minio.PutObjectOptions{})
+    return err
+}
+
+// AbortMultipart treats an upload the store no longer knows as aborted.
+func (s *MinioStore) AbortMultipart(ctx context.Context, key, uploadID string) error {
+    err := minio.Core{Client: s.cli}.AbortMultipartUpload(ctx, s.bucket, key, uploadID)
+    if minio.ToErrorResponse(err).Code == "NoSuchUpload" { return nil }
+    return err
+}
+
 func hasScheme(e string) bool { return len(e) > 7 && (e[:7] == "http://" || (len(e) > 8 && e[:8] == "https://")) }
\ No newline at end of file
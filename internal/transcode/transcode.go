@@ -0,0 +1,252 @@
+// Package transcode turns uploaded videos into HLS renditions and a poster
+// image with ffmpeg, reading the source from and writing the results to the
+// object store.
+package transcode
+
+import (
+    "bytes"
+    "context"
+    "encoding/json"
+    "errors"
+    "fmt"
+    "io"
+    "io/fs"
+    "os"
+    "os/exec"
+    "path"
+    "path/filepath"
+    "slices"
+    "strconv"
+    "strings"
+    "time"
+
+    "real_deal/internal/config"
+    "real_deal/internal/storage"
+)
+
+// Executor runs a command in dir and returns its standard output. Exec runs
+// local binaries; another Executor can run ffmpeg in a container or on a
+// worker host, or fake it.
+type Executor interface {
+    Run(ctx context.Context, dir, name string, args ...string) ([]byte, error)
+}
+
+// Exec runs commands on this host.
+type Exec struct{}
+
+// Run includes the end of the command's stderr in its error.
+func (Exec) Run(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+    cmd := exec.CommandContext(ctx, name, args...)
+    cmd.Dir = dir
+    var stderr bytes.Buffer
+    cmd.Stderr = &stderr
+    out, err := cmd.Output()
+    if err != nil {
+        msg := strings.TrimSpace(stderr.String())
+        if len(msg) > 300 { msg = "…" + msg[len(msg)-300:] }
+        return nil, fmt.Errorf("%s: %w: %s", path.Base(name), err, msg)
+    }
+    return out, nil
+}
+
+// Rendition is one HLS variant. Sources are never scaled up, so a video
+// gets the renditions no taller than itself, or the smallest one.
+type Rendition struct {
+    Name      string
+    Height    int
+    VideoKbps int
+    AudioKbps int
+}
+
+var DefaultRenditions = []Rendition{
+    {Name: "360p", Height: 360, VideoKbps: 800, AudioKbps: 96},
+    {Name: "720p", Height: 720, VideoKbps: 2800, AudioKbps: 128},
+    {Name: "1080p", Height: 1080, VideoKbps: 5000, AudioKbps: 160},
+}
+
+// segmentSeconds is the target HLS segment length.
+const segmentSeconds = 6
+
+// Transcoder works in a scratch directory under Dir ("" is the system
+// temporary directory), removed when the Source is closed.
+type Transcoder struct {
+    Store      storage.Store
+    Exec       Executor
+    FFmpeg     string
+    FFprobe    string
+    Renditions []Rendition
+    Dir        string
+}
+
+func New(st storage.Store, ex Executor, cfg *config.Config) *Transcoder {
+    return &Transcoder{Store: st, Exec: ex, FFmpeg: cfg.FFmpegPath, FFprobe: cfg.FFprobePath, Renditions: DefaultRenditions, Dir: cfg.TranscodeDir}
+}
+
+// demuxers maps the video content types uploads accept to the ffmpeg
+// demuxer for each and the signature its files start with. Uploads are
+// never left to ffmpeg's format detection: a playlist or concat script
+// posing as a video would have it read local files or fetch URLs into the
+// output.
+var demuxers = map[string]struct {
+    Format string
+    Magic  func(head []byte) bool
+}{
+    "video/mp4":       {"mp4", isoMedia},
+    "video/quicktime": {"mov", isoMedia},
+    "video/webm":      {"webm", func(h []byte) bool { return bytes.HasPrefix(h, []byte{0x1a, 0x45, 0xdf, 0xa3}) }},
+}
+
+// isoMedia recognises MP4 and QuickTime files by the type of their first box.
+func isoMedia(h []byte) bool {
+    if len(h) < 8 { return false }
+    switch string(h[4:8]) {
+    case "ftyp", "moov", "mdat", "free", "skip", "wide", "pnot":
+        return true
+    }
+    return false
+}
+
+// Source is a video fetched from the store and probed.
+type Source struct {
+    Path     string
+    Duration time.Duration
+    Height   int
+    format   string
+    dir      string
+}
+
+func (s *Source) Close() error { return os.RemoveAll(s.dir) }
+
+// input are the ffmpeg and ffprobe options that open the source: with its
+// demuxer forced, from the local file only.
+func (s *Source) input() []string {
+    return []string{"-protocol_whitelist", "file", "-f", s.format, "-i", "file:" + s.Path}
+}
+
+// Open fetches the object at key into a scratch directory, checks that it
+// is a contentType video, and probes its duration and height. It fails with
+// storage.ErrNotExist when the object is gone.
+func (t *Transcoder) Open(ctx context.Context, key, contentType string) (*Source, error) {
+    dm, ok := demuxers[contentType]
+    if !ok { return nil, fmt.Errorf("unsupported video type %q", contentType) }
+    dir, err := os.MkdirTemp(t.Dir, "transcode-*")
+    if err != nil { return nil, err }
+    // ffmpeg runs in other directories, so the paths must be absolute.
+    if dir, err = filepath.Abs(dir); err != nil { return nil, err }
+    src := &Source{Path: filepath.Join(dir, "source"), format: dm.Format, dir: dir}
+    if err := t.fetch(ctx, key, src.Path); err != nil { src.Close(); return nil, err }
+    if ok, err := hasMagic(src.Path, dm.Magic); err != nil || !ok {
+        src.Close()
+        if err == nil { err = fmt.Errorf("file is not a %s video", contentType) }
+        return nil, err
+    }
+    args := append([]string{"-v", "error"}, src.input()...)
+    args = append(args, "-select_streams", "v:0", "-show_entries", "stream=height:format=duration", "-of", "json")
+    // ffprobe takes its input without -i.
+    args = slices.DeleteFunc(args, func(a string) bool { return a == "-i" })
+    out, err := t.Exec.Run(ctx, dir, t.FFprobe, args...)
+    if err != nil { src.Close(); return nil, err }
+    var probe struct {
+        Streams []struct{ Height int `json:"height"` } `json:"streams"`
+        Format  struct{ Duration string `json:"duration"` } `json:"format"`
+    }
+    if err := json.Unmarshal(out, &probe); err != nil { src.Close(); return nil, fmt.Errorf("ffprobe output: %w", err) }
+    if len(probe.Streams) == 0 || probe.Streams[0].Height == 0 { src.Close(); return nil, errors.New("no video stream") }
+    secs, err := strconv.ParseFloat(probe.Format.Duration, 64)
+    if err != nil || secs <= 0 { src.Close(); return nil, errors.New("unknown duration") }
+    src.Height, src.Duration = probe.Streams[0].Height, time.Duration(secs*float64(time.Second))
+    return src, nil
+}
+
+func hasMagic(file string, magic func([]byte) bool) (bool, error) {
+    f, err := os.Open(file)
+    if err != nil { return false, err }
+    defer f.Close()
+    head := make([]byte, 16)
+    n, err := io.ReadFull(f, head)
+    if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) { return false, err }
+    return magic(head[:n]), nil
+}
+
+func (t *Transcoder) fetch(ctx context.Context, key, dst string) error {
+    r, _, err := t.Store.Get(ctx, key)
+    if err != nil { return err }
+    defer r.Close()
+    f, err := os.Create(dst)
+    if err != nil { return err }
+    _, err = io.Copy(f, r)
+    if cerr := f.Close(); err == nil { err = cerr }
+    return err
+}
+
+// Output is what HLS stored: the master playlist and poster keys and the
+// rendition names, each of which has a playlist at <name>/index.m3u8.
+type Output struct {
+    Playlist   string
+    Poster     string
+    Renditions []string
+}
+
+// HLS transcodes src into its renditions and a poster and stores them under
+// prefix. The master playlist refers to the renditions and they to their
+// segments by relative paths.
+func (t *Transcoder) HLS(ctx context.Context, src *Source, prefix string) (*Output, error) {
+    dir := filepath.Join(src.dir, "hls")
+    res := &Output{Playlist: prefix + "/master.m3u8", Poster: prefix + "/poster.jpg"}
+    master := "#EXTM3U\n#EXT-X-VERSION:3\n"
+    for _, r := range t.fit(src.Height) {
+        rdir := filepath.Join(dir, r.Name)
+        if err := os.MkdirAll(rdir, 0o755); err != nil { return nil, err }
+        args := append([]string{"-y", "-v", "error"}, src.input()...)
+        _, err := t.Exec.Run(ctx, rdir, t.FFmpeg, append(args,
+            "-vf", fmt.Sprintf("scale=-2:%d", r.Height),
+            "-c:v", "libx264", "-preset", "veryfast", "-profile:v", "main",
+            "-b:v", fmt.Sprintf("%dk", r.VideoKbps), "-maxrate", fmt.Sprintf("%dk", r.VideoKbps*107/100), "-bufsize", fmt.Sprintf("%dk", r.VideoKbps*2),
+            "-c:a", "aac", "-ac", "2", "-b:a", fmt.Sprintf("%dk", r.AudioKbps),
+            "-f", "hls", "-hls_time", strconv.Itoa(segmentSeconds), "-hls_playlist_type", "vod",
+            "-hls_segment_filename", "%04d.ts", "index.m3u8")...)
+        if err != nil { return nil, fmt.Errorf("%s: %w", r.Name, err) }
+        master += fmt.Sprintf("#EXT-X-STREAM-INF:BANDWIDTH=%d\n%s/index.m3u8\n", (r.VideoKbps+r.AudioKbps)*1000, r.Name)
+        res.Renditions = append(res.Renditions, r.Name)
+    }
+    if err := os.WriteFile(filepath.Join(dir, "master.m3u8"), []byte(master), 0o644); err != nil { return nil, err }
+
+    // The poster comes from a second in, or the middle of shorter videos.
+    at := min(time.Second, src.Duration/2)
+    args := append([]string{"-y", "-v", "error", "-ss", fmt.Sprintf("%.3f", at.Seconds())}, src.input()...)
+    _, err := t.Exec.Run(ctx, dir, t.FFmpeg, append(args, "-frames:v", "1", "-vf", fmt.Sprintf("scale=-2:%d", min(src.Height, 720)), "poster.jpg")...)
+    if err != nil { return nil, fmt.Errorf("poster: %w", err) }
+
+    if err := t.store(ctx, dir, prefix); err != nil { return nil, err }
+    return res, nil
+}
+
+func (t *Transcoder) fit(height int) []Rendition {
+    var out []Rendition
+    for _, r := range t.Renditions {
+        if r.Height <= height { out = append(out, r) }
+    }
+    if len(out) == 0 && len(t.Renditions) > 0 { out = t.Renditions[:1] }
+    return out
+}
+
+var contentTypes = map[string]string{
+    ".m3u8": "application/vnd.apple.mpegurl",
+    ".ts":   "video/mp2t",
+    ".jpg":  "image/jpeg",
+}
+
+// store uploads every file under dir to the same path under prefix.
+func (t *Transcoder) store(ctx context.Context, dir, prefix string) error {
+    return filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+        if err != nil || d.IsDir() { return err }
+        rel, err := filepath.Rel(dir, p)
+        if err != nil { return err }
+        f, err := os.Open(p)
+        if err != nil { return err }
+        defer f.Close()
+        fi, err := f.Stat()
+        if err != nil { return err }
+        return t.Store.PutReader(ctx, prefix+"/"+filepath.ToSlash(rel), f, fi.Size(), contentTypes[filepath.Ext(p)])
+    })
+}
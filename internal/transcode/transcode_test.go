@@ -0,0 +1,71 @@
+package transcode
+
+import (
+    "context"
+    "os"
+    "path/filepath"
+    "slices"
+    "strings"
+    "testing"
+
+    "real_deal/internal/storage"
+)
+
+// fakeExec records the commands it is asked to run, answers ffprobe with a
+// 720p ten-second video and leaves ffmpeg's last argument as an empty file.
+type fakeExec struct{ runs [][]string }
+
+func (x *fakeExec) Run(ctx context.Context, dir, name string, args ...string) ([]byte, error) {
+    x.runs = append(x.runs, append([]string{name}, args...))
+    if name == "ffprobe" { return []byte(`{"streams":[{"height":720}],"format":{"duration":"10.0"}}`), nil }
+    return nil, os.WriteFile(filepath.Join(dir, args[len(args)-1]), nil, 0o644)
+}
+
+func TestInputFormatForced(t *testing.T) {
+    st := storage.NewMemory("http://files.test", "secret")
+    ctx := context.Background()
+    files := map[string]string{
+        "mp4":      "\x00\x00\x00\x18ftypmp42rest",
+        "mov":      "\x00\x00\x00\x14ftypqt  rest",
+        "webm":     "\x1a\x45\xdf\xa3rest",
+        "playlist": "#EXTM3U\n#EXTINF:1,\nhttp://169.254.169.254/latest/meta-data\n",
+        "concat":   "ffconcat version 1.0\nfile /etc/passwd\n",
+    }
+    for k, v := range files {
+        if err := st.Put(ctx, k, []byte(v), "video/mp4"); err != nil { t.Fatal(err) }
+    }
+    cases := []struct {
+        key, contentType, format string
+    }{
+        {"mp4", "video/mp4", "mp4"},
+        {"mov", "video/quicktime", "mov"},
+        {"webm", "video/webm", "webm"},
+        {"playlist", "video/mp4", ""},
+        {"concat", "video/webm", ""},
+        {"mp4", "application/x-mpegurl", ""},
+        {"mp4", "", ""},
+    }
+    for _, tc := range cases {
+        t.Run(tc.key+" as "+tc.contentType, func(t *testing.T) {
+            ex := &fakeExec{}
+            tr := &Transcoder{Store: st, Exec: ex, FFmpeg: "ffmpeg", FFprobe: "ffprobe", Renditions: DefaultRenditions, Dir: t.TempDir()}
+            src, err := tr.Open(ctx, tc.key, tc.contentType)
+            if tc.format == "" {
+                if err == nil { src.Close(); t.Fatal("opened") }
+                if len(ex.runs) != 0 { t.Fatalf("ran %v", ex.runs) }
+                return
+            }
+            if err != nil { t.Fatal(err) }
+            defer src.Close()
+            if _, err := tr.HLS(ctx, src, "out"); err != nil { t.Fatal(err) }
+            if len(ex.runs) != 4 { t.Fatalf("ran %d commands, want probe, 360p, 720p and poster", len(ex.runs)) }
+            for _, run := range ex.runs {
+                want := []string{"-protocol_whitelist", "file", "-f", tc.format}
+                i := slices.Index(run, "-protocol_whitelist")
+                if i < 0 || !slices.Equal(run[i:i+4], want) { t.Errorf("%s: got %v, want %v", run[0], run, want) }
+                if !slices.Contains(run, "file:"+src.Path) { t.Errorf("%s: input is not file:%s in %v", run[0], src.Path, run) }
+                if slices.ContainsFunc(run, func(a string) bool { return a == src.Path || strings.Contains(a, "://") }) { t.Errorf("%s: bare input in %v", run[0], run) }
+            }
+        })
+    }
+}
@@ -1,18 +1,21 @@
 "use client"
 import { API_BASE } from '../lib/api'
-import { useState } from 'react'
+import { useEffect, useState } from 'react'
 
 export default function LoginPage(){
   const [email,setEmail] = useState('alice@example.com')
   const [loading,setLoading] = useState(false)
   const [err,setErr] = useState('')
+  const [sent,setSent] = useState(false)
+  const [providers,setProviders] = useState<string[]>([])
+  useEffect(()=>{ fetch(`${API_BASE}/api/auth/providers`).then(r=>r.ok?r.json():[]).then(setProviders).catch(()=>{}) },[])
   const submit = async (e:any)=>{
     e.preventDefault()
     setLoading(true); setErr('')
     try{
       const res = await fetch(`${API_BASE}/api/login`, { method:'POST', headers:{'Content-Type':'application/json'}, credentials:'include', body: JSON.stringify({email}) })
       if(!res.ok) throw new Error('login failed')
-      window.location.href = '/'
+      setSent(true)
     }catch(e:any){ setErr(e.message)} finally{ setLoading(false)}
   }
   return (
@@ -20,9 +23,17 @@ export default function LoginPage(){
       <div className="text-2xl font-semibold mb-4">登录</div>
       <form onSubmit={submit} className="grid gap-3">
         <input value={email} onChange={e=>setEmail(e.target.value)} placeholder="邮箱" className="px-3 py-2 rounded bg-neutral-900 border border-neutral-700" />
-        <button disabled={loading} className="px-3 py-2 rounded bg-neutral-200 text-black">{loading?'处理中...':'登录'}</button>
+        <button disabled={loading} className="px-3 py-2 rounded bg-neutral-200 text-black">{loading?'处理中...':'发送登录链接'}</button>
+        {sent && <div className="text-green-400 text-sm">登录链接已发送，请查收邮件</div>}
         {err && <div className="text-red-400 text-sm">{err}</div>}
       </form>
+      {providers.length>0 && (
+        <div className="grid gap-2 mt-4">
+          {providers.map(p=>(
+            <a key={p} href={`${API_BASE}/api/auth/${p}/start`} className="px-3 py-2 rounded border border-neutral-700 text-center">使用 {p} 登录</a>
+          ))}
+        </div>
+      )}
       <div className="mt-4 text-sm text-neutral-400">示例账号：alice@example.com（candidate）、bob@example.com（recruiter）</div>
     </div>
   )
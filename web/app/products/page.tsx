@@ -5,7 +5,7 @@ import FacetFilter from '../../components/FacetFilter'
 
 export default async function ProductsPage({ searchParams }: { searchParams?: { q?: string, type?: string } }){
   const [items, data] = await Promise.all([
-    api<any[]>('/api/products'),
+    api<{items:any[]}>('/api/products?limit=100').then(p=> p.items),
     api<{products:any[];companies:any[]}>('/api/explore')
   ])
   const q = (searchParams?.q||'').toLowerCase()
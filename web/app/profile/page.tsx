@@ -6,7 +6,7 @@ import ItemCard from '../../components/ItemCard'
 export default async function ProfilePage({ searchParams }: { searchParams?: { q?: string } }){
   const [me, projects, data] = await Promise.all([
     api<any>('/api/me').catch(()=>null),
-    api<any[]>('/api/projects'),
+    api<{items:any[]}>('/api/projects?limit=100').then(p=> p.items),
     api<{products:any[];companies:any[]}>('/api/explore')
   ])
   const header = me ? (